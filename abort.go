@@ -0,0 +1,46 @@
+package message_channel
+
+import "context"
+
+// DropReasonAborted 信道被Abort了，缓存中剩余的以及之后到来的消息都被丢弃了
+const DropReasonAborted DropReason = "aborted"
+
+// Abort 立即关闭当前信道以及所有的子孙信道，丢弃缓存中所有还没有被消费的消息，返回丢弃的消息的数量，用于来不及等待消息处理完的紧急关闭
+// 处理消息的协程不会再消费新的消息，正在被消费函数处理的消息没办法被打断，处理完之后协程退出，ContextConsumerFunc的ctx会被取消
+// 丢弃的消息都会通过OnDropped通知出来，在Abort之后才转发过来的消息也会被丢弃，但是不计入返回的数量
+// 底层的channel要等子信道和上游的泵协程都退出之后才能安全的关闭，所以关闭的过程在后台进行，可以用ReceiverWait等待关闭完成
+func (x *Channel[Message]) Abort() int {
+
+	x.beginDraining()
+	x.aborted.Store(true)
+	x.cancelConsume()
+
+	// 先让子信道停下来，这样它们就不会再往当前信道转发消息了
+	children, _ := x.childrenChannelMap.ChildrenSlice(context.Background())
+	discarded := 0
+	for _, child := range children {
+		discarded += child.Abort()
+	}
+	for _, target := range x.DistributionChildren() {
+		discarded += target.Abort()
+	}
+	discarded += x.discardPending(DropReasonAborted)
+
+	x.spawn(goroutineRoleClose, func() {
+		for _, child := range children {
+			<-child.done
+		}
+		x.upstreamWg.Wait()
+		x.closeChannel()
+
+		// 拉模式的信道可能已经没有人在拉取了，由这里取到关闭信号来结束信道
+		x.discardPending(DropReasonAborted)
+	})
+
+	return discarded
+}
+
+// IsAborted 信道是否已经被Abort了
+func (x *Channel[Message]) IsAborted() bool {
+	return x.aborted.Load()
+}
@@ -0,0 +1,50 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_Abort(t *testing.T) {
+	release := make(chan struct{})
+	consumed := &atomic.Int64{}
+	dropped := &atomic.Int64{}
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+		consumed.Add(1)
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonAborted, reason)
+		dropped.Add(1)
+	}))
+	child := root.MakeChildChannel()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, root.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return root.Stats().Depth == 3
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, 3, root.Abort())
+	assert.True(t, root.IsAborted())
+	assert.True(t, child.IsAborted())
+	assert.Equal(t, int64(3), dropped.Load())
+
+	close(release)
+	root.ReceiverWait(context.Background())
+	child.ReceiverWait(context.Background())
+	assert.Equal(t, int64(1), consumed.Load())
+}
+
+func TestChannel_AbortPullMode(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Equal(t, 3, channel.Abort())
+	channel.ReceiverWait(context.Background())
+	_, err := channel.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
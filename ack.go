@@ -0,0 +1,54 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+type offsetContextKey struct{}
+
+// MessageOffset 在消费函数中用消费函数的ctx获取当前处理的消息的偏移量，配合AckUpTo批量确认，只有配置了AutoCommitInterval时才有
+func MessageOffset(ctx context.Context) (uint64, bool) {
+	offset, ok := ctx.Value(offsetContextKey{}).(uint64)
+	return offset, ok
+}
+
+// AckUpTo 批量确认偏移量不超过offset的消息都已经处理完了，只更新内存中的确认位置，不会每次都写Store
+// 配置了AutoCommitInterval时确认的位置会被定期提交，信道关闭时会再提交一次，这样高吞吐的持久化信道不用为每条消息付出一次提交的开销
+// 确认的位置只会增大，比已经确认的位置小的确认会被忽略
+func (x *Channel[Message]) AckUpTo(offset uint64) {
+	for {
+		acked := x.offsets.acked.Load()
+		if offset <= acked || x.offsets.acked.CompareAndSwap(acked, offset) {
+			return
+		}
+	}
+}
+
+// AckedOffset 通过AckUpTo确认的最大的偏移量，可能还没有被提交
+func (x *Channel[Message]) AckedOffset() uint64 {
+	return x.offsets.acked.Load()
+}
+
+// 把确认的位置提交出去，失败时通过ErrorListener报告，下一次再重试
+func (x *Channel[Message]) commitAcked() {
+	if err := x.Commit(x.offsets.acked.Load()); err != nil {
+		x.reportError(ErrorOpCommit, err)
+	}
+}
+
+// 启动定期提交确认的位置的协程，信道关闭之后停止
+func (x *Channel[Message]) startAutoCommit(interval time.Duration) {
+	x.spawn(goroutineRoleAutoCommit, func() {
+		ticker := x.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				x.commitAcked()
+			case <-x.done:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_AckUpTo(t *testing.T) {
+	store := NewMemoryStore[int]()
+	var channel *Channel[int]
+	channel = NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithStore(store).WithAutoCommit(5 * time.Millisecond).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		offset, ok := MessageOffset(ctx)
+		assert.True(t, ok)
+
+		// 每处理三条消息确认一次
+		if offset%3 == 0 {
+			channel.AckUpTo(offset)
+		}
+		return nil
+	}))
+	for i := 1; i <= 7; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return channel.CommittedOffset() == 6
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint64(6), channel.AckedOffset())
+
+	// 比已经确认的位置小的确认被忽略，关闭时再提交一次
+	channel.AckUpTo(7)
+	channel.AckUpTo(2)
+	channel.SenderWaitAndClose()
+	committed, err := store.LoadCommitted()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), committed)
+
+	_, ok := MessageOffset(context.Background())
+	assert.False(t, ok)
+}
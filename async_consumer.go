@@ -0,0 +1,80 @@
+package message_channel
+
+import (
+	"context"
+	"sync"
+)
+
+// AsyncConsumerFunc 异步完成的消费函数，把消息交出去之后马上返回，处理完之后调用done报告结果，这样基于回调的SDK不会阻塞处理消息的协程
+// done可以在任何协程中调用，只有第一次调用有效，报告的错误和ContextConsumerFunc返回的错误一样处理，
+// 调用了done之后这条消息才算处理完，报告的错误为nil时才会被转发给父信道；ctx和ContextConsumerFunc的一样，Reply、AckUpTo、IsReplay等都可以使用
+type AsyncConsumerFunc[Message any] func(ctx context.Context, index int, message Message, done func(err error))
+
+// asyncWindow 交给异步消费函数还没有完成的消息的窗口
+type asyncWindow struct {
+	lock *sync.Mutex
+	cond *sync.Cond
+
+	// 还没有完成的消息的数量以及上限
+	outstanding int
+	limit       int
+}
+
+func newAsyncWindow(limit int) *asyncWindow {
+	if limit < 1 {
+		limit = 1
+	}
+	x := &asyncWindow{
+		lock:  &sync.Mutex{},
+		limit: limit,
+	}
+	x.cond = sync.NewCond(x.lock)
+	return x
+}
+
+// 占用窗口中的一个位置，窗口满了时等待有消息完成
+func (x *asyncWindow) acquire() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for x.outstanding >= x.limit {
+		x.cond.Wait()
+	}
+	x.outstanding++
+}
+
+func (x *asyncWindow) release() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.outstanding--
+	x.cond.Broadcast()
+}
+
+// 等待所有交出去的消息都完成
+func (x *asyncWindow) wait() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for x.outstanding > 0 {
+		x.cond.Wait()
+	}
+}
+
+// 把一条消息交给异步消费函数，窗口满了时先等待，之后的处理和其他的消费函数一样经过consume，只是在单独的协程中等待done，不会阻塞处理消息的协程
+func (x *Channel[Message]) consumeAsync(envelope envelope[Message]) {
+	x.async.acquire()
+	x.spawn(goroutineRoleConsumer, func() {
+		defer x.async.release()
+		x.consumeDetached(envelope)
+	})
+}
+
+// 调用异步消费函数并等待它调用done，done只有第一次调用有效
+func invokeAsync[Message any](ctx context.Context, consumer AsyncConsumerFunc[Message], index int, message Message) error {
+	result := make(chan error, 1)
+	once := &sync.Once{}
+	consumer(ctx, index, message, func(err error) {
+		once.Do(func() {
+			result <- err
+		})
+	})
+	return <-result
+}
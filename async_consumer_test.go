@@ -0,0 +1,98 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_AsyncConsumer(t *testing.T) {
+	lock := &sync.Mutex{}
+	pending := make(map[int]func(err error))
+	forwarded := make(chan int, 10)
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		forwarded <- message
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10).WithAsyncConsumerFunc(func(ctx context.Context, index int, message int, done func(err error)) {
+		lock.Lock()
+		defer lock.Unlock()
+		pending[message] = done
+	}, 2))
+	outstanding := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(pending)
+	}
+
+	// 处理消息的协程不会被阻塞，但是同时最多有2条消息没有完成
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return outstanding() == 2
+	}, time.Second, time.Millisecond*5)
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 2, outstanding())
+	assert.Equal(t, 2, child.Stats().InFlight)
+
+	// 失败的计入错误之后丢弃，不会转发给父信道，重复调用done没有效果
+	lock.Lock()
+	pending[1](errors.New("failed"))
+	pending[1](nil)
+	lock.Unlock()
+	assert.Eventually(t, func() bool {
+		return outstanding() == 3
+	}, time.Second, time.Millisecond*5)
+	assert.Eventually(t, func() bool {
+		return child.Stats().DroppedByReason[DropReasonConsumerError] == 1
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, uint64(1), child.Stats().ConsumerErrors)
+
+	// 关闭的时候等待所有的消息都完成
+	closed := make(chan struct{})
+	go func() {
+		child.SenderWaitAndClose()
+		close(closed)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	select {
+	case <-closed:
+		t.Fatal("closed before outstanding messages completed")
+	default:
+	}
+	lock.Lock()
+	pending[0](nil)
+	pending[2](nil)
+	lock.Unlock()
+	<-closed
+	parent.SenderWaitAndClose()
+
+	// 完成之后才转发给父信道
+	assert.ElementsMatch(t, []int{0, 2}, []int{<-forwarded, <-forwarded})
+	assert.Empty(t, forwarded)
+}
+
+func TestChannel_AsyncConsumerSwap(t *testing.T) {
+	async := make(chan int, 10)
+	swapped := make(chan int, 10)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithAsyncConsumerFunc(func(ctx context.Context, index int, message int, done func(err error)) {
+		async <- message
+		go done(nil)
+	}, 4))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Equal(t, 1, <-async)
+
+	// 异步消费的信道也可以替换消费函数
+	assert.Nil(t, channel.SwapConsumer(context.Background(), func(ctx context.Context, index int, message int) Decision {
+		swapped <- message
+		return Ack{}
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.Equal(t, 2, <-swapped)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, 0, len(async))
+	assert.Equal(t, uint64(2), channel.Stats().Consumed)
+}
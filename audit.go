@@ -0,0 +1,82 @@
+package message_channel
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord 审计记录中的一行，每行是一个JSON对象，消息本身按照Codec编码之后作为字节保存
+type AuditRecord struct {
+
+	// 消息被交给消费函数的时间
+	Time time.Time `json:"time"`
+
+	// 消费这条消息的信道
+	ChannelID   uint64 `json:"channel_id"`
+	ChannelName string `json:"channel_name,omitempty"`
+
+	// 消息在这个信道中的偏移量
+	Offset uint64 `json:"offset"`
+
+	// 按照Codec编码之后的消息
+	Data []byte `json:"data"`
+}
+
+// Auditor 把信道中被消费的每一条消息连同时间和信道ID一起写出去，用来留下流水线中流过了哪些数据的合规记录
+// 通过WithAuditor配置到信道上之后，每一条被交给消费函数（拉模式下是被Receive取走）的消息都会被记录，和消费函数怎么处理这条消息无关
+// 同一个Auditor可以配置到多个信道上，可以被并发使用
+type Auditor[Message any] struct {
+	lock    *sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+	codec   Codec[Message]
+}
+
+// NewAuditor 创建一个往writer中写审计记录的Auditor
+func NewAuditor[Message any](writer io.Writer, codec Codec[Message]) *Auditor[Message] {
+	return &Auditor[Message]{
+		lock:    &sync.Mutex{},
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		codec:   codec,
+	}
+}
+
+// Audit 写出一条审计记录
+func (x *Auditor[Message]) Audit(at time.Time, channelID uint64, channelName string, offset uint64, message Message) error {
+	data, err := x.codec.Encode(message)
+	if err != nil {
+		return err
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.encoder.Encode(AuditRecord{
+		Time:        at,
+		ChannelID:   channelID,
+		ChannelName: channelName,
+		Offset:      offset,
+		Data:        data,
+	})
+}
+
+// Close writer实现了io.Closer时关闭它
+func (x *Auditor[Message]) Close() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if closer, ok := x.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// 消息被消费时写出审计记录，写出失败时通过ErrorListener报告，不影响消息的处理
+func (x *Channel[Message]) audit(now time.Time, envelope envelope[Message]) {
+	if x.options.Auditor == nil {
+		return
+	}
+	if err := x.options.Auditor.Audit(now, x.ID, x.options.Name, envelope.offset, envelope.message); err != nil {
+		x.reportError(ErrorOpAudit, err)
+	}
+}
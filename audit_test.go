@@ -0,0 +1,67 @@
+package message_channel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_Auditor(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	auditor := NewAuditor[int](buffer, JSONCodec[int]())
+	parent := NewChannel[int](NewChannelOptions[int]().WithName("parent").WithChannelConsumerFunc(func(index int, message int) {
+	}).WithAuditor(auditor))
+
+	// 消费函数不管怎么处理这条消息都会被审计
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child").WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		if message == 2 {
+			return DeadLetter{}
+		}
+		return Ack{}
+	}).WithAuditor(auditor))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+
+	consumed := map[string][]int{}
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		var record AuditRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &record))
+		message, err := JSONCodec[int]().Decode(record.Data)
+		assert.Nil(t, err)
+		assert.False(t, record.Time.IsZero())
+		if record.ChannelName == "child" {
+			assert.Equal(t, child.ID, record.ChannelID)
+		}
+		consumed[record.ChannelName] = append(consumed[record.ChannelName], message)
+	}
+	assert.Equal(t, []int{1, 2, 3}, consumed["child"])
+	assert.Equal(t, []int{1, 3}, consumed["parent"])
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestChannel_AuditorError(t *testing.T) {
+	ops := make([]string, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1).WithAuditor(NewAuditor[int](failingWriter{}, JSONCodec[int]())).WithErrorListener(func(op string, err error) {
+		ops = append(ops, op)
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+
+	// 审计失败不影响消息的处理
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, message)
+	assert.Equal(t, []string{ErrorOpAudit}, ops)
+}
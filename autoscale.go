@@ -0,0 +1,207 @@
+package message_channel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AutoscaleOptions 自动伸缩处理消息的协程池的选项，缓存深度持续偏高时增加协程，持续偏低时减少协程
+// 开启之后多条消息会被同时消费，消费函数需要是并发安全的，消息也不再保证按顺序处理
+type AutoscaleOptions struct {
+
+	// 协程数量的下限，小于1时按照1处理，信道启动时就有这么多协程
+	Min int
+
+	// 协程数量的上限，小于Min时按照Min处理
+	Max int
+
+	// 期望的缓存深度，持续超过时扩容，持续低于一半时缩容，两个阈值之间的区域就是防止来回伸缩的缓冲带
+	TargetDepth int
+
+	// 检查缓存深度的间隔，为0时使用DefaultAutoscaleInterval
+	Interval time.Duration
+
+	// 连续多少次检查都超过或者低于阈值才伸缩，为0时使用DefaultAutoscaleSustain
+	Sustain int
+}
+
+const (
+
+	// DefaultAutoscaleInterval 没有配置Interval时检查缓存深度的间隔
+	DefaultAutoscaleInterval = 100 * time.Millisecond
+
+	// DefaultAutoscaleSustain 没有配置Sustain时需要连续超过或者低于阈值的检查次数
+	DefaultAutoscaleSustain = 3
+)
+
+// workerPool 自动伸缩的协程池中每个协程的退出开关，缩容时关掉最后启动的协程
+type workerPool struct {
+	lock    *sync.Mutex
+	workers []poolWorker
+}
+
+type poolWorker struct {
+	generation uint64
+	retire     context.CancelFunc
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{
+		lock: &sync.Mutex{},
+	}
+}
+
+// 登记一个新的协程，返回协程用来感知自己被缩容的ctx
+func (x *workerPool) add(generation uint64) context.Context {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	ctx, retire := context.WithCancel(context.Background())
+	x.workers = append(x.workers, poolWorker{generation: generation, retire: retire})
+	return ctx
+}
+
+// 当前这一代协程的数量，被看门狗替换掉的协程会自己退出，不再算在池子里
+func (x *workerPool) size(generation uint64) int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	workers := x.workers[:0]
+	for _, worker := range x.workers {
+		if worker.generation == generation {
+			workers = append(workers, worker)
+		} else {
+			worker.retire()
+		}
+	}
+	x.workers = workers
+	return len(x.workers)
+}
+
+// 让最后启动的一个协程处理完手上的消息之后退出
+func (x *workerPool) shrink() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	last := len(x.workers) - 1
+	x.workers[last].retire()
+	x.workers = x.workers[:last]
+}
+
+// Workers 当前处理消息的协程的数量，被看门狗替换掉还没有退出的协程也算在内
+func (x *Channel[Message]) Workers() int {
+	return int(x.runningWorkers.Load())
+}
+
+// 启动一个当前这一代的处理消息的协程，开启了自动伸缩时登记到协程池中
+func (x *Channel[Message]) startWorker() {
+	generation := x.workerGeneration.Load()
+	retire := context.Background()
+	if x.pool != nil {
+		retire = x.pool.add(generation)
+	}
+	x.runningWorkers.Add(1)
+	x.spawn(goroutineRoleWorker, func() {
+		x.work(generation, retire)
+	})
+}
+
+// 自动伸缩的协程，信道关闭之后退出，每次检查时都读取最新的选项，这样Reconfigure修改之后可以立即生效
+func (x *Channel[Message]) autoscale() {
+	interval := autoscaleInterval(x.tuned().AutoscaleOptions)
+	ticker := x.clock.NewTicker(interval)
+	// 修改了检查间隔之后ticker会被换掉，要停止的是最后的那一个
+	defer func() {
+		ticker.Stop()
+	}()
+
+	// 连续超过或者低于阈值的次数，深度回到缓冲带中就重新计数
+	above, below := 0, 0
+	for {
+		retuned := false
+		select {
+		case <-x.done:
+			return
+		case <-ticker.C():
+		case <-x.retune:
+			retuned = true
+		}
+
+		options := x.tuned().AutoscaleOptions
+		if next := autoscaleInterval(options); next != interval {
+			ticker.Stop()
+			interval = next
+			ticker = x.clock.NewTicker(interval)
+		}
+		minWorkers, maxWorkers := autoscaleBounds(options)
+		sustain := options.Sustain
+		if sustain <= 0 {
+			sustain = DefaultAutoscaleSustain
+		}
+
+		// 修改了选项之后马上把协程的数量调整到新的范围内
+		if retuned {
+			x.resizePool(minWorkers, maxWorkers)
+			above, below = 0, 0
+			continue
+		}
+		if x.State() != StateRunning {
+			above, below = 0, 0
+			continue
+		}
+
+		depth := x.buffer.len()
+		switch {
+		case depth > options.TargetDepth:
+			above, below = above+1, 0
+		case depth < options.TargetDepth/2 || depth == 0:
+			above, below = 0, below+1
+		default:
+			above, below = 0, 0
+		}
+
+		size := x.pool.size(x.workerGeneration.Load())
+		if size < minWorkers || size > maxWorkers {
+			// 看门狗替换协程之后池子里只剩下了新启动的那一个，先补到下限
+			x.resizePool(minWorkers, maxWorkers)
+			continue
+		}
+		if above >= sustain && size < maxWorkers {
+			x.startWorker()
+			above = 0
+		}
+		if below >= sustain && size > minWorkers {
+			x.pool.shrink()
+			below = 0
+		}
+	}
+}
+
+// 把协程池中当前这一代协程的数量调整到[minWorkers, maxWorkers]之间
+func (x *Channel[Message]) resizePool(minWorkers, maxWorkers int) {
+	size := x.pool.size(x.workerGeneration.Load())
+	for ; size < minWorkers; size++ {
+		x.startWorker()
+	}
+	for ; size > maxWorkers; size-- {
+		x.pool.shrink()
+	}
+}
+
+// 检查缓存深度的间隔
+func autoscaleInterval(options *AutoscaleOptions) time.Duration {
+	if options.Interval <= 0 {
+		return DefaultAutoscaleInterval
+	}
+	return options.Interval
+}
+
+func autoscaleBounds(options *AutoscaleOptions) (int, int) {
+	minWorkers := options.Min
+	if minWorkers < 1 {
+		minWorkers = 1
+	}
+	maxWorkers := options.Max
+	if maxWorkers < minWorkers {
+		maxWorkers = minWorkers
+	}
+	return minWorkers, maxWorkers
+}
@@ -0,0 +1,52 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_Autoscale(t *testing.T) {
+	release := make(chan struct{})
+	concurrent, peak := &atomic.Int64{}, &atomic.Int64{}
+	options := NewChannelOptions[int]().WithChannelBuffSize(100).WithAutoscale(1, 4, 2).WithChannelConsumerFunc(func(index int, message int) {
+		n := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		<-release
+	})
+	options.AutoscaleOptions.Interval = 5 * time.Millisecond
+	options.AutoscaleOptions.Sustain = 2
+	channel := NewChannel[int](options)
+	assert.Equal(t, 1, channel.Workers())
+
+	// 消费函数都卡住了，缓存持续积压，协程会一直扩容到上限
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return channel.Workers() == 4
+	}, time.Second, time.Millisecond)
+
+	// 最后扩容出来的协程可能还没有取到消息
+	assert.Eventually(t, func() bool {
+		return peak.Load() == 4
+	}, time.Second, time.Millisecond)
+
+	// 积压处理完之后缩容回下限
+	close(release)
+	assert.Eventually(t, func() bool {
+		return channel.Workers() == 1
+	}, time.Second, time.Millisecond)
+
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(20), channel.Stats().Consumed)
+	assert.Equal(t, 0, channel.Workers())
+}
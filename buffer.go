@@ -0,0 +1,589 @@
+package message_channel
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+)
+
+// messageBuffer 信道存放还没有被消费的消息的缓存，信道中存放的是包装了消息的信封envelope
+// 默认使用go的channel实现，需要对缓存中的消息做额外的处理（比如排序）时使用加锁的队列实现
+type messageBuffer[Message any] interface {
+
+	// 放入一条消息，缓存满时阻塞直到有空位或者ctx被取消
+	put(ctx context.Context, message Message) error
+
+	// 往紧急通道中放入一条消息，紧急通道中的消息总是先于普通的消息被取出，紧急通道满时阻塞直到有空位或者ctx被取消
+	putUrgent(ctx context.Context, message Message) error
+
+	// 尝试放入一条消息，缓存满或者已经被关闭时不阻塞直接返回false
+	tryPut(message Message) bool
+
+	// 取出一条消息，缓存为空时阻塞直到有消息或者ctx被取消，缓存已经被关闭并且取完时ok为false
+	take(ctx context.Context) (message Message, ok bool, err error)
+
+	// 尝试取出一条消息，缓存为空时不阻塞，ok表示是否取到了消息，closed表示缓存是否已经被关闭并且取完了
+	tryTake() (message Message, ok bool, closed bool)
+
+	// 缓存中的消息的数量，包括紧急通道中的消息
+	len() int
+
+	// 缓存的容量，紧急通道只是用来放少量的控制类消息的，不算在容量中
+	cap() int
+
+	// 关闭缓存，表示不会再有新的消息了，剩余的消息还可以继续取出
+	// 关闭之后以及正在阻塞等待放入的put都会返回ErrChannelClosed，不会panic
+	close()
+
+	// 缓存是否已经被关闭了
+	isClosed() bool
+
+	// 按照取出的顺序复制最多n条缓存中的消息，不会取出它们，go的channel没办法查看其中的消息，ok为false
+	peek(n int) (messages []Message, ok bool)
+}
+
+// DefaultUrgentBuffSize 没有配置UrgentBuffSize时紧急通道的缓存大小
+const DefaultUrgentBuffSize = 8
+
+// 创建信道使用的缓存
+func newMessageBuffer[Message any](options *ChannelOptions[Message]) messageBuffer[envelope[Message]] {
+	urgentBuffSize := int(options.UrgentBuffSize)
+	if urgentBuffSize == 0 {
+		urgentBuffSize = DefaultUrgentBuffSize
+	}
+	if options.Ordering != nil {
+		less := options.Ordering
+		return newQueueBuffer[envelope[Message]](int(options.ChannelBuffSize), urgentBuffSize, &heapQueue[envelope[Message]]{less: func(a, b envelope[Message]) bool {
+			return less(a.message, b.message)
+		}})
+	}
+	if options.QueueBuffer {
+		return newQueueBuffer[envelope[Message]](int(options.ChannelBuffSize), urgentBuffSize, &fifoQueue[envelope[Message]]{})
+	}
+	return newChanBuffer[envelope[Message]](int(options.ChannelBuffSize), urgentBuffSize)
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// chanBuffer 基于go的channel实现的缓存，缓存大小为0时发送方会等到消息被取走才返回
+// 往已经关闭的go channel中发送会panic，所以发送方在发送期间持有读锁，关闭时先通知正在阻塞的发送方放弃，等它们都退出之后再关闭channel
+type chanBuffer[Message any] struct {
+	channel chan Message
+	urgent  chan Message
+
+	// 发送方持有读锁，关闭的一方持有写锁，closed只在持有写锁时修改
+	guard  *sync.RWMutex
+	closed bool
+
+	// 开始关闭时被关闭，唤醒正在阻塞等待放入的发送方
+	closing     chan struct{}
+	closingOnce *sync.Once
+}
+
+func newChanBuffer[Message any](capacity int, urgentCapacity int) *chanBuffer[Message] {
+	return &chanBuffer[Message]{
+		channel:     make(chan Message, capacity),
+		urgent:      make(chan Message, urgentCapacity),
+		guard:       &sync.RWMutex{},
+		closing:     make(chan struct{}),
+		closingOnce: &sync.Once{},
+	}
+}
+
+func (x *chanBuffer[Message]) put(ctx context.Context, message Message) error {
+	return x.send(ctx, x.channel, message)
+}
+
+func (x *chanBuffer[Message]) putUrgent(ctx context.Context, message Message) error {
+	return x.send(ctx, x.urgent, message)
+}
+
+func (x *chanBuffer[Message]) send(ctx context.Context, channel chan Message, message Message) error {
+	x.guard.RLock()
+	defer x.guard.RUnlock()
+	if x.closed {
+		return ErrChannelClosed
+	}
+	select {
+	case channel <- message:
+		return nil
+	case <-x.closing:
+		return ErrChannelClosed
+	case <-ctx.Done():
+		return context.Canceled
+	}
+}
+
+func (x *chanBuffer[Message]) tryPut(message Message) bool {
+	x.guard.RLock()
+	defer x.guard.RUnlock()
+	if x.closed {
+		return false
+	}
+	select {
+	case x.channel <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (x *chanBuffer[Message]) take(ctx context.Context) (Message, bool, error) {
+	var zero Message
+
+	// 先看一下紧急通道，有消息的话优先取出
+	urgent, channel := x.urgent, x.channel
+	select {
+	case message, ok := <-urgent:
+		if ok {
+			return message, true, nil
+		}
+		urgent = nil
+	default:
+	}
+
+	// 两个channel都关闭并且取完了才算结束，取完的channel置为nil之后select就不会再选中它了
+	for urgent != nil || channel != nil {
+		select {
+		case message, ok := <-urgent:
+			if ok {
+				return message, true, nil
+			}
+			urgent = nil
+		case message, ok := <-channel:
+			if ok {
+				return message, true, nil
+			}
+			channel = nil
+		case <-ctx.Done():
+			return zero, false, ctx.Err()
+		}
+	}
+	return zero, false, nil
+}
+
+func (x *chanBuffer[Message]) tryTake() (Message, bool, bool) {
+	urgentClosed := false
+	select {
+	case message, ok := <-x.urgent:
+		if ok {
+			return message, true, false
+		}
+		urgentClosed = true
+	default:
+	}
+	select {
+	case message, ok := <-x.channel:
+		if ok {
+			return message, true, false
+		}
+		return message, false, urgentClosed
+	default:
+		var zero Message
+		return zero, false, false
+	}
+}
+
+func (x *chanBuffer[Message]) len() int {
+	return len(x.channel) + len(x.urgent)
+}
+
+func (x *chanBuffer[Message]) cap() int {
+	return cap(x.channel)
+}
+
+func (x *chanBuffer[Message]) close() {
+	x.closingOnce.Do(func() {
+		close(x.closing)
+	})
+	x.guard.Lock()
+	defer x.guard.Unlock()
+	if x.closed {
+		return
+	}
+	x.closed = true
+	close(x.channel)
+	close(x.urgent)
+}
+
+func (x *chanBuffer[Message]) isClosed() bool {
+	x.guard.RLock()
+	defer x.guard.RUnlock()
+	return x.closed
+}
+
+func (x *chanBuffer[Message]) peek(n int) ([]Message, bool) {
+	return nil, false
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// messageQueue 加锁的缓存内部使用的队列，决定消息被取出的顺序，不需要是并发安全的
+type messageQueue[Message any] interface {
+	push(message Message)
+	pop() Message
+	size() int
+
+	// 按照取出的顺序返回最前面的最多n条消息，不会修改队列
+	peek(n int) []Message
+}
+
+// queueBuffer 基于加锁的队列实现的缓存，缓存大小为0时按照1处理
+type queueBuffer[Message any] struct {
+	lock     *sync.Mutex
+	queue    messageQueue[Message]
+	capacity int
+	closed   bool
+
+	// 紧急通道，按照放入的顺序取出
+	urgent         []Message
+	urgentCapacity int
+
+	// 有新的消息放入或者缓存被关闭时关闭这个channel并换一个新的，用来唤醒所有等待取消息的一方
+	notEmpty chan struct{}
+
+	// 有消息被取出时关闭这个channel并换一个新的，用来唤醒所有等待放消息的一方
+	notFull chan struct{}
+}
+
+func newQueueBuffer[Message any](capacity int, urgentCapacity int, queue messageQueue[Message]) *queueBuffer[Message] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if urgentCapacity < 1 {
+		urgentCapacity = 1
+	}
+	return &queueBuffer[Message]{
+		lock:           &sync.Mutex{},
+		queue:          queue,
+		capacity:       capacity,
+		urgentCapacity: urgentCapacity,
+		notEmpty:       make(chan struct{}),
+		notFull:        make(chan struct{}),
+	}
+}
+
+func (x *queueBuffer[Message]) put(ctx context.Context, message Message) error {
+	return x.putLane(ctx, message, false)
+}
+
+func (x *queueBuffer[Message]) putUrgent(ctx context.Context, message Message) error {
+	return x.putLane(ctx, message, true)
+}
+
+func (x *queueBuffer[Message]) putLane(ctx context.Context, message Message, urgent bool) error {
+	for {
+		x.lock.Lock()
+		if x.closed {
+			x.lock.Unlock()
+			return ErrChannelClosed
+		}
+		if urgent && len(x.urgent) < x.urgentCapacity {
+			x.urgent = append(x.urgent, message)
+			x.signalNotEmptyLocked()
+			x.lock.Unlock()
+			return nil
+		}
+		if !urgent && x.queue.size() < x.capacity {
+			x.pushLocked(message)
+			x.lock.Unlock()
+			return nil
+		}
+		notFull := x.notFull
+		x.lock.Unlock()
+
+		select {
+		case <-notFull:
+		case <-ctx.Done():
+			return context.Canceled
+		}
+	}
+}
+
+func (x *queueBuffer[Message]) tryPut(message Message) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed || x.queue.size() >= x.capacity {
+		return false
+	}
+	x.pushLocked(message)
+	return true
+}
+
+func (x *queueBuffer[Message]) take(ctx context.Context) (Message, bool, error) {
+	for {
+		message, ok, closed, notEmpty := x.tryTakeOrWait()
+		if ok || closed {
+			return message, ok, nil
+		}
+		select {
+		case <-notEmpty:
+		case <-ctx.Done():
+			var zero Message
+			return zero, false, ctx.Err()
+		}
+	}
+}
+
+func (x *queueBuffer[Message]) tryTake() (Message, bool, bool) {
+	message, ok, closed, _ := x.tryTakeOrWait()
+	return message, ok, closed
+}
+
+// 尝试取出一条消息，没有取到时返回用来等待下一条消息的channel
+func (x *queueBuffer[Message]) tryTakeOrWait() (message Message, ok bool, closed bool, notEmpty chan struct{}) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if len(x.urgent) > 0 {
+		message = x.urgent[0]
+		var zero Message
+		x.urgent[0] = zero
+		x.urgent = x.urgent[1:]
+		x.signalNotFullLocked()
+		return message, true, false, nil
+	}
+	if x.queue.size() > 0 {
+		message = x.queue.pop()
+		x.signalNotFullLocked()
+		return message, true, false, nil
+	}
+	return message, false, x.closed, x.notEmpty
+}
+
+func (x *queueBuffer[Message]) pushLocked(message Message) {
+	x.queue.push(message)
+	x.signalNotEmptyLocked()
+}
+
+func (x *queueBuffer[Message]) signalNotEmptyLocked() {
+	close(x.notEmpty)
+	x.notEmpty = make(chan struct{})
+}
+
+func (x *queueBuffer[Message]) signalNotFullLocked() {
+	close(x.notFull)
+	x.notFull = make(chan struct{})
+}
+
+func (x *queueBuffer[Message]) len() int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.queue.size() + len(x.urgent)
+}
+
+func (x *queueBuffer[Message]) cap() int {
+	return x.capacity
+}
+
+func (x *queueBuffer[Message]) close() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return
+	}
+	x.closed = true
+	x.signalNotEmptyLocked()
+
+	// 唤醒正在等待放入的一方，让它们返回ErrChannelClosed
+	x.signalNotFullLocked()
+}
+
+func (x *queueBuffer[Message]) isClosed() bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.closed
+}
+
+func (x *queueBuffer[Message]) peek(n int) ([]Message, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	messages := make([]Message, 0)
+	for _, message := range x.urgent {
+		if len(messages) >= n {
+			return messages, true
+		}
+		messages = append(messages, message)
+	}
+	return append(messages, x.queue.peek(n-len(messages))...), true
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// fifoQueue 按照放入的顺序取出的队列
+type fifoQueue[Message any] struct {
+	items []Message
+}
+
+func (x *fifoQueue[Message]) push(message Message) {
+	x.items = append(x.items, message)
+}
+
+func (x *fifoQueue[Message]) pop() Message {
+	message := x.items[0]
+	var zero Message
+	x.items[0] = zero
+	x.items = x.items[1:]
+	return message
+}
+
+func (x *fifoQueue[Message]) size() int {
+	return len(x.items)
+}
+
+func (x *fifoQueue[Message]) peek(n int) []Message {
+	if n > len(x.items) {
+		n = len(x.items)
+	}
+	return append([]Message(nil), x.items[:n]...)
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// heapQueue 按照比较函数排序的队列，每次取出的都是最小的消息，相等的消息按照放入的顺序取出
+type heapQueue[Message any] struct {
+	less  LessFunc[Message]
+	items []heapQueueItem[Message]
+	seq   uint64
+}
+
+type heapQueueItem[Message any] struct {
+	message Message
+	seq     uint64
+}
+
+func (x *heapQueue[Message]) push(message Message) {
+	x.seq++
+	heap.Push((*heapQueueItems[Message])(x), heapQueueItem[Message]{message: message, seq: x.seq})
+}
+
+func (x *heapQueue[Message]) pop() Message {
+	return heap.Pop((*heapQueueItems[Message])(x)).(heapQueueItem[Message]).message
+}
+
+func (x *heapQueue[Message]) size() int {
+	return len(x.items)
+}
+
+func (x *heapQueue[Message]) peek(n int) []Message {
+	items := &heapQueueItems[Message]{less: x.less, items: append([]heapQueueItem[Message](nil), x.items...)}
+	sort.Sort(items)
+	if n > len(items.items) {
+		n = len(items.items)
+	}
+	messages := make([]Message, n)
+	for i := range messages {
+		messages[i] = items.items[i].message
+	}
+	return messages
+}
+
+// heapQueueItems 实现heap.Interface，单独定义一个类型是为了不把这些方法暴露在heapQueue上
+type heapQueueItems[Message any] heapQueue[Message]
+
+func (x *heapQueueItems[Message]) Len() int {
+	return len(x.items)
+}
+
+func (x *heapQueueItems[Message]) Less(i, j int) bool {
+	if x.less(x.items[i].message, x.items[j].message) {
+		return true
+	}
+	if x.less(x.items[j].message, x.items[i].message) {
+		return false
+	}
+	return x.items[i].seq < x.items[j].seq
+}
+
+func (x *heapQueueItems[Message]) Swap(i, j int) {
+	x.items[i], x.items[j] = x.items[j], x.items[i]
+}
+
+func (x *heapQueueItems[Message]) Push(item any) {
+	x.items = append(x.items, item.(heapQueueItem[Message]))
+}
+
+func (x *heapQueueItems[Message]) Pop() any {
+	last := len(x.items) - 1
+	item := x.items[last]
+	var zero heapQueueItem[Message]
+	x.items[last] = zero
+	x.items = x.items[:last]
+	return item
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// headBuffer 拉模式的信道使用的缓存，在内部的缓存前面可以放回一条已经取出来的消息，ReceiveIf没有取走的消息就放在这里，
+// 这样它仍然算在缓存里：len、peek以及取出剩余的消息的一方都能看到它，并且总是最先被取出
+type headBuffer[Message any] struct {
+	messageBuffer[Message]
+
+	lock *sync.Mutex
+	head *Message
+}
+
+func newHeadBuffer[Message any](buffer messageBuffer[Message]) *headBuffer[Message] {
+	return &headBuffer[Message]{
+		messageBuffer: buffer,
+		lock:          &sync.Mutex{},
+	}
+}
+
+// 把一条取出来的消息放回最前面，最多只能放回一条
+func (x *headBuffer[Message]) hold(message Message) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.head = &message
+}
+
+// 取出放回的那条消息，没有时ok为false
+func (x *headBuffer[Message]) takeHead() (Message, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.head == nil {
+		var zero Message
+		return zero, false
+	}
+	message := *x.head
+	x.head = nil
+	return message, true
+}
+
+func (x *headBuffer[Message]) take(ctx context.Context) (Message, bool, error) {
+	if message, ok := x.takeHead(); ok {
+		return message, true, nil
+	}
+	return x.messageBuffer.take(ctx)
+}
+
+func (x *headBuffer[Message]) tryTake() (Message, bool, bool) {
+	if message, ok := x.takeHead(); ok {
+		return message, true, false
+	}
+	return x.messageBuffer.tryTake()
+}
+
+func (x *headBuffer[Message]) len() int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	n := x.messageBuffer.len()
+	if x.head != nil {
+		n++
+	}
+	return n
+}
+
+func (x *headBuffer[Message]) peek(n int) ([]Message, bool) {
+	x.lock.Lock()
+	head := x.head
+	x.lock.Unlock()
+	if head == nil || n <= 0 {
+		return x.messageBuffer.peek(n)
+	}
+	rest, ok := x.messageBuffer.peek(n - 1)
+	if !ok {
+		return nil, false
+	}
+	return append([]Message{*head}, rest...), true
+}
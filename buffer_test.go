@@ -0,0 +1,137 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_WithOrdering(t *testing.T) {
+	consumed := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithOrdering(func(a, b int) bool {
+		return a < b
+	}).WithChannelConsumerFunc(func(index int, message int) {
+		consumed = append(consumed, message)
+	}))
+
+	// 先暂停让消息都堆积在缓存中，恢复之后按照顺序消费
+	assert.Nil(t, channel.Pause())
+	for _, message := range []int{5, 3, 4, 1, 2} {
+		assert.Nil(t, channel.Send(context.Background(), message))
+	}
+	assert.Equal(t, 5, channel.Stats().Depth)
+	assert.Nil(t, channel.Resume())
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, consumed)
+}
+
+func TestQueueBuffer(t *testing.T) {
+	type event struct {
+		timestamp int
+		name      string
+	}
+	buffer := newQueueBuffer[event](2, 1, &heapQueue[event]{less: func(a, b event) bool {
+		return a.timestamp < b.timestamp
+	}})
+	assert.Nil(t, buffer.put(context.Background(), event{timestamp: 2, name: "a"}))
+	assert.Nil(t, buffer.put(context.Background(), event{timestamp: 2, name: "b"}))
+	assert.False(t, buffer.tryPut(event{timestamp: 1, name: "c"}))
+
+	// 缓存满了之后阻塞到ctx被取消
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancelFunc()
+	assert.ErrorIs(t, buffer.put(ctx, event{timestamp: 1, name: "c"}), context.Canceled)
+
+	// 时间戳相同的按照放入的顺序取出
+	message, ok, err := buffer.take(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", message.name)
+	assert.Nil(t, buffer.put(context.Background(), event{timestamp: 1, name: "c"}))
+	message, _, _ = buffer.take(context.Background())
+	assert.Equal(t, "c", message.name)
+
+	buffer.close()
+	assert.ErrorIs(t, buffer.put(context.Background(), event{}), ErrChannelClosed)
+	message, ok, closed := buffer.tryTake()
+	assert.True(t, ok)
+	assert.False(t, closed)
+	assert.Equal(t, "b", message.name)
+	_, ok, err = buffer.take(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestChannel_SendUrgent(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		options := NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithUrgentBuffSize(2)
+		if ordered {
+			options.WithOrdering(func(a, b int) bool {
+				return a < b
+			})
+		}
+		channel := NewChannel[int](options)
+		for i := 1; i <= 3; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		assert.Nil(t, channel.SendUrgent(context.Background(), 100))
+		assert.Nil(t, channel.SendUrgent(context.Background(), 200))
+		assert.Equal(t, 5, channel.Stats().Depth)
+		assert.Equal(t, 10, channel.Stats().Capacity)
+
+		// 紧急通道满了之后阻塞
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*10)
+		assert.NotNil(t, channel.SendUrgent(ctx, 300))
+		cancelFunc()
+
+		received := make([]int, 0)
+		go channel.SenderWaitAndClose()
+		for {
+			message, err := channel.Receive(context.Background())
+			if err != nil {
+				assert.ErrorIs(t, err, ErrChannelClosed)
+				break
+			}
+			received = append(received, message)
+		}
+		assert.Equal(t, []int{100, 200, 1, 2, 3}, received)
+	}
+}
+
+func TestBuffer_SendAfterClose(t *testing.T) {
+	buffers := []messageBuffer[int]{
+		newChanBuffer[int](1, 1),
+		newQueueBuffer[int](1, 1, &heapQueue[int]{less: func(a, b int) bool {
+			return a < b
+		}}),
+		newQueueBuffer[int](1, 1, &fifoQueue[int]{}),
+	}
+	for _, buffer := range buffers {
+		assert.Nil(t, buffer.put(context.Background(), 1))
+
+		// 缓存满了阻塞着的发送方在关闭时被唤醒，返回ErrChannelClosed而不是panic
+		blocked := make(chan error)
+		go func() {
+			blocked <- buffer.put(context.Background(), 2)
+		}()
+		time.Sleep(time.Millisecond * 10)
+		buffer.close()
+		assert.ErrorIs(t, <-blocked, ErrChannelClosed)
+
+		assert.True(t, buffer.isClosed())
+		assert.ErrorIs(t, buffer.put(context.Background(), 3), ErrChannelClosed)
+		assert.ErrorIs(t, buffer.putUrgent(context.Background(), 3), ErrChannelClosed)
+		assert.False(t, buffer.tryPut(3))
+		buffer.close()
+
+		// 关闭之前放入的消息还可以取出
+		message, ok, err := buffer.take(context.Background())
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, message)
+		_, ok, err = buffer.take(context.Background())
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	}
+}
@@ -0,0 +1,33 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// Sender 可以往里面发送消息的一方，依赖信道发送消息的代码只依赖这个接口的话，单元测试中就可以换成Mock或者简单的假实现
+type Sender[Message any] interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Receiver 可以从里面拉取消息的一方，拉取到关闭时返回ErrChannelClosed
+type Receiver[Message any] interface {
+	Receive(ctx context.Context) (Message, error)
+}
+
+// Channeler 信道对使用方暴露的主要操作，*Channel实现了这个接口
+// 应用代码依赖Channeler而不是*Channel的话，就可以不启动真正的信道拓扑来做单元测试
+type Channeler[Message any] interface {
+	Sender[Message]
+	Receiver[Message]
+
+	SendWithDeadline(ctx context.Context, message Message, deadline time.Time) error
+	SendUrgent(ctx context.Context, message Message) error
+	SenderWaitAndClose(f ...MapRunFunc[Message])
+	ReceiverWait(ctx context.Context)
+	State() State
+	IsClosed() bool
+	Stats() ChannelStats
+}
+
+var _ Channeler[any] = (*Channel[any])(nil)
@@ -0,0 +1,55 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// 记录发送过来的消息的假Sender
+type recordingSender[Message any] struct {
+	messages []Message
+}
+
+func (x *recordingSender[Message]) Send(ctx context.Context, message Message) error {
+	x.messages = append(x.messages, message)
+	return nil
+}
+
+// 从切片中依次返回消息的假Receiver
+type sliceReceiver[Message any] struct {
+	messages []Message
+}
+
+func (x *sliceReceiver[Message]) Receive(ctx context.Context) (Message, error) {
+	if len(x.messages) == 0 {
+		var zero Message
+		return zero, ErrChannelClosed
+	}
+	message := x.messages[0]
+	x.messages = x.messages[1:]
+	return message, nil
+}
+
+func TestSender_DeadLetter(t *testing.T) {
+	deadLetters := &recordingSender[int]{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithSynchronousMode().WithDeadLetterChannel(deadLetters).WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		if message%2 == 0 {
+			return DeadLetter{}
+		}
+		return Ack{}
+	}))
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{2, 4}, deadLetters.messages)
+}
+
+func TestReceiver_Reduce(t *testing.T) {
+	sum, err := Reduce[int, int](context.Background(), &sliceReceiver[int]{messages: []int{1, 2, 3}}, 0, func(acc int, message int) int {
+		return acc + message
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, sum)
+}
@@ -0,0 +1,176 @@
+// Package channeltest 测试信道时使用的工具
+package channeltest
+
+import (
+	"github.com/golang-infrastructure/go-message-channel"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock 手动控制的时钟，只有调用Advance时时间才会前进，前进时到期的定时器会按照到期的先后顺序触发
+// 通过WithClock交给信道之后，重试的退避、批的最长等待、TTL、窗口、看门狗之类的等待都不需要真的等待了
+type FakeClock struct {
+	lock *sync.Mutex
+
+	// 有新的定时器时通知BlockUntil
+	cond *sync.Cond
+
+	now time.Time
+
+	// 还没有触发或者被停止的定时器
+	waiters []*fakeWaiter
+}
+
+// 一个等待中的定时器，period大于0时是周期定时器
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+var _ message_channel.Clock = (*FakeClock)(nil)
+
+// NewFakeClock 创建一个从start开始的时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	x := &FakeClock{
+		lock: &sync.Mutex{},
+		now:  start,
+	}
+	x.cond = sync.NewCond(x.lock)
+	return x
+}
+
+func (x *FakeClock) Now() time.Time {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.now
+}
+
+func (x *FakeClock) Since(t time.Time) time.Duration {
+	return x.Now().Sub(t)
+}
+
+// Sleep 阻塞到时间被Advance到d之后
+func (x *FakeClock) Sleep(d time.Duration) {
+	<-x.NewTimer(d).C()
+}
+
+func (x *FakeClock) NewTimer(d time.Duration) message_channel.Timer {
+	waiter := x.add(d, 0)
+	return &fakeTimer{clock: x, waiter: waiter}
+}
+
+func (x *FakeClock) NewTicker(d time.Duration) message_channel.Ticker {
+	if d <= 0 {
+		panic("channeltest: non-positive interval for NewTicker")
+	}
+	waiter := x.add(d, d)
+	return &fakeTicker{clock: x, waiter: waiter}
+}
+
+// Advance 让时间前进d，期间到期的定时器都会触发，周期定时器在这段时间内到期多次时和time.Ticker一样接收方来不及接收的触发会被丢掉
+func (x *FakeClock) Advance(d time.Duration) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	target := x.now.Add(d)
+	for {
+		waiter := x.earliest()
+		if waiter == nil || waiter.at.After(target) {
+			break
+		}
+		x.now = waiter.at
+		select {
+		case waiter.c <- x.now:
+		default:
+		}
+		if waiter.period > 0 {
+			waiter.at = waiter.at.Add(waiter.period)
+		} else {
+			x.remove(waiter)
+		}
+	}
+	x.now = target
+}
+
+// Waiters 等待中的定时器的数量，包括Sleep、定时器和周期定时器
+func (x *FakeClock) Waiters() int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return len(x.waiters)
+}
+
+// BlockUntil 阻塞到至少有n个等待中的定时器，用来确认被测试的协程已经开始等待了再调用Advance
+func (x *FakeClock) BlockUntil(n int) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for len(x.waiters) < n {
+		x.cond.Wait()
+	}
+}
+
+// 登记一个d之后到期的定时器，d不大于0时立即触发
+func (x *FakeClock) add(d, period time.Duration) *fakeWaiter {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	waiter := &fakeWaiter{at: x.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		waiter.c <- x.now
+		return waiter
+	}
+	x.waiters = append(x.waiters, waiter)
+	x.cond.Broadcast()
+	return waiter
+}
+
+// 最早到期的定时器，调用方需要持有锁
+func (x *FakeClock) earliest() *fakeWaiter {
+	if len(x.waiters) == 0 {
+		return nil
+	}
+	sort.SliceStable(x.waiters, func(i, j int) bool {
+		return x.waiters[i].at.Before(x.waiters[j].at)
+	})
+	return x.waiters[0]
+}
+
+// 移除定时器，返回它是否还在等待中，调用方需要持有锁
+func (x *FakeClock) remove(waiter *fakeWaiter) bool {
+	for i, w := range x.waiters {
+		if w == waiter {
+			x.waiters = append(x.waiters[:i], x.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (x *fakeTimer) C() <-chan time.Time {
+	return x.waiter.c
+}
+
+func (x *fakeTimer) Stop() bool {
+	x.clock.lock.Lock()
+	defer x.clock.lock.Unlock()
+	return x.clock.remove(x.waiter)
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (x *fakeTicker) C() <-chan time.Time {
+	return x.waiter.c
+}
+
+func (x *fakeTicker) Stop() {
+	x.clock.lock.Lock()
+	defer x.clock.lock.Unlock()
+	x.clock.remove(x.waiter)
+}
@@ -0,0 +1,79 @@
+package channeltest
+
+import (
+	"context"
+	"github.com/golang-infrastructure/go-message-channel"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	timer := clock.NewTimer(time.Minute)
+	ticker := clock.NewTicker(time.Second * 20)
+	stopped := clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Second * 30)
+	assert.Equal(t, start.Add(time.Second*30), clock.Now())
+	assert.Equal(t, start.Add(time.Second*20), <-ticker.C())
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Second * 30)
+	assert.Equal(t, start.Add(time.Minute), <-timer.C())
+	assert.False(t, timer.Stop())
+	assert.Equal(t, start.Add(time.Second*40), <-ticker.C())
+	ticker.Stop()
+	assert.Equal(t, 0, clock.Waiters())
+	assert.Equal(t, time.Minute, clock.Since(start))
+}
+
+func TestFakeClock_Retry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	attempts := &atomic.Int64{}
+	channel := message_channel.NewChannel[int](message_channel.NewChannelOptions[int]().WithClock(clock).WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) message_channel.Decision {
+		if attempts.Add(1) == 1 {
+			return message_channel.Retry{After: time.Hour}
+		}
+		return message_channel.Ack{}
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+
+	// 退避一个小时的重试不需要真的等待
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, int64(2), attempts.Load())
+}
+
+func TestFakeClock_Deadline(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	consumed := &atomic.Int64{}
+	dropped := &atomic.Int64{}
+	channel := message_channel.NewChannel[int](message_channel.NewChannelOptions[int]().WithClock(clock).WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		consumed.Add(1)
+	}).WithOnDropped(func(reason message_channel.DropReason, message int) {
+		assert.Equal(t, message_channel.DropReasonDeadlineExceeded, reason)
+		dropped.Add(1)
+	}))
+	assert.Nil(t, channel.Pause())
+	assert.Nil(t, channel.SendWithDeadline(context.Background(), 1, clock.Now().Add(time.Minute)))
+	assert.Nil(t, channel.SendWithDeadline(context.Background(), 2, clock.Now().Add(time.Hour)))
+
+	// 暂停期间时间前进了十分钟，第一条消息已经过了截止时间
+	clock.Advance(time.Minute * 10)
+	assert.Nil(t, channel.Resume())
+	channel.SenderWaitAndClose()
+	assert.Equal(t, int64(1), consumed.Load())
+	assert.Equal(t, int64(1), dropped.Load())
+}
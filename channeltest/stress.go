@@ -0,0 +1,308 @@
+package channeltest
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-infrastructure/go-message-channel"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+
+	// DefaultStressSenders 没有配置Senders时并发发送消息的协程的数量
+	DefaultStressSenders = 8
+
+	// DefaultStressMessages 没有配置Messages时每个发送协程发送的消息的数量
+	DefaultStressMessages = 500
+)
+
+// StressOptions 压力测试场景的选项，场景中的消息都是不重复的整数，这样才能检查每条消息是否恰好被消费了一次
+type StressOptions struct {
+
+	// 场景中创建的每一个信道的选项都会先交给Configure，可以在这里换上要验证的缓存、Store之类的自定义实现，为nil时使用默认的选项
+	// 每个信道都会调用一次，需要每个信道一份的实现（比如Store）在这里新建；消费函数由场景自己设置，不要覆盖
+	Configure func(options *message_channel.ChannelOptions[int])
+
+	// 并发发送消息的协程的数量，小于1时使用DefaultStressSenders
+	Senders int
+
+	// 每个发送协程发送的消息的数量，小于1时使用DefaultStressMessages
+	Messages int
+
+	// 随机关闭顺序使用的随机数种子，同一个种子的关闭顺序总是一样的，方便复现
+	Seed int64
+}
+
+// StressReport 一次压力测试的结果
+type StressReport struct {
+
+	// 场景的名字
+	Scenario string
+
+	// Send返回nil的消息的数量
+	Sent int
+
+	// Send返回错误的消息的数量，比如发送的时候信道已经关闭了
+	Rejected int
+
+	// 被根信道的消费函数处理的次数
+	Consumed int
+
+	// 发送成功了却一直没有被消费的消息
+	Lost []int
+
+	// 被消费了不止一次的消息
+	Duplicated []int
+
+	// 发送失败了却被消费了的消息
+	Unexpected []int
+
+	// Send发生panic的次数，发生panic的消息按照发送失败统计
+	SendPanics int
+}
+
+// Err 检查不变量：发送成功的消息都恰好被消费一次，发送失败的消息不会被消费，Send不会panic，全部满足时返回nil
+func (x *StressReport) Err() error {
+	if len(x.Lost) == 0 && len(x.Duplicated) == 0 && len(x.Unexpected) == 0 && x.SendPanics == 0 {
+		return nil
+	}
+	return fmt.Errorf("channeltest: %s: %d lost, %d duplicated, %d unexpected, %d send panics", x.Scenario, len(x.Lost), len(x.Duplicated), len(x.Unexpected), x.SendPanics)
+}
+
+// WideFanIn 很多个子信道同时往一个根信道上汇聚，发送完之后所有的子信道同时关闭，最后关闭根信道
+func WideFanIn(options *StressOptions, children int) *StressReport {
+	x := newStress(options, "WideFanIn")
+	root := x.root()
+	nodes := make([]*message_channel.Channel[int], children)
+	for i := range nodes {
+		nodes[i] = root.MakeChildChannelWithOptions(x.channelOptions())
+	}
+
+	x.sendAll(func(message int) *message_channel.Channel[int] {
+		return nodes[message%len(nodes)]
+	})
+	wg := &sync.WaitGroup{}
+	for _, node := range nodes {
+		node := node
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node.SenderWaitAndClose()
+		}()
+	}
+	wg.Wait()
+	root.SenderWaitAndClose()
+	return x.report()
+}
+
+// DeepTree 一条depth层深的子信道链，消息发送到每一层上，发送完之后从最深的一层开始逐层关闭
+func DeepTree(options *StressOptions, depth int) *StressReport {
+	x := newStress(options, "DeepTree")
+	nodes := []*message_channel.Channel[int]{x.root()}
+	for i := 0; i < depth; i++ {
+		nodes = append(nodes, nodes[len(nodes)-1].MakeChildChannelWithOptions(x.channelOptions()))
+	}
+
+	x.sendAll(func(message int) *message_channel.Channel[int] {
+		return nodes[message%len(nodes)]
+	})
+	for i := len(nodes) - 1; i >= 0; i-- {
+		nodes[i].SenderWaitAndClose()
+	}
+	return x.report()
+}
+
+// RandomCloseOrder 每个信道都有fanout个子信道、一共depth层的树，消息发送到每个信道上，发送完之后按照随机的顺序关闭
+// 关闭顺序只遵守信道的约定：一个信道的子信道都关闭了之后它才会被关闭，除此之外完全随机
+func RandomCloseOrder(options *StressOptions, fanout, depth int) *StressReport {
+	x := newStress(options, "RandomCloseOrder")
+	root := x.root()
+	nodes := []*message_channel.Channel[int]{root}
+	parents := map[*message_channel.Channel[int]]*message_channel.Channel[int]{}
+	openChildren := map[*message_channel.Channel[int]]int{}
+	level := []*message_channel.Channel[int]{root}
+	for i := 0; i < depth; i++ {
+		var next []*message_channel.Channel[int]
+		for _, parent := range level {
+			for j := 0; j < fanout; j++ {
+				child := parent.MakeChildChannelWithOptions(x.channelOptions())
+				parents[child] = parent
+				openChildren[parent]++
+				next = append(next, child)
+			}
+		}
+		nodes = append(nodes, next...)
+		level = next
+	}
+
+	x.sendAll(func(message int) *message_channel.Channel[int] {
+		return nodes[message%len(nodes)]
+	})
+
+	// 每次从子信道都已经关闭了的信道中随机选一个关闭
+	random := rand.New(rand.NewSource(x.options.Seed))
+	var ready []*message_channel.Channel[int]
+	for _, node := range nodes {
+		if openChildren[node] == 0 {
+			ready = append(ready, node)
+		}
+	}
+	for len(ready) > 0 {
+		i := random.Intn(len(ready))
+		node := ready[i]
+		ready = append(ready[:i], ready[i+1:]...)
+		node.SenderWaitAndClose()
+		if parent, ok := parents[node]; ok {
+			openChildren[parent]--
+			if openChildren[parent] == 0 {
+				ready = append(ready, parent)
+			}
+		}
+	}
+	return x.report()
+}
+
+// ConcurrentSendClose 消息发送到一半的时候开始关闭信道，发送方不知道信道已经关闭了，继续发送剩下的消息
+// 关闭之后的发送应该返回错误而不是panic，关闭之前发送成功的消息也都应该被消费掉
+func ConcurrentSendClose(options *StressOptions) *StressReport {
+	x := newStress(options, "ConcurrentSendClose")
+	root := x.root()
+	child := root.MakeChildChannelWithOptions(x.channelOptions())
+	nodes := []*message_channel.Channel[int]{root, child}
+
+	half := make(chan struct{})
+	halfOnce := &sync.Once{}
+	attempts := &atomic.Int64{}
+	total := int64(x.options.Senders * x.options.Messages)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		<-half
+		child.SenderWaitAndClose()
+		root.SenderWaitAndClose()
+	}()
+
+	x.sendAll(func(message int) *message_channel.Channel[int] {
+		if attempts.Add(1)*2 >= total {
+			halfOnce.Do(func() {
+				close(half)
+			})
+		}
+		return nodes[message%len(nodes)]
+	})
+	<-closed
+	return x.report()
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// 记录一个场景中每条消息的发送结果和被消费的次数
+type stress struct {
+	options  StressOptions
+	scenario string
+
+	lock     *sync.Mutex
+	sent     map[int]bool
+	consumed map[int]int
+	panics   int
+}
+
+func newStress(options *StressOptions, scenario string) *stress {
+	x := &stress{
+		scenario: scenario,
+		lock:     &sync.Mutex{},
+		sent:     make(map[int]bool),
+		consumed: make(map[int]int),
+	}
+	if options != nil {
+		x.options = *options
+	}
+	if x.options.Senders < 1 {
+		x.options.Senders = DefaultStressSenders
+	}
+	if x.options.Messages < 1 {
+		x.options.Messages = DefaultStressMessages
+	}
+	return x
+}
+
+func (x *stress) channelOptions() *message_channel.ChannelOptions[int] {
+	options := message_channel.NewChannelOptions[int]().WithChannelBuffSize(16)
+	if x.options.Configure != nil {
+		x.options.Configure(options)
+	}
+	return options
+}
+
+// 消费所有消息的根信道
+func (x *stress) root() *message_channel.Channel[int] {
+	return message_channel.NewChannel[int](x.channelOptions().WithChannelConsumerFunc(func(index int, message int) {
+		x.lock.Lock()
+		defer x.lock.Unlock()
+		x.consumed[message]++
+	}))
+}
+
+// 并发的发送所有的消息，target决定每条消息发送到哪个信道，全部发送完之后返回
+func (x *stress) sendAll(target func(message int) *message_channel.Channel[int]) {
+	wg := &sync.WaitGroup{}
+	for s := 0; s < x.options.Senders; s++ {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < x.options.Messages; i++ {
+				message := s*x.options.Messages + i
+				x.send(target(message), message)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (x *stress) send(channel *message_channel.Channel[int], message int) {
+	defer func() {
+		if recover() != nil {
+			x.lock.Lock()
+			x.sent[message] = false
+			x.panics++
+			x.lock.Unlock()
+		}
+	}()
+	err := channel.Send(context.Background(), message)
+	x.lock.Lock()
+	x.sent[message] = err == nil
+	x.lock.Unlock()
+}
+
+func (x *stress) report() *StressReport {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	report := &StressReport{Scenario: x.scenario, SendPanics: x.panics}
+	for message, ok := range x.sent {
+		if ok {
+			report.Sent++
+			if x.consumed[message] == 0 {
+				report.Lost = append(report.Lost, message)
+			}
+		} else {
+			report.Rejected++
+		}
+	}
+	for message, count := range x.consumed {
+		report.Consumed += count
+		if count > 1 {
+			report.Duplicated = append(report.Duplicated, message)
+		}
+		if !x.sent[message] {
+			report.Unexpected = append(report.Unexpected, message)
+		}
+	}
+	sort.Ints(report.Lost)
+	sort.Ints(report.Duplicated)
+	sort.Ints(report.Unexpected)
+	return report
+}
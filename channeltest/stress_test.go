@@ -0,0 +1,48 @@
+package channeltest
+
+import (
+	"github.com/golang-infrastructure/go-message-channel"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStress(t *testing.T) {
+	options := &StressOptions{Senders: 4, Messages: 200, Seed: 1}
+	for _, report := range []*StressReport{
+		WideFanIn(options, 32),
+		DeepTree(options, 8),
+		RandomCloseOrder(options, 3, 3),
+	} {
+		assert.Nil(t, report.Err())
+		assert.Equal(t, 800, report.Sent)
+		assert.Equal(t, 800, report.Consumed)
+	}
+}
+
+func TestStress_ConcurrentSendClose(t *testing.T) {
+	ordered := &StressOptions{Configure: func(options *message_channel.ChannelOptions[int]) {
+		options.WithOrdering(func(a, b int) bool {
+			return a < b
+		})
+	}}
+	dropNewest := &StressOptions{Configure: func(options *message_channel.ChannelOptions[int]) {
+		options.WithOverflowPolicy(message_channel.OverflowDropNewest)
+	}}
+
+	// 不管是哪种缓存，关闭之后的发送都返回错误而不是panic
+	for _, options := range []*StressOptions{nil, ordered} {
+		report := ConcurrentSendClose(options)
+		assert.Nil(t, report.Err())
+		assert.Equal(t, report.Sent, report.Consumed)
+		assert.Equal(t, DefaultStressSenders*DefaultStressMessages, report.Sent+report.Rejected)
+	}
+	report := ConcurrentSendClose(dropNewest)
+	assert.Equal(t, 0, report.SendPanics)
+	assert.Empty(t, report.Duplicated)
+	assert.Empty(t, report.Unexpected)
+}
+
+func TestStressReport_Err(t *testing.T) {
+	assert.Nil(t, (&StressReport{Scenario: "ok"}).Err())
+	assert.EqualError(t, (&StressReport{Scenario: "bad", Lost: []int{1}, SendPanics: 2}).Err(), "channeltest: bad: 1 lost, 0 duplicated, 0 unexpected, 2 send panics")
+}
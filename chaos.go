@@ -0,0 +1,97 @@
+package message_channel
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DropReasonChaos 开启了混沌模式时被随机丢弃的消息
+const DropReasonChaos DropReason = "chaos"
+
+// ErrChaosCrash 混沌模式随机让处理消息的协程崩溃时使用的错误，通过Fatal抛出，监督者收到的崩溃原因是它
+var ErrChaosCrash = errors.New("message channel: chaos crash")
+
+// ChaosOptions 混沌模式的选项，按照配置的概率随机的延迟、重复、丢弃消息或者让处理消息的协程崩溃，
+// 用来测试构建在信道之上的系统能不能扛住这些故障，比如消费函数是不是幂等的、监督者的策略是否合理，不要在生产环境中开启
+// 每种故障的概率都是0到1之间的小数，为0时不会发生这种故障，每条消息按照丢弃、崩溃、延迟、重复的顺序依次判定，事务模式下不生效
+type ChaosOptions struct {
+
+	// 随机数种子，同一个种子得到的故障序列是一样的，方便复现，为0时使用当前时间
+	Seed int64
+
+	// 消息被交给消费函数之前随机延迟的概率，延迟的时长在(0, MaxDelay]之间均匀分布
+	DelayRate float64
+	MaxDelay  time.Duration
+
+	// 消息被消费函数处理两次的概率，两次都处理完之后都会转发给父信道
+	DuplicateRate float64
+
+	// 消息从缓存中取出之后直接丢弃的概率，丢弃原因是DropReasonChaos
+	DropRate float64
+
+	// 处理消息之前让处理消息的协程崩溃的概率，相当于消费函数调用了Fatal(ErrChaosCrash)，同步模式下会直接抛给发送方
+	CrashRate float64
+}
+
+// chaos 按照ChaosOptions注入故障，多个处理消息的协程共用一个随机数生成器，所以需要加锁
+type chaos struct {
+	options *ChaosOptions
+	lock    *sync.Mutex
+	random  *rand.Rand
+}
+
+func newChaos(options *ChaosOptions) *chaos {
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &chaos{
+		options: options,
+		lock:    &sync.Mutex{},
+		random:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// 按照概率判定是否发生故障
+func (x *chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.random.Float64() < rate
+}
+
+// 随机的延迟时长，不需要延迟时返回0
+func (x *chaos) delay() time.Duration {
+	if x.options.MaxDelay <= 0 || !x.roll(x.options.DelayRate) {
+		return 0
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return time.Duration(x.random.Int63n(int64(x.options.MaxDelay))) + 1
+}
+
+// 消息被消费之前注入故障，返回这条消息要被处理几次，为0时消息已经被丢弃了
+func (x *Channel[Message]) injectChaos(envelope envelope[Message]) int {
+	if x.chaos == nil {
+		return 1
+	}
+	if x.chaos.roll(x.chaos.options.DropRate) {
+		x.drop(DropReasonChaos, envelope.message)
+		return 0
+	}
+	if x.chaos.roll(x.chaos.options.CrashRate) {
+		Fatal(ErrChaosCrash)
+	}
+	if delay := x.chaos.delay(); delay > 0 && !sleepContext(x.consumeCtx, x.clock, delay) {
+		x.drop(DropReasonAborted, envelope.message)
+		return 0
+	}
+	if x.chaos.roll(x.chaos.options.DuplicateRate) {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,82 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_ChaosDrop(t *testing.T) {
+	dropped := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		t.Fatal("dropped message consumed")
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonChaos, reason)
+		dropped = append(dropped, message)
+	}).WithChaos(&ChaosOptions{DropRate: 1}))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{0, 1, 2}, dropped)
+}
+
+func TestChannel_ChaosDuplicate(t *testing.T) {
+	consumed := make([]int, 0)
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		consumed = append(consumed, message)
+	}))
+
+	// 重复处理的消息也会重复转发给父信道
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+	}).WithChaos(&ChaosOptions{DuplicateRate: 1, DelayRate: 1, MaxDelay: time.Millisecond}))
+	assert.Nil(t, child.Send(context.Background(), 1))
+	assert.Nil(t, child.Send(context.Background(), 2))
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 1, 2, 2}, consumed)
+}
+
+func TestChannel_ChaosCrash(t *testing.T) {
+	reasons := make([]error, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		t.Fatal("crashed message consumed")
+	}).WithSupervisor(&SupervisorOptions{
+		MaxRestarts: 3,
+		Backoff:     time.Millisecond,
+		RestartListener: func(restarts int, reason error) {
+			reasons = append(reasons, reason)
+		},
+	}).WithChaos(&ChaosOptions{CrashRate: 1}))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Len(t, reasons, 3)
+	for _, reason := range reasons {
+		assert.True(t, errors.Is(reason, ErrChaosCrash))
+	}
+	assert.Equal(t, uint64(3), channel.Stats().DroppedByReason[DropReasonConsumerPanic])
+}
+
+func TestChannel_ChaosSeed(t *testing.T) {
+	run := func() []int {
+		consumed := make([]int, 0)
+		channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(100).WithChannelConsumerFunc(func(index int, message int) {
+			consumed = append(consumed, message)
+		}).WithChaos(&ChaosOptions{Seed: 42, DropRate: 0.5}))
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		channel.SenderWaitAndClose()
+		return consumed
+	}
+
+	// 同一个种子丢弃的是同一批消息
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Greater(t, len(first), 0)
+	assert.Less(t, len(first), 100)
+}
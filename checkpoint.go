@@ -0,0 +1,180 @@
+package message_channel
+
+import (
+	"sync"
+	"time"
+)
+
+// CheckpointInfo 检查点的信息，应用可以把它保存到外部，重启之后从这里继续
+type CheckpointInfo struct {
+
+	// 产生检查点的信道
+	ChannelID   uint64
+	ChannelName string
+
+	// 偏移量不超过Offset的消息都已经被消费完了（处理完、转为死信或者被丢弃），多个协程并发消费时不会越过还在处理中的消息
+	Offset uint64
+
+	// 到目前为止被消费的消息的数量
+	Consumed uint64
+
+	// 产生检查点的时间
+	Time time.Time
+}
+
+// CheckpointFunc 产生检查点时的回调，返回错误时通过ErrorListener报告，这个检查点会在下一次触发时重新产生
+type CheckpointFunc func(info CheckpointInfo) error
+
+// CheckpointOptions 检查点的选项，Every和Interval至少配置一个
+type CheckpointOptions struct {
+
+	// 每消费这么多条消息产生一次检查点，为0时不按照数量产生
+	Every uint64
+
+	// 每隔这么长时间产生一次检查点，为0时不按照时间产生
+	Interval time.Duration
+
+	// 产生检查点时的回调，在后台的协程中调用，不会阻塞消费，信道关闭时会在关闭之前再调用一次
+	Func CheckpointFunc
+}
+
+// checkpointer 跟踪正在处理中的消息的偏移量，算出已经完全消费完的偏移量，在后台按照数量或者时间回调
+type checkpointer struct {
+	options *CheckpointOptions
+
+	lock *sync.Mutex
+
+	// 正在处理中的消息的偏移量，同一个偏移量可能因为重新投递同时在处理多次
+	inFlight map[uint64]int
+
+	// 自上一次检查点以来完成的消息的数量
+	sinceLast uint64
+
+	// 上一次成功的检查点的偏移量
+	last uint64
+
+	// 按照数量触发检查点
+	trigger chan struct{}
+
+	// 后台的协程退出之后关闭
+	stopped chan struct{}
+}
+
+func newCheckpointer(options *CheckpointOptions) *checkpointer {
+	return &checkpointer{
+		options:  options,
+		lock:     &sync.Mutex{},
+		inFlight: make(map[uint64]int),
+		trigger:  make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// 一条消息开始被消费
+func (x *checkpointer) begin(offset uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.inFlight[offset]++
+}
+
+// 一条消息被消费完了，没有开始过的偏移量被忽略
+func (x *checkpointer) end(offset uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	count, ok := x.inFlight[offset]
+	if !ok {
+		return
+	}
+	if count <= 1 {
+		delete(x.inFlight, offset)
+	} else {
+		x.inFlight[offset] = count - 1
+	}
+	x.sinceLast++
+	if x.options.Every > 0 && x.sinceLast >= x.options.Every {
+		x.sinceLast = 0
+		select {
+		case x.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 已经完全消费完的偏移量：没有处理中的消息时就是最近处理完的偏移量，否则是处理中的最小的偏移量的前一个
+func (x *checkpointer) watermark(processed uint64) uint64 {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for offset := range x.inFlight {
+		if offset <= processed {
+			processed = offset - 1
+		}
+	}
+	return processed
+}
+
+// 产生一个检查点，偏移量没有前进时不回调
+func (x *Channel[Message]) checkpoint() {
+	checkpointer := x.checkpointer
+	offset := checkpointer.watermark(x.offsets.processed.Load())
+	if offset <= checkpointer.last {
+		return
+	}
+	err := checkpointer.options.Func(CheckpointInfo{
+		ChannelID:   x.ID,
+		ChannelName: x.options.Name,
+		Offset:      offset,
+		Consumed:    x.stats.consumed.Load(),
+		Time:        x.clock.Now(),
+	})
+	if err != nil {
+		x.reportError(ErrorOpCheckpoint, err)
+		return
+	}
+	checkpointer.last = offset
+}
+
+// 启动按照数量或者时间产生检查点的协程，信道关闭时停止
+func (x *Channel[Message]) startCheckpointer() {
+	x.spawn(goroutineRoleCheckpoint, func() {
+		defer close(x.checkpointer.stopped)
+		var tick <-chan time.Time
+		if x.options.CheckpointOptions.Interval > 0 {
+			ticker := x.clock.NewTicker(x.options.CheckpointOptions.Interval)
+			defer ticker.Stop()
+			tick = ticker.C()
+		}
+		for {
+			select {
+			case <-tick:
+			case <-x.checkpointer.trigger:
+			case <-x.consumeCtx.Done():
+				return
+			}
+			x.checkpoint()
+		}
+	})
+}
+
+// 信道关闭之前产生最后一个检查点，这时所有的消息都已经消费完了
+func (x *Channel[Message]) finalCheckpoint() {
+	if x.checkpointer == nil {
+		return
+	}
+	x.cancelConsume()
+	<-x.checkpointer.stopped
+	x.checkpoint()
+}
+
+// 消息开始被消费时登记，没有配置检查点时什么都不做
+func (x *Channel[Message]) checkpointBegin(offset uint64) {
+	if x.checkpointer != nil {
+		x.checkpointer.begin(offset)
+	}
+}
+
+// 消息被消费完时登记，处理消息时崩溃了的消息也算消费完了，它已经按照DropReasonConsumerPanic被丢弃了
+func (x *Channel[Message]) checkpointEnd(offset uint64) {
+	if x.checkpointer != nil {
+		x.checkpointer.end(offset)
+	}
+}
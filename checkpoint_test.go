@@ -0,0 +1,61 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_Checkpoint(t *testing.T) {
+	lock := &sync.Mutex{}
+	checkpoints := make([]uint64, 0)
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithName("orders").WithCheckpoint(2, 0, func(info CheckpointInfo) error {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "orders", info.ChannelName)
+		checkpoints = append(checkpoints, info.Offset)
+		return nil
+	}).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 5 {
+			<-release
+		}
+	}))
+	for i := 1; i <= 6; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+
+	// 每消费两条消息产生一个检查点，第5条消息还在处理中，检查点不会越过它
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(checkpoints) > 0 && checkpoints[len(checkpoints)-1] == 4
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	lock.Lock()
+	assert.Equal(t, uint64(4), checkpoints[len(checkpoints)-1])
+	lock.Unlock()
+
+	// 关闭之前产生最后一个检查点
+	close(release)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(6), checkpoints[len(checkpoints)-1])
+}
+
+func TestChannel_Checkpoint_Error(t *testing.T) {
+	attempts := 0
+	ops := make([]string, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithSynchronousMode().WithCheckpoint(0, time.Hour, func(info CheckpointInfo) error {
+		attempts++
+		return errors.New("disk full")
+	}).WithErrorListener(func(op string, err error) {
+		ops = append(ops, op)
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	channel.SenderWaitAndClose()
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, []string{ErrorOpCheckpoint}, ops)
+}
@@ -24,13 +24,29 @@ type ChildrenMap[Message any] struct {
 
 	// 用于记录当前channel的子channel
 	channelMap map[uint64]*Channel[Message]
+
+	// BlockUtilEmpty的间隔使用的时钟
+	clock Clock
+
+	// 等待map为空时的检查策略
+	wait childrenWait
+
+	// 每次有子信道被移除时关闭并且换成一个新的，用来唤醒按照通知策略等待的一方
+	removed chan struct{}
 }
 
 // NewChildrenMap 创建一个存放子channel的map
 func NewChildrenMap[Message any]() *ChildrenMap[Message] {
+	return newChildrenMap[Message](SystemClock(), childrenWait{})
+}
+
+func newChildrenMap[Message any](clock Clock, wait childrenWait) *ChildrenMap[Message] {
 	return &ChildrenMap[Message]{
 		lock:       &sync.Mutex{},
 		channelMap: make(map[uint64]*Channel[Message]),
+		clock:      clock,
+		wait:       wait,
+		removed:    make(chan struct{}),
 	}
 }
 
@@ -42,45 +58,63 @@ func (x *ChildrenMap[Message]) Run(ctx context.Context, f MapRunFunc[Message]) e
 	return f(ctx, x.channelMap)
 }
 
-// BlockUtilEmpty 阻塞住直到当前map为空，期间会每隔给定的间隔尝试获取map的情况
+// BlockUtilEmpty 阻塞住直到当前map为空或者ctx结束，期间会按照信道配置的策略检查map的情况，map不为空时在每次检查时调用f
+// interval: 可选的本次等待的检查间隔，覆盖信道选项中配置的间隔，都没有的时候默认是DefaultChildrenPollInterval
 func (x *ChildrenMap[Message]) BlockUtilEmpty(ctx context.Context, f MapRunFunc[Message], interval ...time.Duration) error {
-
-	// 设置间隔的默认值，默认是每隔1秒试探一次
-	if len(interval) == 0 {
-		interval = append(interval, time.Second)
+	if len(interval) > 0 {
+		return x.waitEmpty(ctx, interval[0], f)
 	}
+	return x.waitEmpty(ctx, 0, f)
+}
 
-	isMapNotEmpty := false
+// 阻塞住直到map为空或者ctx结束，map不为空时在检查时调用f，f返回错误或者ctx结束时返回对应的错误
+// interval: 本次等待的检查间隔，为0时使用信道选项中配置的间隔，都没有的时候是DefaultChildrenPollInterval
+func (x *ChildrenMap[Message]) waitEmpty(ctx context.Context, interval time.Duration, f MapRunFunc[Message]) error {
+	if interval <= 0 {
+		interval = x.wait.interval
+	}
+	if interval <= 0 {
+		interval = DefaultChildrenPollInterval
+	}
 	for {
+		empty := false
+		var removed chan struct{}
 		err := x.Run(ctx, func(ctx context.Context, m map[uint64]*Channel[Message]) error {
-
-			// 判断map是否为空了
 			if len(m) == 0 {
-				isMapNotEmpty = false
+				empty = true
 				return nil
 			}
-
-			// 调用用户处理map的函数
+			removed = x.removed
 			if f != nil {
 				return f(ctx, m)
 			}
-
 			return nil
 		})
-		if err != nil {
+		if err != nil || empty {
 			return err
 		}
-
-		// 判断是否需要结束
-		if !isMapNotEmpty {
-			break
+		if !x.sleep(ctx, interval, removed) {
+			return ctx.Err()
 		}
-
-		// 休眠指定的时长
-		time.Sleep(interval[0])
+		interval = x.wait.next(interval)
 	}
+}
 
-	return nil
+// 等待下一次检查，按照通知策略等待时有子信道被移除会提前返回，ctx结束时返回false
+func (x *ChildrenMap[Message]) sleep(ctx context.Context, interval time.Duration, removed chan struct{}) bool {
+	if x.wait.strategy != ChildrenWaitNotify {
+		return sleepContext(ctx, x.clock, interval)
+	}
+	timer := x.clock.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-removed:
+		return true
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // Size 统计map中元素的数量
@@ -115,6 +149,8 @@ func (x *ChildrenMap[Message]) Set(ctx context.Context, id uint64, childChannel
 func (x *ChildrenMap[Message]) Remove(ctx context.Context, id uint64) error {
 	return x.Run(ctx, func(ctx context.Context, f map[uint64]*Channel[Message]) error {
 		delete(f, id)
+		close(x.removed)
+		x.removed = make(chan struct{})
 		return nil
 	})
 }
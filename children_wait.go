@@ -0,0 +1,49 @@
+package message_channel
+
+import "time"
+
+// ChildrenWaitStrategy 关闭时等待子信道都退出的过程中检查子信道map的策略
+type ChildrenWaitStrategy int
+
+const (
+
+	// ChildrenWaitFixed 每隔固定的间隔检查一次，默认的策略
+	ChildrenWaitFixed ChildrenWaitStrategy = iota
+
+	// ChildrenWaitExponential 从间隔开始每检查一次间隔就翻一倍，最多到ChildrenPollMaxInterval，适合子信道退出得比较慢的深层拓扑，不会一直空转
+	ChildrenWaitExponential
+
+	// ChildrenWaitNotify 有子信道退出的时候立即被唤醒重新检查，间隔只作为兜底，适合测试中需要尽快关闭的场景
+	ChildrenWaitNotify
+)
+
+// DefaultChildrenPollInterval SenderWaitAndClose等待子信道退出时默认的检查间隔
+const DefaultChildrenPollInterval = time.Second
+
+// childrenWait 等待子信道map为空时的检查策略
+type childrenWait struct {
+	strategy    ChildrenWaitStrategy
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+// 根据信道的选项得到等待子信道的策略，没有配置间隔的时候由调用方决定默认值
+func newChildrenWait[Message any](options *ChannelOptions[Message]) childrenWait {
+	return childrenWait{
+		strategy:    options.ChildrenWaitStrategy,
+		interval:    options.ChildrenPollInterval,
+		maxInterval: options.ChildrenPollMaxInterval,
+	}
+}
+
+// 检查了一次之后下一次检查之前的间隔
+func (x childrenWait) next(interval time.Duration) time.Duration {
+	if x.strategy != ChildrenWaitExponential {
+		return interval
+	}
+	interval *= 2
+	if x.maxInterval > 0 && interval > x.maxInterval {
+		interval = x.maxInterval
+	}
+	return interval
+}
@@ -0,0 +1,85 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChildrenMap_BlockUtilEmpty(t *testing.T) {
+	m := NewChildrenMap[int]()
+	child := NewChannel[int](NewChannelOptions[int]())
+	assert.Nil(t, m.Set(context.Background(), child.ID, child))
+
+	// 还有子信道的时候一直等到ctx结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	checks := 0
+	err := m.BlockUtilEmpty(ctx, func(ctx context.Context, m map[uint64]*Channel[int]) error {
+		checks++
+		return nil
+	}, time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, checks, 1)
+
+	assert.Nil(t, m.Remove(context.Background(), child.ID))
+	assert.Nil(t, m.BlockUtilEmpty(context.Background(), nil))
+	child.SenderWaitAndClose()
+}
+
+func TestChannel_ChildrenWaitNotify(t *testing.T) {
+	// 检查间隔很长，但是子信道退出的时候会立即被唤醒
+	root := NewChannel[int](NewChannelOptions[int]().WithChildrenWait(ChildrenWaitNotify, time.Hour, 0))
+	child := root.MakeChildChannel()
+	closed := make(chan struct{})
+	go func() {
+		root.SenderWaitAndClose()
+		close(closed)
+	}()
+	assert.Eventually(t, func() bool {
+		return root.State() == StateDraining
+	}, time.Second, time.Millisecond)
+	child.SenderWaitAndClose()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("root was not woken up by the child closing")
+	}
+}
+
+func TestChildrenWait_Exponential(t *testing.T) {
+	wait := childrenWait{strategy: ChildrenWaitExponential, maxInterval: time.Millisecond * 30}
+	assert.Equal(t, time.Millisecond*20, wait.next(time.Millisecond*10))
+	assert.Equal(t, time.Millisecond*30, wait.next(time.Millisecond*20))
+	assert.Equal(t, time.Millisecond*10, childrenWait{}.next(time.Millisecond*10))
+}
+
+func TestChildrenMap_BlockUtilEmptyInterval(t *testing.T) {
+	m := NewChildrenMap[int]()
+	child := NewChannel[int](NewChannelOptions[int]())
+	assert.Nil(t, m.Set(context.Background(), child.ID, child))
+	count := func(m *ChildrenMap[int], interval ...time.Duration) int {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		checks := 0
+		err := m.BlockUtilEmpty(ctx, func(ctx context.Context, m map[uint64]*Channel[int]) error {
+			checks++
+			return nil
+		}, interval...)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		return checks
+	}
+
+	// 本次等待的间隔覆盖选项中配置的间隔
+	m.wait = childrenWait{interval: time.Hour}
+	assert.Greater(t, count(m, time.Millisecond), 1)
+	assert.Equal(t, 1, count(m))
+
+	// 没有传入间隔的时候使用选项中配置的间隔
+	m.wait = childrenWait{interval: time.Millisecond}
+	assert.Greater(t, count(m), 1)
+
+	assert.Nil(t, m.Remove(context.Background(), child.ID))
+	child.SenderWaitAndClose()
+}
@@ -0,0 +1,194 @@
+package message_channel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock 信道中所有和时间相关的功能使用的时钟，比如重试的退避、消息的截止时间、批的最长等待、看门狗、自动伸缩、采样以及各种窗口
+// 默认使用系统时钟，测试中可以通过WithClock换成channeltest.FakeClock，这样不需要真的等待就可以让时间立刻前进
+// 所有方法都可能被并发调用，实现需要自己保证并发安全
+type Clock interface {
+
+	// Now 当前时间
+	Now() time.Time
+
+	// Since 从t到现在经过的时长
+	Since(t time.Time) time.Duration
+
+	// Sleep 阻塞d这么长的时间
+	Sleep(d time.Duration)
+
+	// NewTimer 创建一个d之后触发一次的定时器
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker 创建一个每隔d触发一次的周期定时器，d必须大于0
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 只触发一次的定时器，和time.Timer一样
+type Timer interface {
+
+	// C 定时器触发时收到当时的时间
+	C() <-chan time.Time
+
+	// Stop 停止定时器，定时器已经触发或者已经停止时返回false
+	Stop() bool
+}
+
+// Ticker 周期触发的定时器，和time.Ticker一样，接收方来不及接收时会丢掉中间的触发
+type Ticker interface {
+
+	// C 每次触发时收到当时的时间
+	C() <-chan time.Time
+
+	// Stop 停止定时器，停止之后不会再触发
+	Stop()
+}
+
+// SystemClock 使用系统时间的时钟，没有配置Clock时使用的就是它
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (x systemTimer) C() <-chan time.Time {
+	return x.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (x systemTicker) C() <-chan time.Time {
+	return x.Ticker.C
+}
+
+// 没有配置时钟时使用系统时钟
+func resolveClock(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock()
+	}
+	return clock
+}
+
+// Clock 信道使用的时钟，没有配置Clock时是SystemClock，和信道配合使用的组件可以用它和信道保持一致的时间
+func (x *Channel[Message]) Clock() Clock {
+	return x.clock
+}
+
+// WithClockDeadline 和context.WithDeadline一样，只是按照clock的时间到期，clock是SystemClock时就是context.WithDeadline
+func WithClockDeadline(parent context.Context, clock Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	return withClockDeadline(parent, resolveClock(clock), deadline)
+}
+
+// 按照clock的时间在deadline时取消的ctx，系统时钟直接使用context.WithDeadline，其他时钟用一个协程等待定时器触发
+func withClockDeadline(parent context.Context, clock Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(systemClock); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	x := &clockDeadlineContext{
+		parent:   parent,
+		deadline: deadline,
+		done:     make(chan struct{}),
+		lock:     &sync.Mutex{},
+	}
+	timer := clock.NewTimer(deadline.Sub(clock.Now()))
+	go func() {
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			x.cancel(context.DeadlineExceeded)
+		case <-parent.Done():
+			x.cancel(parent.Err())
+		case <-x.done:
+		}
+	}()
+	return x, func() {
+		x.cancel(context.Canceled)
+	}
+}
+
+// clockDeadlineContext 按照其他时钟到期的ctx，到期之后Err和context.WithDeadline一样返回context.DeadlineExceeded
+type clockDeadlineContext struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	// 取消的原因，只有第一次取消生效
+	lock *sync.Mutex
+	err  error
+}
+
+func (x *clockDeadlineContext) cancel(err error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.err == nil {
+		x.err = err
+		close(x.done)
+	}
+}
+
+func (x *clockDeadlineContext) Deadline() (time.Time, bool) {
+	return x.deadline, true
+}
+
+func (x *clockDeadlineContext) Done() <-chan struct{} {
+	return x.done
+}
+
+func (x *clockDeadlineContext) Err() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.err
+}
+
+func (x *clockDeadlineContext) Value(key any) any {
+	return x.parent.Value(key)
+}
+
+// 按照clock的时间在d之后取消的ctx
+func withClockTimeout(parent context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	return withClockDeadline(parent, clock, clock.Now().Add(d))
+}
+
+// 等待d这么长的时间，ctx被取消时提前返回false
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
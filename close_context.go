@@ -0,0 +1,109 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CloseError SenderWaitAndCloseContext没有在ctx到期之前完成关闭时返回的错误，记录了到期时剩余的情况
+type CloseError struct {
+
+	// ctx的错误
+	Err error
+
+	// ctx到期时当前信道的缓存中还没有被消费的消息的数量
+	Pending int
+
+	// ctx到期时还没有退出的子信道，按照ID排序，为空表示是在等待自己的消息处理完时到期的
+	Children []ChildInfo
+
+	// ctx到期时还没有关闭完的分发子信道，按照ID排序，分发子信道是在当前信道处理完自己的消息之后才关闭的
+	DistributionChildren []ChildInfo
+}
+
+func (x *CloseError) Error() string {
+	if len(x.Children) > 0 {
+		return fmt.Sprintf("message channel: close timed out waiting for %d children, %d pending messages: %v", len(x.Children), x.Pending, x.Err)
+	}
+	if len(x.DistributionChildren) > 0 {
+		return fmt.Sprintf("message channel: close timed out waiting for %d distribution children, %d pending messages: %v", len(x.DistributionChildren), x.Pending, x.Err)
+	}
+	return fmt.Sprintf("message channel: close timed out draining, %d pending messages: %v", x.Pending, x.Err)
+}
+
+func (x *CloseError) Unwrap() error {
+	return x.Err
+}
+
+// SenderWaitAndCloseContext 和SenderWaitAndClose一样等待子信道退出、关闭当前信道并等待剩余的消息处理完，只是整个过程都受ctx控制，没有写死的超时
+// 正常关闭完成时返回nil，ctx到期时返回*CloseError，可以知道关闭是卡在哪里了、还剩多少消息：
+// 等待子信道退出时到期的话当前信道还没有被关闭，仍然处于Draining，可以再次调用继续等待；等待自己的消息处理完时到期的话关闭会在后台继续进行
+func (x *Channel[Message]) SenderWaitAndCloseContext(ctx context.Context) error {
+
+	start := x.clock.Now()
+	x.beginDraining()
+
+	if err := x.childrenChannelMap.waitEmpty(ctx, 0, x.drainProgress(start, nil)); err != nil {
+		if ctx.Err() == nil {
+			x.reportError(ErrorOpDrainChildren, err)
+		} else {
+			return x.closeError(ctx.Err())
+		}
+	}
+
+	// 等待上游信道的泵协程把消息都转发过来
+	upstream := make(chan struct{})
+	go func() {
+		x.upstreamWg.Wait()
+		close(upstream)
+	}()
+	select {
+	case <-upstream:
+	case <-ctx.Done():
+		return x.closeError(ctx.Err())
+	}
+
+	x.closeChannel()
+
+	select {
+	case <-x.done:
+		return nil
+	case <-ctx.Done():
+		return x.closeError(ctx.Err())
+	}
+}
+
+// 记录ctx到期时剩余的情况
+func (x *Channel[Message]) closeError(err error) *CloseError {
+	report := &CloseError{
+		Err:     err,
+		Pending: x.buffer.len(),
+	}
+	children, _ := x.childrenChannelMap.ChildrenSlice(context.Background())
+	for _, child := range children {
+		report.Children = append(report.Children, child.childInfo())
+	}
+	sort.Slice(report.Children, func(i, j int) bool {
+		return report.Children[i].ID < report.Children[j].ID
+	})
+	for _, target := range x.DistributionChildren() {
+		if !target.IsClosed() {
+			report.DistributionChildren = append(report.DistributionChildren, target.childInfo())
+		}
+	}
+	sort.Slice(report.DistributionChildren, func(i, j int) bool {
+		return report.DistributionChildren[i].ID < report.DistributionChildren[j].ID
+	})
+	return report
+}
+
+// 子信道当前的情况
+func (x *Channel[Message]) childInfo() ChildInfo {
+	return ChildInfo{
+		ID:       x.ID,
+		Name:     x.options.Name,
+		Pending:  x.buffer.len(),
+		InFlight: int(x.stats.inFlight.Load()),
+	}
+}
@@ -0,0 +1,72 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_SenderWaitAndCloseContext(t *testing.T) {
+	received := make([]int, 0)
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		received = append(received, message)
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child"))
+	assert.Nil(t, child.Send(context.Background(), 1))
+
+	// 子信道还没有关闭，等到ctx到期时返回还在等待的子信道，父信道还没有被关闭
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := parent.SenderWaitAndCloseContext(ctx)
+	var closeErr *CloseError
+	if assert.ErrorAs(t, err, &closeErr) {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []ChildInfo{{ID: child.ID, Name: "child"}}, closeErr.Children)
+	}
+	assert.Equal(t, StateDraining, parent.State())
+
+	// 子信道关闭之后再次调用就能完成关闭
+	go child.SenderWaitAndClose()
+	assert.Nil(t, parent.SenderWaitAndCloseContext(context.Background()))
+	assert.Equal(t, StateClosed, parent.State())
+	assert.Equal(t, []int{1}, received)
+}
+
+func TestChannel_SenderWaitAndCloseContext_Draining(t *testing.T) {
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(4).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := channel.SenderWaitAndCloseContext(ctx)
+	var closeErr *CloseError
+	if assert.True(t, errors.As(err, &closeErr)) {
+		assert.Empty(t, closeErr.Children)
+		assert.Equal(t, 2, closeErr.Pending)
+	}
+
+	// 关闭在后台继续进行
+	close(release)
+	channel.ReceiverWait(context.Background())
+	assert.True(t, channel.IsClosed())
+}
+
+func TestChannel_SenderWaitAndCloseContext_PollInterval(t *testing.T) {
+	// 和SenderWaitAndClose一样按照选项中配置的间隔检查子信道，子信道退出之后要等到下一次检查才会发现
+	parent := NewChannel[int](NewChannelOptions[int]().WithChildrenWait(ChildrenWaitFixed, time.Hour, 0))
+	child := parent.MakeChildChannel()
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		child.SenderWaitAndClose()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, parent.SenderWaitAndCloseContext(ctx), context.DeadlineExceeded)
+	assert.Nil(t, parent.SenderWaitAndCloseContext(context.Background()))
+}
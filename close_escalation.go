@@ -0,0 +1,73 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultCloseTimeout SenderWaitAndClose等待子信道退出的默认超时时间
+const DefaultCloseTimeout = 30 * time.Second
+
+// CloseEscalation SenderWaitAndClose等待子信道退出超时之后的处理策略
+type CloseEscalation int
+
+const (
+
+	// CloseEscalationGiveUp 通过ErrorListener报告还没有退出的子信道之后不再等待，继续关闭当前信道，默认的策略
+	// 子信道之后再转发上来的消息会因为父信道已经关闭而被丢弃
+	CloseEscalationGiveUp CloseEscalation = iota
+
+	// CloseEscalationError 通过ErrorListener报告*CloseError之后直接返回，不关闭当前信道，信道仍然处于Draining，处理完卡住的子信道之后可以再次调用SenderWaitAndClose
+	CloseEscalationError
+
+	// CloseEscalationForceClose 强制关闭还没有退出的子信道，丢弃它们的缓存中剩余的消息，然后继续关闭当前信道
+	// 子信道正在处理的消息没办法被打断，处理完之后转发上来时会因为父信道已经关闭而被丢弃
+	CloseEscalationForceClose
+
+	// CloseEscalationExtend 报告之后再等待一个超时时间，直到子信道都退出为止
+	CloseEscalationExtend
+)
+
+// 等待子信道都退出，超时之后按照CloseEscalation处理，返回是否继续关闭当前信道
+func (x *Channel[Message]) drainChildren(start time.Time, f MapRunFunc[Message]) bool {
+	timeout := x.tuned().CloseTimeout
+	if timeout <= 0 {
+		timeout = DefaultCloseTimeout
+	}
+	for {
+		ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
+		err := x.childrenChannelMap.BlockUtilEmpty(ctx, x.drainProgress(start, f))
+		timedOut := ctx.Err() != nil
+		cancelFunc()
+		if err == nil {
+			return true
+		}
+
+		// 用户的函数返回的错误，和以前一样报告之后继续关闭
+		if !timedOut {
+			x.reportError(ErrorOpDrainChildren, err)
+			return true
+		}
+
+		x.reportError(ErrorOpDrainChildren, x.closeError(err))
+		switch x.tuned().CloseEscalation {
+		case CloseEscalationError:
+			return false
+		case CloseEscalationExtend:
+			continue
+		case CloseEscalationForceClose:
+			x.forceCloseChildren()
+		}
+		return true
+	}
+}
+
+// 强制关闭所有还没有退出的子信道以及它们的子孙信道
+func (x *Channel[Message]) forceCloseChildren() {
+	expired, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	children, _ := x.childrenChannelMap.ChildrenSlice(context.Background())
+	for _, child := range children {
+		_ = child.shutdown(expired, ShutdownForceClose)
+	}
+}
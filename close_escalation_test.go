@@ -0,0 +1,84 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 创建一个等待子信道超时很短的根信道，返回报告出来的错误
+func newEscalatingRoot(escalation CloseEscalation) (*Channel[int], func() []error) {
+	lock := &sync.Mutex{}
+	errs := make([]error, 0)
+	root := NewChannel[int](NewChannelOptions[int]().WithCloseTimeout(time.Millisecond*20).WithCloseEscalation(escalation).WithChildrenWait(ChildrenWaitFixed, time.Millisecond*5, 0).WithErrorListener(func(op string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if op == ErrorOpDrainChildren {
+			errs = append(errs, err)
+		}
+	}))
+	return root, func() []error {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]error(nil), errs...)
+	}
+}
+
+func TestChannel_CloseEscalationError(t *testing.T) {
+	root, errs := newEscalatingRoot(CloseEscalationError)
+	child := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("stuck"))
+
+	// 超时之后报告卡住的子信道，当前信道没有被关闭
+	root.SenderWaitAndClose()
+	assert.Equal(t, StateDraining, root.State())
+	if assert.Len(t, errs(), 1) {
+		closeErr := &CloseError{}
+		assert.True(t, errors.As(errs()[0], &closeErr))
+		assert.ErrorIs(t, closeErr, context.DeadlineExceeded)
+		assert.Equal(t, []ChildInfo{{ID: child.ID, Name: "stuck"}}, closeErr.Children)
+	}
+
+	// 子信道退出之后可以再次关闭
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+	assert.Equal(t, StateClosed, root.State())
+}
+
+func TestChannel_CloseEscalationForceClose(t *testing.T) {
+	root, errs := newEscalatingRoot(CloseEscalationForceClose)
+	release := make(chan struct{})
+	child := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+
+	// 子信道卡住了，强制关闭之后当前信道照常关闭
+	root.SenderWaitAndClose()
+	assert.Equal(t, StateClosed, root.State())
+	assert.Len(t, errs(), 1)
+	assert.Equal(t, uint64(2), child.Stats().DroppedByReason[DropReasonShutdown])
+
+	close(release)
+	child.ReceiverWait(context.Background())
+	assert.Equal(t, uint64(1), child.Stats().DroppedByReason[DropReasonParentUnavailable])
+}
+
+func TestChannel_CloseEscalationExtend(t *testing.T) {
+	root, errs := newEscalatingRoot(CloseEscalationExtend)
+	child := root.MakeChildChannel()
+	go func() {
+		time.Sleep(time.Millisecond * 70)
+		child.SenderWaitAndClose()
+	}()
+
+	// 一直等到子信道退出为止，每次超时都会报告
+	root.SenderWaitAndClose()
+	assert.Equal(t, StateClosed, root.State())
+	assert.Equal(t, StateClosed, child.State())
+	assert.GreaterOrEqual(t, len(errs()), 1)
+}
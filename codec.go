@@ -0,0 +1,27 @@
+package message_channel
+
+import "encoding/json"
+
+// Codec 把消息编码为字节以及从字节解码出消息，需要把消息写出到进程外面的功能（录制、审计、各种输出）都通过它来序列化
+type Codec[Message any] interface {
+	Encode(message Message) ([]byte, error)
+	Decode(data []byte) (Message, error)
+}
+
+// jsonCodec 用encoding/json编解码
+type jsonCodec[Message any] struct{}
+
+// JSONCodec 用encoding/json编解码消息的Codec
+func JSONCodec[Message any]() Codec[Message] {
+	return jsonCodec[Message]{}
+}
+
+func (jsonCodec[Message]) Encode(message Message) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (jsonCodec[Message]) Decode(data []byte) (Message, error) {
+	var message Message
+	err := json.Unmarshal(data, &message)
+	return message, err
+}
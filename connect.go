@@ -0,0 +1,80 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+)
+
+// EmitFunc 把转换后的消息发送到下游信道
+type EmitFunc[Message any] func(message Message) error
+
+// connectTransform 把一个上游的泵协程连接到下游信道dst上，泵协程负责从上游拉取消息、转换类型后通过emit发送到dst
+// 上游相对于dst就像是一个类型不同的子信道：dst在关闭之前会等待泵协程退出
+// closeDst: dst是否是专门为这次连接创建的，如果是的话泵协程退出后由它负责关闭dst
+func connectTransform[Out any](dst *Channel[Out], closeDst bool, run func(emit EmitFunc[Out])) {
+	connectFanOut[Out]([]*Channel[Out]{dst}, closeDst, func(emits []EmitFunc[Out]) {
+		run(emits[0])
+	})
+}
+
+// connectFanOut 和connectTransform类似，只是一个泵协程同时连接到多个下游信道上，emits和dsts一一对应
+func connectFanOut[Out any](dsts []*Channel[Out], closeDst bool, run func(emits []EmitFunc[Out])) {
+
+	emits := make([]EmitFunc[Out], 0, len(dsts))
+	for _, dst := range dsts {
+		dst := dst
+		dst.upstreamWg.Add(1)
+		emits = append(emits, func(message Out) error {
+			return dst.Send(context.Background(), message)
+		})
+	}
+
+	// 泵协程算在第一个下游信道的协程里
+	dsts[0].spawn(goroutineRolePump, func() {
+		run(emits)
+
+		// 必须先标记自己已经退出，否则关闭dst时会等待自己而死锁
+		for _, dst := range dsts {
+			dst.upstreamWg.Done()
+		}
+		if closeDst {
+			for _, dst := range dsts {
+				dst.SenderWaitAndClose()
+			}
+		}
+	})
+}
+
+// mustPullMode 算子会自己拉取输入信道中的消息，所以输入信道必须是拉模式的
+// 在创建算子的时候就检查，否则泵协程拉取时才失败，输出信道会被悄无声息的关闭
+func mustPullMode[Message any](operator string, chans ...*Channel[Message]) {
+	for _, channel := range chans {
+		if !channel.options.PullMode {
+			panic(fmt.Sprintf("message channel: %s: input channel %d is not in pull mode", operator, channel.ID))
+		}
+	}
+}
+
+// Connect 把两个各自独立创建的信道连接起来：不断的从拉模式信道src中取出消息，经过convert转换之后发送到dst中，convert返回false的消息被过滤掉
+// 两个信道之间不是父子关系，dst相对于src就像是父信道：dst在关闭之前会等待src关闭并且src中剩余的消息都转发完，所以应该先关闭src再关闭dst
+// 发送到dst失败的消息（比如dst配置了RejectSendWhileDraining并且已经开始关闭）按照DropReasonParentUnavailable在src上丢弃，src中剩余的消息仍然会被取走，不会让src的发送方一直阻塞
+// src必须是拉模式的，否则会panic，因为两个信道的消息类型不同，所以这里是一个函数而不是方法
+func Connect[A, B any](src *Channel[A], dst *Channel[B], convert func(message A) (B, bool)) {
+	mustPullMode[A]("Connect", src)
+	connectTransform[B](dst, false, func(emit EmitFunc[B]) {
+		for {
+			message, err := src.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			converted, ok := convert(message)
+			if !ok {
+				continue
+			}
+			if err := emit(converted); err != nil {
+				src.reportError(ErrorOpForward, err)
+				src.drop(DropReasonParentUnavailable, message)
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConnect(t *testing.T) {
+	consumed := make([]string, 0)
+	src := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	dst := NewChannel[string](NewChannelOptions[string]().WithChannelConsumerFunc(func(index int, message string) {
+		consumed = append(consumed, message)
+	}))
+
+	// 奇数被过滤掉
+	Connect[int, string](src, dst, func(message int) (string, bool) {
+		return "#" + strconv.Itoa(message), message%2 == 0
+	})
+	for i := 1; i <= 6; i++ {
+		assert.Nil(t, src.Send(context.Background(), i))
+	}
+
+	// dst关闭之前会等待src中剩余的消息都转发完
+	src.SenderWaitAndClose()
+	dst.SenderWaitAndClose()
+	assert.Equal(t, []string{"#2", "#4", "#6"}, consumed)
+}
+
+func TestConnect_DstDraining(t *testing.T) {
+	dropped := make(chan DropReason, 10)
+	src := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode().WithOnDropped(func(reason DropReason, message int) {
+		dropped <- reason
+	}))
+	dst := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+	}).WithRejectSendWhileDraining())
+	Connect[int, int](src, dst, func(message int) (int, bool) {
+		return message, true
+	})
+
+	// dst开始关闭之后拒绝新的消息，src中的消息仍然会被取走
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		dst.SenderWaitAndClose()
+	}()
+	assert.Eventually(t, func() bool {
+		return dst.State() == StateDraining
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, src.Send(context.Background(), i))
+	}
+	src.SenderWaitAndClose()
+	<-closed
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, DropReasonParentUnavailable, <-dropped)
+	}
+	assert.Panics(t, func() {
+		Connect[int, int](NewChannel[int](NewChannelOptions[int]()), dst, func(message int) (int, bool) {
+			return message, true
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrConsumeTimeout 消费函数处理一条消息超过了ConsumeTimeout
+var ErrConsumeTimeout = errors.New("message channel: consume timeout")
+
+// DropReasonConsumeTimeout 消费函数处理消息超时了，按照ConsumeTimeoutSkip跳过了这条消息
+const DropReasonConsumeTimeout DropReason = "consume_timeout"
+
+// ConsumeTimeoutPolicy 消费函数处理一条消息超时之后怎么处理这条消息
+type ConsumeTimeoutPolicy int
+
+const (
+
+	// ConsumeTimeoutRetry 重新把这条消息交给消费函数处理，和消费函数返回Retry一样受MaxRetries的限制，重试次数用完之后按照死信处理
+	ConsumeTimeoutRetry ConsumeTimeoutPolicy = iota
+
+	// ConsumeTimeoutDeadLetter 把这条消息发送到死信信道
+	ConsumeTimeoutDeadLetter
+
+	// ConsumeTimeoutSkip 丢弃这条消息，丢弃的原因是DropReasonConsumeTimeout
+	ConsumeTimeoutSkip
+)
+
+// 按照ConsumeTimeoutSkip跳过超时的消息，只在信道内部使用
+type skipTimedOut struct{}
+
+func (skipTimedOut) isDecision() {}
+
+// 消费函数在另一个协程中的执行结果
+type consumeResult struct {
+	decision Decision
+	reason   any
+	panicked bool
+}
+
+// 带超时的调用消费函数，超时之后取消消费函数的ctx并且不再等待它返回，按照ConsumeTimeoutPolicy返回处理决定，消费函数自己返回的处理决定被忽略
+// 超时的消费函数还在后台运行，之后返回的结果会被忽略，panic了时作为消费函数的错误报告出去；信道被Abort或者强制关闭导致ctx结束时还是等待消费函数返回
+func (x *Channel[Message]) invokeConsumerTimeout(ctx context.Context, index int, message Message) Decision {
+	tuned := x.tuned()
+	timeout := tuned.ConsumeTimeout
+	if timeout <= 0 {
+		return x.invokeConsumer(ctx, index, message)
+	}
+	ctx, cancelFunc := withClockDeadline(ctx, x.clock, x.clock.Now().Add(timeout))
+	defer cancelFunc()
+
+	// 超时之后abandoned被置为true，消费函数之后的结果由它自己的协程处理
+	lock := &sync.Mutex{}
+	abandoned := false
+	results := make(chan consumeResult, 1)
+	x.spawn(goroutineRoleConsumer, func() {
+		var result consumeResult
+		defer func() {
+			if reason := recover(); reason != nil {
+				result = consumeResult{reason: reason, panicked: true}
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if !abandoned {
+				results <- result
+			} else if result.panicked {
+				x.consumerFailed(consumerPanicError(result.reason), message)
+			}
+		}()
+		result.decision = x.invokeConsumer(ctx, index, message)
+	})
+
+	unwrap := func(result consumeResult) Decision {
+		if result.panicked {
+			panic(result.reason)
+		}
+		return result.decision
+	}
+	select {
+	case result := <-results:
+		return unwrap(result)
+	case <-ctx.Done():
+	}
+	if x.consumeCtx.Err() != nil {
+		return unwrap(<-results)
+	}
+
+	// 超时之后消费函数即使因为ctx被取消马上返回了也按照超时处理
+	lock.Lock()
+	abandoned = true
+	lock.Unlock()
+	select {
+	case result := <-results:
+		if result.panicked {
+			x.consumerFailed(consumerPanicError(result.reason), message)
+		}
+	default:
+	}
+
+	x.stats.consumeTimeouts.Add(1)
+	x.consumerFailed(fmt.Errorf("%w: %s", ErrConsumeTimeout, timeout), message)
+	switch tuned.ConsumeTimeoutPolicy {
+	case ConsumeTimeoutDeadLetter:
+		return DeadLetter{}
+	case ConsumeTimeoutSkip:
+		return skipTimedOut{}
+	default:
+		return Retry{}
+	}
+}
@@ -0,0 +1,76 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_ConsumeTimeout(t *testing.T) {
+	for _, policy := range []ConsumeTimeoutPolicy{ConsumeTimeoutRetry, ConsumeTimeoutDeadLetter, ConsumeTimeoutSkip} {
+		deadLetters := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+		attempts := &atomic.Int64{}
+		consumed := &atomic.Int64{}
+		release := make(chan struct{})
+		channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithMaxRetries(2).WithDeadLetterChannel(deadLetters).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+			if message < 0 {
+				// 卡住的消费函数不理会ctx，信道也不会再等它
+				attempts.Add(1)
+				<-release
+				return nil
+			}
+			consumed.Add(1)
+			return nil
+		}).WithConsumeTimeout(time.Millisecond*20, policy))
+
+		assert.Nil(t, channel.Send(context.Background(), -1))
+		assert.Nil(t, channel.Send(context.Background(), 1))
+		assert.Eventually(t, func() bool {
+			return consumed.Load() == 1
+		}, time.Second, time.Millisecond*5)
+
+		stats := channel.Stats()
+		switch policy {
+		case ConsumeTimeoutRetry:
+			assert.Equal(t, int64(3), attempts.Load())
+			assert.Equal(t, uint64(3), stats.ConsumeTimeouts)
+			assert.Equal(t, uint64(2), stats.Retries)
+			assert.Equal(t, uint64(1), stats.DeadLettered)
+		case ConsumeTimeoutDeadLetter:
+			assert.Equal(t, int64(1), attempts.Load())
+			assert.Equal(t, uint64(1), stats.DeadLettered)
+		case ConsumeTimeoutSkip:
+			assert.Equal(t, int64(1), attempts.Load())
+			assert.Equal(t, uint64(0), stats.DeadLettered)
+			assert.Equal(t, uint64(1), stats.DroppedByReason[DropReasonConsumeTimeout])
+		}
+		close(release)
+
+		channel.SenderWaitAndClose()
+		go deadLetters.SenderWaitAndClose()
+		drain[int](deadLetters)
+	}
+}
+
+func TestChannel_ConsumeTimeoutCancelsContext(t *testing.T) {
+	canceled := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		if message < 0 {
+			<-ctx.Done()
+			canceled.Add(1)
+		}
+		return nil
+	}).WithConsumeTimeout(time.Millisecond*10, ConsumeTimeoutSkip))
+
+	assert.Nil(t, channel.Send(context.Background(), -1))
+	assert.Eventually(t, func() bool {
+		return canceled.Load() == 1
+	}, time.Second, time.Millisecond*5)
+
+	// 及时返回的消费函数不受影响
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(1), channel.Stats().ConsumeTimeouts)
+}
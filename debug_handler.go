@@ -0,0 +1,110 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+// debuggable 能被DebugHandler展示的信道，任意消息类型的*Channel经过topologyAsciiAdapter包装之后都实现了它
+type debuggable interface {
+	Health(ctx context.Context) HealthReport
+	TopologyAscii() string
+}
+
+// 为了在接口中使用，提供一个不带可选参数的版本
+type topologyAsciiAdapter[Message any] struct {
+	*Channel[Message]
+}
+
+func (x topologyAsciiAdapter[Message]) TopologyAscii() string {
+	return x.Channel.TopologyAscii()
+}
+
+// DebugChannelView 调试页面中展示的一个根信道
+type DebugChannelView struct {
+
+	// 拓扑的ASCII图形
+	Topology string `json:"topology"`
+
+	// 整棵树的健康报告，包含每个信道的统计以及待处理的消息数
+	Health HealthReport `json:"health"`
+}
+
+// DebugHandler 类似expvar和pprof的调试接口，展示信道的实时拓扑、每个信道的统计以及待处理的消息数
+// 挂载到调试用的mux上就可以在线上查看流水线的状态，请求带上 ?format=json 或者 Accept: application/json 时返回JSON，否则返回HTML
+type DebugHandler struct {
+
+	// 用于全局互斥操作
+	lock *sync.Mutex
+
+	// 需要展示的根信道
+	channels []debuggable
+}
+
+// NewDebugHandler 创建一个调试接口
+func NewDebugHandler() *DebugHandler {
+	return &DebugHandler{
+		lock: &sync.Mutex{},
+	}
+}
+
+// AddDebugChannel 把根信道加入到调试接口中展示
+func AddDebugChannel[Message any](handler *DebugHandler, channel *Channel[Message]) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	handler.channels = append(handler.channels, topologyAsciiAdapter[Message]{Channel: channel})
+}
+
+// Views 获取所有根信道当前的状态
+func (x *DebugHandler) Views(ctx context.Context) []DebugChannelView {
+	x.lock.Lock()
+	channels := make([]debuggable, len(x.channels))
+	copy(channels, x.channels)
+	x.lock.Unlock()
+
+	views := make([]DebugChannelView, 0, len(channels))
+	for _, channel := range channels {
+		views = append(views, DebugChannelView{
+			Topology: channel.TopologyAscii(),
+			Health:   channel.Health(ctx),
+		})
+	}
+	return views
+}
+
+func (x *DebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	views := x.Views(r.Context())
+
+	if r.URL.Query().Get("format") == "json" || r.Header.Get("Accept") == "application/json" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(views)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = debugPageTemplate.Execute(w, views)
+}
+
+var debugPageTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>message channels</title></head>
+<body>
+{{range .}}
+<h2>{{.Health.Name}}#{{.Health.ID}} {{.Health.Subtree.Status}}</h2>
+<pre>{{.Topology}}</pre>
+<table border="1" cellpadding="4">
+<tr><th>channel</th><th>status</th><th>pending</th><th>capacity</th><th>consumed</th><th>consumer errors</th></tr>
+{{template "row" .Health}}
+</table>
+{{else}}
+<p>no channels</p>
+{{end}}
+</body>
+</html>
+{{define "row"}}<tr><td>{{.Name}}#{{.ID}}</td><td>{{.Self.Status}}</td><td>{{.Self.Pending}}</td><td>{{.Self.Capacity}}</td><td>{{.Self.Consumed}}</td><td>{{.Self.ConsumerErrors}}</td></tr>
+{{range .Children}}{{template "row" .}}{{end}}{{end}}`))
@@ -0,0 +1,62 @@
+package message_channel
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChannel_TopologyAscii(t *testing.T) {
+	root := NewChannel[int](NewChannelOptions[int]().WithName("root"))
+	a := root.MakeChildChannel()
+	a.options.Name = "a"
+	b := root.MakeChildChannel()
+	a.MakeChildChannel()
+
+	topology := root.TopologyAscii()
+	lines := strings.Split(strings.TrimSpace(topology), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "root#"))
+	assert.True(t, strings.HasPrefix(lines[1], "├── a#"))
+	assert.True(t, strings.HasPrefix(lines[2], "│   └── channel#"))
+	assert.True(t, strings.HasPrefix(lines[3], "└── channel#"))
+	b.SenderWaitAndClose()
+}
+
+func TestDebugHandler(t *testing.T) {
+	handler := NewDebugHandler()
+	root := NewChannel[string](NewChannelOptions[string]().WithName("events"))
+	AddDebugChannel[string](handler, root)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/channels?format=json", nil))
+	views := make([]DebugChannelView, 0)
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &views))
+	assert.Equal(t, 1, len(views))
+	assert.Equal(t, "events", views[0].Health.Name)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/channels", nil))
+	assert.Contains(t, recorder.Body.String(), "events#")
+	root.SenderWaitAndClose()
+}
+
+func TestChannel_PublishExpvar(t *testing.T) {
+	root := NewChannel[string](NewChannelOptions[string]().WithName("expvar-root").WithChannelBuffSize(2))
+	child := root.MakeChildChannel()
+	// expvar是全局的，同一个名字只能发布一次，多次运行测试时需要换名字
+	prefix := fmt.Sprintf("test_message_channel_%d", root.ID)
+	assert.Nil(t, root.PublishExpvar(prefix))
+	assert.NotNil(t, root.PublishExpvar(prefix))
+
+	counters := make(map[string]*ExpvarChannelCounters)
+	assert.Nil(t, json.Unmarshal([]byte(expvar.Get(prefix).String()), &counters))
+	assert.Equal(t, 2, len(counters))
+	assert.Equal(t, 2, counters[fmt.Sprintf("expvar-root#%d", root.ID)].Capacity)
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+}
@@ -0,0 +1,109 @@
+package message_channel
+
+import (
+	"sync"
+	"time"
+)
+
+// DebugSample 调试采样器采到的一条消息
+type DebugSample[Message any] struct {
+
+	// 消息所在的信道
+	ChannelID   uint64
+	ChannelName string
+
+	// 消息放入信道的时间和分配到的偏移量
+	At     time.Time
+	Offset uint64
+
+	// 经过Redact脱敏之后的消息
+	Message Message
+
+	// 上一次采样到现在因为超过了采样的速率而没有被记录的消息的数量
+	Skipped uint64
+}
+
+// DebugLogger 记录采样到的消息，在发送方的协程中被调用，需要尽快返回
+type DebugLogger[Message any] func(sample DebugSample[Message])
+
+// Redactor 记录之前对消息脱敏，比如去掉手机号、token之类的敏感字段，返回的消息只用于记录，不会影响信道中流转的消息
+type Redactor[Message any] func(message Message) Message
+
+// DebugSamplerOptions 调试采样器的选项，按照限制的速率记录一部分流经信道的消息，
+// 这样排查线上问题的时候不需要再挂一个完整的消费函数，也不会因为每条消息都打日志把日志刷爆
+type DebugSamplerOptions[Message any] struct {
+
+	// 每秒最多记录多少条消息，超过的消息只计数，小于1时按照1处理
+	Rate int
+
+	// 记录采样到的消息
+	Logger DebugLogger[Message]
+
+	// 记录之前对消息脱敏，为nil时原样记录
+	Redact Redactor[Message]
+}
+
+// debugSampler 按照一秒一个窗口限制采样的速率
+type debugSampler[Message any] struct {
+	options *DebugSamplerOptions[Message]
+	clock   Clock
+
+	lock        *sync.Mutex
+	windowStart time.Time
+	sampled     int
+	skipped     uint64
+}
+
+func newDebugSampler[Message any](options *DebugSamplerOptions[Message], clock Clock) *debugSampler[Message] {
+	return &debugSampler[Message]{
+		options: options,
+		clock:   clock,
+		lock:    &sync.Mutex{},
+	}
+}
+
+// 判断当前这条消息是否要被记录，要记录时同时返回上一次采样之后跳过了多少条消息
+func (x *debugSampler[Message]) allow(now time.Time) (bool, uint64) {
+	rate := x.options.Rate
+	if rate < 1 {
+		rate = 1
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if now.Sub(x.windowStart) >= time.Second {
+		x.windowStart = now
+		x.sampled = 0
+	}
+	if x.sampled >= rate {
+		x.skipped++
+		return false, 0
+	}
+	x.sampled++
+	skipped := x.skipped
+	x.skipped = 0
+	return true, skipped
+}
+
+// 消息放入信道之后按照速率采样记录
+func (x *Channel[Message]) sampleDebug(envelope envelope[Message]) {
+	if x.debugSampler == nil || x.debugSampler.options.Logger == nil {
+		return
+	}
+	now := x.clock.Now()
+	ok, skipped := x.debugSampler.allow(now)
+	if !ok {
+		return
+	}
+	message := envelope.message
+	if x.debugSampler.options.Redact != nil {
+		message = x.debugSampler.options.Redact(message)
+	}
+	x.debugSampler.options.Logger(DebugSample[Message]{
+		ChannelID:   x.ID,
+		ChannelName: x.options.Name,
+		At:          now,
+		Offset:      envelope.offset,
+		Message:     message,
+		Skipped:     skipped,
+	})
+}
@@ -0,0 +1,48 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannel_DebugSampler(t *testing.T) {
+	samples := make([]DebugSample[string], 0)
+	channel := NewChannel[string](NewChannelOptions[string]().WithName("orders").WithPullMode().WithChannelBuffSize(10).WithDebugSampler(2, func(sample DebugSample[string]) {
+		samples = append(samples, sample)
+	}, func(message string) string {
+		return strings.Repeat("*", len(message))
+	}))
+	for _, message := range []string{"alice", "bob", "carol", "dave"} {
+		assert.Nil(t, channel.Send(context.Background(), message))
+	}
+
+	// 一秒之内只记录前两条，记录的是脱敏之后的消息，信道中的消息不受影响
+	assert.Len(t, samples, 2)
+	assert.Equal(t, "*****", samples[0].Message)
+	assert.Equal(t, "***", samples[1].Message)
+	assert.Equal(t, "orders", samples[0].ChannelName)
+	assert.Equal(t, channel.ID, samples[0].ChannelID)
+	assert.Equal(t, uint64(1), samples[0].Offset)
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", message)
+}
+
+func TestDebugSampler_Window(t *testing.T) {
+	sampler := newDebugSampler[int](&DebugSamplerOptions[int]{Rate: 1}, SystemClock())
+	start := time.Now()
+	ok, _ := sampler.allow(start)
+	assert.True(t, ok)
+	ok, _ = sampler.allow(start.Add(time.Millisecond))
+	assert.False(t, ok)
+	ok, _ = sampler.allow(start.Add(time.Millisecond * 2))
+	assert.False(t, ok)
+
+	// 下一个窗口的第一条消息带上之前跳过的数量
+	ok, skipped := sampler.allow(start.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), skipped)
+}
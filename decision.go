@@ -0,0 +1,86 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// DropReasonDeadLetter 消费函数判定消息为死信，但是没有配置死信信道或者发送到死信信道失败了
+const DropReasonDeadLetter DropReason = "dead_letter"
+
+// DropReasonConsumerError ContextConsumerFunc或者AsyncConsumerFunc报告了错误，这条消息没有被处理成功，不会写入Sinks也不会转发给父信道
+const DropReasonConsumerError DropReason = "consumer_error"
+
+// DefaultMaxRetries 没有配置MaxRetries时一条消息最多重试的次数
+const DefaultMaxRetries = 3
+
+// Decision 消费函数处理完一条消息之后告诉信道接下来怎么处理这条消息，这样复杂的处理逻辑都集中在消费函数中，由信道统一执行
+type Decision interface {
+	isDecision()
+}
+
+// Ack 消息已经处理完了
+type Ack struct{}
+
+// Retry 等待After之后重新把这条消息交给消费函数处理
+// 重试是在处理消息的协程中原地进行的，后面的消息会排在这条消息的后面，所以消息的顺序不会乱，重试次数超过MaxRetries之后按照DeadLetter处理
+type Retry struct {
+	After time.Duration
+}
+
+// DeadLetter 把消息发送到死信信道，没有配置死信信道时丢弃
+type DeadLetter struct{}
+
+// StopChannel 这条消息已经处理完了，但是信道需要立即停止，相当于调用了Abort
+type StopChannel struct{}
+
+func (Ack) isDecision()         {}
+func (Retry) isDecision()       {}
+func (DeadLetter) isDecision()  {}
+func (StopChannel) isDecision() {}
+
+// 消费函数报告了错误，按照DropReasonConsumerError丢弃这条消息，只在信道内部使用
+type consumeFailed struct{}
+
+func (consumeFailed) isDecision() {}
+
+// DecisionConsumerFunc 返回处理决定的消费函数，ctx和ContextConsumerFunc的一样，返回nil时按照Ack处理
+type DecisionConsumerFunc[Message any] func(ctx context.Context, index int, message Message) Decision
+
+// 调用配置的消费函数，各种不同的消费函数都统一成返回处理决定的形式
+func (x *Channel[Message]) invokeConsumer(ctx context.Context, index int, message Message) Decision {
+	consumer := x.acquireConsumer()
+	defer consumer.active.Add(-1)
+	switch {
+	case consumer.async != nil:
+		if err := invokeAsync(ctx, consumer.async, index, message); err != nil {
+			x.consumerFailed(err, message)
+			return consumeFailed{}
+		}
+	case consumer.decision != nil:
+		if decision := consumer.decision(ctx, index, message); decision != nil {
+			return decision
+		}
+	case consumer.context != nil:
+		if err := consumer.context(ctx, index, message); err != nil {
+			x.consumerFailed(err, message)
+			return consumeFailed{}
+		}
+	case consumer.channel != nil:
+		consumer.channel(index, message)
+	}
+	return Ack{}
+}
+
+// 把消息发送到死信信道
+func (x *Channel[Message]) deadLetter(message Message) {
+	x.stats.deadLettered.Add(1)
+	if x.options.DeadLetterChannel == nil || x.options.DeadLetterChannel.Send(context.Background(), message) != nil {
+		x.drop(DropReasonDeadLetter, message)
+	}
+}
+
+// 重试之前等待一段时间，等待期间信道被Abort或者强制关闭时返回false
+func (x *Channel[Message]) waitRetry(after time.Duration) bool {
+	return sleepContext(x.consumeCtx, x.clock, after)
+}
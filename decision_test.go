@@ -0,0 +1,55 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_DecisionConsumerFunc(t *testing.T) {
+	deadLetters := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	attempts := make(map[int]int)
+	dropped := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithMaxRetries(2).WithDeadLetterChannel(deadLetters).WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		attempts[message]++
+		switch message {
+		case 2:
+			if attempts[message] == 1 {
+				return Retry{After: time.Millisecond}
+			}
+		case 3:
+			return DeadLetter{}
+		case 4:
+			return Retry{}
+		case 5:
+			return StopChannel{}
+		}
+		return Ack{}
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonAborted, reason)
+		dropped = append(dropped, message)
+	}))
+	assert.Nil(t, channel.Pause())
+	for i := 1; i <= 6; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Nil(t, channel.Resume())
+	channel.ReceiverWait(context.Background())
+
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1, 4: 3, 5: 1}, attempts)
+	assert.Equal(t, []int{6}, dropped)
+	stats := channel.Stats()
+	assert.Equal(t, uint64(3), stats.Retries)
+	assert.Equal(t, uint64(2), stats.DeadLettered)
+	assert.True(t, channel.IsAborted())
+
+	for _, expected := range []int{3, 4} {
+		message, err := deadLetters.Receive(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, message)
+	}
+	go deadLetters.SenderWaitAndClose()
+	_, err := deadLetters.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
@@ -0,0 +1,98 @@
+package message_channel
+
+import (
+	"sync"
+	"time"
+)
+
+// DepthSample 一次采样得到的缓存深度
+type DepthSample struct {
+
+	// 采样的时间
+	Time time.Time `json:"time"`
+
+	// 采样时缓存中还没有被消费的消息的数量
+	Depth int `json:"depth"`
+}
+
+// DepthSamplerOptions 缓存深度采样的选项
+type DepthSamplerOptions struct {
+
+	// 采样的间隔
+	Interval time.Duration
+
+	// 最多保留最近的多少个样本
+	Size int
+}
+
+// depthSampler 在后台按照固定的间隔采样缓存深度，样本保存在一个环形缓冲区中，这样两次抓取指标之间的尖峰也能被看到
+type depthSampler struct {
+	lock    *sync.Mutex
+	samples []DepthSample
+
+	// 下一个样本写入的位置
+	next int
+
+	// 环形缓冲区是否已经写满过
+	full bool
+}
+
+// 启动采样的协程，信道关闭之后采样也会停止
+// spawn: 启动采样的协程的方法，这样采样的协程也带着信道的标签
+func startDepthSampler(options *DepthSamplerOptions, clock Clock, depth func() int, done <-chan struct{}, spawn func(role string, f func())) *depthSampler {
+	size := options.Size
+	if size <= 0 {
+		size = 1
+	}
+	x := &depthSampler{
+		lock:    &sync.Mutex{},
+		samples: make([]DepthSample, size),
+	}
+	spawn(goroutineRoleSampler, func() {
+		ticker := clock.NewTicker(options.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C():
+				x.record(DepthSample{Time: now, Depth: depth()})
+			case <-done:
+				return
+			}
+		}
+	})
+	return x
+}
+
+func (x *depthSampler) record(sample DepthSample) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.samples[x.next] = sample
+	x.next++
+	if x.next == len(x.samples) {
+		x.next = 0
+		x.full = true
+	}
+}
+
+// 按照时间从早到晚返回所有的样本
+func (x *depthSampler) history() []DepthSample {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if !x.full {
+		history := make([]DepthSample, x.next)
+		copy(history, x.samples[:x.next])
+		return history
+	}
+	history := make([]DepthSample, 0, len(x.samples))
+	history = append(history, x.samples[x.next:]...)
+	history = append(history, x.samples[:x.next]...)
+	return history
+}
+
+// DepthHistory 获取最近采样到的缓存深度的时间序列，按照时间从早到晚排列，没有开启采样时返回nil
+func (x *Channel[Message]) DepthHistory() []DepthSample {
+	if x.depthSampler == nil {
+		return nil
+	}
+	return x.depthSampler.history()
+}
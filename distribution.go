@@ -0,0 +1,620 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DropReasonNoDistributionTarget 分发模式的信道上还没有任何可以接收消息的分发子信道
+const DropReasonNoDistributionTarget DropReason = "no_distribution_target"
+
+// DistributionStrategy 分发模式下决定每条消息交给哪一个分发子信道处理
+type DistributionStrategy[Message any] interface {
+
+	// Pick 从targets中选出处理这条消息的子信道，返回它在targets中的下标，targets不会为空
+	// 同一个信道上的Pick可能会被并发调用，实现需要自己保证并发安全
+	Pick(message Message, targets []*Channel[Message]) int
+}
+
+// 分发前需要做一些可能会等待的准备工作的分发策略，准备的时候不持有分发子信道列表的锁，这样等待期间仍然可以增加或者移除分发子信道
+type distributionPreparer[Message any] interface {
+
+	// 按照这一组分发子信道做好准备，ctx结束时不再等待
+	prepare(ctx context.Context, targets []*Channel[Message])
+}
+
+// distributor 分发模式的信道上的分发子信道，分发子信道自己消费消息，不会把消息转发回来
+type distributor[Message any] struct {
+	strategy DistributionStrategy[Message]
+
+	// 分发子信道的列表，修改的时候整个替换掉，这样读取的一方拿到的切片不会再被修改
+	// 分发消息的整个过程都持有读锁，这样移除子信道的时候不会有消息正在发给它
+	lock    *sync.RWMutex
+	targets []*Channel[Message]
+}
+
+func newDistributor[Message any](strategy DistributionStrategy[Message]) *distributor[Message] {
+	if strategy == nil {
+		strategy = WeightedRoundRobin[Message]()
+	}
+	return &distributor[Message]{
+		strategy: strategy,
+		lock:     &sync.RWMutex{},
+	}
+}
+
+func (x *distributor[Message]) snapshot() []*Channel[Message] {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.targets
+}
+
+func (x *distributor[Message]) add(target *Channel[Message]) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	targets := make([]*Channel[Message], 0, len(x.targets)+1)
+	targets = append(targets, x.targets...)
+	x.targets = append(targets, target)
+}
+
+func (x *distributor[Message]) remove(target *Channel[Message]) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	targets := make([]*Channel[Message], 0, len(x.targets))
+	for _, t := range x.targets {
+		if t != target {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == len(x.targets) {
+		return false
+	}
+	x.targets = targets
+	return true
+}
+
+// MakeDistributionChild 在分发模式的信道上创建一个分发子信道，分发子信道使用自己的选项来消费父信道分发给它的消息
+// 运行中也可以随时增加分发子信道，父信道关闭时会在处理完自己缓存中的消息之后关闭所有的分发子信道并等待它们处理完
+// 没有通过WithDistribution开启分发模式的信道上调用会panic
+func (x *Channel[Message]) MakeDistributionChild(options *ChannelOptions[Message]) *Channel[Message] {
+	if x.distributor == nil {
+		panic("message channel: MakeDistributionChild called on a channel without WithDistribution")
+	}
+	if options.ErrorListener == nil {
+		options.ErrorListener = x.options.ErrorListener
+	}
+	if options.Clock == nil {
+		options.Clock = x.options.Clock
+	}
+	if options.LeakTracker == nil {
+		options.LeakTracker = x.options.LeakTracker
+	}
+	if options.GoroutineRegistry == nil {
+		options.GoroutineRegistry = x.options.GoroutineRegistry
+	}
+	if options.LatencyBudgetOptions == nil {
+		options.LatencyBudgetOptions = x.options.LatencyBudgetOptions
+	}
+	var siblings func() []*Channel[Message]
+	if x.options.WorkStealing {
+		siblings = x.distributor.snapshot
+	}
+	child := newChannel[Message](options, nil, siblings)
+	child.depth = x.depth + 1
+	x.distributor.add(child)
+	return child
+}
+
+// RemoveDistributionChild 在运行中移除一个分发子信道，之后的消息不会再分给它，它处理完缓存中剩余的消息之后关闭，关闭完成之后返回
+// child不是当前信道的分发子信道时返回ErrNotDistributionChild
+func (x *Channel[Message]) RemoveDistributionChild(child *Channel[Message]) error {
+	if x.distributor == nil || !x.distributor.remove(child) {
+		return ErrNotDistributionChild
+	}
+	child.SenderWaitAndClose()
+	return nil
+}
+
+// DistributionChildren 获取分发模式的信道上所有的分发子信道，按照创建的顺序排列
+func (x *Channel[Message]) DistributionChildren() []*Channel[Message] {
+	if x.distributor == nil {
+		return nil
+	}
+	targets := x.distributor.snapshot()
+	children := make([]*Channel[Message], len(targets))
+	copy(children, targets)
+	return children
+}
+
+// 把一条消息交给分发策略选出的子信道，子信道的截止时间等元数据会一起带过去
+func (x *Channel[Message]) distribute(envelope envelope[Message]) {
+	if preparer, ok := x.distributor.strategy.(distributionPreparer[Message]); ok {
+
+		// 准备期间分发子信道发生了变化的话按照新的分发子信道重新准备
+		for {
+			targets := x.distributor.snapshot()
+			preparer.prepare(x.consumeCtx, targets)
+			x.distributor.lock.RLock()
+			if sameMembers(targets, x.distributor.targets) {
+				break
+			}
+			x.distributor.lock.RUnlock()
+		}
+	} else {
+		x.distributor.lock.RLock()
+	}
+	defer x.distributor.lock.RUnlock()
+
+	targets := x.distributor.targets
+	if len(targets) == 0 {
+		x.drop(DropReasonNoDistributionTarget, envelope.message)
+		return
+	}
+	target := targets[x.distributor.strategy.Pick(envelope.message, targets)]
+	if err := target.checkSendable(); err != nil {
+		x.reportError(ErrorOpDistribute, err)
+		x.drop(DropReasonNoDistributionTarget, envelope.message)
+		return
+	}
+	envelope.accumulated = envelope.latency(x.clock.Now())
+	if err := target.sendEnvelope(x.consumeCtx, envelope); err != nil {
+		x.reportError(ErrorOpDistribute, err)
+		x.drop(DropReasonAborted, envelope.message)
+	}
+}
+
+// 父信道处理完自己的消息之后关闭所有的分发子信道，它们都处理完之后父信道才算处理完
+func (x *Channel[Message]) closeDistributionChildren() {
+	if x.distributor == nil {
+		return
+	}
+	for _, target := range x.distributor.snapshot() {
+		target.SenderWaitAndClose()
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// SetWeight 修改信道作为分发子信道时的权重，运行中修改之后立即对后续的消息生效，小于1的权重按照1处理
+func (x *Channel[Message]) SetWeight(weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	x.weight.Store(int64(weight))
+}
+
+// Weight 信道作为分发子信道时的权重
+func (x *Channel[Message]) Weight() int {
+	return int(x.weight.Load())
+}
+
+// weightedRoundRobin 平滑的加权轮询，权重越大的子信道分到的消息越多，同时同一个子信道的消息尽量不会连续扎堆
+type weightedRoundRobin[Message any] struct {
+	lock *sync.Mutex
+
+	// 每个子信道当前的累计权重，按照信道ID记录，这样增加子信道之后已有的子信道的进度不会乱掉
+	current map[uint64]int64
+}
+
+// WeightedRoundRobin 按照分发子信道的权重轮询分发，权重都相同时就是普通的轮询，分发模式默认使用的策略
+func WeightedRoundRobin[Message any]() DistributionStrategy[Message] {
+	return &weightedRoundRobin[Message]{
+		lock:    &sync.Mutex{},
+		current: make(map[uint64]int64),
+	}
+}
+
+func (x *weightedRoundRobin[Message]) Pick(message Message, targets []*Channel[Message]) int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	// 每一轮所有的子信道都加上自己的权重，选出累计权重最大的一个，被选中的再减去总权重
+	best := 0
+	var total int64
+	for i, target := range targets {
+		weight := target.weight.Load()
+		total += weight
+		x.current[target.ID] += weight
+		if x.current[target.ID] > x.current[targets[best].ID] {
+			best = i
+		}
+	}
+	x.current[targets[best].ID] -= total
+	return best
+}
+
+// 给信道的权重设置初始值
+func newWeight(weight int) *atomic.Int64 {
+	if weight < 1 {
+		weight = 1
+	}
+	w := &atomic.Int64{}
+	w.Store(int64(weight))
+	return w
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// leastLoaded 每条消息都交给缓存中积压的消息最少的子信道
+type leastLoaded[Message any] struct {
+
+	// 积压相同的子信道之间轮流选择，否则积压都为0的时候消息总是落在第一个子信道上
+	next *atomic.Uint64
+}
+
+// LeastLoaded 把每条消息交给当前缓存深度最小的分发子信道，消费快的子信道自然就会分到更多的消息，适合各个消费者处理速度不一样的场景
+func LeastLoaded[Message any]() DistributionStrategy[Message] {
+	return &leastLoaded[Message]{
+		next: &atomic.Uint64{},
+	}
+}
+
+func (x *leastLoaded[Message]) Pick(message Message, targets []*Channel[Message]) int {
+	start := int(x.next.Add(1) % uint64(len(targets)))
+	best, bestDepth := start, targets[start].buffer.len()
+	for i := 1; i < len(targets) && bestDepth > 0; i++ {
+		index := (start + i) % len(targets)
+		if depth := targets[index].buffer.len(); depth < bestDepth {
+			best, bestDepth = index, depth
+		}
+	}
+	return best
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// KeyFunc 从消息中取出用来分区的键
+type KeyFunc[Message any] func(message Message) string
+
+// partitionByKey 按照键的哈希值把消息固定的分到一个子信道上
+type partitionByKey[Message any] struct {
+	key KeyFunc[Message]
+}
+
+// PartitionByKey 按照键的哈希值分区，分发子信道的数量不变时同一个键的消息总是交给同一个子信道，这样同一个键的消息会按顺序处理
+func PartitionByKey[Message any](key KeyFunc[Message]) DistributionStrategy[Message] {
+	return &partitionByKey[Message]{
+		key: key,
+	}
+}
+
+func (x *partitionByKey[Message]) Pick(message Message, targets []*Channel[Message]) int {
+	return int(hashKey(x.key(message)) % uint64(len(targets)))
+}
+
+// 计算键的哈希值，fnv对于只有结尾几个字符不同的键算出来的高位很接近，再打散一下，这样在哈希环上才能分布均匀
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// DefaultStealInterval 开启工作窃取的分发子信道空闲时检查兄弟信道有没有积压的间隔
+const DefaultStealInterval = 10 * time.Millisecond
+
+// 从缓存中取出下一条消息，缓存已经关闭并且取完时ok为false，ctx被取消时返回ctx的错误
+// 开启了工作窃取时自己的缓存为空就去兄弟信道上拿，自己的缓存关闭之后就不再窃取了，兄弟信道上剩余的消息由它们自己处理
+func (x *Channel[Message]) takeFromBuffer(ctx context.Context) (envelope[Message], bool, error) {
+	if x.siblings == nil {
+		message, ok, err := x.buffer.take(ctx)
+		if ok {
+			x.untrackPending(message)
+		}
+		return message, ok, err
+	}
+	for {
+		message, ok, closed := x.buffer.tryTake()
+		if ok || closed {
+			if ok {
+				x.untrackPending(message)
+			}
+			return message, ok, nil
+		}
+		if message, ok := x.steal(); ok {
+			return message, true, nil
+		}
+		waitCtx, cancelFunc := context.WithTimeout(ctx, DefaultStealInterval)
+		message, ok, err := x.buffer.take(waitCtx)
+		cancelFunc()
+		if err == nil {
+			if ok {
+				x.untrackPending(message)
+			}
+			return message, ok, nil
+		}
+		if ctx.Err() != nil {
+			return message, false, ctx.Err()
+		}
+	}
+}
+
+// 从积压最多的兄弟信道上拿一条消息，暂停中的兄弟信道上的消息不能动
+func (x *Channel[Message]) steal() (envelope[Message], bool) {
+	var victim *Channel[Message]
+	victimDepth := 0
+	for _, sibling := range x.siblings() {
+		if sibling == x || sibling.State() == StatePaused {
+			continue
+		}
+		if depth := sibling.buffer.len(); depth > victimDepth {
+			victim, victimDepth = sibling, depth
+		}
+	}
+	if victim == nil {
+		var zero envelope[Message]
+		return zero, false
+	}
+	message, ok, _ := victim.buffer.tryTake()
+	if ok {
+		victim.untrackPending(message)
+		x.stats.stolen.Add(1)
+	}
+	return message, ok
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// stickyKey 键第一次出现时分给积压最少的子信道，之后同一个键的消息都交给这个子信道，一段时间没有再出现的键会被淘汰
+type stickyKey[Message any] struct {
+	key KeyFunc[Message]
+	ttl time.Duration
+
+	lock        *sync.Mutex
+	assignments map[string]*stickyAssignment
+	lastSweep   time.Time
+}
+
+type stickyAssignment struct {
+
+	// 分到的子信道的ID，按照ID记录，这样子信道的列表变化之后已有的分配不会错位
+	channelID uint64
+
+	// 最近一次出现的时间
+	lastSeen time.Time
+}
+
+// StickyKey 按照键把消息粘在同一个分发子信道上，和PartitionByKey不同的是不依赖子信道的数量，增加子信道之后已有的键不会被打乱
+// 键第一次出现时交给当前积压最少的子信道，超过ttl没有再出现的键会被淘汰，下次出现时重新分配，ttl小于等于0时永远不淘汰
+// 分到的子信道已经不在分发子信道中或者已经关闭时也会重新分配
+func StickyKey[Message any](key KeyFunc[Message], ttl time.Duration) DistributionStrategy[Message] {
+	return &stickyKey[Message]{
+		key:         key,
+		ttl:         ttl,
+		lock:        &sync.Mutex{},
+		assignments: make(map[string]*stickyAssignment),
+	}
+}
+
+func (x *stickyKey[Message]) Pick(message Message, targets []*Channel[Message]) int {
+	key := x.key(message)
+	// 分发子信道和父信道使用同一个时钟
+	now := targets[0].clock.Now()
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.sweep(now)
+
+	if assignment, ok := x.assignments[key]; ok {
+		for i, target := range targets {
+			if target.ID == assignment.channelID && !target.IsClosed() {
+				assignment.lastSeen = now
+				return i
+			}
+		}
+	}
+
+	best := 0
+	for i, target := range targets {
+		if target.buffer.len() < targets[best].buffer.len() {
+			best = i
+		}
+	}
+	x.assignments[key] = &stickyAssignment{channelID: targets[best].ID, lastSeen: now}
+	return best
+}
+
+// 每隔ttl清理一次过期的键，调用方需要持有锁
+func (x *stickyKey[Message]) sweep(now time.Time) {
+	if x.lastSweep.IsZero() {
+		x.lastSweep = now
+	}
+	if x.ttl <= 0 || now.Sub(x.lastSweep) < x.ttl {
+		return
+	}
+	x.lastSweep = now
+	for key, assignment := range x.assignments {
+		if now.Sub(assignment.lastSeen) >= x.ttl {
+			delete(x.assignments, key)
+		}
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// DefaultConsistentHashReplicas 没有配置Replicas时每个分发子信道在哈希环上的虚拟节点的数量
+const DefaultConsistentHashReplicas = 100
+
+// ConsistentHashOptions 一致性哈希分发的选项
+type ConsistentHashOptions[Message any] struct {
+
+	// 从消息中取出用来哈希的键
+	Key KeyFunc[Message]
+
+	// 每个分发子信道在哈希环上的虚拟节点的数量，越多键分布的越均匀，为0时使用DefaultConsistentHashReplicas
+	Replicas int
+
+	// 分发子信道变化之后，在按照新的哈希环分发之前，对每一个可能失去了键的子信道调用，返回之后才开始按照新的哈希环分发
+	// 为nil时等待子信道缓存中积压的消息和正在处理的消息都处理完，这样被迁移的键的消息在迁移前后仍然是按顺序处理的
+	DrainMoved func(from *Channel[Message])
+
+	// 开始按照新的哈希环分发时的回调
+	OnRebalance func(targets []*Channel[Message])
+}
+
+// consistentHash 按照键在哈希环上的位置分发，增加或者移除子信道时只有一小部分键会换到别的子信道上
+type consistentHash[Message any] struct {
+	options  *ConsistentHashOptions[Message]
+	replicas int
+
+	lock *sync.Mutex
+
+	// 哈希环上按照哈希值排序的虚拟节点，以及每个虚拟节点对应的子信道的ID
+	points []uint64
+	owners []uint64
+
+	// 构建哈希环时的子信道，用来判断子信道是否发生了变化
+	members []*Channel[Message]
+}
+
+// ConsistentHash 按照键的一致性哈希分发，运行中通过MakeDistributionChild或者RemoveDistributionChild增加或者移除子信道时只有少量的键会被迁移
+// 有键被迁移时会先等失去键的子信道把积压的消息处理完再往新的子信道上分发，这期间父信道会暂停分发
+func ConsistentHash[Message any](options *ConsistentHashOptions[Message]) DistributionStrategy[Message] {
+	replicas := options.Replicas
+	if replicas <= 0 {
+		replicas = DefaultConsistentHashReplicas
+	}
+	return &consistentHash[Message]{
+		options:  options,
+		replicas: replicas,
+		lock:     &sync.Mutex{},
+	}
+}
+
+// Pick 在信道之外直接调用时子信道发生了变化会直接换成新的哈希环，不会等待失去键的子信道处理完积压的消息
+func (x *consistentHash[Message]) Pick(message Message, targets []*Channel[Message]) int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if !sameMembers(x.members, targets) {
+		x.rebuild(targets)
+	}
+
+	hash := hashKey(x.options.Key(message))
+	i := sort.Search(len(x.points), func(i int) bool {
+		return x.points[i] >= hash
+	})
+	if i == len(x.points) {
+		i = 0
+	}
+	owner := x.owners[i]
+	for index, target := range targets {
+		if target.ID == owner {
+			return index
+		}
+	}
+	return 0
+}
+
+// 子信道发生了变化，先让可能失去键的子信道处理完积压的消息，再换成新的哈希环
+// 等待的时候不持有哈希环的锁，多个工作协程同时发现变化时都会等待，只有第一个等完的会换成新的哈希环
+func (x *consistentHash[Message]) prepare(ctx context.Context, targets []*Channel[Message]) {
+	x.lock.Lock()
+	if sameMembers(x.members, targets) {
+		x.lock.Unlock()
+		return
+	}
+	moved := x.moved(targets)
+	x.lock.Unlock()
+
+	drain := x.options.DrainMoved
+	if drain == nil {
+		drain = func(from *Channel[Message]) {
+			waitIdle[Message](ctx, from)
+		}
+	}
+	for _, member := range moved {
+		drain(member)
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if !sameMembers(x.members, targets) {
+		x.rebuild(targets)
+	}
+}
+
+// 换成targets之后可能会失去键的子信道
+// 有子信道被移除时只有被移除的子信道会失去键，有新的子信道加入时已有的子信道都可能会失去键
+func (x *consistentHash[Message]) moved(targets []*Channel[Message]) []*Channel[Message] {
+	added := false
+	for _, target := range targets {
+		if !containsChannel(x.members, target) {
+			added = true
+		}
+	}
+	moved := make([]*Channel[Message], 0)
+	for _, member := range x.members {
+		if added || !containsChannel(targets, member) {
+			moved = append(moved, member)
+		}
+	}
+	return moved
+}
+
+// 按照targets重新构建哈希环
+func (x *consistentHash[Message]) rebuild(targets []*Channel[Message]) {
+	x.points = x.points[:0]
+	x.owners = x.owners[:0]
+	ring := make(map[uint64]uint64, len(targets)*x.replicas)
+	for _, target := range targets {
+		for replica := 0; replica < x.replicas; replica++ {
+			point := hashKey(fmt.Sprintf("%d#%d", target.ID, replica))
+			if _, ok := ring[point]; !ok {
+				ring[point] = target.ID
+				x.points = append(x.points, point)
+			}
+		}
+	}
+	sort.Slice(x.points, func(i, j int) bool {
+		return x.points[i] < x.points[j]
+	})
+	for _, point := range x.points {
+		x.owners = append(x.owners, ring[point])
+	}
+	x.members = targets
+
+	if x.options.OnRebalance != nil {
+		x.options.OnRebalance(targets)
+	}
+}
+
+// 按照信道的时钟轮询等待信道缓存中的消息和正在处理的消息都处理完，信道已经关闭或者ctx结束时直接返回
+func waitIdle[Message any](ctx context.Context, channel *Channel[Message]) {
+	for !channel.IsClosed() && (channel.buffer.len() > 0 || channel.stats.inFlight.Load() > 0) {
+		if !sleepContext(ctx, channel.clock, time.Millisecond) {
+			return
+		}
+	}
+}
+
+func sameMembers[Message any](a, b []*Channel[Message]) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsChannel[Message any](channels []*Channel[Message], channel *Channel[Message]) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_WeightedDistribution(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithDistribution(WeightedRoundRobin[int]()))
+	heavy := parent.MakeDistributionChild(NewChannelOptions[int]().WithChannelBuffSize(100).WithWeight(3).WithChannelConsumerFunc(func(index int, message int) {}))
+	light := parent.MakeDistributionChild(NewChannelOptions[int]().WithChannelBuffSize(100).WithChannelConsumerFunc(func(index int, message int) {}))
+	assert.Equal(t, 3, heavy.Weight())
+	assert.Equal(t, 1, light.Weight())
+	assert.Equal(t, []*Channel[int]{heavy, light}, parent.DistributionChildren())
+
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, parent.Send(context.Background(), i))
+	}
+
+	// 等前面的消息都分发完之后再修改权重
+	for heavy.Stats().Sent+light.Stats().Sent < 8 {
+		time.Sleep(time.Millisecond)
+	}
+	light.SetWeight(3)
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, parent.Send(context.Background(), i))
+	}
+
+	parent.SenderWaitAndClose()
+	assert.True(t, heavy.IsClosed())
+	assert.True(t, light.IsClosed())
+	assert.Equal(t, uint64(6+4), heavy.Stats().Consumed)
+	assert.Equal(t, uint64(2+4), light.Stats().Consumed)
+}
+
+func TestChannel_DistributionWithoutTargets(t *testing.T) {
+	dropped := 0
+	parent := NewChannel[int](NewChannelOptions[int]().WithDistribution(WeightedRoundRobin[int]()).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonNoDistributionTarget, reason)
+		dropped++
+	}))
+	assert.Nil(t, parent.Send(context.Background(), 1))
+	parent.SenderWaitAndClose()
+	assert.Equal(t, 1, dropped)
+}
+
+func TestChannel_LeastLoadedDistribution(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]().WithDistribution(LeastLoaded[int]()))
+
+	// 慢的子信道暂停着，消息会一直积压在它的缓存中
+	slow := parent.MakeDistributionChild(NewChannelOptions[int]().WithChannelBuffSize(100).WithChannelConsumerFunc(func(index int, message int) {}))
+	// 快的子信道没有缓存，深度总是0
+	fast := parent.MakeDistributionChild(NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {}))
+	assert.Nil(t, slow.Pause())
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, parent.Send(context.Background(), i))
+	}
+	for slow.Stats().Sent+fast.Stats().Sent < 20 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(1), slow.Stats().Sent)
+
+	parent.SenderWaitAndClose()
+	assert.Equal(t, uint64(20), slow.Stats().Consumed+fast.Stats().Consumed)
+}
+
+func TestChannel_PartitionByKey(t *testing.T) {
+	parent := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithDistribution(PartitionByKey[string](func(message string) string {
+		return message
+	})))
+	received := make([][]string, 3)
+	for i := range received {
+		i := i
+		parent.MakeDistributionChild(NewChannelOptions[string]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message string) {
+			received[i] = append(received[i], message)
+		}))
+	}
+	for _, message := range []string{"a", "b", "c", "a", "b", "c", "a"} {
+		assert.Nil(t, parent.Send(context.Background(), message))
+	}
+	parent.SenderWaitAndClose()
+
+	// 同一个键的消息都在同一个子信道上
+	owners := make(map[string]int)
+	total := 0
+	for i, messages := range received {
+		for _, message := range messages {
+			if owner, ok := owners[message]; ok {
+				assert.Equal(t, owner, i)
+			}
+			owners[message] = i
+		}
+		total += len(messages)
+	}
+	assert.Equal(t, 3, len(owners))
+	assert.Equal(t, 7, total)
+}
+
+func TestChannel_WorkStealing(t *testing.T) {
+	parent := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithWorkStealing().WithDistribution(PartitionByKey[string](func(message string) string {
+		return message
+	})))
+	consume := func(index int, message string) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	a := parent.MakeDistributionChild(NewChannelOptions[string]().WithChannelBuffSize(100).WithChannelConsumerFunc(consume))
+	b := parent.MakeDistributionChild(NewChannelOptions[string]().WithChannelBuffSize(100).WithChannelConsumerFunc(consume))
+
+	// 所有的消息都是同一个键，都会分到同一个子信道上，另一个子信道只能靠窃取拿到消息
+	for i := 0; i < 40; i++ {
+		assert.Nil(t, parent.Send(context.Background(), "hot"))
+	}
+	parent.SenderWaitAndClose()
+
+	statsA, statsB := a.Stats(), b.Stats()
+	assert.Equal(t, uint64(40), statsA.Sent+statsB.Sent)
+	assert.Equal(t, uint64(40), statsA.Consumed+statsB.Consumed)
+	assert.Greater(t, statsA.Stolen+statsB.Stolen, uint64(0))
+}
+
+func TestChannel_StickyKeyDistribution(t *testing.T) {
+	parent := NewChannel[string](NewChannelOptions[string]().WithDistribution(StickyKey[string](func(message string) string {
+		return message
+	}, time.Minute)))
+	received := make(map[string]map[int]int)
+	lock := &sync.Mutex{}
+	children := 0
+	makeChild := func() {
+		id := children
+		children++
+		parent.MakeDistributionChild(NewChannelOptions[string]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message string) {
+			lock.Lock()
+			defer lock.Unlock()
+			if received[message] == nil {
+				received[message] = make(map[int]int)
+			}
+			received[message][id]++
+		}))
+	}
+	makeChild()
+	for _, message := range []string{"a", "b", "a"} {
+		assert.Nil(t, parent.Send(context.Background(), message))
+	}
+
+	// 增加子信道之后已有的键仍然交给原来的子信道
+	makeChild()
+	for _, message := range []string{"a", "b", "a", "b"} {
+		assert.Nil(t, parent.Send(context.Background(), message))
+	}
+	parent.SenderWaitAndClose()
+
+	assert.Equal(t, map[int]int{0: 4}, received["a"])
+	assert.Equal(t, map[int]int{0: 3}, received["b"])
+}
+
+func TestStickyKey_TTL(t *testing.T) {
+	a := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithPullMode())
+	b := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithPullMode())
+	strategy := StickyKey[string](func(message string) string {
+		return message
+	}, 10*time.Millisecond)
+
+	assert.Equal(t, 0, strategy.Pick("k", []*Channel[string]{a, b}))
+
+	// 没有过期之前即使另一个子信道更空闲也还是原来的子信道
+	assert.Nil(t, a.Send(context.Background(), "x"))
+	assert.Equal(t, 0, strategy.Pick("k", []*Channel[string]{a, b}))
+
+	// 过期之后重新分配给积压最少的子信道
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 1, strategy.Pick("other", []*Channel[string]{a, b}))
+	assert.Equal(t, 1, strategy.Pick("k", []*Channel[string]{a, b}))
+}
+
+func TestChannel_ConsistentHashRepartition(t *testing.T) {
+	type event struct {
+		key string
+		seq int
+	}
+	rebalances := 0
+	parent := NewChannel[event](NewChannelOptions[event]().WithChannelBuffSize(10).WithDistribution(ConsistentHash[event](&ConsistentHashOptions[event]{
+		Key: func(message event) string {
+			return message.key
+		},
+		OnRebalance: func(targets []*Channel[event]) {
+			rebalances++
+		},
+	})))
+
+	lock := &sync.Mutex{}
+	owners := make(map[string]int)
+	moved := 0
+	last := make(map[string]int)
+	created := 0
+	makeChild := func() *Channel[event] {
+		id := created
+		created++
+		return parent.MakeDistributionChild(NewChannelOptions[event]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message event) {
+			time.Sleep(100 * time.Microsecond)
+			lock.Lock()
+			defer lock.Unlock()
+
+			// 键被迁移前后同一个键的消息仍然是按顺序处理的
+			assert.Greater(t, message.seq, last[message.key])
+			last[message.key] = message.seq
+			if owner, ok := owners[message.key]; ok && owner != id {
+				moved++
+			}
+			owners[message.key] = id
+		}))
+	}
+	send := func(seq int) {
+		for i := 0; i < 50; i++ {
+			assert.Nil(t, parent.Send(context.Background(), event{key: fmt.Sprintf("key-%d", i), seq: seq}))
+		}
+	}
+
+	children := []*Channel[event]{makeChild(), makeChild(), makeChild()}
+	send(1)
+	children = append(children, makeChild())
+	send(2)
+	assert.Nil(t, parent.RemoveDistributionChild(children[0]))
+	assert.True(t, children[0].IsClosed())
+	send(3)
+	assert.ErrorIs(t, parent.RemoveDistributionChild(children[0]), ErrNotDistributionChild)
+	parent.SenderWaitAndClose()
+
+	assert.Equal(t, 3, rebalances)
+	assert.Equal(t, 50, len(owners))
+	for _, seq := range last {
+		assert.Equal(t, 3, seq)
+	}
+
+	// 两次变化都只迁移了一小部分键
+	assert.Less(t, moved, 50)
+}
+
+func TestChannel_ConsistentHashPausedChild(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithDistribution(ConsistentHash[int](&ConsistentHashOptions[int]{
+		Key: strconv.Itoa,
+	})))
+	consumed := &atomic.Int64{}
+	options := func() *ChannelOptions[int] {
+		return NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+			consumed.Add(1)
+		})
+	}
+	a := parent.MakeDistributionChild(options())
+	assert.Nil(t, a.Pause())
+	assert.Nil(t, parent.Send(context.Background(), 1))
+	assert.Eventually(t, func() bool {
+		return a.Stats().Depth == 1
+	}, time.Second, time.Millisecond)
+
+	// 新的子信道加入之后父信道等待暂停中的子信道处理完积压的消息，等待期间仍然可以增加和移除分发子信道
+	parent.MakeDistributionChild(options())
+	assert.Nil(t, parent.Send(context.Background(), 2))
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c := parent.MakeDistributionChild(options())
+		assert.Nil(t, parent.RemoveDistributionChild(c))
+		assert.Equal(t, 2, len(parent.DistributionChildren()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("changing distribution children blocked on a paused child")
+	}
+
+	assert.Nil(t, a.Resume())
+	parent.SenderWaitAndClose()
+	assert.Equal(t, int64(2), consumed.Load())
+}
+
+func TestChannel_DistributionSubtree(t *testing.T) {
+	release := make(chan struct{})
+	parent := NewChannel[int](NewChannelOptions[int]().WithName("parent").WithChannelBuffSize(10).WithDistribution(WeightedRoundRobin[int]()))
+	worker := parent.MakeDistributionChild(NewChannelOptions[int]().WithName("worker").WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child"))
+	assert.Nil(t, parent.Send(context.Background(), 1))
+	assert.Eventually(t, func() bool {
+		return worker.Stats().InFlight == 1
+	}, time.Second, time.Millisecond)
+
+	// 分发子信道和普通的子信道一样出现在子树的健康报告、统计信息以及拓扑图中
+	health := parent.Health(context.Background())
+	assert.Equal(t, 3, health.Subtree.Channels)
+	assert.Equal(t, 2, len(health.Children))
+	subtree, err := parent.SubtreeStats(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subtree.Children))
+	assert.Equal(t, 1, subtree.Total.InFlight)
+	topology := parent.TopologyAscii()
+	assert.Contains(t, topology, fmt.Sprintf("worker#%d", worker.ID))
+	assert.Contains(t, topology, fmt.Sprintf("child#%d", child.ID))
+
+	// 关闭时卡在分发子信道上的话也能从错误中看出来
+	child.SenderWaitAndClose()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = parent.SenderWaitAndCloseContext(ctx)
+	var closeErr *CloseError
+	if assert.ErrorAs(t, err, &closeErr) {
+		assert.Empty(t, closeErr.Children)
+		assert.Equal(t, []ChildInfo{{ID: worker.ID, Name: "worker", InFlight: 1}}, closeErr.DistributionChildren)
+	}
+	close(release)
+	parent.ReceiverWait(context.Background())
+	assert.True(t, worker.IsClosed())
+}
@@ -0,0 +1,45 @@
+package message_channel
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ChildInfo 关闭时还在等待的一个子信道的情况
+type ChildInfo struct {
+	ID   uint64
+	Name string
+
+	// 缓存中还没有被消费的消息的数量
+	Pending int
+
+	// 已经取出来还没有处理完的消息的数量
+	InFlight int
+}
+
+// DrainProgressListener 关闭时等待子信道退出的进度，remainingChildren是还没有退出的子信道，按照ID排序，waited是已经等待的时长
+// 每次检查子信道是否都退出了的时候回调一次，这样关闭卡住的时候能看到是哪些子信道拖住了关闭
+type DrainProgressListener func(remainingChildren []ChildInfo, waited time.Duration)
+
+// 等待子信道退出时每次检查调用的函数，配置了OnDrainProgress时报告进度，然后再调用SenderWaitAndClose传入的函数
+func (x *Channel[Message]) drainProgress(start time.Time, f MapRunFunc[Message]) MapRunFunc[Message] {
+	listener := x.options.OnDrainProgress
+	if listener == nil {
+		return f
+	}
+	return func(ctx context.Context, m map[uint64]*Channel[Message]) error {
+		remaining := make([]ChildInfo, 0, len(m))
+		for _, child := range m {
+			remaining = append(remaining, child.childInfo())
+		}
+		sort.Slice(remaining, func(i, j int) bool {
+			return remaining[i].ID < remaining[j].ID
+		})
+		listener(remaining, x.clock.Since(start))
+		if f != nil {
+			return f(ctx, m)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,27 @@
+package message_channel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_OnDrainProgress(t *testing.T) {
+	reports := make(chan []ChildInfo, 100)
+	root := NewChannel[int](NewChannelOptions[int]().WithChildrenWait(ChildrenWaitFixed, time.Millisecond*5, 0).WithOnDrainProgress(func(remainingChildren []ChildInfo, waited time.Duration) {
+		assert.GreaterOrEqual(t, waited, time.Duration(0))
+		reports <- remainingChildren
+	}))
+	idle := root.MakeChildChannel()
+	slow := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("slow"))
+	idle.SenderWaitAndClose()
+
+	closed := make(chan struct{})
+	go func() {
+		root.SenderWaitAndClose()
+		close(closed)
+	}()
+	assert.Equal(t, []ChildInfo{{ID: slow.ID, Name: "slow"}}, <-reports)
+	slow.SenderWaitAndClose()
+	<-closed
+}
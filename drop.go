@@ -0,0 +1,119 @@
+package message_channel
+
+import "sync"
+
+// DropReason 消息被丢弃的原因
+type DropReason string
+
+const (
+
+	// DropReasonBufferFull 缓存满了，按照溢出策略丢弃了消息
+	DropReasonBufferFull DropReason = "buffer_full"
+
+	// DropReasonConsumerPanic 消费函数处理这条消息的时候panic了，这条消息没有被处理完
+	DropReasonConsumerPanic DropReason = "consumer_panic"
+)
+
+// DropListener 消息被丢弃时的回调，所有丢弃消息的策略都会通过这个回调通知出来，这样丢数据的情况总是可以被观察到
+type DropListener[Message any] func(reason DropReason, message Message)
+
+// OverflowPolicy 缓存满的时候Send的处理策略
+type OverflowPolicy int
+
+const (
+
+	// OverflowBlock 阻塞等待缓存中有空位，默认的策略
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest 丢弃正在发送的这条消息
+	OverflowDropNewest
+
+	// OverflowDropOldest 丢弃缓存中最早的一条消息给正在发送的消息腾出位置
+	OverflowDropOldest
+)
+
+// dropCounters 按照丢弃原因分别统计被丢弃的消息的数量
+type dropCounters struct {
+	lock   *sync.Mutex
+	counts map[DropReason]uint64
+}
+
+func newDropCounters() *dropCounters {
+	return &dropCounters{
+		lock:   &sync.Mutex{},
+		counts: make(map[DropReason]uint64),
+	}
+}
+
+func (x *dropCounters) add(reason DropReason) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.counts[reason]++
+}
+
+// 返回一份计数的拷贝，没有丢弃过消息时返回nil
+func (x *dropCounters) snapshot() map[DropReason]uint64 {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if len(x.counts) == 0 {
+		return nil
+	}
+	counts := make(map[DropReason]uint64, len(x.counts))
+	for reason, count := range x.counts {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// 丢弃一条消息，所有丢弃消息的地方都要经过这里，保证计数和回调不会遗漏
+func (x *Channel[Message]) drop(reason DropReason, message Message) {
+	x.stats.dropped.Add(1)
+	x.stats.drops.add(reason)
+	if x.options.OnDropped != nil {
+		x.options.OnDropped(reason, message)
+	}
+	kind := ErrorEventDropped
+	if reason == DropReasonDeadlineExceeded {
+		kind = ErrorEventExpired
+	}
+	x.publishError(ErrorEvent{Kind: kind, Reason: reason, Message: message})
+}
+
+// 按照溢出策略发送消息，缓存满了的时候不会阻塞，缓存已经关闭时返回ErrChannelClosed
+func (x *Channel[Message]) sendOrDrop(envelope envelope[Message]) error {
+	for {
+		if x.buffer.tryPut(envelope) {
+			x.enqueued(envelope)
+			return nil
+		}
+		if x.buffer.isClosed() {
+			return ErrChannelClosed
+		}
+		x.bufferFull()
+		if x.tuned().OverflowPolicy != OverflowDropOldest {
+			x.drop(DropReasonBufferFull, envelope.message)
+			return nil
+		}
+
+		// 取出最早的一条消息丢掉之后再重试，缓存中已经没有消息可以丢的时候（比如没有缓存的信道）就只能丢弃新消息了
+		oldest, ok, _ := x.buffer.tryTake()
+		if !ok {
+			x.drop(DropReasonBufferFull, envelope.message)
+			return nil
+		}
+		x.untrackPending(oldest)
+		if oldest.wakeup {
+			continue
+		}
+
+		// 还没有提交的预留位置直接释放，之后的Commit会失败，已经提交了的按照普通的消息丢弃
+		if slot := oldest.slot; slot != nil {
+			if slot.release() {
+				continue
+			}
+			oldest = slot.envelope
+			x.untrackPending(oldest)
+		}
+		x.drop(DropReasonBufferFull, oldest.message)
+	}
+}
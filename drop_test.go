@@ -0,0 +1,60 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_OverflowPolicy(t *testing.T) {
+	for policy, expected := range map[OverflowPolicy][]int{
+		OverflowDropNewest: {1, 2},
+		OverflowDropOldest: {2, 3},
+	} {
+		dropped := make([]int, 0)
+		channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(2).WithOverflowPolicy(policy).WithOnDropped(func(reason DropReason, message int) {
+			assert.Equal(t, DropReasonBufferFull, reason)
+			dropped = append(dropped, message)
+		}))
+		for i := 1; i <= 3; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		assert.Equal(t, 1, len(dropped))
+
+		received := make([]int, 0)
+		for i := 0; i < 2; i++ {
+			message, err := channel.Receive(context.Background())
+			assert.Nil(t, err)
+			received = append(received, message)
+		}
+		assert.Equal(t, expected, received)
+
+		stats := channel.Stats()
+		assert.Equal(t, uint64(1), stats.Dropped)
+		assert.Equal(t, map[DropReason]uint64{DropReasonBufferFull: 1}, stats.DroppedByReason)
+		assert.Equal(t, uint64(2), stats.Consumed)
+		go func() {
+			_, err := channel.Receive(context.Background())
+			assert.ErrorIs(t, err, ErrChannelClosed)
+		}()
+		channel.SenderWaitAndClose()
+	}
+}
+
+func TestChannel_OnDroppedConsumerPanic(t *testing.T) {
+	dropped := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 2 {
+			panic("boom")
+		}
+	}).WithSupervisor(&SupervisorOptions{MaxRestarts: 1}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonConsumerPanic, reason)
+		dropped = append(dropped, message)
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{2}, dropped)
+	assert.Equal(t, uint64(1), channel.Stats().DroppedByReason[DropReasonConsumerPanic])
+}
@@ -0,0 +1,51 @@
+package message_channel
+
+import "time"
+
+// DropReasonDeadlineExceeded 消息在缓存中等待的时候过了发送方给它设置的截止时间
+const DropReasonDeadlineExceeded DropReason = "deadline_exceeded"
+
+// envelope 缓存中实际存放的是包装了消息的信封，信封上记录了消息在信道中流转时需要的元数据，子信道转发消息时信封会跟着一起转发
+type envelope[Message any] struct {
+	message Message
+
+	// 消息的截止时间，过了截止时间还没有被消费的消息会被丢弃，零值表示没有截止时间
+	deadline time.Time
+
+	// 消息放入当前信道的缓存的时间，开启了QueueLatency或者配置了LatencyBudgetOptions时才会记录，子信道转发给父信道时父信道会重新记录
+	enqueuedAt time.Time
+
+	// 消息在上游的信道中已经停留的时长之和，转发给下一个信道时累加上在当前信道中停留的时长
+	accumulated time.Duration
+
+	// 是否已经超过了延迟预算，已经被标记过的消息下游的信道不会再处理一次
+	overBudget bool
+
+	// 消息放入信道时分配的偏移量，子信道转发给父信道时父信道会重新分配
+	offset uint64
+
+	// 是否是通过ReplayFrom重新投递的历史消息
+	replay bool
+
+	// Ask发出的请求的关联ID，转发到其它信道时保持不变，回复时用它找到等待的Future，为0表示不是请求
+	request uint64
+
+	// 通过Reserve预留的位置，取到之后需要等待它被提交，不是预留的位置时为nil
+	slot *reservedSlot[Message]
+
+	// 不携带消息的唤醒信号，只用来唤醒阻塞在取消息上的协程，取到之后直接跳过
+	wakeup bool
+}
+
+// 消息是否已经过了截止时间
+func (x *envelope[Message]) expired(now time.Time) bool {
+	return !x.deadline.IsZero() && now.After(x.deadline)
+}
+
+// 消息到目前为止在所有经过的信道中累计停留的时长，没有记录放入时间的消息只算上游累计的部分
+func (x *envelope[Message]) latency(now time.Time) time.Duration {
+	if x.enqueuedAt.IsZero() {
+		return x.accumulated
+	}
+	return x.accumulated + now.Sub(x.enqueuedAt)
+}
@@ -0,0 +1,34 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_SendWithDeadline(t *testing.T) {
+	consumed := make([]int, 0)
+	dropped := make([]int, 0)
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		consumed = append(consumed, message)
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonDeadlineExceeded, reason)
+		dropped = append(dropped, message)
+	}))
+	assert.Nil(t, root.Pause())
+
+	// 截止时间随着消息一起从子信道转发到父信道
+	child := root.MakeChildChannel()
+	assert.Nil(t, child.SendWithDeadline(context.Background(), 1, time.Now().Add(time.Millisecond*20)))
+	assert.Nil(t, root.SendWithDeadline(context.Background(), 2, time.Now().Add(time.Hour)))
+	assert.Nil(t, root.Send(context.Background(), 3))
+	child.SenderWaitAndClose()
+
+	time.Sleep(time.Millisecond * 30)
+	assert.Nil(t, root.Resume())
+	root.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, dropped)
+	assert.ElementsMatch(t, []int{2, 3}, consumed)
+	assert.Equal(t, uint64(1), root.Stats().DroppedByReason[DropReasonDeadlineExceeded])
+}
@@ -0,0 +1,75 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrorEventKind 错误事件的种类
+type ErrorEventKind string
+
+const (
+
+	// ErrorEventConsumerError 消费函数返回了错误或者panic了
+	ErrorEventConsumerError ErrorEventKind = "consumer_error"
+
+	// ErrorEventDropped 消息被丢弃了，Reason是丢弃的原因
+	ErrorEventDropped ErrorEventKind = "dropped"
+
+	// ErrorEventExpired 消息过了截止时间还没有被消费，被丢弃了
+	ErrorEventExpired ErrorEventKind = "expired"
+
+	// ErrorEventInternal 信道内部发生了错误，Op是出错的操作，和ErrorListener收到的一样
+	ErrorEventInternal ErrorEventKind = "internal"
+
+	// ErrorEventSilence 超过SilenceTimeout都没有新的消息，Err是ErrSilenceTimeout
+	ErrorEventSilence ErrorEventKind = "silence"
+)
+
+// ErrorEvent 发布到错误输出信道中的结构化的错误事件，这样错误的处理本身也可以用信道搭成流水线，比如按照种类路由、攒批之后告警
+type ErrorEvent struct {
+
+	// 事件发生的时间
+	Time time.Time
+
+	// 发生错误的信道
+	ChannelID   uint64
+	ChannelName string
+
+	Kind ErrorEventKind
+
+	// Kind是ErrorEventDropped或者ErrorEventExpired时消息被丢弃的原因
+	Reason DropReason
+
+	// Kind是ErrorEventInternal时出错的操作，是ErrorOp开头的常量之一
+	Op string
+
+	// 消费函数返回的错误或者信道内部的错误，丢弃消息时为nil
+	Err error
+
+	// 出错的消息，事务模式下整批失败或者信道内部的错误时为nil
+	Message any
+}
+
+// 把错误事件发布到错误输出信道中，错误输出信道的缓存满了时按照它自己的OverflowPolicy处理，已经关闭时事件被忽略
+func (x *Channel[Message]) publishError(event ErrorEvent) {
+	if x.options.ErrorOutput == nil {
+		return
+	}
+	event.Time = x.clock.Now()
+	event.ChannelID = x.ID
+	event.ChannelName = x.options.Name
+	_ = x.options.ErrorOutput.Send(context.Background(), event)
+}
+
+// 消费函数处理消息失败了，message为nil表示不是某一条消息的失败
+func (x *Channel[Message]) consumerFailed(err error, message any) {
+	x.stats.consumerErrors.Add(1)
+	x.publishError(ErrorEvent{Kind: ErrorEventConsumerError, Err: err, Message: message})
+}
+
+// 消费函数panic时的错误
+func consumerPanicError(reason any) error {
+	return fmt.Errorf("message channel: consumer panic: %v", reason)
+}
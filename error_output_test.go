@@ -0,0 +1,74 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_ErrorOutput(t *testing.T) {
+	errorOutput := NewChannel[ErrorEvent](NewChannelOptions[ErrorEvent]().WithPullMode().WithChannelBuffSize(16))
+	channel := NewChannel[int](NewChannelOptions[int]().WithName("orders").WithPullMode().WithChannelBuffSize(2).WithOverflowPolicy(OverflowDropNewest).WithErrorOutput(errorOutput))
+	assert.Nil(t, channel.SendWithDeadline(context.Background(), 1, time.Now().Add(-time.Second)))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, message)
+
+	// 缓存满了之后丢弃的消息
+	for i := 3; i <= 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{3, 4}, drain[int](channel))
+	go errorOutput.SenderWaitAndClose()
+
+	events := drain[ErrorEvent](errorOutput)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, ErrorEventExpired, events[0].Kind)
+		assert.Equal(t, DropReasonDeadlineExceeded, events[0].Reason)
+		assert.Equal(t, 1, events[0].Message)
+		assert.Equal(t, "orders", events[0].ChannelName)
+		assert.Equal(t, channel.ID, events[0].ChannelID)
+		assert.False(t, events[0].Time.IsZero())
+		assert.Equal(t, ErrorEventDropped, events[1].Kind)
+		assert.Equal(t, DropReasonBufferFull, events[1].Reason)
+		assert.Equal(t, 5, events[1].Message)
+	}
+}
+
+func TestChannel_ErrorOutput_ConsumerError(t *testing.T) {
+	errorOutput := NewChannel[ErrorEvent](NewChannelOptions[ErrorEvent]().WithPullMode().WithChannelBuffSize(16))
+	channel := NewChannel[int](NewChannelOptions[int]().WithErrorOutput(errorOutput).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		if message%2 == 0 {
+			return errors.New("even")
+		}
+		return nil
+	}))
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	go errorOutput.SenderWaitAndClose()
+
+	// 失败的消息先报告错误，再按照DropReasonConsumerError丢弃
+	messages := make([]any, 0)
+	dropped := make([]any, 0)
+	for _, event := range drain[ErrorEvent](errorOutput) {
+		switch event.Kind {
+		case ErrorEventConsumerError:
+			assert.EqualError(t, event.Err, "even")
+			messages = append(messages, event.Message)
+		case ErrorEventDropped:
+			assert.Equal(t, DropReasonConsumerError, event.Reason)
+			dropped = append(dropped, event.Message)
+		default:
+			t.Fatalf("unexpected event %v", event.Kind)
+		}
+	}
+	assert.Equal(t, []any{2, 4}, messages)
+	assert.Equal(t, []any{2, 4}, dropped)
+	assert.Equal(t, uint64(2), channel.Stats().ConsumerErrors)
+}
@@ -0,0 +1,84 @@
+package message_channel
+
+import "errors"
+
+// ErrChannelClosed 信道已经被关闭了
+var ErrChannelClosed = errors.New("message channel: channel closed")
+
+// ErrNotPullMode 信道不是拉模式的，消息由信道自己的协程消费，不能再手动拉取
+var ErrNotPullMode = errors.New("message channel: channel is not in pull mode, can not receive manually")
+
+// ErrChannelDraining 信道正在关闭，配置了RejectSendWhileDraining时不再接收新的消息
+var ErrChannelDraining = errors.New("message channel: channel is draining")
+
+// ErrInvalidStateTransition 信道当前的状态不允许进行这个操作
+var ErrInvalidStateTransition = errors.New("message channel: invalid state transition")
+
+// ErrMaxDepthExceeded 子信道的深度超过了MaxDepth
+var ErrMaxDepthExceeded = errors.New("message channel: max topology depth exceeded")
+
+// ErrMaxChildrenExceeded 子信道的数量超过了MaxChildren
+var ErrMaxChildrenExceeded = errors.New("message channel: max children exceeded")
+
+// ErrNotDistributionChild 要移除的信道不是当前信道的分发子信道
+var ErrNotDistributionChild = errors.New("message channel: not a distribution child of this channel")
+
+// ErrNoReply Ask发出的请求经过的信道都已经关闭了，没有人回复这个请求
+var ErrNoReply = errors.New("message channel: request finished without a reply")
+
+// ErrNoStore 信道没有配置Store
+var ErrNoStore = errors.New("message channel: channel has no store")
+
+// ErrorListener 信道内部发生错误时的回调，这些错误没办法通过返回值告诉调用方，不设置的话就会被忽略
+// op: 出错的操作，是ErrorOp开头的常量之一
+type ErrorListener func(op string, err error)
+
+const (
+
+	// ErrorOpAddChild 把子信道登记到父信道上失败了
+	ErrorOpAddChild = "add_child"
+
+	// ErrorOpRemoveChild 子信道关闭时从父信道上移除失败了
+	ErrorOpRemoveChild = "remove_child"
+
+	// ErrorOpDrainChildren 关闭信道时等待子信道退出失败了
+	ErrorOpDrainChildren = "drain_children"
+
+	// ErrorOpForward 子信道把消息转发给父信道失败了，一般是父信道已经关闭了
+	ErrorOpForward = "forward"
+
+	// ErrorOpDistribute 分发模式下把消息交给分发子信道失败了，一般是分发子信道已经关闭了
+	ErrorOpDistribute = "distribute"
+
+	// ErrorOpTransaction 事务模式下一批消息的事务失败了，这一批消息会被回滚之后重试
+	ErrorOpTransaction = "transaction"
+
+	// ErrorOpStore 读写Store失败了
+	ErrorOpStore = "store"
+
+	// ErrorOpRecord Recorder录制消息失败了
+	ErrorOpRecord = "record"
+
+	// ErrorOpAudit Auditor写出审计记录失败了
+	ErrorOpAudit = "audit"
+
+	// ErrorOpSink 写入或者关闭Sink失败了
+	ErrorOpSink = "sink"
+
+	// ErrorOpMux 多路复用时编码或者解码消息失败了
+	ErrorOpMux = "mux"
+
+	// ErrorOpCommit 自动提交通过AckUpTo确认的偏移量失败了
+	ErrorOpCommit = "commit"
+
+	// ErrorOpCheckpoint 检查点的回调返回了错误
+	ErrorOpCheckpoint = "checkpoint"
+)
+
+// 报告信道内部发生的错误
+func (x *Channel[Message]) reportError(op string, err error) {
+	if x.options.ErrorListener != nil {
+		x.options.ErrorListener(op, err)
+	}
+	x.publishError(ErrorEvent{Kind: ErrorEventInternal, Op: op, Err: err})
+}
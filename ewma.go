@@ -0,0 +1,81 @@
+package message_channel
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaLatencyWeight 消费耗时的指数加权移动平均中最新的一次耗时所占的权重
+const ewmaLatencyWeight = 0.1
+
+// ewmaRateWindow 到达速率的指数加权移动平均的时间常数，越早到达的消息的权重按照这个时间常数指数衰减
+const ewmaRateWindow = 10 * time.Second
+
+// latencyEWMA 耗时的指数加权移动平均，用CAS更新，不需要加锁
+type latencyEWMA struct {
+	bits atomic.Uint64
+}
+
+// 记录一次耗时，第一次记录时直接作为平均值
+func (x *latencyEWMA) observe(d time.Duration) {
+	for {
+		old := x.bits.Load()
+		value := float64(d)
+		if old != 0 {
+			value = math.Float64frombits(old)*(1-ewmaLatencyWeight) + value*ewmaLatencyWeight
+		}
+		// 0表示还没有记录过，耗时刚好是0时存一个最小的正数
+		bits := math.Float64bits(value)
+		if bits == 0 {
+			bits = 1
+		}
+		if x.bits.CompareAndSwap(old, bits) {
+			return
+		}
+	}
+}
+
+func (x *latencyEWMA) value() time.Duration {
+	return time.Duration(math.Float64frombits(x.bits.Load()))
+}
+
+// rateEWMA 到达速率的指数加权移动平均，每条消息到达时贡献1/ewmaRateWindow，之后按照时间指数衰减，
+// 这样读取的时候不需要后台的协程定时更新，没有消息到达时速率也会随着时间降下来
+type rateEWMA struct {
+	lock *sync.Mutex
+
+	// 最近一次更新时的速率，单位是每秒的消息数量
+	rate float64
+	last time.Time
+}
+
+func newRateEWMA() *rateEWMA {
+	return &rateEWMA{
+		lock: &sync.Mutex{},
+	}
+}
+
+// 记录一条消息的到达
+func (x *rateEWMA) observe(now time.Time) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.rate = x.decayed(now) + 1/ewmaRateWindow.Seconds()
+	x.last = now
+}
+
+// 当前的速率
+func (x *rateEWMA) value(now time.Time) float64 {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.decayed(now)
+}
+
+func (x *rateEWMA) decayed(now time.Time) float64 {
+	elapsed := now.Sub(x.last)
+	if x.last.IsZero() || elapsed <= 0 {
+		return x.rate
+	}
+	return x.rate * math.Exp(-elapsed.Seconds()/ewmaRateWindow.Seconds())
+}
@@ -0,0 +1,50 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLatencyEWMA(t *testing.T) {
+	ewma := &latencyEWMA{}
+	assert.Equal(t, time.Duration(0), ewma.value())
+
+	// 第一次记录直接作为平均值，之后每次向最新的耗时靠近10%
+	ewma.observe(time.Millisecond * 100)
+	assert.Equal(t, time.Millisecond*100, ewma.value())
+	ewma.observe(time.Millisecond * 200)
+	assert.Equal(t, time.Millisecond*110, ewma.value())
+}
+
+func TestRateEWMA(t *testing.T) {
+	start := time.Unix(1000, 0)
+	ewma := newRateEWMA()
+	assert.Equal(t, float64(0), ewma.value(start))
+
+	// 稳定的每秒100条消息持续足够久之后速率收敛到100
+	now := start
+	for i := 0; i < 100*60; i++ {
+		now = now.Add(time.Millisecond * 10)
+		ewma.observe(now)
+	}
+	assert.InDelta(t, float64(100), ewma.value(now), 1)
+
+	// 没有消息之后过一个时间常数衰减到原来的1/e
+	assert.InDelta(t, 100/2.718281828, ewma.value(now.Add(ewmaRateWindow)), 1)
+}
+
+func TestChannel_EWMA(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond * 5)
+	}))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	stats := channel.Stats()
+	assert.GreaterOrEqual(t, stats.LatencyEWMA, time.Millisecond*5)
+	assert.Greater(t, stats.ArrivalRateEWMA, float64(0))
+}
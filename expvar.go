@@ -0,0 +1,54 @@
+package message_channel
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// ExpvarChannelCounters 通过expvar导出的一个信道的计数
+type ExpvarChannelCounters struct {
+	ID             uint64            `json:"id"`
+	Name           string            `json:"name"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Status         HealthStatus      `json:"status"`
+	Pending        int               `json:"pending"`
+	Capacity       int               `json:"capacity"`
+	Consumed       uint64            `json:"consumed"`
+	ConsumerErrors uint64            `json:"consumer_errors"`
+}
+
+// PublishExpvar 通过标准库的expvar导出当前信道以及所有子孙信道的计数，这样只抓取/debug/vars没有接Prometheus的团队也能看到
+// 导出的变量名就是prefix，值是一个以"名字#ID"为key的对象，每次被读取的时候都会实时统计
+// 同一个prefix只能被导出一次，重复导出时返回错误
+func (x *Channel[Message]) PublishExpvar(prefix string) error {
+	if expvar.Get(prefix) != nil {
+		return fmt.Errorf("message channel: expvar %q already published", prefix)
+	}
+	expvar.Publish(prefix, expvar.Func(func() any {
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
+		defer cancelFunc()
+		counters := make(map[string]*ExpvarChannelCounters)
+		collectExpvarCounters(x.Health(ctx), counters)
+		return counters
+	}))
+	return nil
+}
+
+// 把健康报告展开为每个信道一项
+func collectExpvarCounters(report HealthReport, counters map[string]*ExpvarChannelCounters) {
+	counters[fmt.Sprintf("%s#%d", report.Name, report.ID)] = &ExpvarChannelCounters{
+		ID:             report.ID,
+		Name:           report.Name,
+		Tags:           report.Tags,
+		Status:         report.Self.Status,
+		Pending:        report.Self.Pending,
+		Capacity:       report.Self.Capacity,
+		Consumed:       report.Self.Consumed,
+		ConsumerErrors: report.Self.ConsumerErrors,
+	}
+	for _, child := range report.Children {
+		collectExpvarCounters(child, counters)
+	}
+}
@@ -0,0 +1,177 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DropReasonParentUnavailable 子信道处理完的消息没办法转发给父信道，父信道已经关闭了或者卡住了
+const DropReasonParentUnavailable DropReason = "parent_unavailable"
+
+// ErrParentClosed 子信道转发消息的时候父信道已经关闭了
+var ErrParentClosed = errors.New("message channel: parent channel closed")
+
+// ErrParentStalled 子信道转发消息的时候父信道超过StallTimeout都没能放进去
+var ErrParentStalled = errors.New("message channel: parent channel stalled")
+
+// ForwardPolicy 子信道转发消息时父信道已经关闭或者卡住了的处理策略
+type ForwardPolicy int
+
+const (
+
+	// ForwardReportError 通过ErrorListener报告ErrParentClosed或者ErrParentStalled，然后丢弃这条消息，默认的策略
+	ForwardReportError ForwardPolicy = iota
+
+	// ForwardDrop 直接丢弃这条消息，只通过OnDropped通知，不报告错误
+	ForwardDrop
+
+	// ForwardBufferLocally 父信道卡住时把消息暂存在子信道本地，子信道继续处理自己的消息，父信道恢复之后按顺序补发
+	// 暂存满了或者父信道已经关闭时和ForwardReportError一样处理，子信道关闭时会等暂存的消息补发完，每条最多再等StallTimeout
+	ForwardBufferLocally
+)
+
+// DefaultForwardLocalBuffSize 没有配置LocalBuffSize时ForwardBufferLocally最多暂存的消息的数量
+const DefaultForwardLocalBuffSize = 1024
+
+// ForwardOptions 子信道把消息转发给父信道的选项，用来发现父信道已经关闭或者卡住了，而不是一直阻塞下去
+type ForwardOptions struct {
+
+	// 父信道超过这个时长都放不进去时认为卡住了，为0时一直等待，只有父信道关闭时才会放弃
+	StallTimeout time.Duration
+
+	// 父信道关闭或者卡住时的处理策略
+	Policy ForwardPolicy
+
+	// ForwardBufferLocally最多暂存多少条消息，为0时使用DefaultForwardLocalBuffSize
+	LocalBuffSize int
+}
+
+// forwardBacklog 父信道卡住时暂存在子信道本地的消息
+type forwardBacklog[Message any] struct {
+	lock      *sync.Mutex
+	envelopes []envelope[Message]
+	limit     int
+}
+
+func newForwardBacklog[Message any](options *ForwardOptions) *forwardBacklog[Message] {
+	limit := options.LocalBuffSize
+	if limit <= 0 {
+		limit = DefaultForwardLocalBuffSize
+	}
+	return &forwardBacklog[Message]{
+		lock:  &sync.Mutex{},
+		limit: limit,
+	}
+}
+
+// 把消息转发给父信道并按照策略处理失败，暂存着消息的时候先补发暂存的，保证转发的顺序不乱
+func (x *Channel[Message]) forwardEnvelope(envelope envelope[Message]) {
+	if x.backlog == nil {
+		if err := x.forwardWithTimeout(envelope); err != nil {
+			x.forwardFailed(err, envelope)
+		}
+		return
+	}
+
+	x.backlog.lock.Lock()
+	defer x.backlog.lock.Unlock()
+
+	// 补发的时候不等待，父信道还是放不进去的话新的消息也排到暂存的后面
+	stopped, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	err := x.flushBacklog(stopped)
+	if err == nil {
+		err = x.forwardWithTimeout(envelope)
+	}
+	if errors.Is(err, ErrParentStalled) && len(x.backlog.envelopes) < x.backlog.limit {
+		x.backlog.envelopes = append(x.backlog.envelopes, envelope)
+		return
+	}
+	if err != nil {
+		x.forwardFailed(err, envelope)
+	}
+}
+
+// 按顺序补发暂存的消息，补发失败时返回失败的原因，剩下的消息继续暂存着，调用方需要持有锁
+func (x *Channel[Message]) flushBacklog(ctx context.Context) error {
+	for len(x.backlog.envelopes) > 0 {
+		if err := x.forward(ctx, x.backlog.envelopes[0]); err != nil {
+			return err
+		}
+		x.backlog.envelopes[0] = envelope[Message]{}
+		x.backlog.envelopes = x.backlog.envelopes[1:]
+	}
+	x.backlog.envelopes = nil
+	return nil
+}
+
+// 子信道结束之前补发所有暂存的消息，补发不出去的按照策略处理
+func (x *Channel[Message]) closeBacklog() {
+	if x.backlog == nil {
+		return
+	}
+	x.backlog.lock.Lock()
+	defer x.backlog.lock.Unlock()
+	for _, envelope := range x.backlog.envelopes {
+		if x.aborted.Load() {
+			x.drop(DropReasonAborted, envelope.message)
+			continue
+		}
+		if err := x.forwardWithTimeout(envelope); err != nil {
+			x.forwardFailed(err, envelope)
+		}
+	}
+	x.backlog.envelopes = nil
+}
+
+// 转发一条消息，配置了StallTimeout时最多等这么久
+func (x *Channel[Message]) forwardWithTimeout(envelope envelope[Message]) error {
+	ctx, cancelFunc := context.Background(), context.CancelFunc(func() {})
+	if options := x.options.ForwardOptions; options != nil && options.StallTimeout > 0 {
+		ctx, cancelFunc = withClockTimeout(ctx, x.clock, options.StallTimeout)
+	}
+	defer cancelFunc()
+	return x.forward(ctx, envelope)
+}
+
+// 转发失败时按照策略处理这条消息
+func (x *Channel[Message]) forwardFailed(err error, envelope envelope[Message]) {
+	if options := x.options.ForwardOptions; options == nil || options.Policy != ForwardDrop {
+		x.reportError(ErrorOpForward, err)
+	}
+	x.drop(DropReasonParentUnavailable, envelope.message)
+}
+
+// 把子信道转发过来的消息放入当前信道，当前信道已经关闭时返回ErrParentClosed，ctx结束之前都没能放进去时返回ErrParentStalled，保存到Store失败时返回Store的错误
+func (x *Channel[Message]) acceptForwarded(ctx context.Context, envelope envelope[Message]) error {
+	if x.State() == StateClosed {
+		return ErrParentClosed
+	}
+	envelope = x.stamp(envelope)
+	if err := x.persist(envelope); err != nil {
+		return err
+	}
+	if x.synchronous != nil {
+		x.enqueued(envelope)
+		x.consumeInline(envelope)
+		return nil
+	}
+	if !x.buffer.tryPut(envelope) {
+		if x.buffer.isClosed() {
+			return ErrParentClosed
+		}
+		if ctx.Err() != nil {
+			return ErrParentStalled
+		}
+		if err := x.buffer.put(ctx, envelope); err != nil {
+			if ctx.Err() != nil {
+				return ErrParentStalled
+			}
+			return ErrParentClosed
+		}
+	}
+	x.enqueued(envelope)
+	return nil
+}
@@ -0,0 +1,87 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 父信道没有缓存，消费第一条消息的时候一直卡到release被关闭
+func newStalledParent(release chan struct{}) (*Channel[int], func() []int) {
+	lock := &sync.Mutex{}
+	consumed := make([]int, 0)
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+		lock.Lock()
+		defer lock.Unlock()
+		consumed = append(consumed, message)
+	}))
+	return parent, func() []int {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]int(nil), consumed...)
+	}
+}
+
+func TestChannel_ForwardParentClosed(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]())
+	errs := make(chan error, 1)
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithErrorListener(func(op string, err error) {
+		assert.Equal(t, ErrorOpForward, op)
+		errs <- err
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonParentUnavailable, reason)
+	}))
+
+	// 子信道还没有关闭的时候父信道就已经关闭了
+	parent.closeChannel()
+	parent.ReceiverWait(context.Background())
+
+	assert.Nil(t, child.Send(context.Background(), 1))
+	assert.ErrorIs(t, <-errs, ErrParentClosed)
+	child.SenderWaitAndClose()
+	assert.Equal(t, uint64(1), child.Stats().DroppedByReason[DropReasonParentUnavailable])
+}
+
+func TestChannel_ForwardDrop(t *testing.T) {
+	release := make(chan struct{})
+	parent, consumed := newStalledParent(release)
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10).WithForwardPolicy(ForwardDrop, time.Millisecond*20).WithErrorListener(func(op string, err error) {
+		t.Errorf("unexpected error: %s %v", op, err)
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+
+	// 父信道卡住的时候子信道没有跟着卡住，转发不出去的消息被丢弃了
+	assert.Eventually(t, func() bool {
+		return child.Stats().Dropped == 2
+	}, time.Second, time.Millisecond*5)
+	close(release)
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, consumed())
+}
+
+func TestChannel_ForwardBufferLocally(t *testing.T) {
+	release := make(chan struct{})
+	parent, consumed := newStalledParent(release)
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10).WithForwardPolicy(ForwardBufferLocally, time.Millisecond*20))
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+
+	// 子信道把自己的消息都处理完了，转发不出去的暂存在本地
+	assert.Eventually(t, func() bool {
+		return child.Stats().Consumed == 4 && child.Stats().Depth == 0
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, uint64(0), child.Stats().Dropped)
+
+	// 父信道恢复之后，子信道关闭之前按照顺序补发
+	close(release)
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2, 3, 4}, consumed())
+}
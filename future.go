@@ -0,0 +1,214 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Future 一个还没有到来的回复，消费函数或者下游的某一个阶段通过Reply回复之后被解决，也可能因为超时、取消、发送失败被解决为错误
+type Future[Reply any] struct {
+	id   uint64
+	done chan struct{}
+	once *sync.Once
+
+	reply Reply
+	err   error
+}
+
+// 给每一次Ask分配的关联ID，所有的信道共用，这样消息被转发到其它信道之后回复也能找到对应的Future
+var requestIDGenerator = &atomic.Uint64{}
+
+// 还在等待回复的请求，关联ID到*pendingRequest的映射
+var pendingRequests = &sync.Map{}
+
+type requestContextKey struct{}
+
+// pendingRequest 一个还在等待回复的请求
+type pendingRequest struct {
+	resolve func(reply any, err error) bool
+
+	// 当前持有这个请求的信道，请求被转发或者分发到别的信道时换成新的信道，信道关闭时还持有的请求被解决为ErrNoReply
+	lock  *sync.Mutex
+	owner *requestSet
+}
+
+// requestSet 一个信道当前持有的还在等待回复的请求
+type requestSet struct {
+	lock     *sync.Mutex
+	requests map[uint64]*pendingRequest
+}
+
+func newRequestSet() *requestSet {
+	return &requestSet{
+		lock:     &sync.Mutex{},
+		requests: make(map[uint64]*pendingRequest),
+	}
+}
+
+// 请求进入了当前信道，从之前持有它的信道中移除，已经被解决了的请求会被忽略
+func (x *requestSet) adopt(id uint64) {
+	value, ok := pendingRequests.Load(id)
+	if !ok {
+		return
+	}
+	request := value.(*pendingRequest)
+	request.lock.Lock()
+	defer request.lock.Unlock()
+	if request.owner == x {
+		return
+	}
+	if request.owner != nil {
+		request.owner.remove(id)
+	}
+	x.lock.Lock()
+	x.requests[id] = request
+	x.lock.Unlock()
+	request.owner = x
+
+	// 加入之前请求可能正好被解决了，此时它已经不在pendingRequests中，不会再有人把它移除
+	if _, ok := pendingRequests.Load(id); !ok {
+		x.remove(id)
+	}
+}
+
+func (x *requestSet) remove(id uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.requests, id)
+}
+
+// 信道关闭了，它还持有的请求既不会再被处理也不会再被转发，都解决为ErrNoReply
+func (x *requestSet) close() {
+	x.lock.Lock()
+	requests := make([]*pendingRequest, 0, len(x.requests))
+	for _, request := range x.requests {
+		requests = append(requests, request)
+	}
+	x.lock.Unlock()
+	for _, request := range requests {
+		request.resolve(nil, ErrNoReply)
+	}
+}
+
+// Ask 往channel中发送一条请求，返回一个在回复到来时被解决的Future，适合命令式的交互：发出一条命令，等待处理结果
+// 请求会带着关联ID流经channel以及它转发到的父信道，任何一个阶段的ContextConsumerFunc或者DecisionConsumerFunc都可以用消费函数的ctx调用Reply回复
+// ctx被取消或者超时时Future被解决为ctx的错误，请求经过的信道都关闭了还没有人回复时被解决为ErrNoReply，请求被丢弃或者没有人回复时ctx没有超时的话要等到信道关闭，所以ctx一般都需要带上超时
+// 因为回复的类型和消息的类型不同，go不允许方法这样实例化，所以这里是一个函数而不是方法
+func Ask[Message, Reply any](ctx context.Context, channel *Channel[Message], message Message) *Future[Reply] {
+	x := &Future[Reply]{
+		id:   requestIDGenerator.Add(1),
+		done: make(chan struct{}),
+		once: &sync.Once{},
+	}
+	pendingRequests.Store(x.id, &pendingRequest{
+		resolve: func(reply any, err error) bool {
+			if err != nil {
+				return x.resolve(*new(Reply), err)
+			}
+			typed, ok := reply.(Reply)
+			if !ok {
+				return x.resolve(typed, fmt.Errorf("message channel: reply of type %T for request expecting %T", reply, *new(Reply)))
+			}
+			return x.resolve(typed, nil)
+		},
+		lock: &sync.Mutex{},
+	})
+
+	if err := channel.checkSendable(); err != nil {
+		x.resolve(*new(Reply), err)
+		return x
+	}
+	if err := channel.sendEnvelope(ctx, envelope[Message]{message: message, request: x.id}); err != nil {
+
+		// 缓存满了等到ctx结束时发送只会返回context.Canceled，这里换成ctx真正的错误，这样超时的请求总是context.DeadlineExceeded
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		x.resolve(*new(Reply), err)
+		return x
+	}
+
+	// ctx永远不会结束时不需要等待它，请求最晚在信道关闭时被解决
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-x.done:
+			case <-ctx.Done():
+				x.resolve(*new(Reply), ctx.Err())
+			}
+		}()
+	}
+	return x
+}
+
+// Reply 在处理请求的消费函数中用消费函数的ctx回复Ask发出的请求，返回回复是否被接受
+// 当前处理的消息不是Ask发出的请求、请求已经被回复过、已经超时或者被取消时返回false，回复的类型和Ask的Reply不一致时Future被解决为错误
+func Reply[R any](ctx context.Context, reply R) bool {
+	return resolveRequest(ctx, reply, nil)
+}
+
+// ReplyError 在处理请求的消费函数中用消费函数的ctx让Ask发出的请求失败，Future被解决为err，返回值和Reply一样
+func ReplyError(ctx context.Context, err error) bool {
+	return resolveRequest(ctx, nil, err)
+}
+
+// IsRequest 判断当前处理的消息是否是Ask发出的还在等待回复的请求
+func IsRequest(ctx context.Context) bool {
+	id, _ := ctx.Value(requestContextKey{}).(uint64)
+	_, ok := pendingRequests.Load(id)
+	return ok
+}
+
+func resolveRequest(ctx context.Context, reply any, err error) bool {
+	id, _ := ctx.Value(requestContextKey{}).(uint64)
+	if id == 0 {
+		return false
+	}
+	request, ok := pendingRequests.Load(id)
+	if !ok {
+		return false
+	}
+	return request.(*pendingRequest).resolve(reply, err)
+}
+
+// 解决Future，只有第一次生效
+func (x *Future[Reply]) resolve(reply Reply, err error) bool {
+	resolved := false
+	x.once.Do(func() {
+		if value, ok := pendingRequests.LoadAndDelete(x.id); ok {
+			request := value.(*pendingRequest)
+			request.lock.Lock()
+			if request.owner != nil {
+				request.owner.remove(x.id)
+			}
+			request.lock.Unlock()
+		}
+		x.reply, x.err = reply, err
+		resolved = true
+		close(x.done)
+	})
+	return resolved
+}
+
+// Done Future被解决时关闭
+func (x *Future[Reply]) Done() <-chan struct{} {
+	return x.done
+}
+
+// Get 等待Future被解决，返回回复或者失败的原因，ctx只控制这一次等待，ctx结束时返回ctx的错误，Future本身不受影响
+func (x *Future[Reply]) Get(ctx context.Context) (Reply, error) {
+	select {
+	case <-x.done:
+		return x.reply, x.err
+	case <-ctx.Done():
+		var zero Reply
+		return zero, ctx.Err()
+	}
+}
+
+// Cancel 放弃等待回复，Future被解决为context.Canceled，之后的回复都会被拒绝
+func (x *Future[Reply]) Cancel() {
+	x.resolve(*new(Reply), context.Canceled)
+}
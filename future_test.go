@@ -0,0 +1,120 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAsk(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		if message < 0 {
+			ReplyError(ctx, errors.New("negative"))
+			return nil
+		}
+		if message == 0 {
+			assert.False(t, IsRequest(ctx))
+			assert.False(t, Reply(ctx, 0))
+			return nil
+		}
+		assert.True(t, IsRequest(ctx))
+		assert.True(t, Reply(ctx, message*2))
+		assert.False(t, Reply(ctx, message*3))
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := Ask[int, int](ctx, channel, 21).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, reply)
+
+	_, err = Ask[int, int](ctx, channel, -1).Get(ctx)
+	assert.EqualError(t, err, "negative")
+
+	// 回复的类型和请求期望的不一致
+	_, err = Ask[int, string](ctx, channel, 1).Get(ctx)
+	assert.NotNil(t, err)
+
+	// 普通发送的消息不是请求，回复被拒绝
+	assert.Nil(t, channel.Send(context.Background(), 0))
+	channel.SenderWaitAndClose()
+	assert.Equal(t, 0, pendingRequestCount())
+}
+
+func TestAsk_RepliedDownstream(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]().WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		Reply(ctx, "parent")
+		return Ack{}
+	}))
+
+	// 子信道不回复，转发给父信道之后由父信道回复
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		return Ack{}
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := Ask[int, string](ctx, child, 1).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "parent", reply)
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+}
+
+func TestAsk_Timeout(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	future := Ask[int, int](ctx, channel, 1)
+	_, err := future.Get(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	<-future.Done()
+
+	future = Ask[int, int](context.Background(), channel, 2)
+	future.Cancel()
+	_, err = future.Get(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, 0, pendingRequestCount())
+
+	// 往已经关闭的信道发送请求立即失败
+	_, err = Ask[int, int](context.Background(), channel, 3).Get(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestAsk_NoReply(t *testing.T) {
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+	}))
+
+	// ctx没有超时，请求转发给了父信道，子信道关闭时还不能确定没有人回复
+	future := Ask[int, int](context.Background(), child, 1)
+	child.SenderWaitAndClose()
+	select {
+	case <-future.Done():
+		t.Fatal("resolved before the parent closed")
+	default:
+	}
+
+	// 请求经过的信道都关闭了还没有人回复
+	parent.SenderWaitAndClose()
+	_, err := future.Get(context.Background())
+	assert.ErrorIs(t, err, ErrNoReply)
+	assert.Equal(t, 0, pendingRequestCount())
+	assert.Empty(t, child.requests.requests)
+	assert.Empty(t, parent.requests.requests)
+}
+
+func pendingRequestCount() int {
+	count := 0
+	pendingRequests.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	return count
+}
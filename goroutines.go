@@ -0,0 +1,150 @@
+package message_channel
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 信道启动的协程上的pprof标签，用go tool pprof -tagfocus或者goroutine的profile（debug=1）就能看出每个协程属于哪个信道
+const (
+	GoroutineLabelChannelID   = "message_channel_id"
+	GoroutineLabelChannelName = "message_channel_name"
+	GoroutineLabelRole        = "message_channel_role"
+)
+
+// 信道启动的协程的角色
+const (
+	goroutineRoleWorker     = "worker"
+	goroutineRoleConsumer   = "consumer"
+	goroutineRoleAutoscale  = "autoscale"
+	goroutineRoleWatchdog   = "watchdog"
+	goroutineRoleSilence    = "silence"
+	goroutineRoleAutoCommit = "auto_commit"
+	goroutineRoleCheckpoint = "checkpoint"
+	goroutineRoleSampler    = "depth_sampler"
+	goroutineRoleRestore    = "restore"
+	goroutineRoleClose      = "close"
+	goroutineRoleKeyed      = "keyed"
+	goroutineRoleOrdered    = "ordered"
+	goroutineRolePump       = "pump"
+	goroutineRoleDemux      = "demux"
+	goroutineRoleSplit      = "split"
+	goroutineRoleJoin       = "join_receiver"
+	goroutineRoleSinkFlush  = "sink_flush"
+	goroutineRoleSinkUpload = "sink_upload"
+)
+
+// ChannelGoroutine 信道启动的一个还在运行的协程
+type ChannelGoroutine struct {
+
+	// 协程的ID，和panic或者runtime.Stack输出的goroutine后面的数字一样
+	GoroutineID uint64 `json:"goroutine_id"`
+
+	// 协程所属的信道的ID和名字
+	ChannelID   uint64 `json:"channel_id"`
+	ChannelName string `json:"channel_name,omitempty"`
+
+	// 协程是做什么的，比如worker是处理消息的协程，consumer是带超时调用消费函数的协程
+	Role string `json:"role"`
+}
+
+// GoroutineRegistry 记录信道启动的协程属于哪个信道，拿到一份协程的dump之后可以按照协程的ID查到它是哪个信道的哪个协程
+// 记录协程的ID需要解析调用栈，有一定的开销，一般只在排查问题时开启；子信道没有单独配置时继承父信道的，这样只需要在根信道上配置一次
+type GoroutineRegistry struct {
+	lock *sync.Mutex
+
+	// 还在运行的协程，key是协程的ID
+	goroutines map[uint64]ChannelGoroutine
+}
+
+// NewGoroutineRegistry 创建一个协程注册表
+func NewGoroutineRegistry() *GoroutineRegistry {
+	return &GoroutineRegistry{
+		lock:       &sync.Mutex{},
+		goroutines: make(map[uint64]ChannelGoroutine),
+	}
+}
+
+// Lookup 按照协程的ID查找它属于哪个信道，协程不是信道启动的或者已经退出了时返回false
+func (x *GoroutineRegistry) Lookup(goroutineID uint64) (ChannelGoroutine, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	goroutine, ok := x.goroutines[goroutineID]
+	return goroutine, ok
+}
+
+// Goroutines 所有还在运行的信道启动的协程，按照协程的ID排序
+func (x *GoroutineRegistry) Goroutines() []ChannelGoroutine {
+	x.lock.Lock()
+	goroutines := make([]ChannelGoroutine, 0, len(x.goroutines))
+	for _, goroutine := range x.goroutines {
+		goroutines = append(goroutines, goroutine)
+	}
+	x.lock.Unlock()
+	sort.Slice(goroutines, func(i, j int) bool {
+		return goroutines[i].GoroutineID < goroutines[j].GoroutineID
+	})
+	return goroutines
+}
+
+func (x *GoroutineRegistry) add(goroutine ChannelGoroutine) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.goroutines[goroutine.GoroutineID] = goroutine
+}
+
+func (x *GoroutineRegistry) remove(goroutineID uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.goroutines, goroutineID)
+}
+
+// 当前协程的ID，从调用栈的第一行“goroutine 123 [running]:”中解析出来
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// GoroutineCount 当前信道以及所有的子孙信道启动的还在运行的协程的数量，用来发现大的拓扑中协程的泄漏
+func (x *Channel[Message]) GoroutineCount() int {
+	count := 0
+	x.eachInSubtree(func(channel *Channel[Message]) {
+		count += int(channel.stats.goroutines.Load())
+	})
+	return count
+}
+
+// 启动一个不属于任何信道的协程，比如Sink在创建时还不知道会被配置到哪个信道上，协程只带着角色的pprof标签
+func spawnRole(role string, f func()) {
+	go pprof.Do(context.Background(), pprof.Labels(GoroutineLabelRole, role), func(context.Context) {
+		f()
+	})
+}
+
+// 启动一个属于当前信道的协程，协程带着信道的ID、名字和角色的pprof标签，它再启动的协程会继承这些标签
+func (x *Channel[Message]) spawn(role string, f func()) {
+	x.stats.goroutines.Add(1)
+	labels := pprof.Labels(GoroutineLabelChannelID, strconv.FormatUint(x.ID, 10), GoroutineLabelChannelName, x.options.Name, GoroutineLabelRole, role)
+	go func() {
+		defer x.stats.goroutines.Add(-1)
+		if registry := x.options.GoroutineRegistry; registry != nil {
+			id := currentGoroutineID()
+			registry.add(ChannelGoroutine{GoroutineID: id, ChannelID: x.ID, ChannelName: x.options.Name, Role: role})
+			defer registry.remove(id)
+		}
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			f()
+		})
+	}()
+}
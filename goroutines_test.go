@@ -0,0 +1,78 @@
+package message_channel
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"runtime/pprof"
+	"testing"
+	"time"
+)
+
+func TestChannel_GoroutineCount(t *testing.T) {
+	registry := NewGoroutineRegistry()
+	channel := NewChannel[int](NewChannelOptions[int]().WithName("orders").WithGoroutineRegistry(registry).WithChannelConsumerFunc(func(index int, message int) {
+	}))
+	child := channel.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child"))
+
+	// 父信道和子信道各有一个处理消息的协程
+	assert.Equal(t, 2, channel.GoroutineCount())
+	assert.Equal(t, 1, child.GoroutineCount())
+
+	// 子信道继承了父信道的注册表，可以按照协程的ID查到它属于哪个信道
+	assert.Eventually(t, func() bool {
+		return len(registry.Goroutines()) == 2
+	}, time.Second, time.Millisecond*5)
+	names := make([]string, 0)
+	for _, goroutine := range registry.Goroutines() {
+		assert.Equal(t, goroutineRoleWorker, goroutine.Role)
+		found, ok := registry.Lookup(goroutine.GoroutineID)
+		assert.True(t, ok)
+		assert.Equal(t, goroutine, found)
+		names = append(names, goroutine.ChannelName)
+	}
+	assert.ElementsMatch(t, []string{"orders", "child"}, names)
+
+	// 协程的dump中带着信道的标签
+	buf := &bytes.Buffer{}
+	assert.Nil(t, pprof.Lookup("goroutine").WriteTo(buf, 1))
+	assert.Contains(t, buf.String(), `"message_channel_name":"orders"`)
+	assert.Contains(t, buf.String(), `"message_channel_role":"worker"`)
+
+	child.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 1
+	}, time.Second, time.Millisecond*5)
+	channel.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 0 && len(registry.Goroutines()) == 0
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestChannel_GoroutineCountKeyed(t *testing.T) {
+	registry := NewGoroutineRegistry()
+	release := make(chan struct{})
+	channel := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithGoroutineRegistry(registry).WithKeyedConcurrency(2, func(message string) string {
+		return message
+	}).WithChannelConsumerFunc(func(index int, message string) {
+		<-release
+	}))
+	assert.Nil(t, channel.Send(context.Background(), "a"))
+	assert.Nil(t, channel.Send(context.Background(), "b"))
+
+	// 处理消息的协程之外每个正在被处理的键还有一个协程
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 3 && len(registry.Goroutines()) == 3
+	}, time.Second, time.Millisecond*5)
+	roles := make([]string, 0)
+	for _, goroutine := range registry.Goroutines() {
+		roles = append(roles, goroutine.Role)
+	}
+	assert.ElementsMatch(t, []string{goroutineRoleWorker, goroutineRoleKeyed, goroutineRoleKeyed}, roles)
+
+	close(release)
+	channel.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 0 && len(registry.Goroutines()) == 0
+	}, time.Second, time.Millisecond*5)
+}
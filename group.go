@@ -0,0 +1,144 @@
+package message_channel
+
+import (
+	"context"
+	"sync"
+)
+
+// groupMember 能被Group管理的信道，任意消息类型的*Channel都实现了它
+type groupMember interface {
+	closeAndWait()
+	doneSignal() <-chan struct{}
+	pendingCount() int
+	childrenCount() int
+	Stats() ChannelStats
+}
+
+func (x *Channel[Message]) closeAndWait() {
+	x.SenderWaitAndClose()
+}
+
+func (x *Channel[Message]) doneSignal() <-chan struct{} {
+	return x.done
+}
+
+func (x *Channel[Message]) pendingCount() int {
+	return x.buffer.len()
+}
+
+func (x *Channel[Message]) childrenCount() int {
+	size, _ := x.childrenChannelMap.Size(context.Background())
+	return size
+}
+
+// GroupStats Group中所有信道的汇总情况
+type GroupStats struct {
+
+	// 一共管理了多少个根信道
+	Channels int
+
+	// 其中已经关闭的有多少个
+	Closed int
+
+	// 所有信道中还没有被处理的消息的总数
+	Pending int
+
+	// 所有根信道的直接子信道的总数
+	Children int
+
+	// 所有根信道的发送、消费、丢弃的消息数的总和
+	Sent     uint64
+	Consumed uint64
+	Dropped  uint64
+}
+
+// Group 管理一组互相独立的根信道，服务中有很多拓扑的时候可以在退出时统一关闭和等待
+// 信道的消息类型可以各不相同
+type Group struct {
+
+	// 用于全局互斥操作
+	lock *sync.Mutex
+
+	// 被管理的根信道
+	members []groupMember
+}
+
+// NewGroup 创建一个信道组
+func NewGroup() *Group {
+	return &Group{
+		lock: &sync.Mutex{},
+	}
+}
+
+// Add 把根信道加入到组中，channel应该是一个*Channel
+func (x *Group) Add(channels ...groupMember) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.members = append(x.members, channels...)
+}
+
+// 复制一份当前的成员，避免在持有锁的时候等待
+func (x *Group) snapshot() []groupMember {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	members := make([]groupMember, len(x.members))
+	copy(members, x.members)
+	return members
+}
+
+// CloseAll 并发的关闭组中所有的信道并等待它们处理完，ctx到期时返回ctx的错误，此时还没关闭完的信道会在后台继续关闭
+// 每个信道只能被关闭一次，所以调用CloseAll之后信道的发送方就不应该再关闭信道了
+func (x *Group) CloseAll(ctx context.Context) error {
+	members := x.snapshot()
+	done := make(chan struct{})
+	go func() {
+		wg := &sync.WaitGroup{}
+		for _, member := range members {
+			wg.Add(1)
+			go func(member groupMember) {
+				defer wg.Done()
+				member.closeAndWait()
+			}(member)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitAll 等待组中所有的信道都被各自的发送方关闭并处理完，ctx到期时返回ctx的错误
+func (x *Group) WaitAll(ctx context.Context) error {
+	for _, member := range x.snapshot() {
+		select {
+		case <-member.doneSignal():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Stats 统计组中所有信道的汇总情况
+func (x *Group) Stats() GroupStats {
+	stats := GroupStats{}
+	for _, member := range x.snapshot() {
+		stats.Channels++
+		select {
+		case <-member.doneSignal():
+			stats.Closed++
+		default:
+		}
+		stats.Pending += member.pendingCount()
+		stats.Children += member.childrenCount()
+		channelStats := member.Stats()
+		stats.Sent += channelStats.Sent
+		stats.Consumed += channelStats.Consumed
+		stats.Dropped += channelStats.Dropped
+	}
+	return stats
+}
@@ -0,0 +1,33 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	group := NewGroup()
+	strings := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10))
+	ints := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	group.Add(strings, ints)
+	child := ints.MakeChildChannel()
+
+	assert.Nil(t, ints.Send(context.Background(), 1))
+	stats := group.Stats()
+	assert.Equal(t, GroupStats{Channels: 2, Closed: 0, Pending: 1, Children: 1, Sent: 1}, stats)
+	child.SenderWaitAndClose()
+
+	// 拉模式的信道中的消息没有人拉取，关闭会一直等待下去
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancelFunc()
+	assert.ErrorIs(t, group.CloseAll(ctx), context.DeadlineExceeded)
+
+	_, err := ints.Receive(context.Background())
+	assert.Nil(t, err)
+	_, err = ints.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+	assert.Nil(t, group.WaitAll(context.Background()))
+	assert.Equal(t, 2, group.Stats().Closed)
+}
@@ -0,0 +1,181 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultStallThreshold 没有配置StallThreshold时判断信道卡住的默认时长
+const DefaultStallThreshold = time.Second * 30
+
+// HealthStatus 信道的健康状态
+type HealthStatus string
+
+const (
+
+	// HealthStatusRunning 正常运行中
+	HealthStatusRunning HealthStatus = "running"
+
+	// HealthStatusPaused 被暂停了，消息不会被消费，暂停期间不会被认为是卡住了
+	HealthStatusPaused HealthStatus = "paused"
+
+	// HealthStatusDraining 发送方已经开始关闭信道，正在等待剩余的消息处理完
+	HealthStatusDraining HealthStatus = "draining"
+
+	// HealthStatusStalled 信道中有消息，但是很久都没有消息被消费了
+	HealthStatusStalled HealthStatus = "stalled"
+
+	// HealthStatusClosed 已经关闭并且处理完了
+	HealthStatusClosed HealthStatus = "closed"
+)
+
+// 状态的严重程度，汇总子树的时候取最严重的状态
+var healthStatusSeverity = map[HealthStatus]int{
+	HealthStatusClosed:   0,
+	HealthStatusRunning:  1,
+	HealthStatusPaused:   2,
+	HealthStatusDraining: 3,
+	HealthStatusStalled:  4,
+}
+
+// HealthSummary 健康指标，既用于单个信道也用于汇总的子树
+type HealthSummary struct {
+
+	// 状态，汇总子树时是子树中最严重的状态
+	Status HealthStatus `json:"status"`
+
+	// 包含的信道的数量
+	Channels int `json:"channels"`
+
+	// 卡住的信道的数量
+	StalledChannels int `json:"stalled_channels"`
+
+	// 缓存中还没有被消费的消息的数量
+	Pending int `json:"pending"`
+
+	// 缓存的容量
+	Capacity int `json:"capacity"`
+
+	// 缓存的饱和度，为Pending/Capacity，没有缓存的信道有消息在等待时为1
+	Saturation float64 `json:"saturation"`
+
+	// 已经消费的消息的数量
+	Consumed uint64 `json:"consumed"`
+
+	// 消费函数崩溃的次数
+	ConsumerErrors uint64 `json:"consumer_errors"`
+
+	// 消费函数的错误率，为ConsumerErrors/Consumed
+	ConsumerErrorRate float64 `json:"consumer_error_rate"`
+}
+
+// HealthReport 一个信道以及它的子树的健康报告，可以用来对接/healthz之类的接口
+type HealthReport struct {
+
+	// 信道的ID
+	ID uint64 `json:"id"`
+
+	// 信道的名字
+	Name string `json:"name"`
+
+	// 信道的标签
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// 信道自己的健康指标
+	Self HealthSummary `json:"self"`
+
+	// 信道以及所有子孙信道汇总的健康指标
+	Subtree HealthSummary `json:"subtree"`
+
+	// 子信道的健康报告，分发子信道排在普通的子信道后面
+	Children []HealthReport `json:"children,omitempty"`
+}
+
+// Health 检查信道以及所有子孙信道的健康状况，ctx用来控制获取子信道时的超时，超时的话报告中不会包含子信道
+func (x *Channel[Message]) Health(ctx context.Context) HealthReport {
+
+	report := HealthReport{
+		ID:   x.ID,
+		Name: x.options.Name,
+		Tags: x.Tags(),
+		Self: x.selfHealth(),
+	}
+	report.Subtree = report.Self
+
+	children, err := x.childrenChannelMap.ChildrenSlice(ctx)
+	if err != nil {
+		return report
+	}
+	for _, child := range append(children, x.DistributionChildren()...) {
+		childReport := child.Health(ctx)
+		report.Children = append(report.Children, childReport)
+		report.Subtree.merge(childReport.Subtree)
+	}
+	return report
+}
+
+// 统计信道自己的健康指标
+func (x *Channel[Message]) selfHealth() HealthSummary {
+
+	summary := HealthSummary{
+		Status:         HealthStatusRunning,
+		Channels:       1,
+		Pending:        x.buffer.len(),
+		Capacity:       x.buffer.cap(),
+		Consumed:       x.stats.consumed.Load(),
+		ConsumerErrors: x.stats.consumerErrors.Load(),
+	}
+
+	stallThreshold := x.tuned().StallThreshold
+	if stallThreshold == 0 {
+		stallThreshold = DefaultStallThreshold
+	}
+
+	select {
+	case <-x.done:
+		summary.Status = HealthStatusClosed
+	default:
+		if summary.Pending > 0 && x.State() != StatePaused && x.clock.Since(time.Unix(0, x.stats.lastConsumeUnixNano.Load())) > stallThreshold {
+			summary.Status = HealthStatusStalled
+			summary.StalledChannels = 1
+		} else if x.State() == StateDraining {
+			summary.Status = HealthStatusDraining
+		} else if x.State() == StatePaused {
+			summary.Status = HealthStatusPaused
+		}
+	}
+
+	summary.computeRates()
+	return summary
+}
+
+// 把子树的指标合并进来
+func (x *HealthSummary) merge(other HealthSummary) {
+	if healthStatusSeverity[other.Status] > healthStatusSeverity[x.Status] {
+		x.Status = other.Status
+	}
+	x.Channels += other.Channels
+	x.StalledChannels += other.StalledChannels
+	x.Pending += other.Pending
+	x.Capacity += other.Capacity
+	x.Consumed += other.Consumed
+	x.ConsumerErrors += other.ConsumerErrors
+	x.computeRates()
+}
+
+// 根据计数重新计算比率
+func (x *HealthSummary) computeRates() {
+	switch {
+	case x.Capacity > 0:
+		x.Saturation = float64(x.Pending) / float64(x.Capacity)
+	case x.Pending > 0:
+		x.Saturation = 1
+	default:
+		x.Saturation = 0
+	}
+	if x.Consumed > 0 {
+		x.ConsumerErrorRate = float64(x.ConsumerErrors) / float64(x.Consumed)
+	} else {
+		x.ConsumerErrorRate = 0
+	}
+}
@@ -0,0 +1,40 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_Health(t *testing.T) {
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(4).WithPullMode().WithStallThreshold(time.Millisecond * 10))
+	child := root.MakeChildChannel()
+	assert.Nil(t, root.Send(context.Background(), 1))
+	assert.Nil(t, root.Send(context.Background(), 2))
+
+	report := root.Health(context.Background())
+	assert.Equal(t, HealthStatusRunning, report.Self.Status)
+	assert.Equal(t, 2, report.Self.Pending)
+	assert.Equal(t, 0.5, report.Self.Saturation)
+	assert.Equal(t, 2, report.Subtree.Channels)
+	assert.Equal(t, 1, len(report.Children))
+
+	// 有消息但是一直没人拉取，超过阈值之后认为卡住了
+	time.Sleep(time.Millisecond * 20)
+	report = root.Health(context.Background())
+	assert.Equal(t, HealthStatusStalled, report.Subtree.Status)
+	assert.Equal(t, 1, report.Subtree.StalledChannels)
+
+	child.SenderWaitAndClose()
+	go root.SenderWaitAndClose()
+	for i := 0; i < 2; i++ {
+		_, err := root.Receive(context.Background())
+		assert.Nil(t, err)
+	}
+	_, err := root.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+	report = root.Health(context.Background())
+	assert.Equal(t, HealthStatusClosed, report.Self.Status)
+	assert.Equal(t, uint64(2), report.Self.Consumed)
+}
@@ -0,0 +1,115 @@
+package message_channel
+
+import "sync"
+
+// keyedExecutor 按键串行、不同的键之间并行的执行器
+// 同一个键同时只会有一个协程在处理，这个键后续的消息排在它的队列里由同一个协程接着处理，这样同一个键的消息总是按顺序处理的
+type keyedExecutor struct {
+	lock *sync.Mutex
+	cond *sync.Cond
+
+	// 启动处理一个键的协程，这样协程会算在信道的协程里
+	spawn func(role string, f func())
+
+	// 最多同时有多少个键在被处理
+	workers int
+	running int
+
+	// 正在被处理的键以及它们后面排队的任务
+	active map[string][]func()
+
+	// 所有的键排队的任务的总数以及上限，达到上限之后分发的一方会被阻塞，这样热点键的积压不会无限制的增长
+	queued     int
+	queueLimit int
+}
+
+func newKeyedExecutor(workers int, spawn func(role string, f func())) *keyedExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+	x := &keyedExecutor{
+		lock:       &sync.Mutex{},
+		spawn:      spawn,
+		workers:    workers,
+		active:     make(map[string][]func()),
+		queueLimit: workers,
+	}
+	x.cond = sync.NewCond(x.lock)
+	return x
+}
+
+// 提交一个任务，键正在被处理时排到它的队列里，否则等到有空闲的协程时开始处理
+func (x *keyedExecutor) submit(key string, task func()) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for {
+		if queue, ok := x.active[key]; ok {
+			if x.queued < x.queueLimit {
+				x.active[key] = append(queue, task)
+				x.queued++
+				return
+			}
+		} else if x.running < x.workers {
+			x.active[key] = nil
+			x.running++
+			x.spawn(goroutineRoleKeyed, func() {
+				x.run(key, task)
+			})
+			return
+		}
+		x.cond.Wait()
+	}
+}
+
+// 处理一个键，直到它的队列空了为止
+func (x *keyedExecutor) run(key string, task func()) {
+	for {
+		task()
+
+		x.lock.Lock()
+		queue := x.active[key]
+		if len(queue) == 0 {
+			delete(x.active, key)
+			x.running--
+			x.cond.Broadcast()
+			x.lock.Unlock()
+			return
+		}
+		task = queue[0]
+		x.active[key] = queue[1:]
+		x.queued--
+		x.cond.Broadcast()
+		x.lock.Unlock()
+	}
+}
+
+// 等待所有提交的任务都处理完
+func (x *keyedExecutor) wait() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for x.running > 0 {
+		x.cond.Wait()
+	}
+}
+
+// 在处理消息的协程以外的协程中消费一条消息，用于按键串行并发消费以及异步消费函数
+// 消费函数panic时和处理消息的协程崩溃一样按照监督策略处理，允许重启时继续处理后面的消息
+func (x *Channel[Message]) consumeDetached(envelope envelope[Message]) {
+	defer func() {
+		reason := recover()
+		if reason == nil {
+			return
+		}
+		x.stats.inFlight.Add(-1)
+		x.consumerFailed(consumerPanicError(reason), envelope.message)
+		x.drop(DropReasonConsumerPanic, envelope.message)
+		x.checkpointEnd(envelope.offset)
+		if x.supervisor == nil || !x.supervisor.allowRestart(reason) {
+			panic(reason)
+		}
+	}()
+	x.stats.inFlight.Add(1)
+	x.consume(x.markConsumed(envelope), envelope, nil)
+	x.stats.inFlight.Add(-1)
+	x.markProcessed(envelope.offset)
+}
@@ -0,0 +1,83 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_KeyedConcurrency(t *testing.T) {
+	type event struct {
+		key string
+		seq int
+	}
+	lock := &sync.Mutex{}
+	running := make(map[string]bool)
+	last := make(map[string]int)
+	concurrent, peak := 0, 0
+	channel := NewChannel[event](NewChannelOptions[event]().WithChannelBuffSize(10).WithKeyedConcurrency(4, func(message event) string {
+		return message.key
+	}).WithChannelConsumerFunc(func(index int, message event) {
+		lock.Lock()
+		assert.False(t, running[message.key])
+		assert.Equal(t, last[message.key]+1, message.seq)
+		running[message.key] = true
+		concurrent++
+		if concurrent > peak {
+			peak = concurrent
+		}
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		lock.Lock()
+		running[message.key] = false
+		last[message.key] = message.seq
+		concurrent--
+		lock.Unlock()
+	}))
+
+	for seq := 1; seq <= 10; seq++ {
+		for key := 0; key < 6; key++ {
+			assert.Nil(t, channel.Send(context.Background(), event{key: fmt.Sprintf("key-%d", key), seq: seq}))
+		}
+	}
+	channel.SenderWaitAndClose()
+
+	assert.Equal(t, uint64(60), channel.Stats().Consumed)
+	assert.Equal(t, 6, len(last))
+	for _, seq := range last {
+		assert.Equal(t, 10, seq)
+	}
+	assert.Greater(t, peak, 1)
+	assert.LessOrEqual(t, peak, 4)
+}
+
+func TestChannel_KeyedConcurrencyPanic(t *testing.T) {
+	dropped := make([]int, 0)
+	consumed := make([]int, 0)
+	lock := &sync.Mutex{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithKeyedConcurrency(2, func(message int) string {
+		return "same"
+	}).WithSupervisor(&SupervisorOptions{MaxRestarts: 1}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonConsumerPanic, reason)
+		dropped = append(dropped, message)
+	}).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 2 {
+			panic("boom")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		consumed = append(consumed, message)
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{2}, dropped)
+	assert.Equal(t, []int{1, 3}, consumed)
+	assert.Equal(t, 0, channel.Stats().InFlight)
+}
@@ -0,0 +1,74 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// DropReasonLatencyBudgetExceeded 消息在整棵信道树中累计停留的时长超过了LatencyBudget
+const DropReasonLatencyBudgetExceeded DropReason = "latency_budget_exceeded"
+
+// LatencyBudgetPolicy 消息累计停留的时长超过LatencyBudget时的处理策略
+type LatencyBudgetPolicy int
+
+const (
+
+	// LatencyBudgetFlag 标记这条消息并触发OnExceeded，消息照常被消费，消费函数可以通过IsOverLatencyBudget判断，默认的策略
+	LatencyBudgetFlag LatencyBudgetPolicy = iota
+
+	// LatencyBudgetDrop 触发OnExceeded之后丢弃这条消息
+	LatencyBudgetDrop
+)
+
+// LatencyBudgetListener 消息累计停留的时长超过LatencyBudget时的回调，latency是到目前为止累计停留的时长
+type LatencyBudgetListener[Message any] func(message Message, latency time.Duration)
+
+// LatencyBudgetOptions 消息在整棵信道树中的延迟预算，从消息第一次放入信道开始计算，经过的每一层子信道中排队和处理的时长都算在内
+// 子信道转发消息时会把消息在子信道中停留的时长累加到信封上一起转发，每一层取出消息时检查累计的时长有没有超过预算
+// 子信道没有单独配置时继承父信道的，同一条消息超过预算之后只会在第一次发现的那一层处理一次
+type LatencyBudgetOptions[Message any] struct {
+
+	// 延迟预算，为0时不检查
+	Budget time.Duration
+
+	// 超过预算时的处理策略
+	Policy LatencyBudgetPolicy
+
+	// 超过预算时的回调，可以为nil
+	OnExceeded LatencyBudgetListener[Message]
+}
+
+type overLatencyBudgetContextKey struct{}
+
+// IsOverLatencyBudget 在ContextConsumerFunc或者DecisionConsumerFunc中判断当前处理的消息是否已经超过了延迟预算
+func IsOverLatencyBudget(ctx context.Context) bool {
+	over, _ := ctx.Value(overLatencyBudgetContextKey{}).(bool)
+	return over
+}
+
+// 是否需要记录消息放入缓存的时间
+func (x *Channel[Message]) recordsEnqueueTime() bool {
+	return x.options.QueueLatency || x.options.LatencyBudgetOptions != nil
+}
+
+// 检查取出来的消息累计停留的时长是否超过了延迟预算，超过了并且被丢弃时返回false
+func (x *Channel[Message]) checkLatencyBudget(envelope *envelope[Message]) bool {
+	options := x.options.LatencyBudgetOptions
+	if options == nil || options.Budget <= 0 || envelope.overBudget {
+		return true
+	}
+	latency := envelope.latency(x.clock.Now())
+	if latency <= options.Budget {
+		return true
+	}
+	envelope.overBudget = true
+	x.stats.overBudget.Add(1)
+	if options.OnExceeded != nil {
+		options.OnExceeded(envelope.message, latency)
+	}
+	if options.Policy == LatencyBudgetDrop {
+		x.drop(DropReasonLatencyBudgetExceeded, envelope.message)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_LatencyBudgetFlag(t *testing.T) {
+	exceeded := &atomic.Int64{}
+	flagged := &atomic.Int64{}
+	parent := NewChannel[int](NewChannelOptions[int]().WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		if IsOverLatencyBudget(ctx) {
+			flagged.Add(1)
+		}
+		return nil
+	}).WithLatencyBudget(time.Millisecond*20, LatencyBudgetFlag, func(message int, latency time.Duration) {
+		assert.GreaterOrEqual(t, latency, time.Millisecond*30)
+		exceeded.Add(1)
+	}))
+
+	// 在子信道中只排队了很短的时间，处理的耗时累加到信封上，到了父信道才超过预算
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond * 30)
+	}))
+	assert.Nil(t, child.Send(context.Background(), 1))
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+
+	assert.Equal(t, int64(1), exceeded.Load())
+	assert.Equal(t, int64(1), flagged.Load())
+	assert.Equal(t, uint64(0), child.Stats().OverLatencyBudget)
+	assert.Equal(t, uint64(1), parent.Stats().OverLatencyBudget)
+	assert.Equal(t, uint64(1), parent.Stats().Consumed)
+}
+
+func TestChannel_LatencyBudgetDrop(t *testing.T) {
+	consumed := &atomic.Int64{}
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		consumed.Add(1)
+	}).WithLatencyBudget(time.Millisecond*20, LatencyBudgetDrop, nil))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond * 30)
+	}))
+
+	// 第一条消息在父信道中超过预算，后面的消息在子信道中排队的时候就已经超过了
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+
+	assert.Equal(t, int64(0), consumed.Load())
+	assert.Equal(t, uint64(1), parent.Stats().DroppedByReason[DropReasonLatencyBudgetExceeded])
+	assert.Equal(t, uint64(2), child.Stats().DroppedByReason[DropReasonLatencyBudgetExceeded])
+}
+
+func TestChannel_LatencyBudgetDisabled(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	time.Sleep(time.Millisecond * 5)
+	_, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), channel.Stats().OverLatencyBudget)
+}
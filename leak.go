@@ -0,0 +1,139 @@
+package message_channel
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Leak 一个创建之后超过了MaxAge还没有关闭的信道
+type Leak struct {
+
+	// 信道的ID、名字和标签
+	ID   uint64            `json:"id"`
+	Name string            `json:"name,omitempty"`
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// 信道创建的时间以及到现在已经存在了多久
+	CreatedAt time.Time     `json:"created_at"`
+	Age       time.Duration `json:"age"`
+
+	// 创建信道时的调用栈，用来找到是哪里创建了之后忘记关闭
+	Stack string `json:"stack"`
+}
+
+// LeakListener Watch发现泄漏的信道时的回调，同一个信道只会回调一次
+type LeakListener func(leak Leak)
+
+// LeakTracker 信道泄漏检测器，记录登记进来的信道的创建调用栈，创建之后超过MaxAge还没有关闭的信道被认为是泄漏了
+// 用于在长时间运行的服务中找到被遗忘的子信道，记录调用栈有一定的开销，一般只在排查问题时开启
+// 子信道没有单独配置LeakTracker时继承父信道的，这样只需要在根信道上配置一次
+type LeakTracker struct {
+	lock *sync.Mutex
+
+	// 超过这个时长还没有关闭的信道被认为是泄漏了
+	maxAge time.Duration
+
+	// 还没有关闭的信道
+	open map[uint64]*trackedChannel
+}
+
+// 一个还没有关闭的信道
+type trackedChannel struct {
+	leak  Leak
+	clock Clock
+
+	// Watch是否已经报告过了
+	reported bool
+}
+
+// NewLeakTracker 创建一个泄漏检测器，maxAge是信道创建之后多久还没有关闭就认为泄漏了
+func NewLeakTracker(maxAge time.Duration) *LeakTracker {
+	return &LeakTracker{
+		lock:   &sync.Mutex{},
+		maxAge: maxAge,
+		open:   make(map[uint64]*trackedChannel),
+	}
+}
+
+// 登记一个刚创建的信道，记录下创建它的调用栈
+func (x *LeakTracker) track(id uint64, name string, tags map[string]string, clock Clock) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.open[id] = &trackedChannel{
+		leak: Leak{
+			ID:        id,
+			Name:      name,
+			Tags:      tags,
+			CreatedAt: clock.Now(),
+			Stack:     string(debug.Stack()),
+		},
+		clock: clock,
+	}
+}
+
+// 信道关闭之后不再跟踪
+func (x *LeakTracker) untrack(id uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.open, id)
+}
+
+// Open 登记进来的信道中还没有关闭的数量
+func (x *LeakTracker) Open() int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return len(x.open)
+}
+
+// Leaks 当前所有泄漏的信道，按照创建时间从早到晚排列
+func (x *LeakTracker) Leaks() []Leak {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.collect(false)
+}
+
+// Watch 每隔interval检查一次，发现新的泄漏的信道时回调listener，一直运行到ctx被取消
+func (x *LeakTracker) Watch(ctx context.Context, interval time.Duration, listener LeakListener) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		x.lock.Lock()
+		leaks := x.collect(true)
+		x.lock.Unlock()
+		for _, leak := range leaks {
+			listener(leak)
+		}
+	}
+}
+
+// 收集泄漏的信道，onlyNew为true时只收集还没有报告过的并把它们标记为已报告，调用方需要持有锁
+func (x *LeakTracker) collect(onlyNew bool) []Leak {
+	leaks := make([]Leak, 0)
+	for _, tracked := range x.open {
+		age := tracked.clock.Since(tracked.leak.CreatedAt)
+		if age < x.maxAge || (onlyNew && tracked.reported) {
+			continue
+		}
+		if onlyNew {
+			tracked.reported = true
+		}
+		leak := tracked.leak
+		leak.Age = age
+		leaks = append(leaks, leak)
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		if !leaks[i].CreatedAt.Equal(leaks[j].CreatedAt) {
+			return leaks[i].CreatedAt.Before(leaks[j].CreatedAt)
+		}
+		return leaks[i].ID < leaks[j].ID
+	})
+	return leaks
+}
@@ -0,0 +1,59 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeakTracker(t *testing.T) {
+	tracker := NewLeakTracker(time.Millisecond * 20)
+	closed := NewChannel[int](NewChannelOptions[int]().WithLeakTracker(tracker))
+	closed.SenderWaitAndClose()
+	root := NewChannel[int](NewChannelOptions[int]().WithName("root").WithLeakTracker(tracker))
+	child := root.MakeChildChannel()
+	assert.Equal(t, 2, tracker.Open())
+
+	// 还没有到MaxAge
+	assert.Empty(t, tracker.Leaks())
+
+	time.Sleep(time.Millisecond * 30)
+	leaks := tracker.Leaks()
+	assert.Len(t, leaks, 2)
+	assert.Equal(t, root.ID, leaks[0].ID)
+	assert.Equal(t, "root", leaks[0].Name)
+	assert.Equal(t, child.ID, leaks[1].ID)
+	assert.GreaterOrEqual(t, leaks[0].Age, time.Millisecond*20)
+	assert.True(t, strings.Contains(leaks[0].Stack, "TestLeakTracker"))
+
+	// 关闭之后就不算泄漏了
+	child.SenderWaitAndClose()
+	assert.Len(t, tracker.Leaks(), 1)
+	root.SenderWaitAndClose()
+	assert.Empty(t, tracker.Leaks())
+	assert.Equal(t, 0, tracker.Open())
+}
+
+func TestLeakTracker_Watch(t *testing.T) {
+	tracker := NewLeakTracker(0)
+	root := NewChannel[int](NewChannelOptions[int]().WithLeakTracker(tracker))
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	reported := make(chan Leak, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracker.Watch(ctx, time.Millisecond*5, func(leak Leak) {
+			reported <- leak
+		})
+	}()
+
+	// 同一个信道只报告一次
+	assert.Equal(t, root.ID, (<-reported).ID)
+	time.Sleep(time.Millisecond * 20)
+	cancelFunc()
+	<-done
+	assert.Len(t, reported, 0)
+	root.SenderWaitAndClose()
+}
@@ -0,0 +1,21 @@
+package message_channel
+
+// 占用一个同时处理消息的名额，没有配置MaxInFlight时什么都不做，等待期间信道被Abort或者强制关闭时返回false
+func (x *Channel[Message]) acquireInFlight() bool {
+	if x.inFlightSlots == nil {
+		return true
+	}
+	select {
+	case x.inFlightSlots <- struct{}{}:
+		return true
+	case <-x.consumeCtx.Done():
+		return false
+	}
+}
+
+// 归还acquireInFlight占用的名额
+func (x *Channel[Message]) releaseInFlight() {
+	if x.inFlightSlots != nil {
+		<-x.inFlightSlots
+	}
+}
@@ -0,0 +1,38 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_MaxInFlight(t *testing.T) {
+	running := &atomic.Int64{}
+	peak := &atomic.Int64{}
+	consumed := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(100).WithMaxInFlight(2).WithKeyedConcurrency(8, func(message int) string {
+		return strconv.Itoa(message)
+	}).WithChannelConsumerFunc(func(index int, message int) {
+		current := running.Add(1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 5)
+		running.Add(-1)
+		consumed.Add(1)
+	}))
+
+	// 有8个键可以并行处理，但是同时最多只有2条消息在被处理
+	for i := 0; i < 32; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, int64(32), consumed.Load())
+	assert.Equal(t, int64(2), peak.Load())
+}
@@ -2,7 +2,11 @@ package message_channel
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,137 +17,905 @@ type Channel[Message any] struct {
 	// 全局唯一的ID，每个信道的ID都不同，用于区分不同的信道
 	ID uint64
 
-	// 真实存储数据的channel，每个channel都有一个消息发送方和消息接收方
-	channel chan Message
+	// 真实存储数据的缓存，每个channel都有一个消息发送方和消息接收方
+	buffer messageBuffer[envelope[Message]]
+
+	// 在拓扑中的深度，根信道为0，子信道是父信道的深度加一
+	depth int
+
+	// 子信道把消息转发给父信道的函数，连同信封一起转发，这样消息的元数据在转发的过程中不会丢失，不是子信道时为nil
+	forward func(ctx context.Context, envelope envelope[Message]) error
+
+	// 父信道卡住时暂存在本地的要转发的消息，转发策略不是ForwardBufferLocally时为nil
+	backlog *forwardBacklog[Message]
+
+	// 缓存中的消息放入的时间，用来计算OldestMessageAge，不记录放入时间或者是同步模式时为nil
+	pending *pendingAges
+
+	// 混沌模式注入故障用的，没有开启混沌模式时为nil
+	chaos *chaos
+
+	// 调试采样器，没有开启时为nil
+	debugSampler *debugSampler[Message]
+
+	// 限制OnFull回调频率的，没有配置OnFull时为nil
+	fullNotifier *fullNotifier
+
+	// 跟踪已经完全消费完的偏移量并产生检查点，没有配置检查点时为nil
+	checkpointer *checkpointer
 
 	// 此信道创建的子信道，子信道会被连接到父信道，同时父信道要等所有的子信道退出后才能退出
 	// 在当前信道要调用其他组件传入信道时就需要创建一个子信道传进去
 	childrenChannelMap *ChildrenMap[Message]
 
+	// 当前信道持有的Ask发出的还在等待回复的请求，信道关闭时还没有被回复的请求被解决为ErrNoReply
+	requests *requestSet
+
+	// 连接到当前信道上的上游信道的泵协程，上游信道的消息类型可以和当前信道不同，当前信道关闭前需要等待它们都退出
+	upstreamWg *sync.WaitGroup
+
 	// 自己的负责处理消息的协程的退出标志位，用于外界的同步等待
 	selfWorkerWg *sync.WaitGroup
 
 	// 创建信道时的选项
 	options *ChannelOptions[Message]
+
+	// 拉模式下同时只有一个调用方在取消息，ReceiveIf没有拿走的那一条消息放回head的最前面，下一次取消息时先取它，只能在持有receiving时放回
+	// head和buffer是同一个缓存，不是拉模式时为nil
+	receiving chan struct{}
+	head      *headBuffer[envelope[Message]]
+
+	// 当前使用的消费函数，可以通过SwapConsumer替换
+	consumer *atomic.Pointer[consumerFuncs[Message]]
+
+	// 运行中可以通过Reconfigure修改的选项的当前值，这些选项都要通过tuned读取，其他的选项仍然读options
+	tuning     *atomic.Pointer[ChannelOptions[Message]]
+	tuningLock *sync.Mutex
+
+	// Reconfigure修改了自动伸缩的选项之后唤醒自动伸缩的协程，没有开启自动伸缩时为nil
+	retune chan struct{}
+
+	// 信道中的消息全部被处理完之后会被关闭，用于可以被ctx打断的等待
+	done chan struct{}
+
+	// 传给ContextConsumerFunc的ctx，信道被Abort、强制关闭或者已经关闭时取消
+	consumeCtx    context.Context
+	cancelConsume context.CancelFunc
+
+	// 保证信道的结束逻辑只会被执行一次
+	finishOnce *sync.Once
+
+	// 保证底层的channel只会被关闭一次
+	closeOnce *sync.Once
+
+	// 信道的各项统计计数，都是原子操作，开销很小所以总是开启
+	stats *channelStats
+
+	// 缓存深度的采样，没有开启采样时为nil
+	depthSampler *depthSampler
+
+	// 处理消息的协程的代数，看门狗每重启一次处理消息的协程就加一
+	workerGeneration *atomic.Uint64
+
+	// 还没有退出的处理消息的协程的数量，被看门狗替换掉的协程处理完手上的消息之前也算在内，全部退出时信道才算处理完毕
+	runningWorkers *atomic.Int64
+
+	// 信道的生命周期状态，状态的迁移需要持有stateLock
+	state     *atomic.Int32
+	stateLock *sync.Mutex
+
+	// 没有被暂停时是一个已经关闭的channel，暂停时换成一个新的channel，恢复的时候关闭它
+	resumed chan struct{}
+
+	// 信道被Abort之后置为true，之后收到的消息都会被直接丢弃
+	aborted *atomic.Bool
+
+	// 最近一次回调OnPressureChange时的压力等级乘以10
+	pressureStep *atomic.Int64
+
+	// 处理消息的协程的监督者，没有配置监督策略时为nil
+	supervisor *supervisor
+
+	// 分发模式下的分发子信道，没有开启分发模式时为nil
+	distributor *distributor[Message]
+
+	// 作为分发子信道时的权重，运行中可以通过SetWeight修改
+	weight *atomic.Int64
+
+	// 开启了工作窃取的分发子信道获取兄弟信道的函数，自己的缓存为空时会从兄弟信道上拿消息处理，没有开启时为nil
+	siblings func() []*Channel[Message]
+
+	// 自动伸缩的协程池，没有开启自动伸缩时为nil
+	pool *workerPool
+
+	// 按键串行并发消费的执行器，没有开启时为nil
+	keyed *keyedExecutor
+
+	// 交给异步消费函数还没有完成的消息的窗口，没有配置AsyncConsumerFunc时为nil
+	async *asyncWindow
+
+	// 并行处理、按顺序完成的执行器，没有开启或者开启了按键串行并发消费时为nil
+	ordered *orderedExecutor
+
+	// 限制同时处理的消息的数量的信号量，没有配置MaxInFlight时为nil
+	inFlightSlots chan struct{}
+
+	// 消息的偏移量以及已经提交的偏移量
+	offsets *offsetTracker
+
+	// 正在通过ReplayFrom重新投递的历史消息，重新投递完之前不会再处理缓存中的消息，没有在重新投递时为nil
+	replay     *atomic.Pointer[replayFeed[Message]]
+	replayLock *sync.Mutex
+
+	// 同步模式下串行处理消息的锁，没有开启同步模式时为nil
+	synchronous *sync.Mutex
+
+	// 和时间相关的功能使用的时钟
+	clock Clock
 }
 
 // NewChannel 创建一个信道
 func NewChannel[Message any](options *ChannelOptions[Message]) *Channel[Message] {
+	return newChannel[Message](options, nil, nil)
+}
+
+// 创建一个信道，forward不为nil时创建的是子信道，siblings不为nil时创建的是开启了工作窃取的分发子信道，都需要在启动处理消息的协程之前设置好
+func newChannel[Message any](options *ChannelOptions[Message], forward func(ctx context.Context, envelope envelope[Message]) error, siblings func() []*Channel[Message]) *Channel[Message] {
 
+	clock := resolveClock(options.Clock)
+	buffer := newMessageBuffer[Message](options)
+	var head *headBuffer[envelope[Message]]
+	if options.PullMode {
+		head = newHeadBuffer[envelope[Message]](buffer)
+		buffer = head
+	}
 	x := &Channel[Message]{
+		clock:              clock,
+		forward:            forward,
+		siblings:           siblings,
 		ID:                 idGenerator.Add(1),
-		channel:            make(chan Message, options.ChannelBuffSize),
+		buffer:             buffer,
+		head:               head,
 		options:            options,
-		childrenChannelMap: NewChildrenMap[Message](),
+		childrenChannelMap: newChildrenMap[Message](clock, newChildrenWait(options)),
 		selfWorkerWg:       &sync.WaitGroup{},
+		upstreamWg:         &sync.WaitGroup{},
+		requests:           newRequestSet(),
+		done:               make(chan struct{}),
+		finishOnce:         &sync.Once{},
+		closeOnce:          &sync.Once{},
+		stats:              newChannelStats(clock),
+		state:              &atomic.Int32{},
+		stateLock:          &sync.Mutex{},
+		resumed:            make(chan struct{}),
+		aborted:            &atomic.Bool{},
+		pressureStep:       &atomic.Int64{},
+		workerGeneration:   &atomic.Uint64{},
+		runningWorkers:     &atomic.Int64{},
+		weight:             newWeight(options.Weight),
+		offsets:            newOffsetTracker(),
+		replay:             &atomic.Pointer[replayFeed[Message]]{},
+		replayLock:         &sync.Mutex{},
+		receiving:          make(chan struct{}, 1),
+		consumer:           &atomic.Pointer[consumerFuncs[Message]]{},
+		tuning:             &atomic.Pointer[ChannelOptions[Message]]{},
+		tuningLock:         &sync.Mutex{},
+	}
+	x.tuning.Store(options)
+	x.consumer.Store(&consumerFuncs[Message]{
+		channel:  options.ChannelConsumerFunc,
+		context:  options.ContextConsumerFunc,
+		decision: options.DecisionConsumerFunc,
+		async:    options.AsyncConsumerFunc,
+	})
+	close(x.resumed)
+	if options.LeakTracker != nil {
+		options.LeakTracker.track(x.ID, options.Name, options.Tags, clock)
+	}
+	x.consumeCtx, x.cancelConsume = context.WithCancel(context.Background())
+	if len(options.SideOutputs) > 0 {
+		x.consumeCtx = context.WithValue(x.consumeCtx, sideOutputsContextKey{}, options.SideOutputs)
+	}
+	if options.SupervisorOptions != nil {
+		x.supervisor = newSupervisor(options.SupervisorOptions, clock)
+	}
+
+	if options.SynchronousMode {
+		x.synchronous = &sync.Mutex{}
+	} else if x.recordsEnqueueTime() {
+		x.pending = newPendingAges()
+	}
+
+	if forward != nil && options.ForwardOptions != nil && options.ForwardOptions.Policy == ForwardBufferLocally {
+		x.backlog = newForwardBacklog[Message](options.ForwardOptions)
+	}
+
+	if options.ChaosOptions != nil {
+		x.chaos = newChaos(options.ChaosOptions)
+	}
+
+	if options.DebugSamplerOptions != nil {
+		x.debugSampler = newDebugSampler[Message](options.DebugSamplerOptions, clock)
+	}
+
+	if options.OnFull != nil {
+		x.fullNotifier = newFullNotifier(options.OnFull, options.OnFullInterval)
+	}
+
+	if options.AsyncConsumerFunc != nil {
+		x.async = newAsyncWindow(options.AsyncWindow)
+	} else if options.KeyedWorkers > 0 && options.ConcurrencyKey != nil {
+		x.keyed = newKeyedExecutor(options.KeyedWorkers, x.spawn)
+	}
+	if options.OrderedWorkers > 0 && x.keyed == nil && x.async == nil {
+		x.ordered = newOrderedExecutor(options.OrderedWorkers, x.spawn)
+	}
+	if options.MaxInFlight > 0 {
+		x.inFlightSlots = make(chan struct{}, options.MaxInFlight)
+	}
+
+	if options.Distribution != nil {
+		x.distributor = newDistributor[Message](options.Distribution)
+	}
+
+	if options.DepthSamplerOptions != nil {
+		x.depthSampler = startDepthSampler(options.DepthSamplerOptions, clock, func() int {
+			return x.buffer.len()
+		}, x.done, x.spawn)
+	}
+
+	if options.AutoCommitInterval > 0 {
+		x.startAutoCommit(options.AutoCommitInterval)
+	}
+
+	if options.CheckpointOptions != nil {
+		x.checkpointer = newCheckpointer(options.CheckpointOptions)
+		x.startCheckpointer()
+	}
+
+	if options.SilenceTimeout > 0 {
+		x.spawn(goroutineRoleSilence, func() {
+			x.watchSilence(options.SilenceTimeout, options.OnSilence)
+		})
 	}
 
-	// 启动处理消息的协程
 	x.selfWorkerWg.Add(1)
-	go func() {
 
-		defer func() {
+	if x.options.Registry != nil {
+		Register[Message](x.options.Registry, x)
+	}
 
-			// 退出的时候需要设置自己的退出标记位
-			x.selfWorkerWg.Done()
+	x.stateLock.Lock()
+	_ = x.setState(StateRunning)
+	x.stateLock.Unlock()
 
-			// 同时退出的时候如果有事件回调的话需要触发一下事件回调
-			if x.options.CloseEventListener != nil {
-				x.options.CloseEventListener()
-			}
+	var restored []envelope[Message]
+	if options.Store != nil {
+		restored = x.loadFromStore()
+	}
+
+	// 同步模式下没有处理消息的协程，消息在发送方的协程中直接处理掉，关闭时就处理完了
+	if x.synchronous != nil {
+		for _, envelope := range restored {
+			x.stats.sent.Add(1)
+			x.consumeInline(envelope)
+		}
+		return x
+	}
+
+	// 拉模式下没有处理消息的协程，由调用方通过Receive主动拉取消息，拉取到信道关闭时认为处理完毕
+	// 从Store中恢复的消息可能比缓存还多，所以在后台放入，调用方拉取的时候它们就会陆续放进来
+	if x.options.PullMode {
+		if len(restored) > 0 {
+			x.upstreamWg.Add(1)
+			x.spawn(goroutineRoleRestore, func() {
+				defer x.upstreamWg.Done()
+				x.putRestored(restored)
+			})
+		}
+		return x
+	}
+
+	// 启动处理消息的协程，开启了自动伸缩时先启动下限数量的协程
+	workers := 1
+	if options.AutoscaleOptions != nil {
+		x.pool = newWorkerPool()
+		x.retune = make(chan struct{}, 1)
+		workers, _ = autoscaleBounds(options.AutoscaleOptions)
+	}
+	for i := 0; i < workers; i++ {
+		x.startWorker()
+	}
+	if options.AutoscaleOptions != nil {
+		x.spawn(goroutineRoleAutoscale, x.autoscale)
+	}
+
+	if options.WatchdogOptions != nil {
+		x.spawn(goroutineRoleWatchdog, func() {
+			x.watch(options.WatchdogOptions)
+		})
+	}
+
+	// 处理消息的协程已经启动了，上次没有提交的消息放完之后信道才交给调用方，这样它们总是排在新的消息前面
+	x.putRestored(restored)
+
+	return x
+}
+
+// 处理消息的协程，消费完channel中所有的消息之后退出
+// 消费函数panic时如果配置了监督者会按照策略重新启动一个处理消息的协程，否则继续panic
+// generation: 协程的代数，看门狗重启协程之后旧的协程处理完手上的消息就会退出
+// retire: 自动伸缩缩容时被取消，协程处理完手上的消息之后退出
+func (x *Channel[Message]) work(generation uint64, retire context.Context) {
+
+	normalExit := false
 
-		}()
+	// 正在处理的消息，崩溃时需要把它作为被丢弃的消息报告出去，事务模式下是正在提交的那一批消息
+	var current envelope[Message]
+	var batch []envelope[Message]
+	defer func() {
+		if normalExit {
+			if x.runningWorkers.Add(-1) == 0 {
+				if x.keyed != nil {
+					x.keyed.wait()
+				}
+				if x.ordered != nil {
+					x.ordered.wait()
+				}
+				if x.async != nil {
+					x.async.wait()
+				}
+				x.closeDistributionChildren()
+				x.finish()
+			}
+			return
+		}
+		reason := recover()
 
-		// 开始消费，处理channel
-		count := 0
-		for message := range x.channel {
-			count++
-			if x.options.ChannelConsumerFunc != nil {
-				x.options.ChannelConsumerFunc(count, message)
+		// 崩溃时正在处理的那条消息丢失了
+		if x.options.TransactionalSinkOptions != nil {
+			x.consumerFailed(consumerPanicError(reason), nil)
+			x.stats.inFlight.Add(-int64(len(batch)))
+			for _, envelope := range batch {
+				x.drop(DropReasonConsumerPanic, envelope.message)
+				x.checkpointEnd(envelope.offset)
 			}
+		} else {
+			x.consumerFailed(consumerPanicError(reason), current.message)
+			x.stats.inFlight.Add(-1)
+			x.drop(DropReasonConsumerPanic, current.message)
+			x.checkpointEnd(current.offset)
 		}
+		if x.supervisor == nil || !x.supervisor.allowRestart(reason) {
+			panic(reason)
+		}
+		x.spawn(goroutineRoleWorker, func() {
+			x.work(generation, retire)
+		})
 	}()
 
-	return x
+	if x.options.TransactionalSinkOptions != nil {
+		x.workTransactional(generation, retire, &batch)
+		normalExit = true
+		return
+	}
+
+	// 开始消费，处理channel，暂停的时候先不从channel中取消息
+	for {
+		// 已经被看门狗替换掉了或者被缩容了，剩下的消息交给其他的协程处理，交给其他协程处理的消息也要在取下一条之前检查
+		if x.workerGeneration.Load() != generation || retire.Err() != nil {
+			normalExit = true
+			return
+		}
+		if x.waitResumed(retire) != nil {
+			normalExit = true
+			return
+		}
+		envelope, ok, err := x.takeNext(retire)
+		if err != nil {
+			normalExit = true
+			return
+		}
+		if !ok {
+			break
+		}
+		if !x.admit(&envelope) {
+			continue
+		}
+		if x.async != nil {
+			x.consumeAsync(envelope)
+			continue
+		}
+		if x.keyed != nil {
+			x.keyed.submit(x.options.ConcurrencyKey(envelope.message), func() {
+				x.consumeDetached(envelope)
+			})
+			continue
+		}
+		if x.ordered != nil {
+			x.ordered.submit(func() []func() {
+				return x.consumeOrdered(envelope)
+			})
+			continue
+		}
+		current = envelope
+		x.stats.inFlight.Add(1)
+		x.consume(x.markConsumed(envelope), envelope, nil)
+		x.stats.inFlight.Add(-1)
+		x.markProcessed(envelope.offset)
+	}
+	normalExit = true
+}
+
+// 用消费函数处理一条消息并执行消费函数返回的处理决定，子信道处理完之后再转发给父信道，开启了混沌模式时先注入故障，配置了MaxInFlight时先占用一个名额
+// effects: 不为nil时下游操作不立即执行，而是追加到effects中由调用方按顺序执行
+func (x *Channel[Message]) consume(index int, envelope envelope[Message], effects *[]func()) {
+	if x.distributor != nil {
+		x.distribute(envelope)
+		return
+	}
+	if x.consumer.Load().empty() && x.forward == nil && len(x.options.Sinks) == 0 {
+		return
+	}
+	if !x.acquireInFlight() {
+		x.drop(DropReasonAborted, envelope.message)
+		return
+	}
+	defer x.releaseInFlight()
+	for times := x.injectChaos(envelope); times > 0; times-- {
+		x.deliver(index, envelope, effects)
+	}
+}
+
+// 把一条消息交给消费函数处理，按照消费函数返回的处理决定重试、转为死信或者转发给父信道
+func (x *Channel[Message]) deliver(index int, envelope envelope[Message], effects *[]func()) {
+	maxRetries := x.tuned().MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	ctx := x.consumeCtx
+	if envelope.replay {
+		ctx = context.WithValue(ctx, replayContextKey{}, true)
+	}
+	if envelope.request != 0 {
+		ctx = context.WithValue(ctx, requestContextKey{}, envelope.request)
+	}
+	if x.options.AutoCommitInterval > 0 {
+		ctx = context.WithValue(ctx, offsetContextKey{}, envelope.offset)
+	}
+	if envelope.overBudget {
+		ctx = context.WithValue(ctx, overLatencyBudgetContextKey{}, true)
+	}
+	for attempt := 0; ; attempt++ {
+		start := x.clock.Now()
+		decision := x.invokeConsumerTimeout(ctx, index, envelope.message)
+		elapsed := x.clock.Since(start)
+		x.stats.latency.observe(elapsed)
+		x.stats.latencyEWMA.observe(elapsed)
+		x.checkSlowConsume(envelope.message, elapsed)
+
+		switch decision := decision.(type) {
+		case Retry:
+			if maxRetries > 0 && attempt >= maxRetries {
+				x.deadLetter(envelope.message)
+				return
+			}
+			x.stats.retries.Add(1)
+			if !x.waitRetry(decision.After) {
+				x.drop(DropReasonAborted, envelope.message)
+				return
+			}
+		case DeadLetter:
+			x.deadLetter(envelope.message)
+			return
+		case skipTimedOut:
+			x.drop(DropReasonConsumeTimeout, envelope.message)
+			return
+		case consumeFailed:
+			x.drop(DropReasonConsumerError, envelope.message)
+			return
+		case StopChannel:
+			x.downstream(index, envelope, effects)
+			x.Abort()
+			return
+		default:
+			x.downstream(index, envelope, effects)
+			return
+		}
+	}
+}
+
+// 处理完的消息写出到Sinks并转发给父信道，effects不为nil时先攒起来由调用方执行
+func (x *Channel[Message]) downstream(index int, envelope envelope[Message], effects *[]func()) {
+	if effects == nil {
+		x.writeSinks(envelope.message)
+		x.forwardToParent(index, envelope)
+		return
+	}
+	*effects = append(*effects, func() {
+		x.writeSinks(envelope.message)
+		x.forwardToParent(index, envelope)
+	})
+}
+
+// 子信道把处理完的消息转发给父信道
+func (x *Channel[Message]) forwardToParent(index int, envelope envelope[Message]) {
+	if x.forward == nil {
+		return
+	}
+	if x.options.ForwardTransform != nil {
+		var ok bool
+		if envelope.message, ok = x.options.ForwardTransform(index, envelope.message); !ok {
+			return
+		}
+	}
+	envelope.accumulated = envelope.latency(x.clock.Now())
+	x.forwardEnvelope(envelope)
+}
+
+// 判断取出来的消息是否还应该被消费，不应该被消费的消息会被丢弃
+func (x *Channel[Message]) admit(envelope *envelope[Message]) bool {
+	if x.aborted.Load() {
+		x.drop(DropReasonAborted, envelope.message)
+		return false
+	}
+	if envelope.expired(x.clock.Now()) {
+		x.drop(DropReasonDeadlineExceeded, envelope.message)
+		return false
+	}
+	return x.checkLatencyBudget(envelope)
+}
+
+// 记录消费了一条消息，配置了Auditor时写出审计记录，返回这条消息的序号
+func (x *Channel[Message]) markConsumed(envelope envelope[Message]) int {
+	now := x.clock.Now()
+	x.stats.lastConsumeUnixNano.Store(now.UnixNano())
+	x.stats.throughput.observe(now)
+	x.audit(now, envelope)
+	x.checkpointBegin(envelope.offset)
+	return int(x.stats.consumed.Add(1))
+}
+
+// 信道中的消息都处理完毕时调用，只会生效一次
+func (x *Channel[Message]) finish() {
+	x.finishOnce.Do(func() {
+
+		// 子信道暂存着没有转发出去的消息的话先补发
+		x.closeBacklog()
+		x.closeSinks()
+		if x.options.AutoCommitInterval > 0 {
+			x.commitAcked()
+		}
+		x.finalCheckpoint()
+
+		x.stateLock.Lock()
+		_ = x.setState(StateClosed)
+		x.stateLock.Unlock()
+
+		// 先从注册表中移除，这样等待信道关闭的一方醒来之后就已经找不到这个信道了
+		if x.options.Registry != nil {
+			Unregister[Message](x.options.Registry, x)
+		}
+		if x.options.LeakTracker != nil {
+			x.options.LeakTracker.untrack(x.ID)
+		}
+
+		// 同时退出的时候如果有事件回调的话需要触发一下事件回调，回调在唤醒等待关闭的一方之前执行，
+		// 这样子信道被关闭之后就已经从父信道上移除了
+		if x.options.CloseEventListener != nil {
+			x.options.CloseEventListener()
+		}
+
+		// 退出的时候需要设置自己的退出标记位
+		x.cancelConsume()
+		x.requests.close()
+		x.selfWorkerWg.Done()
+		close(x.done)
+	})
 }
 
 // Send 往当前的消息队列中发送一条消息，消息放入之后会被异步处理
+// 缓存满的时候按照OverflowPolicy处理，丢弃策略下不会阻塞，被丢弃的消息通过OnDropped通知，此时仍然返回nil
+// 信道已经关闭时返回ErrChannelClosed，配置了RejectSendWhileDraining时正在关闭的信道返回ErrChannelDraining，配置了Store时保存失败返回Store的错误
 func (x *Channel[Message]) Send(ctx context.Context, message Message) error {
-	select {
-	case x.channel <- message:
+	if err := x.checkSendable(); err != nil {
+		return err
+	}
+	return x.sendEnvelope(ctx, envelope[Message]{message: message})
+}
+
+// SendWithDeadline 发送一条带有截止时间的消息，消息在缓存中等到了截止时间还没有被消费的话会被丢弃并通过OnDropped通知，而不是晚了之后再被处理
+// 截止时间是发送方给每条消息单独设置的绝对时间，会随着消息一起被子信道转发给父信道，每一层信道在消费之前都会检查一次
+func (x *Channel[Message]) SendWithDeadline(ctx context.Context, message Message, deadline time.Time) error {
+	if err := x.checkSendable(); err != nil {
+		return err
+	}
+	return x.sendEnvelope(ctx, envelope[Message]{message: message, deadline: deadline})
+}
+
+// 按照溢出策略把信封放入缓存
+func (x *Channel[Message]) sendEnvelope(ctx context.Context, envelope envelope[Message]) error {
+	envelope = x.stamp(envelope)
+	if err := x.persist(envelope); err != nil {
+		return err
+	}
+	if x.synchronous != nil {
+		x.enqueued(envelope)
+		x.consumeInline(envelope)
 		return nil
-	case <-ctx.Done():
-		return context.Canceled
 	}
+	if x.tuned().OverflowPolicy != OverflowBlock {
+		return x.sendOrDrop(envelope)
+	}
+	if x.fullNotifier != nil {
+		if x.buffer.tryPut(envelope) {
+			x.enqueued(envelope)
+			return nil
+		}
+		x.bufferFull()
+	}
+	if err := x.buffer.put(ctx, envelope); err != nil {
+		return err
+	}
+	x.enqueued(envelope)
+	return nil
 }
 
-// MakeChildChannel 创建一条新的消息队列，对接到当前的消息队列上作为一个子队列
-// 当前队列关闭之前需要等待所有的孩子队列关闭
-func (x *Channel[Message]) MakeChildChannel() *Channel[Message] {
+// SendUrgent 通过紧急通道发送一条消息，处理消息的时候总是先处理完紧急通道中的消息再处理普通的消息，
+// 这样控制类的命令就不会被排在大量积压的数据后面，紧急通道的缓存大小由UrgentBuffSize配置，满了的时候总是阻塞等待，不受OverflowPolicy影响
+func (x *Channel[Message]) SendUrgent(ctx context.Context, message Message) error {
+	if err := x.checkSendable(); err != nil {
+		return err
+	}
+	envelope := x.stamp(envelope[Message]{message: message})
+	if err := x.persist(envelope); err != nil {
+		return err
+	}
+	if x.synchronous != nil {
+		x.enqueued(envelope)
+		x.consumeInline(envelope)
+		return nil
+	}
+	if err := x.buffer.putUrgent(ctx, envelope); err != nil {
+		return err
+	}
+	x.enqueued(envelope)
+	return nil
+}
 
-	subChannel := NewChannel[Message](&ChannelOptions[Message]{
+// 检查信道当前的状态是否还可以发送消息
+func (x *Channel[Message]) checkSendable() error {
+	switch x.State() {
+	case StateClosed:
+		return ErrChannelClosed
+	case StateDraining:
+		if x.tuned().RejectSendWhileDraining {
+			return ErrChannelDraining
+		}
+	}
+	return nil
+}
 
-		// 创建一个子信道，并将子信道上的所有消息都转发到父信道上，这意味着父信道只能等子信道关闭之后才能够关闭
-		ChannelConsumerFunc: func(index int, message Message) {
-			x.channel <- message
-		},
+// Receive 从拉模式的信道中取出一条消息，信道中没有消息时会阻塞直到有消息到来或者ctx被取消
+// 信道已经被关闭并且剩余的消息都被取完时返回ErrChannelClosed，只有通过WithPullMode创建的信道才能调用此方法
+func (x *Channel[Message]) Receive(ctx context.Context) (Message, error) {
+	message, _, err := x.receive(ctx, nil)
+	return message, err
+}
+
+// MakeChildChannel 创建一条新的消息队列，对接到当前的消息队列上作为一个子队列
+// 当前队列关闭之前需要等待所有的孩子队列关闭
+// transforms: 可选的转发之前对消息的处理，按照顺序执行，其中一个返回false时这条消息就不会被转发了
+func (x *Channel[Message]) MakeChildChannel(transforms ...ForwardTransformFunc[Message]) *Channel[Message] {
+	return x.MakeChildChannelWithOptions(&ChannelOptions[Message]{
 
 		// 子信道的缓存大小和父信道保持一致
 		ChannelBuffSize: x.options.ChannelBuffSize,
+
+		ForwardTransform: chainForwardTransforms(transforms),
 	})
+}
 
-	// 在子信道关闭的时候告知父信道自己已经退出了
-	subChannel.options.CloseEventListener = func() {
+// 把多个转发之前的处理串起来，没有处理时返回nil
+func chainForwardTransforms[Message any](transforms []ForwardTransformFunc[Message]) ForwardTransformFunc[Message] {
+	if len(transforms) == 0 {
+		return nil
+	}
+	return func(index int, message Message) (Message, bool) {
+		for _, transform := range transforms {
+			var ok bool
+			if message, ok = transform(index, message); !ok {
+				return message, false
+			}
+		}
+		return message, true
+	}
+}
+
+// MakeChildChannelWithOptions 使用自己的选项创建一个子信道，子信道可以有自己的名字、缓存大小、回调和各种策略
+// 子信道配置了消费函数的话，消息先交给消费函数处理，处理完之后再经过ForwardTransform转发给父信道，消费函数返回的处理决定不是Ack时不会转发
+// 子信道总是需要把消息转发给父信道，所以不能是拉模式的，PullMode会被忽略；没有设置ErrorListener、MaxDepth、Clock、LeakTracker和GoroutineRegistry时继承父信道的，标签和父信道的合并
+// 子信道的深度超过MaxDepth或者子信道的数量超过MaxChildren时创建失败，返回nil并通过ErrorListener报告ErrMaxDepthExceeded或者ErrMaxChildrenExceeded
+// options不会被修改，可以用来创建多个子信道，需要在调用的地方处理创建失败的情况时使用MakeChildChannelContext
+func (x *Channel[Message]) MakeChildChannelWithOptions(options *ChannelOptions[Message]) *Channel[Message] {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancelFunc()
+	subChannel, err := x.makeChildChannel(ctx, options)
+	if err != nil {
+		x.reportError(ErrorOpAddChild, err)
+	}
+	return subChannel
+}
+
+// MakeChildChannelContext 和MakeChildChannelWithOptions一样创建一个子信道，只是创建失败时把错误返回给调用方而不是通过ErrorListener报告
+// 父信道已经关闭时返回ErrParentClosed，超过MaxDepth或者MaxChildren时返回ErrMaxDepthExceeded或者ErrMaxChildrenExceeded，ctx在注册之前就结束了时返回ctx的错误
+// 返回错误时不会留下创建了一半的子信道
+func (x *Channel[Message]) MakeChildChannelContext(ctx context.Context, options *ChannelOptions[Message]) (*Channel[Message], error) {
+	if x.buffer.isClosed() {
+		return nil, ErrParentClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return x.makeChildChannel(ctx, options)
+}
+
+// 创建一个子信道并注册到当前信道上，注册失败时关闭已经创建出来的子信道并返回nil
+func (x *Channel[Message]) makeChildChannel(ctx context.Context, options *ChannelOptions[Message]) (*Channel[Message], error) {
+
+	if x.options.MaxDepth > 0 && x.depth+1 > x.options.MaxDepth {
+		return nil, fmt.Errorf("%w: max depth is %d", ErrMaxDepthExceeded, x.options.MaxDepth)
+	}
+
+	childOptions := *options
+	childOptions.PullMode = false
+	childOptions.Tags = mergeTags(x.options.Tags, options.Tags)
+	if childOptions.ErrorListener == nil {
+		childOptions.ErrorListener = x.options.ErrorListener
+	}
+	if childOptions.MaxDepth == 0 {
+		childOptions.MaxDepth = x.options.MaxDepth
+	}
+	if childOptions.Clock == nil {
+		childOptions.Clock = x.options.Clock
+	}
+	if childOptions.LeakTracker == nil {
+		childOptions.LeakTracker = x.options.LeakTracker
+	}
+	if childOptions.GoroutineRegistry == nil {
+		childOptions.GoroutineRegistry = x.options.GoroutineRegistry
+	}
+	if childOptions.LatencyBudgetOptions == nil {
+		childOptions.LatencyBudgetOptions = x.options.LatencyBudgetOptions
+	}
+
+	// 在子信道关闭的时候告知父信道自己已经退出了，然后再触发子信道自己的关闭回调，没有注册成功的子信道不会触发
+	var subChannel *Channel[Message]
+	registered := &atomic.Bool{}
+	closeEventListener := options.CloseEventListener
+	childOptions.CloseEventListener = func() {
+		if !registered.Load() {
+			return
+		}
 		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*30)
 		defer cancelFunc()
 		err := x.childrenChannelMap.Remove(ctx, subChannel.ID)
 		if err != nil {
-			// TODO
+			x.reportError(ErrorOpRemoveChild, err)
+		}
+		if closeEventListener != nil {
+			closeEventListener()
 		}
 	}
 
-	// 为当前信道增加一个孩子信道
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancelFunc()
-	err := x.childrenChannelMap.Set(ctx, subChannel.ID, subChannel)
+	// 创建一个子信道，并将子信道上的所有消息都转发到父信道上，这意味着父信道只能等子信道关闭之后才能够关闭
+	subChannel = newChannel[Message](&childOptions, x.acceptForwarded, nil)
+	subChannel.depth = x.depth + 1
+
+	// 为当前信道增加一个孩子信道，检查数量和加入map在同一次加锁中完成
+	err := x.childrenChannelMap.Run(ctx, func(ctx context.Context, m map[uint64]*Channel[Message]) error {
+		if x.options.MaxChildren > 0 && len(m) >= x.options.MaxChildren {
+			return fmt.Errorf("%w: max children is %d", ErrMaxChildrenExceeded, x.options.MaxChildren)
+		}
+		m[subChannel.ID] = subChannel
+		registered.Store(true)
+		return nil
+	})
 	if err != nil {
-		// TODO
+		subChannel.SenderWaitAndClose()
+		return nil, err
 	}
 
-	return subChannel
+	return subChannel, nil
 }
 
 // ReceiverWait 消息的接收方调用的，消息的接收方需要同步等待此消息信道被处理完毕时调用
 func (x *Channel[Message]) ReceiverWait(ctx context.Context) {
 	// 消息接收方等待发送消息的协程退出就认为是信道已经处理完了
-	x.selfWorkerWg.Wait()
-	select {}
+	select {
+	case <-x.done:
+	case <-ctx.Done():
+	}
 }
 
 // SenderWaitAndClose 消息的发送方调用，消息的发送方需要同步等待消息被处理完时调用
+// 等待子信道退出最多等CloseTimeout这么长的时间，超时之后的处理见CloseEscalation
+// f: 可选的在等待子信道退出时每次检查子信道map时执行的函数，已经不推荐使用了，需要观察关闭的进度时配置OnDrainProgress
 func (x *Channel[Message]) SenderWaitAndClose(f ...MapRunFunc[Message]) {
 
+	start := x.clock.Now()
+	x.beginDraining()
+
 	if len(f) == 0 {
 		f = append(f, nil)
 	}
 
-	// 等待子channel消费完成退出
-	timeout, cancelFunc := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancelFunc()
-	err := x.childrenChannelMap.BlockUtilEmpty(timeout, f[0])
-	if err != nil {
-		// TODO
+	// 等待子channel消费完成退出，超时之后按照CloseEscalation处理
+	if !x.drainChildren(start, f[0]) {
+		return
 	}
 
+	// 等待上游信道的泵协程把消息都转发过来
+	x.upstreamWg.Wait()
+
 	// 关闭channel表示发送者不会再发送了，发送完队列中剩余的想这些就要退出了
-	close(x.channel)
+	x.closeChannel()
 
 	// 等待消费完队列中剩余的消息
 	x.selfWorkerWg.Wait()
 }
 
 // TopologyAscii 把拓扑逻辑转为ASCII图形，这样就能比较方便的观察依赖关系了
+// 每一行是一个信道，展示信道的名字、ID、缓存中还没有被消费的消息数以及标签，子信道和分发子信道一起按照ID排序
+// f: 可选的在每个信道的子信道map上执行的函数，可以用来在遍历的时候顺便收集一些信息
 func (x *Channel[Message]) TopologyAscii(f ...MapRunFunc[Message]) string {
-	// TODO
-	return ""
+	builder := &strings.Builder{}
+	builder.WriteString(x.topologyLabel())
+	builder.WriteString("\n")
+	x.writeTopologyChildren(builder, "", f)
+	return builder.String()
+}
+
+// 信道在拓扑图中展示的标签
+func (x *Channel[Message]) topologyLabel() string {
+	name := x.options.Name
+	if name == "" {
+		name = "channel"
+	}
+	label := fmt.Sprintf("%s#%d pending=%d", name, x.ID, x.buffer.len())
+	if tags := formatTags(x.options.Tags); tags != "" {
+		label += " " + tags
+	}
+	return label
+}
+
+// 递归的输出子信道，prefix是当前层级的缩进
+func (x *Channel[Message]) writeTopologyChildren(builder *strings.Builder, prefix string, f []MapRunFunc[Message]) {
+	var children []*Channel[Message]
+	_ = x.childrenChannelMap.Run(context.Background(), func(ctx context.Context, m map[uint64]*Channel[Message]) error {
+		for _, child := range m {
+			children = append(children, child)
+		}
+		for _, runFunc := range f {
+			if runFunc != nil {
+				if err := runFunc(ctx, m); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	children = append(children, x.DistributionChildren()...)
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].ID < children[j].ID
+	})
+
+	for index, child := range children {
+		branch, indent := "├── ", "│   "
+		if index == len(children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		builder.WriteString(prefix + branch + child.topologyLabel() + "\n")
+		child.writeTopologyChildren(builder, prefix+indent, f)
+	}
 }
@@ -1,6 +1,8 @@
 package message_channel
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -26,14 +28,177 @@ func TestChannel_Single(t *testing.T) {
 	channel := NewChannel[string](options)
 	go func() {
 		for i := 0; i < 10; i++ {
-			channel.Send(fmt.Sprintf("message %d", i))
+			channel.Send(context.Background(), fmt.Sprintf("message %d", i))
 			time.Sleep(time.Second)
 		}
 		channel.SenderWaitAndClose()
 	}()
-	channel.ReceiverWait()
+	channel.ReceiverWait(context.Background())
 }
 
 func TestChannel_Very_Complex(t *testing.T) {
 
 }
+
+func TestChannel_CloseWithoutConsumer(t *testing.T) {
+	// 没有设置消费函数也不是拉模式的信道，消息会被直接丢弃，关闭时不需要有人拉取
+	channel := NewChannel[string](NewChannelOptions[string]())
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, channel.Send(context.Background(), fmt.Sprintf("message %d", i)))
+	}
+	channel.SenderWaitAndClose()
+	_, err := channel.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrNotPullMode)
+}
+
+func TestChannel_Receive(t *testing.T) {
+	channel := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(1))
+	go func() {
+		assert.Nil(t, channel.Send(context.Background(), "message"))
+		channel.SenderWaitAndClose()
+	}()
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "message", message)
+	_, err = channel.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+	channel.ReceiverWait(context.Background())
+}
+
+func TestChannel_ContextConsumerFunc(t *testing.T) {
+	started := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		if message == 1 {
+			return errors.New("bad message")
+		}
+
+		// 一直等到信道被Abort
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	<-started
+	channel.Abort()
+	channel.ReceiverWait(context.Background())
+	assert.Equal(t, uint64(2), channel.Stats().ConsumerErrors)
+}
+
+func TestChannel_ErrorListener(t *testing.T) {
+	ops := make([]string, 0)
+	root := NewChannel[int](NewChannelOptions[int]().WithErrorListener(func(op string, err error) {
+		ops = append(ops, op)
+		assert.EqualError(t, err, "inspect failed")
+	}))
+	child := root.MakeChildChannel()
+	child.SenderWaitAndClose()
+	middle := root.MakeChildChannel()
+	grandchild := middle.MakeChildChannel()
+	root.SenderWaitAndClose(func(ctx context.Context, m map[uint64]*Channel[int]) error {
+		return errors.New("inspect failed")
+	})
+	assert.Equal(t, []string{ErrorOpDrainChildren}, ops)
+	assert.NotNil(t, grandchild.options.ErrorListener)
+	grandchild.SenderWaitAndClose()
+	middle.SenderWaitAndClose()
+}
+
+func TestChannel_MakeChildChannelWithOptions(t *testing.T) {
+	received := make([]string, 0)
+	root := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message string) {
+		received = append(received, message)
+	}))
+
+	seen := make([]string, 0)
+	closed := false
+	options := NewChannelOptions[string]().WithName("child").WithChannelBuffSize(1).WithPullMode().WithChannelConsumerFunc(func(index int, message string) {
+		seen = append(seen, message)
+	}).WithCloseEventListener(func() {
+		closed = true
+	})
+	child := root.MakeChildChannelWithOptions(options)
+	assert.Equal(t, "child", child.Stats().Name)
+	assert.Equal(t, 1, child.Stats().Capacity)
+	assert.True(t, options.PullMode)
+
+	assert.Nil(t, child.Send(context.Background(), "a"))
+	assert.Nil(t, child.Send(context.Background(), "b"))
+	child.SenderWaitAndClose()
+	assert.True(t, closed)
+	size, err := root.childrenChannelMap.Size(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, size)
+
+	root.SenderWaitAndClose()
+	assert.Equal(t, []string{"a", "b"}, seen)
+	assert.Equal(t, []string{"a", "b"}, received)
+}
+
+func TestChannel_MakeChildChannelTransform(t *testing.T) {
+	received := make([]int, 0)
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		received = append(received, message)
+	}))
+
+	// 丢掉奇数，剩下的乘以10之后再转发
+	child := root.MakeChildChannel(func(index int, message int) (int, bool) {
+		return message, message%2 == 0
+	}, func(index int, message int) (int, bool) {
+		return message * 10, true
+	})
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+	}
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+	assert.Equal(t, []int{20, 40}, received)
+}
+
+func TestChannel_MaxDepth(t *testing.T) {
+	errs := make([]error, 0)
+	root := NewChannel[int](NewChannelOptions[int]().WithMaxDepth(2).WithErrorListener(func(op string, err error) {
+		assert.Equal(t, ErrorOpAddChild, op)
+		errs = append(errs, err)
+	}))
+	child := root.MakeChildChannel()
+	grandchild := child.MakeChildChannel()
+	assert.NotNil(t, grandchild)
+	assert.Nil(t, grandchild.MakeChildChannel())
+	assert.Equal(t, 1, len(errs))
+	assert.ErrorIs(t, errs[0], ErrMaxDepthExceeded)
+
+	grandchild.SenderWaitAndClose()
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+}
+
+func TestChannel_MakeChildChannelContext(t *testing.T) {
+	closed := 0
+	root := NewChannel[int](NewChannelOptions[int]().WithMaxChildren(1).WithErrorListener(func(op string, err error) {
+		t.Errorf("unexpected error: %s %v", op, err)
+	}))
+	child, err := root.MakeChildChannelContext(context.Background(), NewChannelOptions[int]())
+	assert.Nil(t, err)
+	assert.NotNil(t, child)
+
+	// 超过MaxChildren的子信道没有被注册，也不会触发它的关闭回调
+	extra, err := root.MakeChildChannelContext(context.Background(), NewChannelOptions[int]().WithCloseEventListener(func() {
+		closed++
+	}))
+	assert.ErrorIs(t, err, ErrMaxChildrenExceeded)
+	assert.Nil(t, extra)
+	assert.Equal(t, 0, closed)
+	size, _ := root.childrenChannelMap.Size(context.Background())
+	assert.Equal(t, 1, size)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = root.MakeChildChannelContext(ctx, NewChannelOptions[int]())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+	_, err = root.MakeChildChannelContext(context.Background(), NewChannelOptions[int]())
+	assert.ErrorIs(t, err, ErrParentClosed)
+}
@@ -0,0 +1,134 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MuxFrame 多路复用的传输上的一帧，Stream标明这一帧属于哪一路，Data是按照这一路的Codec编码之后的消息
+// 帧本身只是普通的结构体，可以直接放进Channel[MuxFrame]，也可以编码之后通过网络之类的桥接传到另一边
+type MuxFrame struct {
+	Stream string `json:"stream"`
+	Data   []byte `json:"data"`
+}
+
+// Mux 把多路消息类型各不相同的信道合并到同一个传输信道上，每条消息都标上所属的流，另一边用Demux还原成各自类型的信道
+// 这样很多路逻辑上独立的流只需要一个网络连接之类的桥接，传输信道在关闭之前会等待所有接入的流都关闭
+type Mux struct {
+	transport *Channel[MuxFrame]
+}
+
+// NewMux 创建一个往transport上复用的Mux
+func NewMux(transport *Channel[MuxFrame]) *Mux {
+	return &Mux{transport: transport}
+}
+
+// MuxStream 把拉模式信道src以stream的名字接入mux，src中的消息按照codec编码之后作为帧发送到传输信道，编码失败的消息通过src的ErrorListener报告之后丢弃
+// src必须是拉模式的，否则会panic，src关闭之后这一路就结束了，因为需要根据消息类型实例化，所以这里是一个函数而不是方法
+func MuxStream[Message any](mux *Mux, stream string, src *Channel[Message], codec Codec[Message]) {
+	mustPullMode[Message]("MuxStream", src)
+	connectTransform[MuxFrame](mux.transport, false, func(emit EmitFunc[MuxFrame]) {
+		for {
+			message, err := src.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			data, err := codec.Encode(message)
+			if err != nil {
+				src.reportError(ErrorOpMux, fmt.Errorf("message channel: mux stream %s: %w", stream, err))
+				continue
+			}
+			if err := emit(MuxFrame{Stream: stream, Data: data}); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// Demux 从传输信道上读取Mux复用过来的帧，按照所属的流解码之后发送到各自类型的信道中
+// 先用DemuxStream注册好每一路，再调用Start开始读取，传输信道关闭之后所有还原出来的信道也会跟着关闭
+// 没有注册的流的帧按照DropReasonUnroutable在传输信道上丢弃，解码失败的帧通过传输信道的ErrorListener报告之后丢弃
+type Demux struct {
+	transport *Channel[MuxFrame]
+
+	lock    *sync.Mutex
+	streams map[string]*demuxStream
+	started bool
+}
+
+// 一路还原出来的信道
+type demuxStream struct {
+
+	// 解码之后发送到还原出来的信道中
+	deliver func(data []byte) error
+
+	// 传输信道关闭之后关闭还原出来的信道
+	close func()
+}
+
+// NewDemux 创建一个从transport上读取的Demux，transport必须是拉模式的，否则会panic
+func NewDemux(transport *Channel[MuxFrame]) *Demux {
+	mustPullMode[MuxFrame]("NewDemux", transport)
+	return &Demux{
+		transport: transport,
+		lock:      &sync.Mutex{},
+		streams:   make(map[string]*demuxStream),
+	}
+}
+
+// DemuxStream 注册名字为stream的一路，返回还原出来的拉模式信道，需要在Start之前调用，同一个名字注册两次或者已经Start了会panic
+func DemuxStream[Message any](demux *Demux, stream string, codec Codec[Message]) *Channel[Message] {
+	out := NewChannel[Message](NewChannelOptions[Message]().WithChannelBuffSize(demux.transport.options.ChannelBuffSize).WithPullMode())
+	demux.lock.Lock()
+	defer demux.lock.Unlock()
+	if demux.started {
+		panic(fmt.Sprintf("message channel: DemuxStream: stream %s registered after Start", stream))
+	}
+	if _, ok := demux.streams[stream]; ok {
+		panic(fmt.Sprintf("message channel: DemuxStream: stream %s registered twice", stream))
+	}
+	demux.streams[stream] = &demuxStream{
+		deliver: func(data []byte) error {
+			message, err := codec.Decode(data)
+			if err != nil {
+				return err
+			}
+			_ = out.Send(context.Background(), message)
+			return nil
+		},
+		close: out.closeAndWait,
+	}
+	return out
+}
+
+// Start 开始从传输信道上读取帧，只有第一次调用生效
+func (x *Demux) Start() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.started {
+		return
+	}
+	x.started = true
+	x.transport.spawn(goroutineRoleDemux, x.run)
+}
+
+func (x *Demux) run() {
+	for {
+		frame, err := x.transport.Receive(context.Background())
+		if err != nil {
+			break
+		}
+		stream, ok := x.streams[frame.Stream]
+		if !ok {
+			x.transport.drop(DropReasonUnroutable, frame)
+			continue
+		}
+		if err := stream.deliver(frame.Data); err != nil {
+			x.transport.reportError(ErrorOpMux, fmt.Errorf("message channel: demux stream %s: %w", frame.Stream, err))
+		}
+	}
+	for _, stream := range x.streams {
+		stream.close()
+	}
+}
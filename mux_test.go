@@ -0,0 +1,99 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+
+	// 接收的一边，传输信道中的帧由下面模拟的网络桥接放进来
+	remote := NewChannel[MuxFrame](NewChannelOptions[MuxFrame]().WithChannelBuffSize(10).WithPullMode())
+	demux := NewDemux(remote)
+	numbers := DemuxStream[int](demux, "numbers", JSONCodec[int]())
+	words := DemuxStream[string](demux, "words", JSONCodec[string]())
+	demux.Start()
+
+	// 发送的一边，每一帧编码为字节之后通过“网络”传到另一边
+	transport := NewChannel[MuxFrame](NewChannelOptions[MuxFrame]().WithChannelConsumerFunc(func(index int, frame MuxFrame) {
+		data, err := json.Marshal(frame)
+		assert.Nil(t, err)
+		var received MuxFrame
+		assert.Nil(t, json.Unmarshal(data, &received))
+		assert.Nil(t, remote.Send(context.Background(), received))
+	}))
+	mux := NewMux(transport)
+	numberSource := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	wordSource := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithPullMode())
+	MuxStream[int](mux, "numbers", numberSource, JSONCodec[int]())
+	MuxStream[string](mux, "words", wordSource, JSONCodec[string]())
+
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, numberSource.Send(context.Background(), i))
+	}
+	assert.Nil(t, wordSource.Send(context.Background(), "hello"))
+	assert.Nil(t, wordSource.Send(context.Background(), "world"))
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	var gotNumbers []int
+	var gotWords []string
+	go func() {
+		defer wg.Done()
+		gotNumbers = drain[int](numbers)
+	}()
+	go func() {
+		defer wg.Done()
+		gotWords = drain[string](words)
+	}()
+
+	// 按照顺序关闭：源信道、发送端的传输信道、接收端的传输信道，还原出来的信道跟着关闭
+	numberSource.SenderWaitAndClose()
+	wordSource.SenderWaitAndClose()
+	transport.SenderWaitAndClose()
+	remote.SenderWaitAndClose()
+	wg.Wait()
+
+	assert.Equal(t, []int{1, 2, 3}, gotNumbers)
+	assert.Equal(t, []string{"hello", "world"}, gotWords)
+}
+
+func TestDemux_Unroutable(t *testing.T) {
+	dropped := make(chan MuxFrame, 1)
+	ops := make(chan string, 1)
+	transport := NewChannel[MuxFrame](NewChannelOptions[MuxFrame]().WithChannelBuffSize(10).WithPullMode().WithOnDropped(func(reason DropReason, frame MuxFrame) {
+		dropped <- frame
+	}).WithErrorListener(func(op string, err error) {
+		ops <- op
+	}))
+	demux := NewDemux(transport)
+	numbers := DemuxStream[int](demux, "numbers", JSONCodec[int]())
+	assert.Panics(t, func() {
+		DemuxStream[int](demux, "numbers", JSONCodec[int]())
+	})
+	demux.Start()
+
+	assert.Nil(t, transport.Send(context.Background(), MuxFrame{Stream: "unknown", Data: []byte("1")}))
+	assert.Nil(t, transport.Send(context.Background(), MuxFrame{Stream: "numbers", Data: []byte("not a number")}))
+	assert.Nil(t, transport.Send(context.Background(), MuxFrame{Stream: "numbers", Data: []byte("2")}))
+	transport.SenderWaitAndClose()
+
+	assert.Equal(t, []int{2}, drain[int](numbers))
+	assert.Equal(t, "unknown", (<-dropped).Stream)
+	assert.Equal(t, ErrorOpMux, <-ops)
+}
+
+// 取出拉模式信道中所有的消息，直到信道关闭
+func drain[Message any](channel *Channel[Message]) []Message {
+	messages := make([]Message, 0)
+	for {
+		message, err := channel.Receive(context.Background())
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
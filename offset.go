@@ -0,0 +1,253 @@
+package message_channel
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Store 持久化信道中的消息和已经提交的偏移量，配置了Store的信道重新创建时会从上次提交的偏移量之后继续消费
+// 所有方法都可能被并发调用，实现需要自己保证并发安全
+type Store[Message any] interface {
+
+	// Append 在消息放入信道之前保存这条消息，返回错误时消息不会被放入信道，发送方会收到这个错误
+	// 保存之后没能放入信道的消息（比如被溢出策略丢弃了或者信道已经关闭了）仍然留在Store中，重新创建信道时会被再次投递，所以Store提供的是至少一次的语义
+	Append(offset uint64, message Message) error
+
+	// Read 按照偏移量从小到大读取偏移量不小于from的消息，f返回false时停止读取
+	Read(ctx context.Context, from uint64, f func(offset uint64, message Message) bool) error
+
+	// SaveCommitted 保存已经提交的偏移量，偏移量不超过它的消息之后不会再被读取，实现可以在这时删除它们
+	SaveCommitted(offset uint64) error
+
+	// LoadCommitted 读取上次提交的偏移量，从来没有提交过时返回0
+	LoadCommitted() (uint64, error)
+}
+
+// offsetTracker 信道中消息的偏移量，每条放入信道的消息都会被分配一个递增的偏移量，从1开始
+type offsetTracker struct {
+
+	// 最近一次分配的偏移量，放入失败的消息也会占用一个偏移量，所以偏移量是递增的但不保证是连续的
+	last atomic.Uint64
+
+	// 最近一条处理完的消息的偏移量，多个协程并发处理消息时是处理完的消息中最大的偏移量
+	processed atomic.Uint64
+
+	// 通过AckUpTo确认的最大的偏移量，由自动提交定期提交
+	acked atomic.Uint64
+
+	// 已经提交的偏移量，只会增大
+	lock      *sync.Mutex
+	committed uint64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		lock: &sync.Mutex{},
+	}
+}
+
+// CommittedOffset 已经提交的偏移量，偏移量不超过它的消息都已经被外部的检查点系统确认过了
+func (x *Channel[Message]) CommittedOffset() uint64 {
+	x.offsets.lock.Lock()
+	defer x.offsets.lock.Unlock()
+	return x.offsets.committed
+}
+
+// Commit 提交偏移量，表示偏移量不超过offset的消息都已经处理完了，配置了Store时会同时保存到Store中
+// 提交的偏移量只会增大，比已经提交的偏移量小的提交会被忽略，事务模式下每一批消息提交成功之后会自动提交这一批的偏移量
+func (x *Channel[Message]) Commit(offset uint64) error {
+	x.offsets.lock.Lock()
+	defer x.offsets.lock.Unlock()
+	if offset <= x.offsets.committed {
+		return nil
+	}
+	if x.options.Store != nil {
+		if err := x.options.Store.SaveCommitted(offset); err != nil {
+			return err
+		}
+	}
+	x.offsets.committed = offset
+	return nil
+}
+
+// ProcessedOffset 最近一条处理完的消息的偏移量，检查点系统可以定期用它来调用Commit
+func (x *Channel[Message]) ProcessedOffset() uint64 {
+	return x.offsets.processed.Load()
+}
+
+// LastOffset 最近一条放入信道的消息的偏移量
+func (x *Channel[Message]) LastOffset() uint64 {
+	return x.offsets.last.Load()
+}
+
+// 给要放入信道的消息分配偏移量，Ask发出的请求改由当前信道持有，开启了QueueLatency或者配置了LatencyBudgetOptions时同时记录放入的时间
+func (x *Channel[Message]) stamp(envelope envelope[Message]) envelope[Message] {
+	envelope.offset = x.offsets.last.Add(1)
+	if envelope.request != 0 {
+		x.requests.adopt(envelope.request)
+	}
+	if x.recordsEnqueueTime() {
+		envelope.enqueuedAt = x.clock.Now()
+	}
+	return envelope
+}
+
+// 配置了Store时在消息放入信道之前保存这条消息，保存失败时消息不应该被放入信道
+func (x *Channel[Message]) persist(envelope envelope[Message]) error {
+	if x.options.Store == nil {
+		return nil
+	}
+	return x.options.Store.Append(envelope.offset, envelope.message)
+}
+
+// 消息成功放入信道之后调用，配置了Recorder时录制这条消息，开启了调试采样器时按照速率记录这条消息，配置了OnPressureChange时检查压力等级
+func (x *Channel[Message]) enqueued(envelope envelope[Message]) {
+	x.stats.sent.Add(1)
+	now := x.clock.Now()
+	x.stats.arrivalRate.observe(now)
+	if x.options.SilenceTimeout > 0 {
+		x.stats.lastSendUnixNano.Store(now.UnixNano())
+	}
+	x.trackPending(envelope)
+	x.sampleDebug(envelope)
+	x.checkPressure()
+	if x.options.Recorder != nil {
+		if err := x.options.Recorder.Record(now, envelope.message); err != nil {
+			x.reportError(ErrorOpRecord, err)
+		}
+	}
+}
+
+// 记录一条消息处理完了
+func (x *Channel[Message]) markProcessed(offset uint64) {
+	x.checkpointEnd(offset)
+	x.checkPressure()
+	for {
+		processed := x.offsets.processed.Load()
+		if offset <= processed || x.offsets.processed.CompareAndSwap(processed, offset) {
+			return
+		}
+	}
+}
+
+// 读取Store中上次提交的偏移量之后的消息，信道交给调用方之前执行，这样新的消息分配的偏移量不会和它们重复
+// 返回的消息需要再放入缓存中
+func (x *Channel[Message]) loadFromStore() []envelope[Message] {
+	store := x.options.Store
+	committed, err := store.LoadCommitted()
+	if err != nil {
+		x.reportError(ErrorOpStore, err)
+		return nil
+	}
+	x.offsets.lock.Lock()
+	x.offsets.committed = committed
+	x.offsets.lock.Unlock()
+	x.offsets.last.Store(committed)
+	x.offsets.processed.Store(committed)
+
+	var pending []envelope[Message]
+	err = store.Read(context.Background(), committed+1, func(offset uint64, message Message) bool {
+		pending = append(pending, envelope[Message]{message: message, offset: offset})
+		if offset > x.offsets.last.Load() {
+			x.offsets.last.Store(offset)
+		}
+		return true
+	})
+	if err != nil {
+		x.reportError(ErrorOpStore, err)
+	}
+	return pending
+}
+
+// 把从Store中恢复的消息放入缓存，它们已经在Store中了，不需要再保存
+func (x *Channel[Message]) putRestored(pending []envelope[Message]) {
+	for _, envelope := range pending {
+		if err := x.buffer.put(context.Background(), envelope); err != nil {
+			return
+		}
+		x.stats.sent.Add(1)
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// memoryStore 保存在内存中的Store
+type memoryStore[Message any] struct {
+	lock      *sync.RWMutex
+	offsets   []uint64
+	messages  map[uint64]Message
+	committed uint64
+}
+
+// NewMemoryStore 创建一个保存在内存中的Store，进程退出之后就没有了，适合测试以及在同一个进程中重建信道
+// 提交之后偏移量不超过提交的偏移量的消息会被删除，这样长时间运行的信道占用的内存不会一直增长，它们也就不能再通过ReplayFrom重新投递了
+func NewMemoryStore[Message any]() Store[Message] {
+	return &memoryStore[Message]{
+		lock:     &sync.RWMutex{},
+		messages: make(map[uint64]Message),
+	}
+}
+
+func (x *memoryStore[Message]) Append(offset uint64, message Message) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if _, ok := x.messages[offset]; !ok {
+		// 并发发送时偏移量到达的顺序可能是乱的，保持偏移量有序
+		i := sort.Search(len(x.offsets), func(i int) bool {
+			return x.offsets[i] > offset
+		})
+		x.offsets = append(x.offsets, 0)
+		copy(x.offsets[i+1:], x.offsets[i:])
+		x.offsets[i] = offset
+	}
+	x.messages[offset] = message
+	return nil
+}
+
+func (x *memoryStore[Message]) Read(ctx context.Context, from uint64, f func(offset uint64, message Message) bool) error {
+	x.lock.RLock()
+	i := sort.Search(len(x.offsets), func(i int) bool {
+		return x.offsets[i] >= from
+	})
+	offsets := make([]uint64, len(x.offsets)-i)
+	copy(offsets, x.offsets[i:])
+	x.lock.RUnlock()
+
+	// 读取的时候不持有锁，这样f中可以继续往同一个Store中写入
+	for _, offset := range offsets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		x.lock.RLock()
+		message := x.messages[offset]
+		x.lock.RUnlock()
+		if !f(offset, message) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (x *memoryStore[Message]) SaveCommitted(offset uint64) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.committed = offset
+
+	// 删除已经提交的消息，剩下的偏移量拷贝到新的切片中，这样被删除的部分占用的内存可以被回收
+	i := sort.Search(len(x.offsets), func(i int) bool {
+		return x.offsets[i] > offset
+	})
+	for _, committed := range x.offsets[:i] {
+		delete(x.messages, committed)
+	}
+	x.offsets = append([]uint64(nil), x.offsets[i:]...)
+	return nil
+}
+
+func (x *memoryStore[Message]) LoadCommitted() (uint64, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.committed, nil
+}
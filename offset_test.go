@@ -0,0 +1,121 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_Offsets(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Equal(t, uint64(3), channel.LastOffset())
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(3), channel.ProcessedOffset())
+
+	assert.Equal(t, uint64(0), channel.CommittedOffset())
+	assert.Nil(t, channel.Commit(2))
+	assert.Nil(t, channel.Commit(1))
+	assert.Equal(t, uint64(2), channel.CommittedOffset())
+}
+
+func TestChannel_ResumeFromStore(t *testing.T) {
+	store := NewMemoryStore[int]()
+
+	// 第一次只处理并提交了前两条消息
+	first := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithStore(store))
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, first.Send(context.Background(), i))
+	}
+	for i := 1; i <= 2; i++ {
+		message, err := first.Receive(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, i, message)
+	}
+	assert.Nil(t, first.Commit(first.ProcessedOffset()))
+	assert.Equal(t, uint64(2), first.CommittedOffset())
+
+	// 剩下的消息丢掉了，但是Store中还有
+	assert.Equal(t, 3, first.Abort())
+
+	// 重新创建的信道从提交的偏移量之后继续消费，新的消息排在后面
+	received := make([]int, 0)
+	second := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(1).WithStore(store).WithChannelConsumerFunc(func(index int, message int) {
+		received = append(received, message)
+	}))
+	assert.Equal(t, uint64(2), second.CommittedOffset())
+	assert.Equal(t, uint64(5), second.LastOffset())
+	assert.Nil(t, second.Send(context.Background(), 6))
+	assert.Equal(t, uint64(6), second.LastOffset())
+	second.SenderWaitAndClose()
+	assert.Equal(t, []int{3, 4, 5, 6}, received)
+	assert.Equal(t, uint64(6), second.ProcessedOffset())
+}
+
+func TestChannel_TransactionalSinkCommitsOffsets(t *testing.T) {
+	sink := &memorySink{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithTransactionalSink(sink, 2, 0))
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(4), channel.CommittedOffset())
+}
+
+// 包装一个Store，可以让Append失败，并记录Append时信道缓存中消息的数量
+type appendCheckingStore struct {
+	Store[int]
+	channel *Channel[int]
+	fail    bool
+	depths  []int
+}
+
+func (x *appendCheckingStore) Append(offset uint64, message int) error {
+	if x.fail {
+		return errors.New("disk full")
+	}
+	x.depths = append(x.depths, x.channel.Stats().Depth)
+	return x.Store.Append(offset, message)
+}
+
+func TestChannel_StoreAppendBeforeEnqueue(t *testing.T) {
+	store := &appendCheckingStore{Store: NewMemoryStore[int]()}
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithStore(store))
+	store.channel = channel
+
+	// 先保存再放入缓存，保存的时候消息还没有出现在缓存中
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.Equal(t, []int{0, 1}, store.depths)
+
+	// 保存失败时消息不会被放入信道
+	store.fail = true
+	assert.EqualError(t, channel.Send(context.Background(), 3), "disk full")
+	assert.Equal(t, 2, channel.Stats().Depth)
+	store.fail = false
+
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2}, drain[int](channel))
+}
+
+func TestMemoryStore_TrimCommitted(t *testing.T) {
+	store := NewMemoryStore[int]()
+	for offset := uint64(1); offset <= 5; offset++ {
+		assert.Nil(t, store.Append(offset, int(offset)*10))
+	}
+
+	// 提交之后偏移量不超过提交的偏移量的消息被删除了
+	assert.Nil(t, store.SaveCommitted(3))
+	messages := make([]int, 0)
+	assert.Nil(t, store.Read(context.Background(), 0, func(offset uint64, message int) bool {
+		messages = append(messages, message)
+		return true
+	}))
+	assert.Equal(t, []int{40, 50}, messages)
+	memory := store.(*memoryStore[int])
+	assert.Equal(t, 2, len(memory.messages))
+	assert.Equal(t, []uint64{4, 5}, memory.offsets)
+}
@@ -0,0 +1,133 @@
+package message_channel
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// pendingAges 记录缓存中每条还没有被取走的消息放入的时间，用来计算最早的一条消息已经等了多久
+// 缓存可能是按照优先级排序的，最早放入的不一定最先被取走，所以按照放入的时间另外维护一个小顶堆
+type pendingAges struct {
+	lock *sync.Mutex
+
+	// 还在缓存中的消息的偏移量到放入时间的映射
+	enqueuedAt map[uint64]time.Time
+
+	// 放入之后还没来得及登记就已经被取走了的消息的偏移量
+	taken map[uint64]struct{}
+
+	// 按照放入时间排列的小顶堆，已经被取走的消息等到了堆顶或者重建堆的时候才会被移除
+	byAge pendingHeap
+}
+
+type pendingEntry struct {
+	offset     uint64
+	enqueuedAt time.Time
+}
+
+type pendingHeap []pendingEntry
+
+func (x pendingHeap) Len() int           { return len(x) }
+func (x pendingHeap) Less(i, j int) bool { return x[i].enqueuedAt.Before(x[j].enqueuedAt) }
+func (x pendingHeap) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x *pendingHeap) Push(v any)        { *x = append(*x, v.(pendingEntry)) }
+func (x *pendingHeap) Pop() any {
+	old := *x
+	entry := old[len(old)-1]
+	*x = old[:len(old)-1]
+	return entry
+}
+
+func newPendingAges() *pendingAges {
+	return &pendingAges{
+		lock:       &sync.Mutex{},
+		enqueuedAt: make(map[uint64]time.Time),
+		taken:      make(map[uint64]struct{}),
+	}
+}
+
+// 登记一条放入了缓存的消息
+func (x *pendingAges) add(offset uint64, enqueuedAt time.Time) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if _, ok := x.taken[offset]; ok {
+		delete(x.taken, offset)
+		return
+	}
+	x.enqueuedAt[offset] = enqueuedAt
+	heap.Push(&x.byAge, pendingEntry{offset: offset, enqueuedAt: enqueuedAt})
+}
+
+// 移除一条从缓存中取走的消息
+func (x *pendingAges) remove(offset uint64) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if _, ok := x.enqueuedAt[offset]; !ok {
+		x.taken[offset] = struct{}{}
+		return
+	}
+	delete(x.enqueuedAt, offset)
+	x.prune()
+
+	// 取走的顺序和放入的顺序相差很大时堆中会积累很多已经取走的消息，超过一定数量之后重建
+	if len(x.byAge) > 2*len(x.enqueuedAt)+64 {
+		rebuilt := make(pendingHeap, 0, len(x.enqueuedAt))
+		for offset, enqueuedAt := range x.enqueuedAt {
+			rebuilt = append(rebuilt, pendingEntry{offset: offset, enqueuedAt: enqueuedAt})
+		}
+		heap.Init(&rebuilt)
+		x.byAge = rebuilt
+	}
+}
+
+// 缓存中最早的一条消息放入的时间，缓存中没有消息时返回false
+func (x *pendingAges) oldest() (time.Time, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.prune()
+	if len(x.byAge) == 0 {
+		return time.Time{}, false
+	}
+	return x.byAge[0].enqueuedAt, true
+}
+
+// 移除堆顶已经被取走的消息，调用方需要持有锁
+func (x *pendingAges) prune() {
+	for len(x.byAge) > 0 {
+		if _, ok := x.enqueuedAt[x.byAge[0].offset]; ok {
+			return
+		}
+		heap.Pop(&x.byAge)
+	}
+}
+
+// OldestMessageAge 缓存中最早放入的一条还没有被取走的消息已经等了多久，缓存中没有消息时返回0
+// 积压的数量看不出消息是不是卡住了，这个时长可以直接用来告警“流水线中有超过多久的数据还没有处理”
+// 需要开启QueueLatency或者配置LatencyBudgetOptions记录消息放入的时间，否则总是返回0，重新投递的历史消息和从Store中恢复的消息不参与计算
+func (x *Channel[Message]) OldestMessageAge() time.Duration {
+	if x.pending == nil {
+		return 0
+	}
+	enqueuedAt, ok := x.pending.oldest()
+	if !ok {
+		return 0
+	}
+	return x.clock.Since(enqueuedAt)
+}
+
+// 消息放入缓存之后登记放入的时间
+func (x *Channel[Message]) trackPending(envelope envelope[Message]) {
+	if x.pending == nil || envelope.enqueuedAt.IsZero() {
+		return
+	}
+	x.pending.add(envelope.offset, envelope.enqueuedAt)
+}
+
+// 消息从缓存中被取走之后不再计算它的等待时长，不管是被消费、被丢弃还是被兄弟信道窃取
+func (x *Channel[Message]) untrackPending(envelope envelope[Message]) {
+	if x.pending == nil || envelope.enqueuedAt.IsZero() {
+		return
+	}
+	x.pending.remove(envelope.offset)
+}
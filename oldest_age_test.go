@@ -0,0 +1,45 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_OldestMessageAge(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithQueueLatency(nil))
+	assert.Equal(t, time.Duration(0), channel.OldestMessageAge())
+
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	time.Sleep(time.Millisecond * 20)
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.GreaterOrEqual(t, channel.OldestMessageAge(), time.Millisecond*20)
+	assert.GreaterOrEqual(t, channel.Stats().OldestMessageAge, time.Millisecond*20)
+
+	// 最早的一条被取走之后按照下一条计算
+	_, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Less(t, channel.OldestMessageAge(), time.Millisecond*20)
+
+	_, err = channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), channel.OldestMessageAge())
+}
+
+func TestChannel_OldestMessageAgeDropOldest(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1).WithOverflowPolicy(OverflowDropOldest).WithQueueLatency(nil))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	time.Sleep(time.Millisecond * 20)
+
+	// 被挤掉的消息不再计算
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.Less(t, channel.OldestMessageAge(), time.Millisecond*20)
+}
+
+func TestChannel_OldestMessageAgeDisabled(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	time.Sleep(time.Millisecond * 5)
+	assert.Equal(t, time.Duration(0), channel.OldestMessageAge())
+}
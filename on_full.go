@@ -0,0 +1,52 @@
+package message_channel
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOnFullInterval 没有配置OnFullInterval时两次OnFull回调之间至少间隔的时长
+const DefaultOnFullInterval = time.Second
+
+// FullListener 发送时发现缓存满了的回调，pending是当时缓存中的消息的数量
+type FullListener func(pending uint64)
+
+// fullNotifier 限制OnFull回调的频率，缓存一直是满的时候不会每次发送都回调
+type fullNotifier struct {
+	listener FullListener
+	interval time.Duration
+
+	// 上一次回调的时间，UnixNano，0表示还没有回调过
+	last *atomic.Int64
+}
+
+func newFullNotifier(listener FullListener, interval time.Duration) *fullNotifier {
+	if interval <= 0 {
+		interval = DefaultOnFullInterval
+	}
+	return &fullNotifier{
+		listener: listener,
+		interval: interval,
+		last:     &atomic.Int64{},
+	}
+}
+
+// 距离上一次回调超过了间隔时回调，并发的发送方同时发现缓存满了时只有一个会回调
+func (x *fullNotifier) notify(now time.Time, pending int) {
+	last := x.last.Load()
+	if last != 0 && now.UnixNano()-last < int64(x.interval) {
+		return
+	}
+	if !x.last.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	x.listener(uint64(pending))
+}
+
+// 发送时发现缓存满了，在按照溢出策略阻塞等待或者丢弃之前通知出来，这样即使是阻塞的策略把饱和隐藏在了延迟后面也能被发现
+func (x *Channel[Message]) bufferFull() {
+	if x.fullNotifier == nil || x.buffer.isClosed() {
+		return
+	}
+	x.fullNotifier.notify(x.clock.Now(), x.buffer.len())
+}
@@ -0,0 +1,47 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_OnFull(t *testing.T) {
+	lock := &sync.Mutex{}
+	notified := make([]uint64, 0)
+	onFull := func(pending uint64) {
+		lock.Lock()
+		defer lock.Unlock()
+		notified = append(notified, pending)
+	}
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(2).WithOnFull(onFull).WithOnFullInterval(time.Hour))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.Empty(t, notified)
+
+	// 阻塞的策略下缓存满了也会通知，一直满着的时候按照间隔限制回调的频率
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, channel.Send(ctx, 3))
+	assert.NotNil(t, channel.Send(ctx, 4))
+	assert.Equal(t, []uint64{2}, notified)
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2}, drain[int](channel))
+}
+
+func TestChannel_OnFull_Drop(t *testing.T) {
+	notified := 0
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1).WithOverflowPolicy(OverflowDropNewest).WithOnFull(func(pending uint64) {
+		assert.Equal(t, uint64(1), pending)
+		notified++
+	}).WithOnFullInterval(time.Nanosecond))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 2, notified)
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, drain[int](channel))
+}
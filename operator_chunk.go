@@ -0,0 +1,67 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ChunkChild 把拉模式信道channel中的消息按照数量分组为切片，发送到一个消息类型为切片的下游信道中
+// 每凑够n条消息发送一次，maxWait大于0时一组中第一条消息等待超过maxWait之后即使没有凑够也会发送
+// 返回的下游信道工作在拉模式下，channel被关闭之后剩余的消息会作为最后一组发送，然后下游信道也会跟着关闭
+// channel必须是拉模式的，否则会panic
+// 因为返回的信道的消息类型是由Message派生出来的，go不允许方法这样实例化，所以这里是一个函数而不是方法
+func ChunkChild[Message any](channel *Channel[Message], n int, maxWait time.Duration) *Channel[[]Message] {
+	mustPullMode[Message]("ChunkChild", channel)
+	parent := NewChannel[[]Message](NewChannelOptions[[]Message]().WithChannelBuffSize(channel.options.ChannelBuffSize).WithPullMode())
+	connectTransform[[]Message](parent, true, func(emit EmitFunc[[]Message]) {
+		chunkLoop[Message](channel.Receive, channel.clock, n, maxWait, emit)
+	})
+	return parent
+}
+
+// chunkLoop 不断的通过receive获取消息并按照数量和等待时间分组，receive返回ctx超时以外的错误时认为没有更多消息了
+func chunkLoop[Message any](receive func(ctx context.Context) (Message, error), clock Clock, n int, maxWait time.Duration, emit EmitFunc[[]Message]) {
+
+	if n <= 0 {
+		n = 1
+	}
+
+	chunk := make([]Message, 0, n)
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		_ = emit(chunk)
+		chunk = make([]Message, 0, n)
+	}
+
+	var deadline time.Time
+	for {
+
+		// 已经有攒着的消息的时候，最多只等到这一组的截止时间
+		ctx, cancelFunc := context.Background(), context.CancelFunc(func() {})
+		if len(chunk) > 0 && maxWait > 0 {
+			ctx, cancelFunc = withClockDeadline(context.Background(), clock, deadline)
+		}
+		message, err := receive(ctx)
+		cancelFunc()
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				flush()
+				continue
+			}
+			flush()
+			return
+		}
+
+		if len(chunk) == 0 {
+			deadline = clock.Now().Add(maxWait)
+		}
+		chunk = append(chunk, message)
+		if len(chunk) >= n {
+			flush()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package message_channel
+
+import "context"
+
+// MapTo 和Then一样在拉模式信道in的后面接上一个转换的阶段，只是f可以失败：f返回的结果发送到返回的下游信道中，
+// f返回错误时这条消息没有结果，错误按照消费函数的错误计入in的统计，并且发布到in的错误输出信道中，这样可以在错误输出上统一处理转换失败的消息
+// 返回的下游信道工作在拉模式下，缓存大小和in一样，关闭顺序和Then一样，in必须是拉模式的，否则会panic
+// 因为下游信道的消息类型和in的不同，go不允许方法这样实例化，所以这里是一个函数而不是方法
+func MapTo[In, Out any](in *Channel[In], f func(message In) (Out, error)) *Channel[Out] {
+	mustPullMode[In]("MapTo", in)
+	out := NewChannel[Out](NewChannelOptions[Out]().WithChannelBuffSize(in.options.ChannelBuffSize).WithPullMode())
+	connectTransform[Out](out, true, func(emit EmitFunc[Out]) {
+		for {
+			message, err := in.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			result, err := f(message)
+			if err != nil {
+				in.consumerFailed(err, message)
+				continue
+			}
+			if err := emit(result); err != nil {
+				in.reportError(ErrorOpForward, err)
+				in.drop(DropReasonParentUnavailable, message)
+			}
+		}
+	})
+	return out
+}
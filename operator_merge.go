@@ -0,0 +1,91 @@
+package message_channel
+
+import (
+	"container/heap"
+	"context"
+)
+
+// LessFunc 比较两条消息的顺序，a应该排在b前面时返回true
+type LessFunc[Message any] func(a, b Message) bool
+
+// MergeSorted 把多个各自有序的拉模式信道合并为一个全局有序的信道，比如合并多个按时间排好序的日志分片
+// 每个输入信道都必须先有一条消息或者被关闭才能确定下一条输出的消息，所有的输入信道都关闭之后返回的信道也会跟着关闭
+// 不同信道中顺序相同的消息，按照信道在chans中的先后顺序输出，同一个信道中的消息保持原来的顺序
+// 返回的信道工作在拉模式下
+// 输入信道不是拉模式时会panic
+func MergeSorted[Message any](less LessFunc[Message], chans ...*Channel[Message]) *Channel[Message] {
+
+	mustPullMode[Message]("MergeSorted", chans...)
+
+	var buffSize uint64
+	if len(chans) > 0 {
+		buffSize = chans[0].options.ChannelBuffSize
+	}
+
+	out := NewChannel[Message](NewChannelOptions[Message]().WithChannelBuffSize(buffSize).WithPullMode())
+	connectTransform[Message](out, true, func(emit EmitFunc[Message]) {
+
+		h := &mergeHeap[Message]{less: less}
+		for index, channel := range chans {
+			// 输入信道都是拉模式的，这里只会因为信道已经关闭而失败，关闭的信道不再参与归并
+			if message, err := channel.Receive(context.Background()); err == nil {
+				h.items = append(h.items, &mergeHeapItem[Message]{message: message, source: index})
+			}
+		}
+		heap.Init(h)
+
+		for h.Len() > 0 {
+			item := heap.Pop(h).(*mergeHeapItem[Message])
+			if err := emit(item.message); err != nil {
+				return
+			}
+
+			// 从刚刚输出的消息所在的信道补充下一条消息
+			if message, err := chans[item.source].Receive(context.Background()); err == nil {
+				heap.Push(h, &mergeHeapItem[Message]{message: message, source: item.source})
+			}
+		}
+	})
+	return out
+}
+
+// 堆中的元素，记录消息来自哪个输入信道
+type mergeHeapItem[Message any] struct {
+	message Message
+	source  int
+}
+
+// 多路归并使用的小顶堆
+type mergeHeap[Message any] struct {
+	items []*mergeHeapItem[Message]
+	less  LessFunc[Message]
+}
+
+func (x *mergeHeap[Message]) Len() int {
+	return len(x.items)
+}
+
+func (x *mergeHeap[Message]) Less(i, j int) bool {
+	a, b := x.items[i], x.items[j]
+	if x.less(a.message, b.message) {
+		return true
+	}
+	if x.less(b.message, a.message) {
+		return false
+	}
+	return a.source < b.source
+}
+
+func (x *mergeHeap[Message]) Swap(i, j int) {
+	x.items[i], x.items[j] = x.items[j], x.items[i]
+}
+
+func (x *mergeHeap[Message]) Push(v any) {
+	x.items = append(x.items, v.(*mergeHeapItem[Message]))
+}
+
+func (x *mergeHeap[Message]) Pop() any {
+	last := x.items[len(x.items)-1]
+	x.items = x.items[:len(x.items)-1]
+	return last
+}
@@ -0,0 +1,26 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+)
+
+// ReduceFunc 把一条消息归约到累加值上，返回新的累加值
+type ReduceFunc[Message, Acc any] func(acc Acc, message Message) Acc
+
+// Reduce 把拉模式(WithPullMode)的信道一直消费到信道关闭为止，channel也可以是其它返回ErrChannelClosed表示结束的Receiver，每条消息都会被归约到累加值上，最后返回归约的结果
+// 适用于基于信道拓扑构建的批处理任务，等所有的发送方都关闭信道之后就能拿到最终结果
+// ctx: 用来做超时控制，被取消时会返回已经归约的部分结果以及ctx的错误
+func Reduce[Message, Acc any](ctx context.Context, channel Receiver[Message], init Acc, f ReduceFunc[Message, Acc]) (Acc, error) {
+	acc := init
+	for {
+		message, err := channel.Receive(ctx)
+		if err != nil {
+			if errors.Is(err, ErrChannelClosed) {
+				return acc, nil
+			}
+			return acc, err
+		}
+		acc = f(acc, message)
+	}
+}
@@ -0,0 +1,110 @@
+package message_channel
+
+import (
+	"context"
+	"sync"
+)
+
+// PredicateFunc 判断消息是否满足条件
+type PredicateFunc[Message any] func(message Message) bool
+
+// Split 按照给定的条件把当前拉模式信道中的消息分流到多个信道中，每条消息会被发送到第一个满足条件的信道
+// 返回的信道比条件多一个，最后一个是默认信道，一个条件都不满足的消息会被发送到默认信道
+// 每个输出都有自己的转发协程和不限长度的待转发队列，某个输出一直没人读取时不会阻塞其它输出，但是它的消息会一直堆积在内存中
+// 当前信道被关闭之后返回的所有信道也会跟着关闭，当前信道必须是拉模式的，否则会panic，返回的信道都工作在拉模式下
+func (x *Channel[Message]) Split(preds ...PredicateFunc[Message]) []*Channel[Message] {
+
+	mustPullMode[Message]("Split", x)
+
+	outputs := make([]*Channel[Message], 0, len(preds)+1)
+	for i := 0; i <= len(preds); i++ {
+		outputs = append(outputs, NewChannel[Message](NewChannelOptions[Message]().WithChannelBuffSize(x.options.ChannelBuffSize).WithPullMode()))
+	}
+
+	// 每个输出由各自的转发协程负责，转发完自己的消息就关闭，不用等其它的输出
+	forwarders := make([]*splitForwarder[Message], 0, len(outputs))
+	for _, output := range outputs {
+		forwarder := newSplitForwarder[Message]()
+		forwarders = append(forwarders, forwarder)
+		connectTransform[Message](output, true, forwarder.run)
+	}
+
+	x.spawn(goroutineRoleSplit, func() {
+		for {
+			message, err := x.Receive(context.Background())
+			if err != nil {
+				break
+			}
+
+			// 没有匹配到任何条件的时候使用最后一个默认信道
+			index := len(preds)
+			for i, pred := range preds {
+				if pred(message) {
+					index = i
+					break
+				}
+			}
+			forwarders[index].push(message)
+		}
+
+		// 当前信道关闭之后各个输出把堆积的消息转发完就会关闭
+		for _, forwarder := range forwarders {
+			forwarder.close()
+		}
+	})
+
+	return outputs
+}
+
+// splitForwarder 把分流到某个输出的消息先放到队列中，再由单独的协程转发，这样各个输出之间不会互相阻塞
+type splitForwarder[Message any] struct {
+	lock    *sync.Mutex
+	cond    *sync.Cond
+	pending []Message
+	closed  bool
+}
+
+func newSplitForwarder[Message any]() *splitForwarder[Message] {
+	lock := &sync.Mutex{}
+	return &splitForwarder[Message]{
+		lock: lock,
+		cond: sync.NewCond(lock),
+	}
+}
+
+func (x *splitForwarder[Message]) push(message Message) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.pending = append(x.pending, message)
+	x.cond.Signal()
+}
+
+func (x *splitForwarder[Message]) close() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.closed = true
+	x.cond.Signal()
+}
+
+// 一直转发到队列被关闭并且堆积的消息都转发完，转发失败时丢弃后面所有的消息
+func (x *splitForwarder[Message]) run(emit EmitFunc[Message]) {
+	for {
+		x.lock.Lock()
+		for len(x.pending) == 0 && !x.closed {
+			x.cond.Wait()
+		}
+		if len(x.pending) == 0 {
+			x.lock.Unlock()
+			return
+		}
+		message := x.pending[0]
+		var zero Message
+		x.pending[0] = zero
+		x.pending = x.pending[1:]
+		x.lock.Unlock()
+
+		if err := emit(message); err != nil {
+			return
+		}
+	}
+}
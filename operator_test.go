@@ -0,0 +1,323 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReduce(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	go func() {
+		for i := 1; i <= 10; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		channel.SenderWaitAndClose()
+	}()
+	sum, err := Reduce[int, int](context.Background(), channel, 0, func(acc int, message int) int {
+		return acc + message
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 55, sum)
+}
+
+func TestChunkChild(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	chunks := ChunkChild[int](channel, 3, time.Second)
+	go func() {
+		for i := 1; i <= 7; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		channel.SenderWaitAndClose()
+	}()
+	result, err := Reduce[[]int, [][]int](context.Background(), chunks, nil, func(acc [][]int, message []int) [][]int {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, result)
+}
+
+func TestChunkChild_MaxWait(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	chunks := ChunkChild[int](channel, 3, time.Millisecond*50)
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+
+	// 生产者停顿的时间超过了maxWait，没有凑够的一组也要在信道关闭之前发出来
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFunc()
+	chunk, err := chunks.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, chunk)
+
+	go func() {
+		assert.Nil(t, channel.Send(context.Background(), 3))
+		channel.SenderWaitAndClose()
+	}()
+	chunk, err = chunks.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3}, chunk)
+	_, err = chunks.Receive(ctx)
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
+
+func TestZip(t *testing.T) {
+	a := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	b := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(4))
+	zipped := Zip[int, string, string](a, b, func(i int, s string) string {
+		return fmt.Sprintf("%d%s", i, s)
+	})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			assert.Nil(t, a.Send(context.Background(), i))
+		}
+		a.SenderWaitAndClose()
+	}()
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, s := range []string{"a", "b", "c", "d"} {
+			assert.Nil(t, b.Send(context.Background(), s))
+		}
+		b.SenderWaitAndClose()
+	}()
+	result, err := Reduce[string, []string](context.Background(), zipped, nil, func(acc []string, message string) []string {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1a", "2b", "3c"}, result)
+
+	// a先关闭了，b中多出来的消息没有被配对，留在b中由调用方自己处理
+	rest, err := Reduce[string, []string](context.Background(), b, nil, func(acc []string, message string) []string {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d"}, rest)
+	wg.Wait()
+}
+
+func TestJoin(t *testing.T) {
+	requests := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	responses := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	joined := Join[int, int, int, int](requests, responses, &JoinOptions[int, int, int, int]{
+		KeyA:     func(i int) int { return i },
+		KeyB:     func(i int) int { return i / 10 },
+		Window:   time.Minute,
+		JoinFunc: func(a int, b int) int { return a + b },
+	})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			assert.Nil(t, requests.Send(context.Background(), i))
+		}
+		requests.SenderWaitAndClose()
+	}()
+	go func() {
+		for _, i := range []int{30, 10, 40} {
+			assert.Nil(t, responses.Send(context.Background(), i))
+		}
+		responses.SenderWaitAndClose()
+	}()
+	sum, err := Reduce[int, int](context.Background(), joined, 0, func(acc int, message int) int {
+		return acc + message
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 11+33, sum)
+}
+
+func TestJoin_Window(t *testing.T) {
+	requests := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	responses := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	joined := Join[int, int, int, int](requests, responses, &JoinOptions[int, int, int, int]{
+		KeyA:     func(i int) int { return i },
+		KeyB:     func(i int) int { return i / 10 },
+		Window:   time.Millisecond * 50,
+		JoinFunc: func(a int, b int) int { return a + b },
+	})
+	go func() {
+		assert.Nil(t, requests.Send(context.Background(), 1))
+
+		// 1的响应在窗口之后才到，不能再被关联上
+		time.Sleep(time.Millisecond * 150)
+		assert.Nil(t, responses.Send(context.Background(), 10))
+		assert.Nil(t, requests.Send(context.Background(), 2))
+		assert.Nil(t, responses.Send(context.Background(), 20))
+		requests.SenderWaitAndClose()
+		responses.SenderWaitAndClose()
+	}()
+	result, err := Reduce[int, []int](context.Background(), joined, nil, func(acc []int, message int) []int {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{22}, result)
+}
+
+func TestMergeSorted(t *testing.T) {
+	inputs := make([]*Channel[int], 0)
+	for _, shard := range [][]int{{1, 4, 7}, {2, 5, 8}, {3, 6, 9, 10}} {
+		channel := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+		inputs = append(inputs, channel)
+		go func(shard []int) {
+			for _, i := range shard {
+				assert.Nil(t, channel.Send(context.Background(), i))
+			}
+			channel.SenderWaitAndClose()
+		}(shard)
+	}
+	merged := MergeSorted[int](func(a, b int) bool { return a < b }, inputs...)
+	result, err := Reduce[int, []int](context.Background(), merged, nil, func(acc []int, message int) []int {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, result)
+}
+
+func TestMergeSorted_Ties(t *testing.T) {
+	type event struct {
+		time  int
+		shard int
+	}
+	inputs := make([]*Channel[event], 0)
+	for shard := 0; shard < 3; shard++ {
+		channel := NewChannel[event](NewChannelOptions[event]().WithPullMode().WithChannelBuffSize(2))
+		inputs = append(inputs, channel)
+		assert.Nil(t, channel.Send(context.Background(), event{time: 1, shard: shard}))
+		assert.Nil(t, channel.Send(context.Background(), event{time: 2, shard: shard}))
+		go channel.SenderWaitAndClose()
+	}
+	merged := MergeSorted[event](func(a, b event) bool { return a.time < b.time }, inputs...)
+	result, err := Reduce[event, []event](context.Background(), merged, nil, func(acc []event, message event) []event {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []event{{1, 0}, {1, 1}, {1, 2}, {2, 0}, {2, 1}, {2, 2}}, result)
+}
+
+func TestMergeSorted_NotPullMode(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]())
+	assert.Panics(t, func() {
+		MergeSorted[int](func(a, b int) bool { return a < b }, channel)
+	})
+	channel.SenderWaitAndClose()
+}
+
+func TestChannel_Split(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	outputs := channel.Split(func(i int) bool {
+		return i%2 == 0
+	}, func(i int) bool {
+		return i%3 == 0
+	})
+	assert.Equal(t, 3, len(outputs))
+	go func() {
+		for i := 1; i <= 10; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		channel.SenderWaitAndClose()
+	}()
+	results := make([][]int, len(outputs))
+	wg := &sync.WaitGroup{}
+	for index, output := range outputs {
+		wg.Add(1)
+		go func(index int, output *Channel[int]) {
+			defer wg.Done()
+			results[index], _ = Reduce[int, []int](context.Background(), output, nil, func(acc []int, message int) []int {
+				return append(acc, message)
+			})
+		}(index, output)
+	}
+	wg.Wait()
+	assert.Equal(t, [][]int{{2, 4, 6, 8, 10}, {3, 9}, {1, 5, 7}}, results)
+}
+
+func TestChannel_Split_SlowOutput(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	outputs := channel.Split(func(i int) bool {
+		return i%2 == 0
+	})
+	go func() {
+		for i := 1; i <= 10; i++ {
+			assert.Nil(t, channel.Send(context.Background(), i))
+		}
+		channel.SenderWaitAndClose()
+	}()
+
+	// 偶数输出一直没有人读取，奇数输出也要能读完
+	odd, err := Reduce[int, []int](context.Background(), outputs[1], nil, func(acc []int, message int) []int {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 3, 5, 7, 9}, odd)
+
+	even, err := Reduce[int, []int](context.Background(), outputs[0], nil, func(acc []int, message int) []int {
+		return append(acc, message)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 4, 6, 8, 10}, even)
+}
+
+func TestOperators_NotPullMode(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]())
+	pull := NewChannel[int](NewChannelOptions[int]().WithPullMode())
+	assert.Panics(t, func() { channel.Split() })
+	assert.Panics(t, func() { ChunkChild[int](channel, 1, 0) })
+	assert.Panics(t, func() { Zip[int, int, int](pull, channel, func(a, b int) int { return a }) })
+	assert.Panics(t, func() { Join[int, int, int, int](channel, pull, &JoinOptions[int, int, int, int]{}) })
+	assert.Panics(t, func() { Then[int, int](channel, func(message int) int { return message }) })
+	channel.SenderWaitAndClose()
+}
+
+func TestThen(t *testing.T) {
+	source := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(4))
+	doubled := Then[int, int](source, func(message int) int {
+		return message * 2
+	})
+	lock := &sync.Mutex{}
+	received := make([]string, 0)
+	closed := make(chan struct{})
+	ThenWithOptions[int, string](doubled, func(message int) string {
+		return fmt.Sprintf("#%d", message)
+	}, NewChannelOptions[string]().WithChannelConsumerFunc(func(index int, message string) {
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, message)
+	}).WithCloseEventListener(func() {
+		close(closed)
+	}))
+
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, source.Send(context.Background(), i))
+	}
+
+	// 只需要关闭最上游的信道，下游的阶段处理完剩余的消息之后依次关闭
+	source.SenderWaitAndClose()
+	<-closed
+	assert.True(t, doubled.IsClosed())
+	assert.Equal(t, []string{"#2", "#4", "#6"}, received)
+}
+
+func TestMapTo(t *testing.T) {
+	errorOutput := NewChannel[ErrorEvent](NewChannelOptions[ErrorEvent]().WithPullMode().WithChannelBuffSize(10))
+	source := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(4).WithErrorOutput(errorOutput))
+	parsed := MapTo[string, int](source, strconv.Atoi)
+
+	for _, message := range []string{"1", "x", "3"} {
+		assert.Nil(t, source.Send(context.Background(), message))
+	}
+	go source.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 3}, drain[int](parsed))
+
+	// 转换失败的消息发布到错误输出中
+	event, err := errorOutput.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, ErrorEventConsumerError, event.Kind)
+	assert.Equal(t, "x", event.Message)
+	assert.Equal(t, uint64(1), source.Stats().ConsumerErrors)
+
+	go errorOutput.SenderWaitAndClose()
+	drain[ErrorEvent](errorOutput)
+}
@@ -0,0 +1,33 @@
+package message_channel
+
+import "context"
+
+// Then 在拉模式信道channel的后面接上一个阶段：取出channel中的每条消息，经过f转换之后发送到返回的下游信道中，这样线性的流水线可以从上往下读
+// 比如 Then(Then(raw, parse), enrich)，返回的下游信道工作在拉模式下，缓存大小和channel一样，可以继续调用Then
+// 下游信道和channel之间的关闭顺序和父子信道一样：channel被关闭并且剩余的消息都转换完之后下游信道才会跟着关闭，所以只需要关闭最上游的信道
+// channel必须是拉模式的，否则会panic，因为下游信道的消息类型和channel的不同，go不允许方法这样实例化，所以这里是一个函数而不是方法
+func Then[A, B any](channel *Channel[A], f func(message A) B) *Channel[B] {
+	return ThenWithOptions[A, B](channel, f, NewChannelOptions[B]().WithChannelBuffSize(channel.options.ChannelBuffSize).WithPullMode())
+}
+
+// ThenWithOptions 和Then一样，只是下游信道使用自己的选项创建，比如作为流水线的最后一个阶段配置消费函数，options为nil时和Then一样
+func ThenWithOptions[A, B any](channel *Channel[A], f func(message A) B, options *ChannelOptions[B]) *Channel[B] {
+	mustPullMode[A]("Then", channel)
+	if options == nil {
+		options = NewChannelOptions[B]().WithChannelBuffSize(channel.options.ChannelBuffSize).WithPullMode()
+	}
+	next := NewChannel[B](options)
+	connectTransform[B](next, true, func(emit EmitFunc[B]) {
+		for {
+			message, err := channel.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			if err := emit(f(message)); err != nil {
+				channel.reportError(ErrorOpForward, err)
+				channel.drop(DropReasonParentUnavailable, message)
+			}
+		}
+	})
+	return next
+}
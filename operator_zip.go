@@ -0,0 +1,181 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// Zip 把两个拉模式信道中的消息按照顺序一一配对，每对消息经过f合并后发送到返回的信道中
+// 两个信道中任意一个被关闭之后就不会再有新的配对了，此时返回的信道也会跟着关闭，另一个信道中多出来的消息不会被取走
+// a和b都必须是拉模式的信道，否则会panic，返回的信道工作在拉模式下
+func Zip[A, B, C any](a *Channel[A], b *Channel[B], f func(A, B) C) *Channel[C] {
+	mustPullMode[A]("Zip", a)
+	mustPullMode[B]("Zip", b)
+	out := NewChannel[C](NewChannelOptions[C]().WithChannelBuffSize(a.options.ChannelBuffSize).WithPullMode())
+	connectTransform[C](out, true, func(emit EmitFunc[C]) {
+		for {
+			messageA, err := a.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			messageB, err := b.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			if err := emit(f(messageA, messageB)); err != nil {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// JoinOptions 按照key关联两个信道时的选项
+type JoinOptions[A, B any, K comparable, C any] struct {
+
+	// 从a中的消息提取用来关联的key
+	KeyA func(A) K
+
+	// 从b中的消息提取用来关联的key
+	KeyB func(B) K
+
+	// 两条消息到达的时间相差不超过这个窗口时才会被关联，超出窗口还没有等到另一半的消息会被丢弃
+	Window time.Duration
+
+	// 把关联上的两条消息合并为一条
+	JoinFunc func(A, B) C
+}
+
+// 还在等待另一半的消息
+type joinPending[Message any] struct {
+	message   Message
+	arrivedAt time.Time
+}
+
+// Join 把两个拉模式信道中key相同并且到达时间在窗口之内的消息关联起来，合并后发送到返回的信道中，比如关联请求日志和响应日志
+// 每条消息最多只会被关联一次，同一个key有多条消息在等待时会先关联最早到达的那一条
+// 两个信道都被关闭之后返回的信道也会跟着关闭，a和b都必须是拉模式的信道，否则会panic，返回的信道工作在拉模式下
+func Join[A, B any, K comparable, C any](a *Channel[A], b *Channel[B], options *JoinOptions[A, B, K, C]) *Channel[C] {
+	mustPullMode[A]("Join", a)
+	mustPullMode[B]("Join", b)
+	out := NewChannel[C](NewChannelOptions[C]().WithChannelBuffSize(a.options.ChannelBuffSize).WithPullMode())
+	connectTransform[C](out, true, func(emit EmitFunc[C]) {
+
+		// 两边各自用一个协程拉取，这样无论哪边先到都能及时处理
+		chanA := make(chan A)
+		chanB := make(chan B)
+		a.spawn(goroutineRoleJoin, func() {
+			receiveInto[A](a, chanA)
+		})
+		b.spawn(goroutineRoleJoin, func() {
+			receiveInto[B](b, chanB)
+		})
+
+		pendingA := make(map[K][]*joinPending[A])
+		pendingB := make(map[K][]*joinPending[B])
+
+		ticker := a.clock.NewTicker(joinExpireInterval(options.Window))
+		defer ticker.Stop()
+
+		for chanA != nil || chanB != nil {
+			select {
+			case message, ok := <-chanA:
+				if !ok {
+					chanA = nil
+					continue
+				}
+				key := options.KeyA(message)
+				if other, ok := takeJoinPending[B, K](pendingB, key, a.clock.Now(), options.Window); ok {
+					_ = emit(options.JoinFunc(message, other))
+				} else {
+					pendingA[key] = append(pendingA[key], &joinPending[A]{message: message, arrivedAt: a.clock.Now()})
+				}
+			case message, ok := <-chanB:
+				if !ok {
+					chanB = nil
+					continue
+				}
+				key := options.KeyB(message)
+				if other, ok := takeJoinPending[A, K](pendingA, key, a.clock.Now(), options.Window); ok {
+					_ = emit(options.JoinFunc(other, message))
+				} else {
+					pendingB[key] = append(pendingB[key], &joinPending[B]{message: message, arrivedAt: a.clock.Now()})
+				}
+			case now := <-ticker.C():
+				expireJoinPending[A, K](pendingA, now, options.Window)
+				expireJoinPending[B, K](pendingB, now, options.Window)
+			}
+		}
+	})
+	return out
+}
+
+// 把拉模式信道中的消息全部拉取出来放到go原生的channel中，信道关闭时关闭原生channel，调用方需要保证channel是拉模式的
+func receiveInto[Message any](channel *Channel[Message], c chan<- Message) {
+	defer close(c)
+	for {
+		message, err := channel.Receive(context.Background())
+		if err != nil {
+			return
+		}
+		c <- message
+	}
+}
+
+// 取出key对应的最早到达并且还在窗口内的消息，只会清理这一个key下过期的消息，其它key的过期消息交给定时清理
+func takeJoinPending[Message any, K comparable](pending map[K][]*joinPending[Message], key K, now time.Time, window time.Duration) (Message, bool) {
+	list := trimJoinPending[Message](pending[key], 0, now, window)
+	if len(list) == 0 {
+		delete(pending, key)
+		var zero Message
+		return zero, false
+	}
+	message := list[0].message
+	list = trimJoinPending[Message](list, 1, now, window)
+	if len(list) == 0 {
+		delete(pending, key)
+	} else {
+		pending[key] = list
+	}
+	return message, true
+}
+
+// 清理掉所有key下已经超出窗口的消息
+func expireJoinPending[Message any, K comparable](pending map[K][]*joinPending[Message], now time.Time, window time.Duration) {
+	for key, list := range pending {
+		list = trimJoinPending[Message](list, 0, now, window)
+		if len(list) == 0 {
+			delete(pending, key)
+		} else {
+			pending[key] = list
+		}
+	}
+}
+
+// 从列表头部至少去掉skip个元素，再继续去掉已经超出窗口的元素
+// 剩下的元素会被移动到原来的底层数组的开头，并把腾出来的位置置空，避免一直引用着已经被取走的消息
+func trimJoinPending[Message any](list []*joinPending[Message], skip int, now time.Time, window time.Duration) []*joinPending[Message] {
+	index := skip
+	for index < len(list) && now.Sub(list[index].arrivedAt) > window {
+		index++
+	}
+	if index == 0 {
+		return list
+	}
+	if index > len(list) {
+		index = len(list)
+	}
+	n := copy(list, list[index:])
+	for i := n; i < len(list); i++ {
+		list[i] = nil
+	}
+	return list[:n]
+}
+
+// 清理过期消息的间隔，窗口越小清理越频繁
+func joinExpireInterval(window time.Duration) time.Duration {
+	if window <= 0 {
+		return time.Second
+	}
+	return window
+}
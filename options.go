@@ -1,5 +1,10 @@
 package message_channel
 
+import (
+	"context"
+	"time"
+)
+
 // ------------------------------------------------ ---------------------------------------------------------------------
 
 // CloseEventListener channel被关闭时的监听器
@@ -12,31 +17,269 @@ type ChannelConsumerFunc[Message any] func(index int, message Message)
 
 // ------------------------------------------------ ---------------------------------------------------------------------
 
+// ContextConsumerFunc 带有ctx的消费函数，ctx会在信道被Abort、强制关闭或者已经关闭时被取消，耗时长的消费逻辑可以据此尽快结束
+// 返回的错误会被计入ConsumerErrors，这条消息按照DropReasonConsumerError丢弃，不会写入Sinks也不会转发给父信道
+type ContextConsumerFunc[Message any] func(ctx context.Context, index int, message Message) error
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// ForwardTransformFunc 子信道把消息转发给父信道之前对消息做的处理，可以修改消息，返回false时这条消息不会被转发
+type ForwardTransformFunc[Message any] func(index int, message Message) (Message, bool)
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// SlowConsumeListener 消费函数处理一条消息的耗时超过了期限时的回调
+type SlowConsumeListener[Message any] func(message Message, elapsed time.Duration)
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// DequeueListener 开启了QueueLatency时每条消息从缓存中被取出时的回调
+// queuedFor: 消息在当前信道的缓存中等待的时长，不包括消费函数处理的时间
+type DequeueListener[Message any] func(message Message, queuedFor time.Duration)
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
 // ChannelOptions 创建Channel时的选项
 type ChannelOptions[Message any] struct {
 
 	// 每个channel都可以有一个名字
 	Name string
 
+	// 信道的标签，比如租户、阶段、组件，会出现在拓扑图、统计、健康报告等导出的信息中，方便按照标签切分
+	// 通过MakeChildChannel创建的子信道会继承父信道的标签
+	Tags map[string]string
+
 	// 关闭Channel时的回调函数
 	CloseEventListener CloseEventListener
 
 	// 用于消费channel中的元素
 	ChannelConsumerFunc ChannelConsumerFunc[Message]
 
+	// 带有ctx的消费函数，和ChannelConsumerFunc同时设置时只使用ContextConsumerFunc
+	ContextConsumerFunc ContextConsumerFunc[Message]
+
+	// 返回处理决定的消费函数，设置了的话除了AsyncConsumerFunc以外其它的消费函数都不会被使用
+	DecisionConsumerFunc DecisionConsumerFunc[Message]
+
+	// 异步完成的消费函数，设置了的话其它的消费函数以及KeyedWorkers、OrderedWorkers都不会被使用
+	AsyncConsumerFunc AsyncConsumerFunc[Message]
+
+	// 最多同时有多少条消息交给了AsyncConsumerFunc还没有完成，达到上限之后处理消息的协程等待有消息完成，小于1时按照1处理
+	AsyncWindow int
+
+	// 消费函数返回Retry时一条消息最多重试的次数，为0时使用DefaultMaxRetries，小于0时不限制
+	MaxRetries int
+
+	// 消费函数返回DeadLetter时消息被发送到这里，一般是另一个信道，为nil时丢弃
+	DeadLetterChannel Sender[Message]
+
+	// 只对子信道有效，消费函数处理完之后、转发给父信道之前对消息做的处理，这样不需要额外的中间信道就可以给每个子信道加上自己的处理逻辑
+	ForwardTransform ForwardTransformFunc[Message]
+
+	// 拓扑的最大深度，根信道的深度为0，超过这个深度时MakeChildChannel会失败，防止组件不小心无限的创建子信道，为0时不限制
+	MaxDepth int
+
+	// 当前信道最多同时有多少个子信道，超过时MakeChildChannel会失败，不会被子信道继承，为0时不限制
+	MaxChildren int
+
 	// channel的缓存大小
 	ChannelBuffSize uint64
+
+	// 是否工作在拉模式下，拉模式的信道没有处理消息的协程，消息需要调用方通过Receive主动拉取，此时ChannelConsumerFunc不会被使用
+	// 注意拉模式的信道在关闭时会等待剩余的消息被拉取完，所以必须有调用方一直拉取到信道关闭为止
+	PullMode bool
+
+	// 使用加锁的队列而不是go的channel实现缓存，开销稍大一些，但是可以通过Peek查看缓存中的消息，配置了Ordering时总是使用加锁的队列
+	QueueBuffer bool
+
+	// 创建信道时把信道按照名字登记到这个注册表中，为nil时不登记
+	Registry *Registry
+
+	// 消费函数panic时的监督策略，为nil时不监督，消费函数panic会导致进程退出
+	SupervisorOptions *SupervisorOptions
+
+	// 信道中有消息但是超过这个时长都没有消息被消费时认为信道卡住了，为0时使用默认的DefaultStallThreshold
+	StallThreshold time.Duration
+
+	// 在后台定时采样缓存深度，为nil时不采样
+	DepthSamplerOptions *DepthSamplerOptions
+
+	// 记录信道启动的协程属于哪个信道，为nil时不记录，子信道没有单独配置时继承父信道的
+	GoroutineRegistry *GoroutineRegistry
+
+	// 看门狗的选项，为nil时不开启看门狗
+	WatchdogOptions *WatchdogOptions
+
+	// 自动伸缩处理消息的协程池，为nil时只有一个处理消息的协程，拉模式下不生效
+	AutoscaleOptions *AutoscaleOptions
+
+	// 消费函数处理一条消息的期限，超过期限时会计数并触发SlowConsumeListener，用来快速找到哪个阶段是瓶颈，为0时不检查
+	ConsumeDeadline time.Duration
+
+	// 消费函数处理一条消息的耗时超过ConsumeDeadline时的回调
+	SlowConsumeListener SlowConsumeListener[Message]
+
+	// 消费函数处理一条消息的超时时间，超时之后取消传给消费函数的ctx，不再等待它返回，按照ConsumeTimeoutPolicy处理这条消息之后继续处理后面的消息，为0时不限制
+	ConsumeTimeout time.Duration
+
+	// 消费函数处理一条消息超时之后怎么处理这条消息，默认重试
+	ConsumeTimeoutPolicy ConsumeTimeoutPolicy
+
+	// 是否记录每条消息放入缓存的时间，开启之后统计消息在缓存中等待的时长，这样就能区分是排队慢还是消费函数处理慢
+	QueueLatency bool
+
+	// 开启了QueueLatency时每条消息从缓存中被取出时的回调
+	OnDequeue DequeueListener[Message]
+
+	// 消息在整棵信道树中的延迟预算，为nil时不检查，子信道没有单独配置时继承父信道的
+	LatencyBudgetOptions *LatencyBudgetOptions[Message]
+
+	// 混沌模式的选项，为nil时不开启，只用于测试，子信道不会继承
+	ChaosOptions *ChaosOptions
+
+	// 调试采样器的选项，为nil时不开启
+	DebugSamplerOptions *DebugSamplerOptions[Message]
+
+	// 缓存满的时候Send的处理策略，默认阻塞等待
+	OverflowPolicy OverflowPolicy
+
+	// 消息被丢弃时的回调，不管是因为什么原因被丢弃的都会回调
+	OnDropped DropListener[Message]
+
+	// 关闭时等待子信道退出的进度的回调，为nil时不报告
+	OnDrainProgress DrainProgressListener
+
+	// 关闭时等待子信道都退出的检查策略和间隔，间隔为0时SenderWaitAndClose和SenderWaitAndCloseContext都使用DefaultChildrenPollInterval
+	// 按指数退避时间隔最多增长到ChildrenPollMaxInterval，为0时不设上限
+	ChildrenWaitStrategy    ChildrenWaitStrategy
+	ChildrenPollInterval    time.Duration
+	ChildrenPollMaxInterval time.Duration
+
+	// SenderWaitAndClose等待子信道退出的超时时间以及超时之后的处理策略，超时时间为0时使用DefaultCloseTimeout
+	CloseTimeout    time.Duration
+	CloseEscalation CloseEscalation
+
+	// 发送时发现缓存满了的回调，不管是什么溢出策略都会回调，两次回调之间至少间隔OnFullInterval，为0时使用DefaultOnFullInterval
+	OnFull         FullListener
+	OnFullInterval time.Duration
+
+	// 超过SilenceTimeout都没有新的消息放入时回调OnSilence，同时往ErrorOutput中发布一个ErrorEventSilence事件，用来发现上游已经挂了，为0时不检查
+	SilenceTimeout time.Duration
+	OnSilence      func()
+
+	// PressureLevel估算排队等待的时长时对比的目标，为0时使用ConsumeDeadline
+	PressureLatencyTarget time.Duration
+
+	// 压力等级按照0.1取整之后变化时的回调，为nil时不检查
+	OnPressureChange PressureListener
+
+	// 调用Shutdown时ctx到期了还没有关闭完成时的处理策略，默认强制关闭
+	ShutdownFallback ShutdownFallback
+
+	// 信道开始关闭之后Send是否返回ErrChannelDraining拒绝新的消息，默认在处理完之前都还可以继续发送
+	// 子信道转发上来的消息不受影响
+	RejectSendWhileDraining bool
+
+	// 信道的状态发生变化时的回调
+	StateListener StateListener
+
+	// 缓存中的消息按照这个比较函数排序，每次消费的都是缓存中最小的消息而不是最早到达的消息，为nil时按照到达的顺序消费
+	// 适合给时间戳稍微有些乱序的事件流排序，只能在缓存中同时存在的消息之间排序，所以缓存越大能纠正的乱序程度越大
+	Ordering LessFunc[Message]
+
+	// SendUrgent使用的紧急通道的缓存大小，为0时使用DefaultUrgentBuffSize
+	UrgentBuffSize uint64
+
+	// 信道内部发生错误时的回调，通过MakeChildChannel创建的子信道会继承父信道的回调
+	ErrorListener ErrorListener
+
+	// 开启分发模式，信道不再自己消费消息，而是按照这个策略把每条消息交给一个分发子信道处理，分发子信道通过MakeDistributionChild创建
+	Distribution DistributionStrategy[Message]
+
+	// 作为分发子信道时的权重，权重越大分到的消息越多，为0时按照1处理，运行中可以通过SetWeight修改
+	Weight int
+
+	// 分发模式下空闲的分发子信道是否可以从积压的兄弟信道的缓存中拿消息处理，开启之后同一个键的消息不再保证由同一个子信道按顺序处理
+	// 适合按键分区但是键的分布不均匀，同时又不要求同一个键的消息按顺序处理的场景
+	WorkStealing bool
+
+	// 按键串行并发消费，同时最多有KeyedWorkers个键在被处理，同一个键的消息不会被同时处理，并且总是按照到达的顺序处理
+	// 不同的键之间并行处理，消费函数需要是并发安全的，两个都设置了才会开启
+	KeyedWorkers   int
+	ConcurrencyKey KeyFunc[Message]
+
+	// 并行处理、按顺序完成，同时最多有OrderedWorkers条消息在被处理，处理完之后写出到Sinks和转发给父信道按照消息到达的顺序进行
+	// 这样有顺序要求的下游也能享受到并行处理的加速，消费函数需要是并发安全的，和KeyedWorkers同时设置时只使用KeyedWorkers，为0时不开启
+	OrderedWorkers int
+
+	// 同时最多有多少条消息在被消费函数处理，不管有多少个处理消息的协程都不会超过这个数量，重试时等待的消息也占着名额，为0时不限制
+	// 适合消费函数访问的资源有硬性的并发上限的场景，事务模式下按批提交，不受这个限制
+	MaxInFlight int
+
+	// 事务模式，信道不再调用消费函数，而是把消息按批交给支持事务的输出，为nil时不开启
+	TransactionalSinkOptions *TransactionalSinkOptions[Message]
+
+	// 持久化消息和已经提交的偏移量，配置之后信道创建时会先把上次提交的偏移量之后的消息重新放入信道
+	Store Store[Message]
+
+	// 定期提交通过AckUpTo确认的偏移量的间隔，为0时不自动提交
+	AutoCommitInterval time.Duration
+
+	// 按照数量或者时间把已经完全消费完的偏移量交给回调，没有Store也可以由应用自己保存消费的进度，为nil时不产生检查点
+	CheckpointOptions *CheckpointOptions
+
+	// 录制每一条成功放入信道的消息以及放入的时间，之后可以通过Play回放
+	Recorder *Recorder[Message]
+
+	// 审计被消费的每一条消息，为nil时不审计
+	Auditor *Auditor[Message]
+
+	// 被消费函数成功处理的消息的附加输出，信道关闭时会被关闭
+	Sinks []Sink[Message]
+
+	// 错误输出信道，消费函数的错误、被丢弃和过期的消息、信道内部的错误都会作为ErrorEvent发布到这里，为nil时不发布
+	// 错误输出信道不会随着信道一起关闭，它的缓存满了时会阻塞产生错误的协程，不希望被阻塞时可以给它配置丢弃的OverflowPolicy
+	ErrorOutput *Channel[ErrorEvent]
+
+	// 按照名字注册的旁路输出，消费函数通过EmitTo把消息发送过去，旁路输出不会随着信道一起关闭
+	SideOutputs map[string]Sender[Message]
+
+	// 同步模式，Send直接在调用方的协程中调用消费函数，处理完之后才返回，没有处理消息的协程也不经过缓存
+	// 消息的处理是确定性的，单元测试中不再需要等待消息被异步消费，开启之后拉模式、自动伸缩等和处理消息的协程相关的选项都不再生效
+	SynchronousMode bool
+
+	// 只对子信道有效，转发给父信道时父信道已经关闭或者卡住了的处理方式，为nil时一直等待，父信道关闭时报告错误并丢弃
+	ForwardOptions *ForwardOptions
+
+	// 泄漏检测器，登记创建信道时的调用栈，关闭时移除，为nil时不检测，子信道没有设置时继承父信道的
+	LeakTracker *LeakTracker
+
+	// 所有和时间相关的功能使用的时钟，为nil时使用系统时钟，子信道没有设置时继承父信道的
+	Clock Clock
 }
 
 func NewChannelOptions[Message any]() *ChannelOptions[Message] {
 	return &ChannelOptions[Message]{}
 }
 
+func (x *ChannelOptions[Message]) WithTag(key, value string) *ChannelOptions[Message] {
+	if x.Tags == nil {
+		x.Tags = make(map[string]string)
+	}
+	x.Tags[key] = value
+	return x
+}
+
 func (x *ChannelOptions[Message]) WithName(name string) *ChannelOptions[Message] {
 	x.Name = name
 	return x
 }
 
+func (x *ChannelOptions[Message]) WithTags(tags map[string]string) *ChannelOptions[Message] {
+	x.Tags = copyTags(tags)
+	return x
+}
+
 func (x *ChannelOptions[Message]) WithCloseEventListener(closeEventListener CloseEventListener) *ChannelOptions[Message] {
 	x.CloseEventListener = closeEventListener
 	return x
@@ -47,9 +290,337 @@ func (x *ChannelOptions[Message]) WithChannelConsumerFunc(channelConsumerFunc Ch
 	return x
 }
 
+func (x *ChannelOptions[Message]) WithContextConsumerFunc(contextConsumerFunc ContextConsumerFunc[Message]) *ChannelOptions[Message] {
+	x.ContextConsumerFunc = contextConsumerFunc
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithDecisionConsumerFunc(decisionConsumerFunc DecisionConsumerFunc[Message]) *ChannelOptions[Message] {
+	x.DecisionConsumerFunc = decisionConsumerFunc
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithAsyncConsumerFunc(asyncConsumerFunc AsyncConsumerFunc[Message], window int) *ChannelOptions[Message] {
+	x.AsyncConsumerFunc = asyncConsumerFunc
+	x.AsyncWindow = window
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithMaxRetries(maxRetries int) *ChannelOptions[Message] {
+	x.MaxRetries = maxRetries
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithDeadLetterChannel(deadLetterChannel Sender[Message]) *ChannelOptions[Message] {
+	x.DeadLetterChannel = deadLetterChannel
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithForwardTransform(forwardTransform ForwardTransformFunc[Message]) *ChannelOptions[Message] {
+	x.ForwardTransform = forwardTransform
+	return x
+}
+
 func (x *ChannelOptions[Message]) WithChannelBuffSize(channelBuffSize uint64) *ChannelOptions[Message] {
 	x.ChannelBuffSize = channelBuffSize
 	return x
 }
 
+func (x *ChannelOptions[Message]) WithPullMode() *ChannelOptions[Message] {
+	x.PullMode = true
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithQueueBuffer() *ChannelOptions[Message] {
+	x.QueueBuffer = true
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithRegistry(registry *Registry) *ChannelOptions[Message] {
+	x.Registry = registry
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithGoroutineRegistry(registry *GoroutineRegistry) *ChannelOptions[Message] {
+	x.GoroutineRegistry = registry
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithStallThreshold(stallThreshold time.Duration) *ChannelOptions[Message] {
+	x.StallThreshold = stallThreshold
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithDepthSampler(interval time.Duration, size int) *ChannelOptions[Message] {
+	x.DepthSamplerOptions = &DepthSamplerOptions{
+		Interval: interval,
+		Size:     size,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithConsumeDeadline(consumeDeadline time.Duration, slowConsumeListener SlowConsumeListener[Message]) *ChannelOptions[Message] {
+	x.ConsumeDeadline = consumeDeadline
+	x.SlowConsumeListener = slowConsumeListener
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithConsumeTimeout(consumeTimeout time.Duration, policy ConsumeTimeoutPolicy) *ChannelOptions[Message] {
+	x.ConsumeTimeout = consumeTimeout
+	x.ConsumeTimeoutPolicy = policy
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithQueueLatency(onDequeue DequeueListener[Message]) *ChannelOptions[Message] {
+	x.QueueLatency = true
+	x.OnDequeue = onDequeue
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithLatencyBudget(budget time.Duration, policy LatencyBudgetPolicy, onExceeded LatencyBudgetListener[Message]) *ChannelOptions[Message] {
+	x.LatencyBudgetOptions = &LatencyBudgetOptions[Message]{
+		Budget:     budget,
+		Policy:     policy,
+		OnExceeded: onExceeded,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithChaos(chaosOptions *ChaosOptions) *ChannelOptions[Message] {
+	x.ChaosOptions = chaosOptions
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithDebugSampler(rate int, logger DebugLogger[Message], redact Redactor[Message]) *ChannelOptions[Message] {
+	x.DebugSamplerOptions = &DebugSamplerOptions[Message]{
+		Rate:   rate,
+		Logger: logger,
+		Redact: redact,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithAutoscale(min, max int, targetDepth int) *ChannelOptions[Message] {
+	x.AutoscaleOptions = &AutoscaleOptions{
+		Min:         min,
+		Max:         max,
+		TargetDepth: targetDepth,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithWatchdog(watchdogOptions *WatchdogOptions) *ChannelOptions[Message] {
+	x.WatchdogOptions = watchdogOptions
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOverflowPolicy(overflowPolicy OverflowPolicy) *ChannelOptions[Message] {
+	x.OverflowPolicy = overflowPolicy
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOnDrainProgress(onDrainProgress DrainProgressListener) *ChannelOptions[Message] {
+	x.OnDrainProgress = onDrainProgress
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithChildrenWait(strategy ChildrenWaitStrategy, interval, maxInterval time.Duration) *ChannelOptions[Message] {
+	x.ChildrenWaitStrategy = strategy
+	x.ChildrenPollInterval = interval
+	x.ChildrenPollMaxInterval = maxInterval
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithCloseTimeout(closeTimeout time.Duration) *ChannelOptions[Message] {
+	x.CloseTimeout = closeTimeout
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithCloseEscalation(closeEscalation CloseEscalation) *ChannelOptions[Message] {
+	x.CloseEscalation = closeEscalation
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithSilenceTimeout(silenceTimeout time.Duration, onSilence func()) *ChannelOptions[Message] {
+	x.SilenceTimeout = silenceTimeout
+	x.OnSilence = onSilence
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithPressure(latencyTarget time.Duration, onChange PressureListener) *ChannelOptions[Message] {
+	x.PressureLatencyTarget = latencyTarget
+	x.OnPressureChange = onChange
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOnFull(onFull FullListener) *ChannelOptions[Message] {
+	x.OnFull = onFull
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOnFullInterval(onFullInterval time.Duration) *ChannelOptions[Message] {
+	x.OnFullInterval = onFullInterval
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOnDropped(onDropped DropListener[Message]) *ChannelOptions[Message] {
+	x.OnDropped = onDropped
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithShutdownFallback(shutdownFallback ShutdownFallback) *ChannelOptions[Message] {
+	x.ShutdownFallback = shutdownFallback
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithRejectSendWhileDraining() *ChannelOptions[Message] {
+	x.RejectSendWhileDraining = true
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithStateListener(stateListener StateListener) *ChannelOptions[Message] {
+	x.StateListener = stateListener
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOrdering(less LessFunc[Message]) *ChannelOptions[Message] {
+	x.Ordering = less
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithUrgentBuffSize(urgentBuffSize uint64) *ChannelOptions[Message] {
+	x.UrgentBuffSize = urgentBuffSize
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithErrorListener(errorListener ErrorListener) *ChannelOptions[Message] {
+	x.ErrorListener = errorListener
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithMaxDepth(maxDepth int) *ChannelOptions[Message] {
+	x.MaxDepth = maxDepth
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithMaxChildren(maxChildren int) *ChannelOptions[Message] {
+	x.MaxChildren = maxChildren
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithDistribution(strategy DistributionStrategy[Message]) *ChannelOptions[Message] {
+	x.Distribution = strategy
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithWeight(weight int) *ChannelOptions[Message] {
+	x.Weight = weight
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithWorkStealing() *ChannelOptions[Message] {
+	x.WorkStealing = true
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithKeyedConcurrency(workers int, key KeyFunc[Message]) *ChannelOptions[Message] {
+	x.KeyedWorkers = workers
+	x.ConcurrencyKey = key
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithOrderedCompletion(workers int) *ChannelOptions[Message] {
+	x.OrderedWorkers = workers
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithMaxInFlight(maxInFlight int) *ChannelOptions[Message] {
+	x.MaxInFlight = maxInFlight
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithTransactionalSink(sink TransactionalSink[Message], batchSize int, maxWait time.Duration) *ChannelOptions[Message] {
+	x.TransactionalSinkOptions = &TransactionalSinkOptions[Message]{
+		Sink:      sink,
+		BatchSize: batchSize,
+		MaxWait:   maxWait,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithStore(store Store[Message]) *ChannelOptions[Message] {
+	x.Store = store
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithAutoCommit(interval time.Duration) *ChannelOptions[Message] {
+	x.AutoCommitInterval = interval
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithCheckpoint(every uint64, interval time.Duration, f CheckpointFunc) *ChannelOptions[Message] {
+	x.CheckpointOptions = &CheckpointOptions{
+		Every:    every,
+		Interval: interval,
+		Func:     f,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithRecorder(recorder *Recorder[Message]) *ChannelOptions[Message] {
+	x.Recorder = recorder
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithSink(sink Sink[Message]) *ChannelOptions[Message] {
+	x.Sinks = append(x.Sinks, sink)
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithErrorOutput(errorOutput *Channel[ErrorEvent]) *ChannelOptions[Message] {
+	x.ErrorOutput = errorOutput
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithSideOutput(name string, output Sender[Message]) *ChannelOptions[Message] {
+	if x.SideOutputs == nil {
+		x.SideOutputs = make(map[string]Sender[Message])
+	}
+	x.SideOutputs[name] = output
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithAuditor(auditor *Auditor[Message]) *ChannelOptions[Message] {
+	x.Auditor = auditor
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithSynchronousMode() *ChannelOptions[Message] {
+	x.SynchronousMode = true
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithForwardPolicy(policy ForwardPolicy, stallTimeout time.Duration) *ChannelOptions[Message] {
+	x.ForwardOptions = &ForwardOptions{
+		Policy:       policy,
+		StallTimeout: stallTimeout,
+	}
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithLeakTracker(leakTracker *LeakTracker) *ChannelOptions[Message] {
+	x.LeakTracker = leakTracker
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithClock(clock Clock) *ChannelOptions[Message] {
+	x.Clock = clock
+	return x
+}
+
+func (x *ChannelOptions[Message]) WithSupervisor(supervisorOptions *SupervisorOptions) *ChannelOptions[Message] {
+	x.SupervisorOptions = supervisorOptions
+	return x
+}
+
 // ------------------------------------------------ ---------------------------------------------------------------------
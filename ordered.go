@@ -0,0 +1,125 @@
+package message_channel
+
+import "sync"
+
+// orderedExecutor 并行处理、按顺序完成的执行器
+// 消息按照提交的顺序分配序号，由多个协程同时处理，处理完之后的下游操作（写出到Sinks、转发给父信道）先放在重排序的缓存里，轮到它的时候才执行，
+// 这样下游看到的消息的顺序和到达的顺序一样，而处理的过程是并行的
+type orderedExecutor struct {
+	lock *sync.Mutex
+	cond *sync.Cond
+
+	// 启动处理一条消息的协程，这样协程会算在信道的协程里
+	spawn func(role string, f func())
+
+	// 最多同时有多少条消息在被处理
+	workers int
+	running int
+
+	// 下一条提交的消息的序号，以及下一个要执行下游操作的序号
+	issued uint64
+	next   uint64
+
+	// 已经处理完了但是还没有轮到的消息的下游操作，提交了还没有执行完下游操作的消息最多有2*workers条，再多时提交的一方会被阻塞，这样一条很慢的消息不会让缓存无限制的增长
+	completed map[uint64][]func()
+
+	// 是否有协程正在按顺序执行下游操作，同时只有一个协程在执行
+	flushing bool
+}
+
+func newOrderedExecutor(workers int, spawn func(role string, f func())) *orderedExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+	x := &orderedExecutor{
+		lock:      &sync.Mutex{},
+		spawn:     spawn,
+		workers:   workers,
+		completed: make(map[uint64][]func()),
+	}
+	x.cond = sync.NewCond(x.lock)
+	return x
+}
+
+// 提交一个任务，等到有空闲的协程时开始处理，任务返回的下游操作会按照提交的顺序执行
+func (x *orderedExecutor) submit(task func() []func()) {
+	x.lock.Lock()
+	for x.running >= x.workers || x.issued-x.next >= uint64(2*x.workers) {
+		x.cond.Wait()
+	}
+	seq := x.issued
+	x.issued++
+	x.running++
+	x.lock.Unlock()
+
+	x.spawn(goroutineRoleOrdered, func() {
+		var effects []func()
+		defer func() {
+			x.complete(seq, effects)
+		}()
+		effects = task()
+	})
+}
+
+// 一条消息处理完了，轮到它的时候执行它以及它后面已经处理完了的消息的下游操作
+func (x *orderedExecutor) complete(seq uint64, effects []func()) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.running--
+	x.completed[seq] = effects
+	x.cond.Broadcast()
+	if x.flushing {
+		return
+	}
+	x.flushing = true
+	for {
+		effects, ok := x.completed[x.next]
+		if !ok {
+			break
+		}
+		delete(x.completed, x.next)
+		x.lock.Unlock()
+		for _, effect := range effects {
+			effect()
+		}
+		x.lock.Lock()
+		x.next++
+		x.cond.Broadcast()
+	}
+	x.flushing = false
+}
+
+// 等待所有提交的任务都处理完并且下游操作都执行完
+func (x *orderedExecutor) wait() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for x.running > 0 || x.next < x.issued {
+		x.cond.Wait()
+	}
+}
+
+// 并行处理一条消息，返回按顺序执行的下游操作，消费函数panic时和处理消息的协程崩溃一样按照监督策略处理，允许重启时这条消息没有下游操作
+func (x *Channel[Message]) consumeOrdered(envelope envelope[Message]) (effects []func()) {
+	defer func() {
+		reason := recover()
+		if reason == nil {
+			return
+		}
+		effects = nil
+		x.stats.inFlight.Add(-1)
+		x.consumerFailed(consumerPanicError(reason), envelope.message)
+		x.drop(DropReasonConsumerPanic, envelope.message)
+		x.checkpointEnd(envelope.offset)
+		if x.supervisor == nil || !x.supervisor.allowRestart(reason) {
+			panic(reason)
+		}
+	}()
+	x.stats.inFlight.Add(1)
+	x.consume(x.markConsumed(envelope), envelope, &effects)
+
+	// 轮到这条消息的下游操作执行完了才算处理完了
+	return append(effects, func() {
+		x.stats.inFlight.Add(-1)
+		x.markProcessed(envelope.offset)
+	})
+}
@@ -0,0 +1,71 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_OrderedCompletion(t *testing.T) {
+	running := &atomic.Int64{}
+	peak := &atomic.Int64{}
+	parentLock := &sync.Mutex{}
+	forwarded := make([]int, 0)
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(100).WithChannelConsumerFunc(func(index int, message int) {
+		parentLock.Lock()
+		forwarded = append(forwarded, message)
+		parentLock.Unlock()
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(100).WithOrderedCompletion(4).WithChannelConsumerFunc(func(index int, message int) {
+		current := running.Add(1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		// 越早到达的消息处理得越慢，完成的顺序和到达的顺序相反
+		time.Sleep(time.Millisecond * time.Duration(10-message%10))
+		running.Add(-1)
+	}))
+
+	expected := make([]int, 0)
+	for i := 0; i < 40; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+		expected = append(expected, i)
+	}
+	assert.Nil(t, parent.WaitForSubtreeDrained(context.Background()))
+
+	// 并行处理，但是转发给父信道的顺序和到达的顺序一样
+	parentLock.Lock()
+	assert.Equal(t, expected, forwarded)
+	parentLock.Unlock()
+	assert.Greater(t, peak.Load(), int64(1))
+	assert.LessOrEqual(t, peak.Load(), int64(4))
+
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+	assert.Equal(t, uint64(40), parent.Stats().Consumed)
+}
+
+func TestChannel_OrderedCompletionGoroutines(t *testing.T) {
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithOrderedCompletion(2).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+
+	// 每条正在处理的消息各有一个协程，算在信道的协程里
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 3
+	}, time.Second, time.Millisecond*5)
+	close(release)
+	channel.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		return channel.GoroutineCount() == 0
+	}, time.Second, time.Millisecond*5)
+}
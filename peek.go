@@ -0,0 +1,50 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrPeekUnsupported 缓存是用go的channel实现的，没办法查看其中的消息，需要配置QueueBuffer或者Ordering
+var ErrPeekUnsupported = errors.New("message channel: peek requires a queue buffer")
+
+// Peek 按照消费的顺序返回缓存中最前面的最多n条消息的拷贝，不会取出它们，适合调试以及让运维根据积压的内容决定要不要放行
+// 返回的只是调用时的情况，返回之后它们随时可能被消费掉；还没有提交的预留位置会被跳过，紧急通道中的消息排在最前面
+// 缓存是用go的channel实现的时候返回ErrPeekUnsupported，需要配置QueueBuffer或者Ordering；同步模式下没有缓存，总是返回空的
+func (x *Channel[Message]) Peek(ctx context.Context, n int) ([]Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0)
+	if n <= 0 || x.synchronous != nil {
+		return messages, nil
+	}
+
+	// 跳过的唤醒信号和预留位置也占着位置，多看一些直到凑够n条或者看完整个缓存
+	for limit := n; ; limit += n {
+		envelopes, ok := x.buffer.peek(limit)
+		if !ok {
+			return nil, ErrPeekUnsupported
+		}
+		messages = messages[:0]
+		for _, envelope := range envelopes {
+			if envelope.wakeup {
+				continue
+			}
+			if slot := envelope.slot; slot != nil {
+				committed, ok := slot.committed()
+				if !ok {
+					continue
+				}
+				envelope = committed
+			}
+			messages = append(messages, envelope.message)
+			if len(messages) == n {
+				return messages, nil
+			}
+		}
+		if len(envelopes) < limit {
+			return messages, nil
+		}
+	}
+}
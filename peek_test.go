@@ -0,0 +1,56 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_Peek(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithQueueBuffer().WithChannelBuffSize(10))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Nil(t, channel.SendUrgent(context.Background(), 0))
+
+	// 还没有提交的预留位置被跳过，提交了之后就能看到了
+	reservation, err := channel.Reserve(context.Background())
+	assert.Nil(t, err)
+	messages, err := channel.Peek(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, messages)
+	assert.Nil(t, reservation.Commit(4))
+	messages, err = channel.Peek(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, messages)
+
+	// 只是查看，消息还在缓存中
+	messages, err = channel.Peek(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1}, messages)
+	assert.Equal(t, 5, channel.Stats().Depth)
+
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, drain[int](channel))
+}
+
+func TestChannel_PeekOrdering(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithOrdering(func(a, b int) bool {
+		return a < b
+	}))
+	for _, message := range []int{3, 1, 2} {
+		assert.Nil(t, channel.Send(context.Background(), message))
+	}
+	messages, err := channel.Peek(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, messages)
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2, 3}, drain[int](channel))
+}
+
+func TestChannel_PeekUnsupported(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]())
+	_, err := channel.Peek(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrPeekUnsupported)
+	channel.SenderWaitAndClose()
+}
@@ -0,0 +1,137 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DropReasonSnapshot SnapshotPending失败之后取出来的消息没能放回缓存中
+const DropReasonSnapshot DropReason = "snapshot"
+
+// pendingSnapshotVersion SnapshotPending的格式的版本
+const pendingSnapshotVersion = 1
+
+// pendingSnapshot SnapshotPending导出的缓存中的消息
+type pendingSnapshot struct {
+	Version  int                      `json:"version"`
+	Messages []pendingSnapshotMessage `json:"messages"`
+}
+
+type pendingSnapshotMessage struct {
+
+	// 用Codec编码之后的消息
+	Data []byte `json:"data"`
+
+	// 消息的截止时间，没有截止时间时为nil
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// SnapshotPending 把缓存中还没有被处理的消息取出来，用codec编码之后返回，计划重启之前调用，重启之后通过RestorePending放回新的信道
+// 取出来的消息之后不会再被当前信道处理，这样恢复之后不会被处理两次；最好先Pause，否则处理消息的协程还会和它一起从缓存中取消息，
+// 被协程取走的消息照常处理，不会出现在快照中；这样不需要开启Store就可以在重启的时候保留积压的消息，开启了Store时不需要使用它
+// 编码失败或者ctx结束时返回错误，已经取出来的消息会被放回缓存的末尾，放不回去的按照DropReasonSnapshot丢弃
+func (x *Channel[Message]) SnapshotPending(ctx context.Context, codec Codec[Message]) ([]byte, error) {
+	snapshot := pendingSnapshot{Version: pendingSnapshotVersion}
+	taken := make([]envelope[Message], 0)
+	fail := func(err error) ([]byte, error) {
+		for _, envelope := range taken {
+			if x.buffer.tryPut(envelope) {
+				x.trackPending(envelope)
+				continue
+			}
+			x.drop(DropReasonSnapshot, envelope.message)
+		}
+		return nil, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		envelope, ok := x.takePending()
+		if !ok {
+			break
+		}
+		taken = append(taken, envelope)
+		data, err := codec.Encode(envelope.message)
+		if err != nil {
+			return fail(fmt.Errorf("message channel: encode pending message: %w", err))
+		}
+		message := pendingSnapshotMessage{Data: data}
+		if !envelope.deadline.IsZero() {
+			deadline := envelope.deadline
+			message.Deadline = &deadline
+		}
+		snapshot.Messages = append(snapshot.Messages, message)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fail(err)
+	}
+	return data, nil
+}
+
+// RestorePending 解码SnapshotPending导出的消息并按照原来的顺序放回缓存中，codec需要和导出时使用的一样，消息的截止时间会被保留
+// 先解码全部的消息，有一条解码失败的话一条都不会放入；放入的过程和Send一样，缓存满时阻塞，返回放入的消息的数量
+func (x *Channel[Message]) RestorePending(ctx context.Context, data []byte, codec Codec[Message]) (int, error) {
+	snapshot := pendingSnapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, err
+	}
+	if snapshot.Version != pendingSnapshotVersion {
+		return 0, fmt.Errorf("message channel: unsupported pending snapshot version %d", snapshot.Version)
+	}
+	messages := make([]Message, len(snapshot.Messages))
+	for i, pending := range snapshot.Messages {
+		message, err := codec.Decode(pending.Data)
+		if err != nil {
+			return 0, fmt.Errorf("message channel: decode pending message: %w", err)
+		}
+		messages[i] = message
+	}
+
+	for i, message := range messages {
+		var err error
+		if deadline := snapshot.Messages[i].Deadline; deadline != nil {
+			err = x.SendWithDeadline(ctx, message, *deadline)
+		} else {
+			err = x.Send(ctx, message)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// 从缓存中取出一条还没有被处理的消息，跳过唤醒信号，还没有提交的预留位置会被释放，缓存为空时返回false
+func (x *Channel[Message]) takePending() (envelope[Message], bool) {
+	for {
+		envelope, ok, closed := x.buffer.tryTake()
+		if closed {
+			// 拉模式的信道没有处理消息的协程，取到关闭信号的一方负责结束信道
+			if x.options.PullMode {
+				x.finish()
+			}
+			return envelope, false
+		}
+		if !ok {
+			return envelope, false
+		}
+		x.untrackPending(envelope)
+		if envelope.wakeup {
+			continue
+		}
+		if slot := envelope.slot; slot != nil {
+			if slot.release() {
+				continue
+			}
+			envelope = slot.envelope
+			x.untrackPending(envelope)
+		}
+		return envelope, true
+	}
+}
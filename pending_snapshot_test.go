@@ -0,0 +1,55 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 编码到某条消息时失败的Codec
+type failingCodec struct {
+	Codec[int]
+	failOn int
+}
+
+func (x failingCodec) Encode(message int) ([]byte, error) {
+	if message == x.failOn {
+		return nil, errors.New("boom")
+	}
+	return x.Codec.Encode(message)
+}
+
+func TestChannel_SnapshotPending(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		t.Errorf("snapshotted message %d was consumed", message)
+	}))
+	assert.Nil(t, channel.Pause())
+	deadline := time.Now().Add(time.Hour).Round(0)
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.SendWithDeadline(context.Background(), 2, deadline))
+	assert.Nil(t, channel.Send(context.Background(), 3))
+
+	// 编码失败时消息都还在缓存中
+	_, err := channel.SnapshotPending(context.Background(), failingCodec{Codec: JSONCodec[int](), failOn: 2})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, channel.Stats().Depth)
+
+	data, err := channel.SnapshotPending(context.Background(), JSONCodec[int]())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, channel.Stats().Depth)
+	assert.Contains(t, string(data), `"deadline"`)
+	channel.SenderWaitAndClose()
+
+	// 在新的信道中恢复，截止时间也保留了下来
+	restored := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	count, err := restored.RestorePending(context.Background(), data, JSONCodec[int]())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	go restored.SenderWaitAndClose()
+	assert.ElementsMatch(t, []int{1, 2, 3}, drain[int](restored))
+
+	_, err = restored.RestorePending(context.Background(), []byte(`{"version":2}`), JSONCodec[int]())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,180 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// BatchSinkFunc 流水线的终点，每次处理一批消息，流水线中没有Batch阶段时每批只有一条消息
+type BatchSinkFunc[Message any] func(messages []Message)
+
+// Pipeline 用链式调用的方式描述一条流水线，比如 NewPipeline(buffSize).Filter().Map().Batch().RateLimit(ctx, limit, per).To(sink)
+// 每个阶段都会被编译为一个拉模式的信道并连接到上一个阶段上，这样常见的拓扑不需要再手动一个一个的创建和连接信道
+// 为了让Batch之后的阶段也能继续链式调用，流水线内部流转的都是消息切片
+type Pipeline[Message any] struct {
+
+	// 流水线的入口，消息从这里发送进流水线
+	source *Channel[Message]
+
+	// 当前最后一个阶段的输出
+	tail *Channel[[]Message]
+
+	// 流水线的终点，调用To之后才会有
+	sink *Channel[[]Message]
+
+	// 每个阶段的信道的缓存大小
+	buffSize uint64
+}
+
+// NewPipeline 创建一条流水线，buffSize是流水线中每个阶段的信道的缓存大小
+func NewPipeline[Message any](buffSize uint64) *Pipeline[Message] {
+	return newPipeline[Message](buffSize, NewChannelOptions[Message]().WithChannelBuffSize(buffSize))
+}
+
+// 用指定的选项创建流水线的入口信道，入口信道总是工作在拉模式下
+func newPipeline[Message any](buffSize uint64, sourceOptions *ChannelOptions[Message]) *Pipeline[Message] {
+	x := &Pipeline[Message]{
+		source:   NewChannel[Message](sourceOptions.WithPullMode()),
+		buffSize: buffSize,
+	}
+	x.tail = NewChannel[[]Message](NewChannelOptions[[]Message]().WithChannelBuffSize(buffSize).WithPullMode())
+	connectTransform[[]Message](x.tail, true, func(emit EmitFunc[[]Message]) {
+		for {
+			message, err := x.source.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			_ = emit([]Message{message})
+		}
+	})
+	return x
+}
+
+// 在流水线的末尾追加一个阶段，run负责从上一个阶段拉取消息并输出到新的阶段
+func (x *Pipeline[Message]) stage(run func(previous *Channel[[]Message], emit EmitFunc[[]Message])) *Pipeline[Message] {
+	previous := x.tail
+	x.tail = NewChannel[[]Message](NewChannelOptions[[]Message]().WithChannelBuffSize(x.buffSize).WithPullMode())
+	connectTransform[[]Message](x.tail, true, func(emit EmitFunc[[]Message]) {
+		run(previous, emit)
+	})
+	return x
+}
+
+// 在流水线的末尾追加一个逐批处理的阶段
+func (x *Pipeline[Message]) eachStage(f func(messages []Message, emit EmitFunc[[]Message])) *Pipeline[Message] {
+	return x.stage(func(previous *Channel[[]Message], emit EmitFunc[[]Message]) {
+		for {
+			messages, err := previous.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			f(messages, emit)
+		}
+	})
+}
+
+// Filter 只保留满足条件的消息
+func (x *Pipeline[Message]) Filter(pred PredicateFunc[Message]) *Pipeline[Message] {
+	return x.eachStage(func(messages []Message, emit EmitFunc[[]Message]) {
+		kept := make([]Message, 0, len(messages))
+		for _, message := range messages {
+			if pred(message) {
+				kept = append(kept, message)
+			}
+		}
+		if len(kept) > 0 {
+			_ = emit(kept)
+		}
+	})
+}
+
+// Map 对每条消息做转换
+func (x *Pipeline[Message]) Map(f func(message Message) Message) *Pipeline[Message] {
+	return x.eachStage(func(messages []Message, emit EmitFunc[[]Message]) {
+		mapped := make([]Message, 0, len(messages))
+		for _, message := range messages {
+			mapped = append(mapped, f(message))
+		}
+		_ = emit(mapped)
+	})
+}
+
+// Batch 把消息重新分组为每批n条，一批中第一条消息等待超过maxWait之后即使没有凑够也会输出
+func (x *Pipeline[Message]) Batch(n int, maxWait time.Duration) *Pipeline[Message] {
+	return x.stage(func(previous *Channel[[]Message], emit EmitFunc[[]Message]) {
+
+		// 把上一个阶段输出的一批批消息展开为一条条消息
+		var pending []Message
+		receive := func(ctx context.Context) (Message, error) {
+			for len(pending) == 0 {
+				messages, err := previous.Receive(ctx)
+				if err != nil {
+					var zero Message
+					return zero, err
+				}
+				pending = messages
+			}
+			message := pending[0]
+			pending = pending[1:]
+			return message, nil
+		}
+
+		chunkLoop[Message](receive, previous.clock, n, maxWait, emit)
+	})
+}
+
+// RateLimit 限制每个周期per之内最多输出limit条消息，限流是按照消息的条数而不是批数计算的
+// 一批有多条消息时整批等到这批中最后一条消息允许放行的时间才一起输出，ctx被取消之后不再等待，剩余的消息会尽快流到终点
+func (x *Pipeline[Message]) RateLimit(ctx context.Context, limit int, per time.Duration) *Pipeline[Message] {
+
+	if limit <= 0 {
+		limit = 1
+	}
+	interval := per / time.Duration(limit)
+
+	// 下一条消息最早可以被放行的时间
+	var next time.Time
+	return x.eachStage(func(messages []Message, emit EmitFunc[[]Message]) {
+		if len(messages) == 0 {
+			return
+		}
+		clock := x.source.clock
+		if now := clock.Now(); next.Before(now) {
+			next = now
+		}
+
+		// 这批中最后一条消息允许放行的时间
+		last := next.Add(interval * time.Duration(len(messages)-1))
+		if wait := last.Sub(clock.Now()); wait > 0 {
+			sleepContext(ctx, clock, wait)
+		}
+		next = last.Add(interval)
+		_ = emit(messages)
+	})
+}
+
+// To 把流水线连接到终点sink上，返回流水线的入口信道
+// 往入口信道发送完消息之后调用入口信道的SenderWaitAndClose，然后调用Wait就可以等待流水线处理完毕
+func (x *Pipeline[Message]) To(sink BatchSinkFunc[Message]) *Channel[Message] {
+	x.sink = NewChannel[[]Message](NewChannelOptions[[]Message]().WithChannelBuffSize(x.buffSize).WithChannelConsumerFunc(func(index int, messages []Message) {
+		sink(messages)
+	}))
+	previous := x.tail
+	connectTransform[[]Message](x.sink, true, func(emit EmitFunc[[]Message]) {
+		for {
+			messages, err := previous.Receive(context.Background())
+			if err != nil {
+				return
+			}
+			_ = emit(messages)
+		}
+	})
+	return x.source
+}
+
+// Wait 等待流水线的终点处理完所有的消息，需要先调用To
+func (x *Pipeline[Message]) Wait(ctx context.Context) {
+	if x.sink != nil {
+		x.sink.ReceiverWait(ctx)
+	}
+}
@@ -0,0 +1,87 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	lock := &sync.Mutex{}
+	batches := make([][]int, 0)
+	pipeline := NewPipeline[int](10).
+		Filter(func(i int) bool { return i%2 == 0 }).
+		Map(func(i int) int { return i * 10 }).
+		Batch(2, time.Second).
+		RateLimit(context.Background(), 1000, time.Second)
+	source := pipeline.To(func(messages []int) {
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, messages)
+	})
+	for i := 1; i <= 10; i++ {
+		assert.Nil(t, source.Send(context.Background(), i))
+	}
+	source.SenderWaitAndClose()
+	pipeline.Wait(context.Background())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, [][]int{{20, 40}, {60, 80}, {100}}, batches)
+}
+
+func TestPipeline_RateLimit(t *testing.T) {
+	count := 0
+	pipeline := NewPipeline[int](10).RateLimit(context.Background(), 1, time.Millisecond*20)
+	source := pipeline.To(func(messages []int) {
+		count += len(messages)
+	})
+	start := time.Now()
+	for i := 1; i <= 6; i++ {
+		assert.Nil(t, source.Send(context.Background(), i))
+	}
+	source.SenderWaitAndClose()
+	pipeline.Wait(context.Background())
+
+	// 第一条消息立即放行，之后每条都要等20毫秒
+	assert.Equal(t, 6, count)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*100)
+}
+
+func TestPipeline_RateLimit_Cancel(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	count := 0
+	pipeline := NewPipeline[int](10).RateLimit(ctx, 1, time.Hour)
+	source := pipeline.To(func(messages []int) {
+		count += len(messages)
+	})
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, source.Send(context.Background(), i))
+	}
+
+	// 取消之后不再等待，剩余的消息会马上被放行
+	cancelFunc()
+	source.SenderWaitAndClose()
+	pipeline.Wait(context.Background())
+	assert.Equal(t, 3, count)
+}
+
+func TestPipeline_RateLimit_Batch(t *testing.T) {
+	var elapsed []time.Duration
+	pipeline := NewPipeline[int](10).Batch(5, time.Second).RateLimit(context.Background(), 1, time.Millisecond*20)
+	start := time.Now()
+	source := pipeline.To(func(messages []int) {
+		elapsed = append(elapsed, time.Since(start))
+	})
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, source.Send(context.Background(), i))
+	}
+	source.SenderWaitAndClose()
+	pipeline.Wait(context.Background())
+
+	// 一批5条消息要等到第5条消息允许放行的时间，也就是80毫秒之后才输出
+	assert.Equal(t, 1, len(elapsed))
+	assert.GreaterOrEqual(t, elapsed[0], time.Millisecond*80)
+}
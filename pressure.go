@@ -0,0 +1,55 @@
+package message_channel
+
+import "math"
+
+// PressureListener 压力等级变化时的回调，level是变化之后按照0.1取整的等级
+// 在发送消息或者处理完消息的协程中同步调用，需要尽快返回，并发的变化可能在不同的协程中回调
+type PressureListener func(level float64)
+
+// PressureLevel 信道当前的压力等级，0表示完全空闲，1表示马上就要阻塞发送方了，配合的生产者可以据此在被阻塞之前主动降低发送的速率
+// 取缓存占用的比例和排队等待的时长两者中较大的一个：排队等待的时长按照积压的消息数量乘以消费耗时的指数加权移动平均再除以处理消息的协程数估算，
+// 和PressureLatencyTarget相比得到0到1之间的值，没有配置PressureLatencyTarget时使用ConsumeDeadline，都没有配置时只看缓存占用的比例
+func (x *Channel[Message]) PressureLevel() float64 {
+	depth := x.buffer.len()
+	level := 0.0
+	if capacity := x.buffer.cap(); capacity > 0 {
+		level = float64(depth) / float64(capacity)
+	}
+
+	target := x.options.PressureLatencyTarget
+	if target <= 0 {
+		target = x.tuned().ConsumeDeadline
+	}
+	if target > 0 {
+		workers := x.Workers()
+		if workers < 1 {
+			workers = 1
+		}
+		wait := float64(depth) * float64(x.stats.latencyEWMA.value()) / float64(workers)
+		if latency := wait / float64(target); latency > level {
+			level = latency
+		}
+	}
+	if level > 1 {
+		level = 1
+	}
+	return level
+}
+
+// 配置了OnPressureChange时检查压力等级是否变化了，变化了的话回调，同一个等级只会回调一次
+func (x *Channel[Message]) checkPressure() {
+	if x.options.OnPressureChange == nil {
+		return
+	}
+	step := int64(math.Floor(x.PressureLevel() * 10))
+	for {
+		old := x.pressureStep.Load()
+		if old == step {
+			return
+		}
+		if x.pressureStep.CompareAndSwap(old, step) {
+			x.options.OnPressureChange(float64(step) / 10)
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_PressureLevel(t *testing.T) {
+	lock := &sync.Mutex{}
+	levels := make([]float64, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithPressure(0, func(level float64) {
+		lock.Lock()
+		defer lock.Unlock()
+		levels = append(levels, level)
+	}))
+
+	// 只看缓存占用的比例，每变化一个等级回调一次
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.InDelta(t, 0.5, channel.PressureLevel(), 0.001)
+	for i := 0; i < 5; i++ {
+		_, err := channel.Receive(context.Background())
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, float64(0), channel.PressureLevel())
+	lock.Lock()
+	assert.Equal(t, []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.4, 0.3, 0.2, 0.1, 0}, levels)
+	lock.Unlock()
+
+	go channel.SenderWaitAndClose()
+	drain[int](channel)
+}
+
+func TestChannel_PressureLevelLatency(t *testing.T) {
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(100).WithPressure(time.Millisecond*100, nil).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 0 {
+			time.Sleep(time.Millisecond * 20)
+			return
+		}
+		<-release
+	}))
+
+	// 缓存只用了一小部分，但是按照消费耗时积压的消息要排队很久
+	assert.Nil(t, channel.Send(context.Background(), 0))
+	assert.Eventually(t, func() bool {
+		return channel.Stats().LatencyEWMA > 0
+	}, time.Second, time.Millisecond*5)
+	for i := 1; i <= 10; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return channel.PressureLevel() == 1
+	}, time.Second, time.Millisecond*5)
+	close(release)
+	channel.SenderWaitAndClose()
+}
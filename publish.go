@@ -0,0 +1,59 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+)
+
+// Publication PublishAll中要发送到某一个信道的一条消息，通过Publish创建，不同的Publication可以是不同类型的信道
+type Publication interface {
+
+	// 在信道中预留一个位置，返回提交和取消的函数
+	reserve(ctx context.Context) (commit func() error, cancel func(), err error)
+}
+
+type publication[Message any] struct {
+	channel *Channel[Message]
+	message Message
+}
+
+// Publish 创建一个把message发送到channel的Publication，因为Publication需要带着各自的消息类型，所以这里是一个函数而不是方法
+func Publish[Message any](channel *Channel[Message], message Message) Publication {
+	return &publication[Message]{channel: channel, message: message}
+}
+
+func (x *publication[Message]) reserve(ctx context.Context) (func() error, func(), error) {
+	reservation, err := x.channel.Reserve(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() error {
+		return reservation.Commit(x.message)
+	}, reservation.Cancel, nil
+}
+
+// PublishAll 把一个事件拆成的几条相关的消息发送到多个信道中，对处理消息的一方来说要么全部都发出去了，要么一条都没有发出去
+// 先按照顺序在每个信道中预留位置，任何一个预留失败（比如ctx超时、信道已经关闭）时取消已经预留的位置并返回错误，全部预留成功之后再依次提交
+// 预留的位置在提交之前处理消息的一方会等待，所以不会有信道先看到自己的那一部分；只有预留的位置在提交之前被OverflowDropOldest挤掉或者信道被强制关闭时才会只发出去一部分，这时返回ErrReservationReleased
+func PublishAll(ctx context.Context, pubs ...Publication) error {
+	commits := make([]func() error, 0, len(pubs))
+	cancels := make([]func(), 0, len(pubs))
+	for i, pub := range pubs {
+		commit, cancel, err := pub.reserve(ctx)
+		if err != nil {
+			for _, cancel := range cancels {
+				cancel()
+			}
+			return fmt.Errorf("message channel: publish %d of %d: %w", i+1, len(pubs), err)
+		}
+		commits = append(commits, commit)
+		cancels = append(cancels, cancel)
+	}
+	var firstErr error
+	for i, commit := range commits {
+		if err := commit(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("message channel: publish %d of %d: %w", i+1, len(pubs), err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,29 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPublishAll(t *testing.T) {
+	orders := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(2))
+	audits := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(1))
+	assert.Nil(t, PublishAll(context.Background(), Publish(orders, 1), Publish(audits, "order 1")))
+
+	// audits满了，orders上已经预留的位置被取消，一条都不会发出去
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := PublishAll(ctx, Publish(orders, 2), Publish(audits, "order 2"))
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(1), orders.Stats().Sent)
+
+	go orders.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, drain[int](orders))
+	go audits.SenderWaitAndClose()
+	assert.Equal(t, []string{"order 1"}, drain[string](audits))
+
+	// 信道已经关闭
+	assert.ErrorIs(t, PublishAll(context.Background(), Publish(orders, 3)), ErrChannelClosed)
+}
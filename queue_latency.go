@@ -0,0 +1,14 @@
+package message_channel
+
+// 消息从缓存中被取出时调用，开启了QueueLatency时统计消息在缓存中等待的时长并触发OnDequeue
+// 重新投递的历史消息以及从Store中恢复的消息没有放入的时间，不参与统计
+func (x *Channel[Message]) dequeued(envelope envelope[Message]) {
+	if !x.options.QueueLatency || envelope.enqueuedAt.IsZero() {
+		return
+	}
+	queuedFor := x.clock.Since(envelope.enqueuedAt)
+	x.stats.queueLatency.observe(queuedFor)
+	if x.options.OnDequeue != nil {
+		x.options.OnDequeue(envelope.message, queuedFor)
+	}
+}
@@ -0,0 +1,43 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_QueueLatency(t *testing.T) {
+	lock := &sync.Mutex{}
+	queued := make(map[int]time.Duration)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+	}).WithQueueLatency(func(message int, queuedFor time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		queued[message] = queuedFor
+	}))
+
+	// 暂停期间消息都在缓存中排队
+	assert.Nil(t, channel.Pause())
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	time.Sleep(time.Millisecond * 20)
+	assert.Nil(t, channel.Resume())
+	channel.SenderWaitAndClose()
+
+	assert.Len(t, queued, 2)
+	assert.GreaterOrEqual(t, queued[1], time.Millisecond*20)
+	stats := channel.Stats()
+	assert.GreaterOrEqual(t, stats.QueueLatencyMax, time.Millisecond*20)
+	assert.GreaterOrEqual(t, stats.QueueLatencyP99, stats.QueueLatencyP50)
+	assert.Less(t, stats.LatencyMax, time.Millisecond*20)
+}
+
+func TestChannel_QueueLatencyDisabled(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	_, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), channel.Stats().QueueLatencyMax)
+}
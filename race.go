@@ -0,0 +1,12 @@
+package message_channel
+
+import "context"
+
+// Race 返回chans中任何一个最先产生的消息，其他的信道不再等待，它们之后产生的消息也不会被取走，适合在信道流水线上做对冲请求：
+// 同一个请求发到几条流水线上，谁先回来就用谁的，剩下的回复留给调用方自己处理或者随着信道关闭丢掉
+// chans都需要是拉模式的，否则返回ErrNotPullMode，所有的信道都关闭了还没有消息时返回ErrChannelClosed，ctx结束时返回ctx的错误
+// 因为需要根据消息类型实例化，所以这里是一个函数而不是方法
+func Race[Message any](ctx context.Context, chans ...*Channel[Message]) (Message, error) {
+	_, message, err := SelectReceive(ctx, chans...)
+	return message, err
+}
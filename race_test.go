@@ -0,0 +1,35 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRace(t *testing.T) {
+	slow := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(1))
+	fast := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(1))
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		assert.Nil(t, slow.Send(context.Background(), "slow"))
+	}()
+	go func() {
+		time.Sleep(time.Millisecond * 5)
+		assert.Nil(t, fast.Send(context.Background(), "fast"))
+	}()
+
+	message, err := Race(context.Background(), slow, fast)
+	assert.Nil(t, err)
+	assert.Equal(t, "fast", message)
+
+	// 输掉的回复没有被取走
+	reply, err := slow.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "slow", reply)
+
+	go slow.SenderWaitAndClose()
+	go fast.SenderWaitAndClose()
+	_, err = Race(context.Background(), slow, fast)
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
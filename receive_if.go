@@ -0,0 +1,69 @@
+package message_channel
+
+import "context"
+
+// ReceiveIf 拉模式下等到有消息之后，只有最前面的一条消息满足pred时才取走它，返回这条消息和true，
+// 不满足时消息留在最前面，返回false，之后的Receive和ReceiveIf还会先拿到它，适合按照协议消费："是我在等的那个回复才取走"
+// 没有消息时和Receive一样阻塞，其他的错误也和Receive一样
+func (x *Channel[Message]) ReceiveIf(ctx context.Context, pred func(message Message) bool) (Message, bool, error) {
+	return x.receive(ctx, pred)
+}
+
+// 拉模式下取一条消息，pred不为nil时只有满足pred才取走
+func (x *Channel[Message]) receive(ctx context.Context, pred func(message Message) bool) (Message, bool, error) {
+	var zero Message
+	if !x.options.PullMode {
+		return zero, false, ErrNotPullMode
+	}
+	select {
+	case x.receiving <- struct{}{}:
+	case <-ctx.Done():
+		return zero, false, ctx.Err()
+	}
+	defer func() {
+		<-x.receiving
+	}()
+
+	for {
+		if err := x.waitResumed(ctx); err != nil {
+			return zero, false, err
+		}
+		envelope, ok := x.takeHead()
+		if !ok {
+			var err error
+			envelope, ok, err = x.takeNext(ctx)
+			if err != nil {
+				return zero, false, err
+			}
+			if !ok {
+				x.finish()
+				return zero, false, ErrChannelClosed
+			}
+			if !x.admit(&envelope) {
+				continue
+			}
+		}
+		if pred != nil && !pred(envelope.message) {
+			x.holdHead(envelope)
+			return zero, false, nil
+		}
+		x.markConsumed(envelope)
+		x.markProcessed(envelope.offset)
+		return envelope.message, true, nil
+	}
+}
+
+// 把ReceiveIf没有取走的消息放回缓存的最前面，它重新开始计算在缓存中等待的时长
+func (x *Channel[Message]) holdHead(envelope envelope[Message]) {
+	x.trackPending(envelope)
+	x.head.hold(envelope)
+}
+
+// 取出放回缓存最前面的消息，它已经从缓存中取出来过一次了，不需要再经过takeNext
+func (x *Channel[Message]) takeHead() (envelope[Message], bool) {
+	envelope, ok := x.head.takeHead()
+	if ok {
+		x.untrackPending(envelope)
+	}
+	return envelope, ok
+}
@@ -0,0 +1,85 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_ReceiveIf(t *testing.T) {
+	channel := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(10))
+	isAck := func(message string) bool {
+		return message == "ack"
+	}
+	assert.Nil(t, channel.Send(context.Background(), "data"))
+	assert.Nil(t, channel.Send(context.Background(), "ack"))
+
+	// 最前面的不是ack，留在原地
+	message, ok, err := channel.ReceiveIf(context.Background(), isAck)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "", message)
+	message, ok, err = channel.ReceiveIf(context.Background(), isAck)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	message, err = channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "data", message)
+	message, ok, err = channel.ReceiveIf(context.Background(), isAck)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "ack", message)
+	assert.Equal(t, uint64(2), channel.Stats().Consumed)
+
+	// 没有消息时阻塞到ctx结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, _, err = channel.ReceiveIf(ctx, isAck)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 关闭之后留在最前面的消息还可以被取走
+	assert.Nil(t, channel.Send(context.Background(), "late"))
+	_, ok, _ = channel.ReceiveIf(context.Background(), isAck)
+	assert.False(t, ok)
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []string{"late"}, drain[string](channel))
+
+	push := NewChannel[int](NewChannelOptions[int]())
+	_, _, err = push.ReceiveIf(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrNotPullMode)
+	push.SenderWaitAndClose()
+}
+
+func TestChannel_ReceiveIfHeldSnapshot(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithQueueBuffer().WithChannelBuffSize(10))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+
+	// 没有被取走的消息仍然算在缓存里
+	_, ok, err := channel.ReceiveIf(context.Background(), func(message int) bool {
+		return false
+	})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 3, channel.Stats().Depth)
+	peeked, err := channel.Peek(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, peeked)
+
+	// 快照中也包括它，恢复之后还在最前面
+	data, err := channel.SnapshotPending(context.Background(), JSONCodec[int]())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, channel.Stats().Depth)
+	go channel.SenderWaitAndClose()
+	assert.Empty(t, drain[int](channel))
+
+	restored := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	n, err := restored.RestorePending(context.Background(), data, JSONCodec[int]())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	go restored.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 2, 3}, drain[int](restored))
+}
@@ -0,0 +1,120 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrNotReconfigurable Reconfigure修改了运行中不能修改的选项
+var ErrNotReconfigurable = errors.New("message channel: option cannot be reconfigured on a running channel")
+
+// 运行中可以通过Reconfigure修改的选项
+var reconfigurableOptions = map[string]bool{
+	"MaxRetries":              true,
+	"ConsumeDeadline":         true,
+	"ConsumeTimeout":          true,
+	"ConsumeTimeoutPolicy":    true,
+	"StallThreshold":          true,
+	"OverflowPolicy":          true,
+	"RejectSendWhileDraining": true,
+	"CloseTimeout":            true,
+	"CloseEscalation":         true,
+	"Weight":                  true,
+	"AutoscaleOptions":        true,
+}
+
+// Reconfigure 修改运行中的信道的选项，f拿到的是当前选项的一份拷贝，修改完之后整体生效，不需要重建拓扑
+// 可以修改的选项有MaxRetries、ConsumeDeadline、ConsumeTimeout、ConsumeTimeoutPolicy、StallThreshold、OverflowPolicy、RejectSendWhileDraining、CloseTimeout、CloseEscalation、Weight，
+// 以及开启了自动伸缩时AutoscaleOptions中的各项（处理消息的协程的数量范围、期望的缓存深度、检查的间隔），但是不能开启或者关闭自动伸缩
+// 修改了其他的选项时返回ErrNotReconfigurable，这时候什么都不会修改；f不要原地修改选项中的map和切片，它们和正在使用的选项是共享的
+// 修改了协程的数量范围时等待协程的数量调整到新的范围内才返回，缩容的协程会先处理完手上的消息，ctx结束时返回ctx的错误，这时候新的选项已经生效了
+// 正在处理的消息可能用的还是修改之前的选项，之后的消息都使用新的选项
+func (x *Channel[Message]) Reconfigure(ctx context.Context, f func(options *ChannelOptions[Message])) error {
+	x.tuningLock.Lock()
+	defer x.tuningLock.Unlock()
+
+	if x.IsClosed() {
+		return ErrChannelClosed
+	}
+
+	old := x.tuned()
+	options := *old
+	if old.AutoscaleOptions != nil {
+		autoscale := *old.AutoscaleOptions
+		options.AutoscaleOptions = &autoscale
+	}
+	f(&options)
+	if err := checkReconfigurable(old, &options); err != nil {
+		return err
+	}
+	if (old.AutoscaleOptions == nil) != (options.AutoscaleOptions == nil) {
+		return fmt.Errorf("%w: AutoscaleOptions cannot be enabled or disabled", ErrNotReconfigurable)
+	}
+
+	x.tuning.Store(&options)
+	if options.Weight != old.Weight {
+		x.SetWeight(options.Weight)
+	}
+	if options.AutoscaleOptions == nil {
+		return nil
+	}
+
+	// 唤醒自动伸缩的协程按照新的范围调整协程的数量
+	select {
+	case x.retune <- struct{}{}:
+	default:
+	}
+	minWorkers, maxWorkers := autoscaleBounds(options.AutoscaleOptions)
+	for {
+		workers := x.Workers()
+		if (workers >= minWorkers && workers <= maxWorkers) || x.IsClosed() {
+			return nil
+		}
+		if !sleepContext(ctx, x.clock, statePollInterval) {
+			return ctx.Err()
+		}
+	}
+}
+
+// 当前生效的可以在运行中修改的选项
+func (x *Channel[Message]) tuned() *ChannelOptions[Message] {
+	return x.tuning.Load()
+}
+
+// 检查是否只修改了可以在运行中修改的选项，函数、map、切片和指针只能比较是不是同一个
+func checkReconfigurable[Message any](old, options *ChannelOptions[Message]) error {
+	before, after := reflect.ValueOf(old).Elem(), reflect.ValueOf(options).Elem()
+	for i := 0; i < before.NumField(); i++ {
+		name := before.Type().Field(i).Name
+		if reconfigurableOptions[name] {
+			continue
+		}
+		if !sameOption(before.Field(i), after.Field(i)) {
+			return fmt.Errorf("%w: %s", ErrNotReconfigurable, name)
+		}
+	}
+	return nil
+}
+
+func sameOption(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Func, reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	case reflect.Map, reflect.Slice:
+		return a.Pointer() == b.Pointer() && a.Len() == b.Len()
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return sameOption(a.Elem(), b.Elem())
+	}
+	if !a.Type().Comparable() {
+		return true
+	}
+	return a.Interface() == b.Interface()
+}
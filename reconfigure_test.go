@@ -0,0 +1,69 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_Reconfigure(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+
+	// 缓存满了之后改成丢弃新消息，发送不再阻塞
+	assert.Nil(t, channel.Reconfigure(context.Background(), func(options *ChannelOptions[int]) {
+		options.OverflowPolicy = OverflowDropNewest
+		options.MaxRetries = 5
+		options.Weight = 3
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	assert.Equal(t, uint64(1), channel.Stats().DroppedByReason[DropReasonBufferFull])
+	assert.Equal(t, 3, channel.Weight())
+
+	// 修改了不能修改的选项时整体都不生效
+	err := channel.Reconfigure(context.Background(), func(options *ChannelOptions[int]) {
+		options.MaxRetries = 10
+		options.Name = "renamed"
+	})
+	assert.ErrorIs(t, err, ErrNotReconfigurable)
+	assert.Contains(t, err.Error(), "Name")
+	assert.Equal(t, 5, channel.tuned().MaxRetries)
+
+	err = channel.Reconfigure(context.Background(), func(options *ChannelOptions[int]) {
+		options.AutoscaleOptions = &AutoscaleOptions{Min: 2, Max: 2}
+	})
+	assert.ErrorIs(t, err, ErrNotReconfigurable)
+
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, drain[int](channel))
+	channel.ReceiverWait(context.Background())
+	assert.ErrorIs(t, channel.Reconfigure(context.Background(), func(options *ChannelOptions[int]) {}), ErrChannelClosed)
+}
+
+func TestChannel_ReconfigureWorkers(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithAutoscale(1, 1, 100).WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond)
+	}))
+	assert.Equal(t, 1, channel.Workers())
+
+	// 扩容和缩容都等到协程的数量调整好了才返回
+	assert.Nil(t, channel.Reconfigure(context.Background(), func(options *ChannelOptions[int]) {
+		options.AutoscaleOptions.Min = 3
+		options.AutoscaleOptions.Max = 4
+	}))
+	assert.Equal(t, 3, channel.Workers())
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, channel.Reconfigure(ctx, func(options *ChannelOptions[int]) {
+		options.AutoscaleOptions.Min = 1
+		options.AutoscaleOptions.Max = 1
+	}))
+	assert.Equal(t, 1, channel.Workers())
+
+	channel.SenderWaitAndClose()
+	assert.Equal(t, uint64(5), channel.Stats().Consumed)
+}
@@ -0,0 +1,113 @@
+package message_channel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordLine 录制文件中的一行，每行是一个JSON对象，消息本身按照Codec编码之后作为字节保存
+type recordLine struct {
+	Time time.Time `json:"time"`
+	Data []byte    `json:"data"`
+}
+
+// Recorder 把信道收到的消息连同收到的时间一起录制下来，之后可以通过Play按照原来的节奏重新放入信道，用于压测和回归测试
+// 通过WithRecorder配置到信道上之后，每一条成功放入信道的消息都会被录制，可以被并发使用
+type Recorder[Message any] struct {
+	lock    *sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+	codec   Codec[Message]
+}
+
+// NewRecorder 创建一个往writer中录制的Recorder
+func NewRecorder[Message any](writer io.Writer, codec Codec[Message]) *Recorder[Message] {
+	return &Recorder[Message]{
+		lock:    &sync.Mutex{},
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		codec:   codec,
+	}
+}
+
+// NewFileRecorder 创建一个录制到文件中的Recorder，文件已经存在时追加在后面，用完之后需要调用Close
+func NewFileRecorder[Message any](path string, codec Codec[Message]) (*Recorder[Message], error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder[Message](file, codec), nil
+}
+
+// Record 录制一条消息
+func (x *Recorder[Message]) Record(at time.Time, message Message) error {
+	data, err := x.codec.Encode(message)
+	if err != nil {
+		return err
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.encoder.Encode(recordLine{Time: at, Data: data})
+}
+
+// Close writer实现了io.Closer时关闭它
+func (x *Recorder[Message]) Close() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if closer, ok := x.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// PlaybackTiming 回放录制的消息时的节奏
+type PlaybackTiming int
+
+const (
+
+	// PlaybackAsFastAsPossible 不等待，尽可能快的把消息放入信道
+	PlaybackAsFastAsPossible PlaybackTiming = iota
+
+	// PlaybackOriginalGaps 保持录制时消息之间的时间间隔
+	PlaybackOriginalGaps
+)
+
+// Play 把Recorder录制的消息按照顺序重新发送到当前信道中，返回发送成功的消息的数量
+// 发送失败、解码失败或者ctx被取消时停止回放并返回错误，读到末尾时返回nil
+func (x *Channel[Message]) Play(ctx context.Context, reader io.Reader, codec Codec[Message], timing PlaybackTiming) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	played := 0
+	var previous time.Time
+	for scanner.Scan() {
+		var line recordLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return played, err
+		}
+		message, err := codec.Decode(line.Data)
+		if err != nil {
+			return played, err
+		}
+
+		if timing == PlaybackOriginalGaps && !previous.IsZero() {
+			if gap := line.Time.Sub(previous); gap > 0 {
+				if !sleepContext(ctx, x.clock, gap) {
+					return played, ctx.Err()
+				}
+			}
+		}
+		previous = line.Time
+
+		if err := x.Send(ctx, message); err != nil {
+			return played, err
+		}
+		played++
+	}
+	return played, scanner.Err()
+}
@@ -0,0 +1,63 @@
+package message_channel
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChannel_RecordAndPlay(t *testing.T) {
+	recording := &bytes.Buffer{}
+	source := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithRecorder(NewRecorder[string](recording, JSONCodec[string]())).WithChannelConsumerFunc(func(index int, message string) {}))
+	for _, message := range []string{"a", "b", "c"} {
+		assert.Nil(t, source.Send(context.Background(), message))
+		time.Sleep(50 * time.Millisecond)
+	}
+	source.SenderWaitAndClose()
+
+	// 尽可能快的回放
+	received := make([]string, 0)
+	fast := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message string) {
+		received = append(received, message)
+	}))
+	start := time.Now()
+	played, err := fast.Play(context.Background(), bytes.NewReader(recording.Bytes()), JSONCodec[string](), PlaybackAsFastAsPossible)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, played)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	fast.SenderWaitAndClose()
+	assert.Equal(t, []string{"a", "b", "c"}, received)
+
+	// 保持原来的时间间隔回放
+	paced := NewChannel[string](NewChannelOptions[string]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message string) {}))
+	start = time.Now()
+	played, err = paced.Play(context.Background(), bytes.NewReader(recording.Bytes()), JSONCodec[string](), PlaybackOriginalGaps)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, played)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	paced.SenderWaitAndClose()
+}
+
+func TestFileRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	recorder, err := NewFileRecorder[int](path, JSONCodec[int]())
+	assert.Nil(t, err)
+	assert.Nil(t, recorder.Record(time.Now(), 1))
+	assert.Nil(t, recorder.Record(time.Now(), 2))
+	assert.Nil(t, recorder.Close())
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode())
+	played, err := channel.Play(context.Background(), file, JSONCodec[int](), PlaybackAsFastAsPossible)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, played)
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, message)
+}
@@ -0,0 +1,68 @@
+package message_channel
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultRegistry 进程级别的默认注册表，创建信道时通过WithRegistry(DefaultRegistry)就可以注册到这里
+var DefaultRegistry = NewRegistry()
+
+// Registry 按照名字登记信道的注册表，这样松耦合的组件就可以通过名字找到已经存在的拓扑并接入进去
+// 注册表中的信道的消息类型可以各不相同，查找时需要给出消息类型
+type Registry struct {
+
+	// 用于全局互斥操作
+	lock *sync.RWMutex
+
+	// 名字到信道的映射，值是*Channel[Message]
+	channels map[string]any
+}
+
+// NewRegistry 创建一个注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		lock:     &sync.RWMutex{},
+		channels: make(map[string]any),
+	}
+}
+
+// Register 把信道按照它的名字登记到注册表中，没有名字的信道不会被登记
+// 已经有同名的信道时会被新的信道覆盖，信道关闭时如果注册表中还是它自己就会被自动移除
+func Register[Message any](registry *Registry, channel *Channel[Message]) {
+	if channel.options.Name == "" {
+		return
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.channels[channel.options.Name] = channel
+}
+
+// Lookup 根据名字查找信道，信道不存在或者消息类型不一致时返回false
+func Lookup[Message any](registry *Registry, name string) (*Channel[Message], bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	channel, ok := registry.channels[name].(*Channel[Message])
+	return channel, ok
+}
+
+// Unregister 从注册表中移除信道
+func Unregister[Message any](registry *Registry, channel *Channel[Message]) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if current, ok := registry.channels[channel.options.Name].(*Channel[Message]); ok && current == channel {
+		delete(registry.channels, channel.options.Name)
+	}
+}
+
+// Names 返回注册表中所有信道的名字，按照字典序排列
+func (x *Registry) Names() []string {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	names := make([]string, 0, len(x.channels))
+	for name := range x.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
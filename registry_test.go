@@ -0,0 +1,25 @@
+package message_channel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	channel := NewChannel[string](NewChannelOptions[string]().WithName("events").WithRegistry(registry))
+
+	found, ok := Lookup[string](registry, "events")
+	assert.True(t, ok)
+	assert.Equal(t, channel, found)
+
+	// 消息类型不一致时找不到
+	_, ok = Lookup[int](registry, "events")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"events"}, registry.Names())
+
+	// 关闭之后自动从注册表中移除
+	channel.SenderWaitAndClose()
+	_, ok = Lookup[string](registry, "events")
+	assert.False(t, ok)
+}
@@ -0,0 +1,114 @@
+package message_channel
+
+import "context"
+
+// replayFeed 重新投递的历史消息，处理消息的一方从这里取消息直到它被关闭
+type replayFeed[Message any] struct {
+	messages chan envelope[Message]
+}
+
+type replayContextKey struct{}
+
+// IsReplay 在ContextConsumerFunc或者DecisionConsumerFunc中判断当前处理的消息是否是通过ReplayFrom重新投递的历史消息
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayContextKey{}).(bool)
+	return replay
+}
+
+// ReplayFrom 把Store中偏移量不小于offset的历史消息重新交给消费函数处理，适合修复了消费逻辑的bug之后重新处理一遍
+// 只重新投递调用时已经放入信道的消息，重新投递的过程中信道会暂停处理缓存中的新消息，历史消息都交给消费函数之后返回，然后恢复处理新的消息
+// 重新投递的消息可以通过IsReplay区分出来，它们不会再被保存到Store中，也不会影响已经提交的偏移量
+// Store在提交之后可能已经删除了已经提交的消息（比如NewMemoryStore创建的Store），这些消息不会再被重新投递
+// 没有配置Store时返回ErrNoStore，信道已经开始关闭时返回ErrChannelClosed，ctx被取消时停止重新投递并返回ctx的错误
+func (x *Channel[Message]) ReplayFrom(ctx context.Context, offset uint64) error {
+	store := x.options.Store
+	if store == nil {
+		return ErrNoStore
+	}
+
+	// 同时只能有一个重新投递
+	x.replayLock.Lock()
+	defer x.replayLock.Unlock()
+
+	// 重新投递完之前不能关闭底层的channel，否则唤醒信号可能会发送到已经关闭的channel上
+	// 检查状态和登记在stateLock中一起进行，关闭的一方也是在stateLock中开始关闭的，这样要么关闭的一方等待之前这里已经登记了，要么这里能看到信道已经开始关闭
+	x.stateLock.Lock()
+	if state := x.State(); state == StateDraining || state == StateClosed {
+		x.stateLock.Unlock()
+		return ErrChannelClosed
+	}
+	x.upstreamWg.Add(1)
+	x.stateLock.Unlock()
+	defer x.upstreamWg.Done()
+
+	last := x.LastOffset()
+	feed := &replayFeed[Message]{
+		messages: make(chan envelope[Message]),
+	}
+	x.replay.Store(feed)
+	defer func() {
+		x.replay.Store(nil)
+		close(feed.messages)
+	}()
+
+	// 唤醒所有阻塞在缓存上取消息的协程，让它们转过来处理重新投递的消息
+	wakeups := x.Workers()
+	if wakeups < 1 {
+		wakeups = 1
+	}
+	for i := 0; i < wakeups; i++ {
+		if err := x.buffer.putUrgent(ctx, envelope[Message]{wakeup: true}); err != nil {
+			return err
+		}
+	}
+
+	var sendErr error
+	err := store.Read(ctx, offset, func(offset uint64, message Message) bool {
+		if offset > last {
+			return false
+		}
+		select {
+		case feed.messages <- envelope[Message]{message: message, offset: offset, replay: true}:
+			return true
+		case <-ctx.Done():
+			sendErr = ctx.Err()
+			return false
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// 取出下一条要处理的消息，正在重新投递历史消息时先处理历史消息
+func (x *Channel[Message]) takeNext(ctx context.Context) (envelope[Message], bool, error) {
+	for {
+		if feed := x.replay.Load(); feed != nil {
+			select {
+			case envelope, ok := <-feed.messages:
+				if ok {
+					return envelope, true, nil
+				}
+			case <-ctx.Done():
+				var zero envelope[Message]
+				return zero, false, ctx.Err()
+			}
+			continue
+		}
+		envelope, ok, err := x.takeFromBuffer(ctx)
+		if err == nil && ok && envelope.wakeup {
+			continue
+		}
+		if err == nil && ok && envelope.slot != nil {
+			var committed bool
+			if envelope, committed = envelope.slot.wait(x.consumeCtx); !committed {
+				continue
+			}
+		}
+		if ok {
+			x.dequeued(envelope)
+		}
+		return envelope, ok, err
+	}
+}
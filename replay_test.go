@@ -0,0 +1,90 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_ReplayFrom(t *testing.T) {
+	lock := &sync.Mutex{}
+	received := make([]string, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithStore(NewMemoryStore[int]()).WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if IsReplay(ctx) {
+			received = append(received, fmt.Sprintf("replay-%d", message))
+		} else {
+			received = append(received, fmt.Sprintf("%d", message))
+		}
+		return nil
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	for channel.ProcessedOffset() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 处理消息的协程阻塞在取消息上，重新投递时会被唤醒
+	assert.Nil(t, channel.ReplayFrom(context.Background(), 2))
+	assert.Nil(t, channel.Send(context.Background(), 4))
+	channel.SenderWaitAndClose()
+
+	assert.Equal(t, []string{"1", "2", "3", "replay-2", "replay-3", "4"}, received)
+	assert.Equal(t, uint64(4), channel.LastOffset())
+	assert.Equal(t, uint64(6), channel.Stats().Consumed)
+}
+
+func TestChannel_ReplayFromBeforeLiveTraffic(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode().WithStore(NewMemoryStore[int]()))
+	for i := 1; i <= 2; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	received := []int{message}
+
+	// 缓存中还有没有处理的消息，重新投递的历史消息排在它们前面
+	replayed := make(chan error)
+	go func() {
+		replayed <- channel.ReplayFrom(context.Background(), 1)
+	}()
+	for channel.replay.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		message, err := channel.Receive(context.Background())
+		assert.Nil(t, err)
+		received = append(received, message)
+	}
+	assert.Nil(t, <-replayed)
+	assert.Equal(t, []int{1, 1, 2, 2}, received)
+	assert.Equal(t, 0, channel.Abort())
+}
+
+func TestChannel_ReplayFromWithoutStore(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]())
+	assert.ErrorIs(t, channel.ReplayFrom(context.Background(), 1), ErrNoStore)
+	channel.SenderWaitAndClose()
+}
+
+func TestChannel_ReplayFromConcurrentClose(t *testing.T) {
+	// 和关闭同时进行时要么在关闭之前重新投递完，要么返回ErrChannelClosed，不会在关闭之后还往缓存中发送唤醒信号
+	for i := 0; i < 50; i++ {
+		channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithStore(NewMemoryStore[int]()).WithChannelConsumerFunc(func(index int, message int) {
+		}))
+		assert.Nil(t, channel.Send(context.Background(), 1))
+		replayed := make(chan error, 1)
+		go func() {
+			replayed <- channel.ReplayFrom(context.Background(), 1)
+		}()
+		channel.SenderWaitAndClose()
+		if err := <-replayed; err != nil {
+			assert.ErrorIs(t, err, ErrChannelClosed)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrReservationReleased 预留的位置已经被提交或者取消了，或者在提交之前被OverflowDropOldest挤掉、被关闭时丢弃了
+var ErrReservationReleased = errors.New("message channel: reservation released")
+
+// ErrReservationUnsupported 配置了Ordering的信道按照消息排序，还不知道消息是什么的时候没法预留位置
+var ErrReservationUnsupported = errors.New("message channel: reservation is not supported with ordering")
+
+// Reservation 通过Reserve在信道的缓存中预留的一个位置，之后用Commit放入消息或者用Cancel放弃，两者只有第一次调用生效
+// 预留的位置在缓存中占着它被预留时的顺序，处理消息的一方取到一个还没有提交的位置时会等待它被提交或者取消，所以预留之后应该尽快提交或者取消
+type Reservation[Message any] struct {
+	channel *Channel[Message]
+
+	// 缓存中的位置，同步模式下没有缓存，为nil
+	slot *reservedSlot[Message]
+
+	// 同步模式下是否已经提交或者取消了
+	lock     *sync.Mutex
+	released bool
+}
+
+// 预留的位置的状态
+type slotState int
+
+const (
+	slotPending slotState = iota
+	slotCommitted
+	slotReleased
+)
+
+// reservedSlot 缓存中一个预留的位置，作为信封的一部分放入缓存中
+type reservedSlot[Message any] struct {
+	owner *Channel[Message]
+
+	lock  *sync.Mutex
+	state slotState
+
+	// 提交的消息，state为slotCommitted时才有
+	envelope envelope[Message]
+
+	// 被提交或者释放时关闭
+	done chan struct{}
+}
+
+// Reserve 在缓存中预留一个位置，缓存满了时阻塞等待，不受OverflowPolicy影响，这样在构造代价很大的消息之前就能确定它一定放得进去
+// 预留成功之后Commit一定不会阻塞也不会失败（除非位置被OverflowDropOldest挤掉或者信道被强制关闭），多个信道上的预留可以用来避免只发出去一部分
+// 信道已经关闭或者正在关闭并且配置了RejectSendWhileDraining时返回对应的错误，配置了Ordering时返回ErrReservationUnsupported
+func (x *Channel[Message]) Reserve(ctx context.Context) (*Reservation[Message], error) {
+	if err := x.checkSendable(); err != nil {
+		return nil, err
+	}
+	reservation := &Reservation[Message]{
+		channel: x,
+		lock:    &sync.Mutex{},
+	}
+	if x.synchronous != nil {
+		return reservation, nil
+	}
+	if x.options.Ordering != nil {
+		return nil, ErrReservationUnsupported
+	}
+	slot := &reservedSlot[Message]{
+		owner: x,
+		lock:  &sync.Mutex{},
+		done:  make(chan struct{}),
+	}
+	if err := x.buffer.put(ctx, envelope[Message]{slot: slot}); err != nil {
+		return nil, err
+	}
+	reservation.slot = slot
+	return reservation, nil
+}
+
+// Commit 把消息放入预留的位置，已经提交、取消或者位置已经被释放时返回ErrReservationReleased
+func (x *Reservation[Message]) Commit(message Message) error {
+	if x.slot == nil {
+		x.lock.Lock()
+		if x.released {
+			x.lock.Unlock()
+			return ErrReservationReleased
+		}
+		x.released = true
+		x.lock.Unlock()
+		return x.channel.sendEnvelope(context.Background(), envelope[Message]{message: message})
+	}
+	if !x.slot.commit(message) {
+		return ErrReservationReleased
+	}
+	return nil
+}
+
+// Cancel 放弃预留的位置，处理消息的一方会跳过它，已经提交了时什么都不做
+func (x *Reservation[Message]) Cancel() {
+	if x.slot == nil {
+		x.lock.Lock()
+		x.released = true
+		x.lock.Unlock()
+		return
+	}
+	x.slot.release()
+}
+
+// 提交消息，提交之前先按照普通的发送登记这条消息，这样处理消息的一方拿到它的时候它已经被保存和统计过了
+func (x *reservedSlot[Message]) commit(message Message) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.state != slotPending {
+		return false
+	}
+	x.envelope = x.owner.stamp(envelope[Message]{message: message})
+	if err := x.owner.persist(x.envelope); err != nil {
+		x.owner.reportError(ErrorOpStore, err)
+	}
+	x.owner.enqueued(x.envelope)
+	x.state = slotCommitted
+	close(x.done)
+	return true
+}
+
+// 释放预留的位置，已经提交了时返回false
+func (x *reservedSlot[Message]) release() bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.state != slotPending {
+		return x.state == slotReleased
+	}
+	x.state = slotReleased
+	close(x.done)
+	return true
+}
+
+// 已经提交的消息，还没有提交或者已经被释放时返回false
+func (x *reservedSlot[Message]) committed() (envelope[Message], bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.envelope, x.state == slotCommitted
+}
+
+// 处理消息的一方取到了预留的位置，等待它被提交，被取消或者ctx结束时返回false，ctx结束时位置被释放
+func (x *reservedSlot[Message]) wait(ctx context.Context) (envelope[Message], bool) {
+	select {
+	case <-x.done:
+	case <-ctx.Done():
+		x.release()
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.state != slotCommitted {
+		return envelope[Message]{}, false
+	}
+
+	// 提交的时候登记过放入的时间，这时候才真正从缓存中取走
+	x.owner.untrackPending(x.envelope)
+	return x.envelope, true
+}
@@ -0,0 +1,82 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_Reserve(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(2))
+	reservation, err := channel.Reserve(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, channel.Send(context.Background(), 2))
+
+	// 缓存已经满了，预留的位置也算在里面
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = channel.Reserve(ctx)
+	assert.NotNil(t, err)
+
+	// 取到还没有提交的位置时等待提交，预留的消息排在预留时的位置上
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(t, reservation.Commit(1))
+	}()
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, message)
+	assert.ErrorIs(t, reservation.Commit(3), ErrReservationReleased)
+	reservation.Cancel()
+	message, err = channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, message)
+
+	// 取消的位置被跳过
+	reservation, err = channel.Reserve(context.Background())
+	assert.Nil(t, err)
+	reservation.Cancel()
+	assert.ErrorIs(t, reservation.Commit(3), ErrReservationReleased)
+	assert.Nil(t, channel.Send(context.Background(), 4))
+
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{4}, drain[int](channel))
+	assert.Equal(t, uint64(3), channel.Stats().Sent)
+}
+
+func TestChannel_Reserve_DropOldest(t *testing.T) {
+	dropped := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1).WithOverflowPolicy(OverflowDropOldest).WithOnDropped(func(reason DropReason, message int) {
+		dropped = append(dropped, message)
+	}))
+
+	// 还没有提交的位置被挤掉之后提交失败
+	reservation, err := channel.Reserve(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.ErrorIs(t, reservation.Commit(2), ErrReservationReleased)
+	assert.Empty(t, dropped)
+	go channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1}, drain[int](channel))
+}
+
+func TestChannel_Reserve_Unsupported(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(1).WithOrdering(func(a, b int) bool { return a < b }))
+	_, err := channel.Reserve(context.Background())
+	assert.ErrorIs(t, err, ErrReservationUnsupported)
+	go channel.SenderWaitAndClose()
+	assert.Empty(t, drain[int](channel))
+
+	// 同步模式下没有缓存，提交时直接处理
+	consumed := make([]int, 0)
+	synchronous := NewChannel[int](NewChannelOptions[int]().WithSynchronousMode().WithChannelConsumerFunc(func(index int, message int) {
+		consumed = append(consumed, message)
+	}))
+	reservation, err := synchronous.Reserve(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, reservation.Commit(1))
+	assert.Equal(t, []int{1}, consumed)
+	assert.ErrorIs(t, reservation.Commit(1), ErrReservationReleased)
+	synchronous.SenderWaitAndClose()
+}
@@ -0,0 +1,106 @@
+package message_channel
+
+import (
+	"reflect"
+	"sync"
+)
+
+// DropReasonUnroutable Router中没有能处理这种类型的消息的处理函数，也没有配置OnUnhandled
+const DropReasonUnroutable DropReason = "unroutable"
+
+// Router 按照消息的具体类型分发消息的信道，各种类型的事件混在一个流里的时候，不需要在一个消费函数里写一个巨大的类型switch
+// 通过On给每一种类型注册处理函数，消息先按照具体类型精确匹配，匹配不上时再按照注册的顺序找第一个实现了的接口类型
+// Router本身就是一个Channel[any]，发送、关闭、统计都和普通的信道一样
+type Router struct {
+	*Channel[any]
+
+	lock *sync.RWMutex
+
+	// 按照具体类型注册的处理函数
+	handlers map[reflect.Type]func(message any)
+
+	// 按照接口类型注册的处理函数，按照注册的顺序匹配
+	interfaces []routerInterfaceHandler
+
+	// 没有匹配的处理函数时的回调
+	unhandled func(message any)
+}
+
+type routerInterfaceHandler struct {
+	typ     reflect.Type
+	handler func(message any)
+}
+
+// NewRouter 创建一个Router，消费函数由Router设置，options中配置的消费函数会被覆盖，options为nil时使用默认的选项
+func NewRouter(options *ChannelOptions[any]) *Router {
+	if options == nil {
+		options = NewChannelOptions[any]()
+	}
+	x := &Router{
+		lock:     &sync.RWMutex{},
+		handlers: make(map[reflect.Type]func(message any)),
+	}
+	options.ContextConsumerFunc = nil
+	options.DecisionConsumerFunc = nil
+	options.AsyncConsumerFunc = nil
+	x.Channel = NewChannel[any](options.WithChannelConsumerFunc(func(index int, message any) {
+		x.dispatch(message)
+	}))
+	return x
+}
+
+// On 注册处理类型为T的消息的处理函数，T是接口类型时处理所有实现了这个接口并且没有更精确的处理函数的消息，同一个类型重复注册时后注册的生效
+func On[T any](router *Router, handler func(message T)) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	wrapped := func(message any) {
+		handler(message.(T))
+	}
+	router.lock.Lock()
+	defer router.lock.Unlock()
+	if typ.Kind() != reflect.Interface {
+		router.handlers[typ] = wrapped
+		return
+	}
+	for i, registered := range router.interfaces {
+		if registered.typ == typ {
+			router.interfaces[i].handler = wrapped
+			return
+		}
+	}
+	router.interfaces = append(router.interfaces, routerInterfaceHandler{typ: typ, handler: wrapped})
+}
+
+// OnUnhandled 设置没有匹配的处理函数时的回调，没有设置时这些消息按照DropReasonUnroutable丢弃
+func (x *Router) OnUnhandled(handler func(message any)) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.unhandled = handler
+}
+
+// 把消息交给匹配的处理函数
+func (x *Router) dispatch(message any) {
+	handler := x.route(message)
+	if handler == nil {
+		x.drop(DropReasonUnroutable, message)
+		return
+	}
+	handler(message)
+}
+
+// 找到处理这条消息的处理函数，没有时返回nil
+func (x *Router) route(message any) func(message any) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	if message != nil {
+		typ := reflect.TypeOf(message)
+		if handler, ok := x.handlers[typ]; ok {
+			return handler
+		}
+		for _, registered := range x.interfaces {
+			if typ.Implements(registered.typ) {
+				return registered.handler
+			}
+		}
+	}
+	return x.unhandled
+}
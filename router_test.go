@@ -0,0 +1,65 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type orderCreated struct {
+	ID int
+}
+
+type orderCancelled struct {
+	ID int
+}
+
+type temperature float64
+
+func (x temperature) String() string {
+	return fmt.Sprintf("%.1f°C", float64(x))
+}
+
+func TestRouter(t *testing.T) {
+	handled := make([]string, 0)
+	dropped := make([]any, 0)
+	router := NewRouter(NewChannelOptions[any]().WithChannelBuffSize(10).WithOnDropped(func(reason DropReason, message any) {
+		assert.Equal(t, DropReasonUnroutable, reason)
+		dropped = append(dropped, message)
+	}))
+	On[orderCreated](router, func(message orderCreated) {
+		handled = append(handled, fmt.Sprintf("created %d", message.ID))
+	})
+	On[*orderCancelled](router, func(message *orderCancelled) {
+		handled = append(handled, fmt.Sprintf("cancelled %d", message.ID))
+	})
+
+	// 没有精确匹配的类型时按照接口匹配
+	On[fmt.Stringer](router, func(message fmt.Stringer) {
+		handled = append(handled, "stringer "+message.String())
+	})
+
+	for _, message := range []any{orderCreated{ID: 1}, &orderCancelled{ID: 2}, temperature(21.5), orderCancelled{ID: 3}, nil} {
+		assert.Nil(t, router.Send(context.Background(), message))
+	}
+	router.SenderWaitAndClose()
+
+	assert.Equal(t, []string{"created 1", "cancelled 2", "stringer 21.5°C"}, handled)
+	assert.Equal(t, []any{orderCancelled{ID: 3}, nil}, dropped)
+}
+
+func TestRouter_OnUnhandled(t *testing.T) {
+	unhandled := make([]any, 0)
+	router := NewRouter(nil)
+	On[int](router, func(message int) {
+	})
+	router.OnUnhandled(func(message any) {
+		unhandled = append(unhandled, message)
+	})
+	assert.Nil(t, router.Send(context.Background(), 1))
+	assert.Nil(t, router.Send(context.Background(), "text"))
+	router.SenderWaitAndClose()
+	assert.Equal(t, []any{"text"}, unhandled)
+	assert.Equal(t, uint64(0), router.Stats().Dropped)
+}
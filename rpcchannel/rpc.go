@@ -0,0 +1,211 @@
+// Package rpcchannel 在一对请求信道和回复信道上实现的RPC，请求和回复通过关联ID对应起来，多个并发的调用共用同一对信道
+package rpcchannel
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-infrastructure/go-message-channel"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Request 请求信道中传递的请求，只有可以被编码的字段，所以也可以通过桥接在进程之间传递
+type Request[Req any] struct {
+
+	// 关联ID，回复时原样带回
+	ID uint64 `json:"id"`
+
+	// 调用方的截止时间，零值表示没有截止时间，服务端处理时用它作为ctx的截止时间
+	Deadline time.Time `json:"deadline,omitempty"`
+
+	Payload Req `json:"payload"`
+}
+
+// Response 回复信道中传递的回复
+type Response[Resp any] struct {
+
+	// 对应的请求的关联ID
+	ID uint64 `json:"id"`
+
+	Payload Resp `json:"payload,omitempty"`
+
+	// 处理失败时的错误信息，为空表示成功
+	Error string `json:"error,omitempty"`
+}
+
+// RemoteError 服务端的处理函数返回的错误，错误只能以文本的形式传回来
+type RemoteError struct {
+	Message string
+}
+
+func (x *RemoteError) Error() string {
+	return "rpcchannel: remote error: " + x.Message
+}
+
+// Handler 服务端处理一个请求，ctx带有调用方的截止时间
+type Handler[Req, Resp any] func(ctx context.Context, request Req) (Resp, error)
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// Client RPC的调用方，往请求信道发送请求，从回复信道中按照关联ID取回复，可以被并发使用
+// 回复信道必须是拉模式的并且只能由一个Client读取，没有对应的调用在等待的回复（比如调用方已经超时了）会被直接丢弃
+type Client[Req, Resp any] struct {
+	requests  *message_channel.Channel[Request[Req]]
+	responses *message_channel.Channel[Response[Resp]]
+
+	ids *atomic.Uint64
+
+	lock *sync.Mutex
+
+	// 还在等待回复的调用
+	pending map[uint64]chan Response[Resp]
+
+	// 回复信道关闭之后所有的调用都会以这个错误失败
+	err error
+}
+
+// NewClient 创建一个Client并开始读取回复信道，回复信道不是拉模式的时候所有的调用都会以message_channel.ErrNotPullMode失败
+func NewClient[Req, Resp any](requests *message_channel.Channel[Request[Req]], responses *message_channel.Channel[Response[Resp]]) *Client[Req, Resp] {
+	x := &Client[Req, Resp]{
+		requests:  requests,
+		responses: responses,
+		ids:       &atomic.Uint64{},
+		lock:      &sync.Mutex{},
+		pending:   make(map[uint64]chan Response[Resp]),
+	}
+	go x.receive()
+	return x
+}
+
+// Call 发送一个请求并等待回复，ctx的截止时间会随着请求一起传给服务端，请求在请求信道中等到了截止时间还没有被处理的话会被丢弃
+// ctx结束时返回ctx的错误，服务端处理失败时返回*RemoteError，回复信道已经关闭时返回message_channel.ErrChannelClosed，这时请求可能已经被处理了
+func (x *Client[Req, Resp]) Call(ctx context.Context, request Req) (Resp, error) {
+	var zero Resp
+	id := x.ids.Add(1)
+	reply := make(chan Response[Resp], 1)
+	x.lock.Lock()
+	if x.err != nil {
+		x.lock.Unlock()
+		return zero, x.err
+	}
+	x.pending[id] = reply
+	x.lock.Unlock()
+	defer func() {
+		x.lock.Lock()
+		delete(x.pending, id)
+		x.lock.Unlock()
+	}()
+
+	var err error
+	deadline, ok := ctx.Deadline()
+	if ok {
+		err = x.requests.SendWithDeadline(ctx, Request[Req]{ID: id, Deadline: deadline, Payload: request}, deadline)
+	} else {
+		err = x.requests.Send(ctx, Request[Req]{ID: id, Payload: request})
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return zero, err
+	}
+
+	select {
+	case response, ok := <-reply:
+		if !ok {
+			x.lock.Lock()
+			defer x.lock.Unlock()
+			return zero, x.err
+		}
+		if response.Error != "" {
+			return zero, &RemoteError{Message: response.Error}
+		}
+		return response.Payload, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// 把回复交给等待的调用，回复信道关闭之后让所有等待中的调用失败
+func (x *Client[Req, Resp]) receive() {
+	var err error
+	for {
+		var response Response[Resp]
+		response, err = x.responses.Receive(context.Background())
+		if err != nil {
+			break
+		}
+		x.lock.Lock()
+		reply, ok := x.pending[response.ID]
+		delete(x.pending, response.ID)
+		x.lock.Unlock()
+		if ok {
+			reply <- response
+		}
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.err = err
+	for id, reply := range x.pending {
+		close(reply)
+		delete(x.pending, id)
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// Serve 用concurrency个协程从拉模式的请求信道中取请求交给handler处理，把结果发送到回复信道，concurrency小于1时按照1处理
+// 处理的ctx带有调用方的截止时间，截止时间按照请求信道的Clock判断，已经过了截止时间的请求不再处理
+// 请求信道关闭之后返回nil，ctx结束时返回ctx的错误，请求信道不是拉模式的时候返回message_channel.ErrNotPullMode
+func Serve[Req, Resp any](ctx context.Context, requests *message_channel.Channel[Request[Req]], responses *message_channel.Channel[Response[Resp]], handler Handler[Req, Resp], concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg := &sync.WaitGroup{}
+
+	// 除了请求信道正常关闭和ctx结束之外的错误，比如请求信道不是拉模式的，需要返回给调用方
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				request, err := requests.Receive(ctx)
+				if err != nil {
+					if ctx.Err() == nil && !errors.Is(err, message_channel.ErrChannelClosed) {
+						errs <- err
+					}
+					return
+				}
+				serveOne(ctx, request, requests.Clock(), responses, handler)
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+func serveOne[Req, Resp any](ctx context.Context, request Request[Req], clock message_channel.Clock, responses *message_channel.Channel[Response[Resp]], handler Handler[Req, Resp]) {
+	if !request.Deadline.IsZero() {
+		if !clock.Now().Before(request.Deadline) {
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = message_channel.WithClockDeadline(ctx, clock, request.Deadline)
+		defer cancel()
+	}
+	response := Response[Resp]{ID: request.ID}
+	payload, err := handler(ctx, request.Payload)
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Payload = payload
+	}
+	_ = responses.Send(ctx, response)
+}
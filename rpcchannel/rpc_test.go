@@ -0,0 +1,119 @@
+package rpcchannel
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-infrastructure/go-message-channel"
+	"github.com/golang-infrastructure/go-message-channel/channeltest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newPair() (*message_channel.Channel[Request[int]], *message_channel.Channel[Response[string]]) {
+	requests := message_channel.NewChannel[Request[int]](message_channel.NewChannelOptions[Request[int]]().WithPullMode().WithChannelBuffSize(16))
+	responses := message_channel.NewChannel[Response[string]](message_channel.NewChannelOptions[Response[string]]().WithPullMode().WithChannelBuffSize(16))
+	return requests, responses
+}
+
+func TestCall(t *testing.T) {
+	requests, responses := newPair()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve[int, string](context.Background(), requests, responses, func(ctx context.Context, request int) (string, error) {
+			if request < 0 {
+				return "", errors.New("negative")
+			}
+
+			// 后到的请求先处理完，回复按照关联ID交给对应的调用
+			time.Sleep(time.Duration(10-request) * time.Millisecond)
+			return string(rune('a' + request)), nil
+		}, 4)
+	}()
+	client := NewClient[int, string](requests, responses)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(request int) {
+			defer wg.Done()
+			response, err := client.Call(context.Background(), request)
+			assert.Nil(t, err)
+			assert.Equal(t, string(rune('a'+request)), response)
+		}(i)
+	}
+	wg.Wait()
+
+	_, err := client.Call(context.Background(), -1)
+	var remote *RemoteError
+	assert.ErrorAs(t, err, &remote)
+	assert.Equal(t, "negative", remote.Message)
+
+	requests.SenderWaitAndClose()
+	assert.Nil(t, <-served)
+	responses.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), 1)
+		return errors.Is(err, message_channel.ErrChannelClosed)
+	}, time.Second, time.Millisecond)
+}
+
+func TestCall_Deadline(t *testing.T) {
+	requests, responses := newPair()
+	deadlines := make(chan bool, 1)
+	go func() {
+		_ = Serve[int, string](context.Background(), requests, responses, func(ctx context.Context, request int) (string, error) {
+			_, ok := ctx.Deadline()
+			deadlines <- ok
+			<-ctx.Done()
+
+			// 晚一点再回复，调用方这时已经超时返回了
+			time.Sleep(10 * time.Millisecond)
+			return "", ctx.Err()
+		}, 1)
+	}()
+	client := NewClient[int, string](requests, responses)
+
+	// 截止时间传到了服务端，服务端超时之后的回复被丢弃
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.Call(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, <-deadlines)
+
+	requests.SenderWaitAndClose()
+	responses.SenderWaitAndClose()
+}
+
+func TestServe_NotPullMode(t *testing.T) {
+	requests := message_channel.NewChannel[Request[int]](message_channel.NewChannelOptions[Request[int]]())
+	responses := message_channel.NewChannel[Response[string]](message_channel.NewChannelOptions[Response[string]]().WithPullMode().WithChannelBuffSize(16))
+	err := Serve[int, string](context.Background(), requests, responses, func(ctx context.Context, request int) (string, error) {
+		return "", nil
+	}, 2)
+	assert.ErrorIs(t, err, message_channel.ErrNotPullMode)
+	requests.SenderWaitAndClose()
+	go responses.SenderWaitAndClose()
+	_, err = responses.Receive(context.Background())
+	assert.ErrorIs(t, err, message_channel.ErrChannelClosed)
+}
+
+func TestServe_DeadlineUsesChannelClock(t *testing.T) {
+	// 按照请求信道的时钟请求已经过了截止时间，虽然按照系统时间还没有
+	now := time.Now()
+	clock := channeltest.NewFakeClock(now.Add(time.Hour))
+	requests := message_channel.NewChannel[Request[int]](message_channel.NewChannelOptions[Request[int]]().WithPullMode().WithChannelBuffSize(16).WithClock(clock))
+	responses := message_channel.NewChannel[Response[string]](message_channel.NewChannelOptions[Response[string]]().WithPullMode().WithChannelBuffSize(16))
+	handled := 0
+	assert.Nil(t, requests.Send(context.Background(), Request[int]{ID: 1, Deadline: now.Add(time.Minute), Payload: 1}))
+	go requests.SenderWaitAndClose()
+	assert.Nil(t, Serve[int, string](context.Background(), requests, responses, func(ctx context.Context, request int) (string, error) {
+		handled++
+		return "", nil
+	}, 1))
+	assert.Equal(t, 0, handled)
+	go responses.SenderWaitAndClose()
+	_, err := responses.Receive(context.Background())
+	assert.ErrorIs(t, err, message_channel.ErrChannelClosed)
+}
@@ -0,0 +1,69 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuorumNotReached ScatterGather在超时之前没有收集到足够多的回复
+var ErrQuorumNotReached = errors.New("message channel: scatter gather quorum not reached")
+
+// GatherResult ScatterGather中一个信道的结果
+type GatherResult[Reply any] struct {
+
+	// 这个信道在传入的channels中的下标
+	Index int
+
+	// 这个信道的回复，Err不为nil时是零值
+	Reply Reply
+
+	// 这个信道没有成功回复的原因，凑够quorum之后还没有回复的信道是context.Canceled，超时的信道是context.DeadlineExceeded
+	Err error
+}
+
+// ScatterGather 把message作为请求同时发送到channels中的每一个信道，收集到quorum个成功的回复或者超时之后返回，适合扇出查询的场景
+// 每个信道的消费函数和Ask一样用Reply回复，返回的结果和channels一一对应，没有回复的信道在Err中说明原因
+// quorum小于等于0或者大于信道的数量时需要所有的信道都回复，timeout为0时只受ctx控制，成功的回复不够quorum时同时返回部分结果和ErrQuorumNotReached
+// 剩下的信道都回复了也凑不够quorum时不再等待，因为回复的类型和消息的类型不同，所以这里是一个函数而不是方法
+func ScatterGather[Message, Reply any](ctx context.Context, message Message, channels []*Channel[Message], quorum int, timeout time.Duration) ([]GatherResult[Reply], error) {
+	if quorum <= 0 || quorum > len(channels) {
+		quorum = len(channels)
+	}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]GatherResult[Reply], len(channels))
+	gathered := make(chan GatherResult[Reply], len(channels))
+	for i, channel := range channels {
+		go func(index int, channel *Channel[Message]) {
+			reply, err := Ask[Message, Reply](ctx, channel, message).Get(context.Background())
+			gathered <- GatherResult[Reply]{Index: index, Reply: reply, Err: err}
+		}(i, channel)
+	}
+
+	// 凑够quorum或者不可能凑够之后取消剩下的请求，但还是等所有的请求都结束，这样每个信道的结果都是确定的
+	succeeded, failed := 0, 0
+	for range channels {
+		result := <-gathered
+		results[result.Index] = result
+		if result.Err == nil {
+			succeeded++
+		} else {
+			failed++
+		}
+		if succeeded >= quorum || len(channels)-failed < quorum {
+			cancel()
+		}
+	}
+	if succeeded < quorum {
+		return results, fmt.Errorf("%w: %d of %d replies, want %d", ErrQuorumNotReached, succeeded, len(channels), quorum)
+	}
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScatterGather(t *testing.T) {
+	replier := func(reply string, delay time.Duration) *Channel[int] {
+		return NewChannel[int](NewChannelOptions[int]().WithContextConsumerFunc(func(ctx context.Context, index int, message int) error {
+			time.Sleep(delay)
+			if reply == "" {
+				ReplyError(ctx, errors.New("unavailable"))
+				return nil
+			}
+			Reply(ctx, reply)
+			return nil
+		}))
+	}
+	channels := []*Channel[int]{
+		replier("a", 0),
+		replier("", 0),
+		replier("c", 10*time.Millisecond),
+		replier("d", time.Second),
+	}
+
+	// 凑够两个回复就返回，慢的信道被取消
+	results, err := ScatterGather[int, string](context.Background(), 1, channels, 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", results[0].Reply)
+	assert.EqualError(t, results[1].Err, "unavailable")
+	assert.Equal(t, "c", results[2].Reply)
+	assert.ErrorIs(t, results[3].Err, context.Canceled)
+	assert.Equal(t, 3, results[3].Index)
+
+	// 需要所有的信道都回复，但有一个信道失败了，不用等到超时
+	start := time.Now()
+	results, err = ScatterGather[int, string](context.Background(), 1, channels, 0, 5*time.Second)
+	assert.ErrorIs(t, err, ErrQuorumNotReached)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.EqualError(t, results[1].Err, "unavailable")
+
+	// 超时的时候返回部分结果
+	results, err = ScatterGather[int, string](context.Background(), 1, []*Channel[int]{channels[0], channels[3]}, 2, 50*time.Millisecond)
+	assert.ErrorIs(t, err, ErrQuorumNotReached)
+	assert.Equal(t, "a", results[0].Reply)
+	assert.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+
+	for _, channel := range channels {
+		channel.SenderWaitAndClose()
+	}
+}
@@ -0,0 +1,122 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Selectable 可以被SelectReceiveAny同时等待的信道，所有的*Channel都实现了它，这样消息类型不同的信道也可以放在一起等待
+type Selectable interface {
+
+	// 等到信道最前面有消息，不会取走它
+	waitHead(ctx context.Context) error
+
+	// 不阻塞的取走waitHead等到的那条消息，已经被别人取走了时返回false
+	takeHeadAny(ctx context.Context) (any, bool, error)
+
+	isPullMode() bool
+}
+
+// SelectReceive 同时等待多个拉模式信道，从最先有消息的那个信道中取走一条消息，返回信道在chans中的下标和消息
+// 没有被选中的信道中的消息不会被取走，已经关闭的信道会被忽略，所有的信道都关闭了时返回ErrChannelClosed，有不是拉模式的信道时返回ErrNotPullMode
+// 因为需要根据消息类型实例化，所以这里是一个函数而不是方法，消息类型不同的信道使用SelectReceiveAny
+func SelectReceive[Message any](ctx context.Context, chans ...*Channel[Message]) (int, Message, error) {
+	selectables := make([]Selectable, len(chans))
+	for i, channel := range chans {
+		selectables[i] = channel
+	}
+	index, message, err := SelectReceiveAny(ctx, selectables...)
+	if err != nil {
+		var zero Message
+		return index, zero, err
+	}
+	return index, message.(Message), nil
+}
+
+// SelectReceiveAny 和SelectReceive一样，只是信道的消息类型可以各不相同，取到的消息是any
+func SelectReceiveAny(ctx context.Context, chans ...Selectable) (int, any, error) {
+	for _, channel := range chans {
+		if !channel.isPullMode() {
+			return -1, nil, ErrNotPullMode
+		}
+	}
+	for {
+		index, err := selectHead(ctx, chans)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		// 等到之后到取走之前可能被其他的调用方抢走了，那就重新等
+		message, ok, err := chans[index].takeHeadAny(ctx)
+		if err != nil {
+			return -1, nil, err
+		}
+		if ok {
+			return index, message, nil
+		}
+	}
+}
+
+// 等到其中一个信道最前面有消息，返回它的下标，没有被选中的信道等到的消息留在各自的最前面
+func selectHead(ctx context.Context, chans []Selectable) (int, error) {
+	waitCtx, cancelFunc := context.WithCancel(ctx)
+	defer cancelFunc()
+
+	// 第一个case是ctx，之后每个信道一个case
+	cases := make([]reflect.SelectCase, 0, len(chans)+1)
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	for _, channel := range chans {
+		ready := make(chan error, 1)
+		go func(channel Selectable) {
+			ready <- channel.waitHead(waitCtx)
+		}(channel)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ready)})
+	}
+
+	for open := len(chans); open > 0; open-- {
+		chosen, value, _ := reflect.Select(cases)
+		if chosen == 0 {
+			return -1, ctx.Err()
+		}
+		if value.IsNil() {
+			return chosen - 1, nil
+		}
+		if err := value.Interface().(error); !errors.Is(err, ErrChannelClosed) {
+			return -1, err
+		}
+
+		// 这个信道已经关闭了，Chan为零值的case不会再被选中
+		cases[chosen].Chan = reflect.Value{}
+	}
+	return -1, ErrChannelClosed
+}
+
+func (x *Channel[Message]) waitHead(ctx context.Context) error {
+	_, _, err := x.receive(ctx, func(message Message) bool {
+		return false
+	})
+	return err
+}
+
+func (x *Channel[Message]) takeHeadAny(ctx context.Context) (any, bool, error) {
+	select {
+	case x.receiving <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	defer func() {
+		<-x.receiving
+	}()
+	envelope, ok := x.takeHead()
+	if !ok {
+		return nil, false, nil
+	}
+	x.markConsumed(envelope)
+	x.markProcessed(envelope.offset)
+	return envelope.message, true, nil
+}
+
+func (x *Channel[Message]) isPullMode() bool {
+	return x.options.PullMode
+}
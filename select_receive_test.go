@@ -0,0 +1,74 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSelectReceive(t *testing.T) {
+	a := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	b := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	assert.Nil(t, b.Send(context.Background(), 2))
+
+	index, message, err := SelectReceive(context.Background(), a, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 2, message)
+
+	// 等待期间到达的消息也能被选中，没有被选中的信道中的消息不会被取走
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		assert.Nil(t, a.Send(context.Background(), 1))
+	}()
+	index, message, err = SelectReceive(context.Background(), a, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 1, message)
+	assert.Nil(t, b.Send(context.Background(), 3))
+	assert.Nil(t, a.Send(context.Background(), 4))
+	received := make([]int, 0)
+	for i := 0; i < 2; i++ {
+		_, message, err = SelectReceive(context.Background(), a, b)
+		assert.Nil(t, err)
+		received = append(received, message)
+	}
+	assert.ElementsMatch(t, []int{3, 4}, received)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, _, err = SelectReceive(ctx, a, b)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 关闭的信道被忽略，都关闭了时返回ErrChannelClosed
+	go a.SenderWaitAndClose()
+	assert.Nil(t, b.Send(context.Background(), 5))
+	index, message, err = SelectReceive(context.Background(), a, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 5, message)
+	go b.SenderWaitAndClose()
+	_, _, err = SelectReceive(context.Background(), a, b)
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
+
+func TestSelectReceiveAny(t *testing.T) {
+	numbers := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10))
+	words := NewChannel[string](NewChannelOptions[string]().WithPullMode().WithChannelBuffSize(10))
+	assert.Nil(t, words.Send(context.Background(), "hello"))
+	index, message, err := SelectReceiveAny(context.Background(), numbers, words)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, "hello", message)
+
+	push := NewChannel[int](NewChannelOptions[int]())
+	_, _, err = SelectReceiveAny(context.Background(), numbers, push)
+	assert.ErrorIs(t, err, ErrNotPullMode)
+
+	push.SenderWaitAndClose()
+	go numbers.SenderWaitAndClose()
+	go words.SenderWaitAndClose()
+	_, _, err = SelectReceiveAny(context.Background(), numbers, words)
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
@@ -0,0 +1,158 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DropReasonShutdown 优雅关闭超时后强制关闭时缓存中剩余的消息被丢弃了
+const DropReasonShutdown DropReason = "shutdown"
+
+// ShutdownFallback 优雅关闭在ctx到期时还没有完成时的处理策略
+type ShutdownFallback int
+
+const (
+
+	// ShutdownForceClose 丢弃整个子树缓存中剩余的消息并立即返回，默认的策略
+	// 正在被消费函数处理的消息没办法被打断（ContextConsumerFunc的ctx会被取消），关闭的过程会在后台继续进行直到这些消息处理完
+	ShutdownForceClose ShutdownFallback = iota
+
+	// ShutdownKeepWaiting 继续等待直到所有的消息都处理完，返回的错误中会记录到期时剩余的情况
+	ShutdownKeepWaiting
+)
+
+// ShutdownError 优雅关闭没有在ctx到期之前完成时返回的错误
+type ShutdownError struct {
+
+	// ctx的错误
+	Err error
+
+	// ctx到期时整个子树的缓存中还没有被消费的消息的数量
+	Pending int
+
+	// ctx到期时子树中还没有关闭完成的信道的数量
+	Channels int
+
+	// 强制关闭时丢弃的消息的数量
+	Discarded int
+
+	// 是否在ctx到期之后继续等待并最终完成了关闭
+	Completed bool
+}
+
+func (x *ShutdownError) Error() string {
+	if x.Completed {
+		return fmt.Sprintf("message channel: shutdown completed after deadline, %d pending messages in %d channels at deadline: %v", x.Pending, x.Channels, x.Err)
+	}
+	return fmt.Sprintf("message channel: shutdown force closed, %d pending messages in %d channels, %d discarded: %v", x.Pending, x.Channels, x.Discarded, x.Err)
+}
+
+func (x *ShutdownError) Unwrap() error {
+	return x.Err
+}
+
+// Shutdown 优雅的关闭当前信道以及所有的子孙信道：先关闭所有子信道，等它们把消息都转发上来之后再关闭自己，等待剩余的消息处理完
+// 和SenderWaitAndClose不同的是关闭的整个过程都受ctx控制，ctx到期时按照ShutdownFallback处理，并通过ShutdownError报告剩余的情况
+// 整个子树使用当前信道的ShutdownFallback，正常关闭完成时返回nil
+func (x *Channel[Message]) Shutdown(ctx context.Context) error {
+	if err := x.shutdown(ctx, x.options.ShutdownFallback); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (x *Channel[Message]) shutdown(ctx context.Context, fallback ShutdownFallback) *ShutdownError {
+
+	x.beginDraining()
+
+	// 关闭的过程放到后台执行，这样ctx到期之后可以按照策略选择继续等待或者直接返回
+	finished := make(chan struct{})
+	x.spawn(goroutineRoleClose, func() {
+		defer close(finished)
+
+		// 子信道同时关闭，它们的消息都转发到当前信道上
+		children, _ := x.childrenChannelMap.ChildrenSlice(context.Background())
+		wg := &sync.WaitGroup{}
+		for _, child := range children {
+			wg.Add(1)
+			go func(child *Channel[Message]) {
+				defer wg.Done()
+				_ = child.shutdown(ctx, fallback)
+			}(child)
+		}
+		wg.Wait()
+
+		x.upstreamWg.Wait()
+		x.closeChannel()
+		<-x.done
+	})
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+
+	report := &ShutdownError{
+		Err: ctx.Err(),
+	}
+	x.eachInSubtree(func(channel *Channel[Message]) {
+		select {
+		case <-channel.done:
+		default:
+			report.Channels++
+			report.Pending += channel.buffer.len()
+		}
+	})
+
+	if fallback == ShutdownKeepWaiting {
+		<-finished
+		report.Completed = true
+		return report
+	}
+
+	x.eachInSubtree(func(channel *Channel[Message]) {
+		channel.cancelConsume()
+		report.Discarded += channel.discardPending(DropReasonShutdown)
+	})
+	return report
+}
+
+// 关闭底层的channel，表示不会再有新的消息了，可以被多次调用
+// 同步模式下没有处理消息的协程，等正在处理的消息处理完之后信道就结束了
+func (x *Channel[Message]) closeChannel() {
+	x.closeOnce.Do(func() {
+		x.buffer.close()
+		if x.synchronous != nil {
+			x.synchronous.Lock()
+			x.synchronous.Unlock()
+			x.finish()
+		}
+	})
+}
+
+// 丢弃缓存中所有还没有被消费的消息，返回丢弃的消息的数量
+func (x *Channel[Message]) discardPending(reason DropReason) int {
+	discarded := 0
+	for {
+		envelope, ok := x.takePending()
+		if !ok {
+			return discarded
+		}
+		x.drop(reason, envelope.message)
+		discarded++
+	}
+}
+
+// 在当前信道以及所有的子孙信道上执行f，父信道先于子信道，分发子信道也包括在内
+func (x *Channel[Message]) eachInSubtree(f func(channel *Channel[Message])) {
+	f(x)
+	children, _ := x.childrenChannelMap.ChildrenSlice(context.Background())
+	for _, child := range children {
+		child.eachInSubtree(f)
+	}
+	for _, target := range x.DistributionChildren() {
+		target.eachInSubtree(f)
+	}
+}
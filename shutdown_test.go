@@ -0,0 +1,84 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_Shutdown(t *testing.T) {
+	consumed := &atomic.Int64{}
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		consumed.Add(1)
+	}))
+	child := root.MakeChildChannel()
+	grandchild := child.MakeChildChannel()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, root.Send(context.Background(), i))
+		assert.Nil(t, child.Send(context.Background(), i))
+		assert.Nil(t, grandchild.Send(context.Background(), i))
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFunc()
+	assert.Nil(t, root.Shutdown(ctx))
+	assert.Equal(t, int64(9), consumed.Load())
+	size, err := root.childrenChannelMap.Size(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, size)
+}
+
+func TestChannel_ShutdownForceClose(t *testing.T) {
+	release := make(chan struct{})
+	dropped := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonShutdown, reason)
+		dropped.Add(1)
+	}))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	assert.Eventually(t, func() bool {
+		return channel.Stats().Consumed == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancelFunc()
+	err := channel.Shutdown(ctx)
+	shutdownError := &ShutdownError{}
+	assert.True(t, errors.As(err, &shutdownError))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, shutdownError.Pending)
+	assert.Equal(t, 2, shutdownError.Discarded)
+	assert.Equal(t, 1, shutdownError.Channels)
+	assert.Equal(t, int64(2), dropped.Load())
+
+	// 卡住的消息处理完之后关闭在后台完成
+	close(release)
+	channel.ReceiverWait(context.Background())
+}
+
+func TestChannel_ShutdownKeepWaiting(t *testing.T) {
+	consumed := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithShutdownFallback(ShutdownKeepWaiting).WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond * 10)
+		consumed.Add(1)
+	}))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*5)
+	defer cancelFunc()
+	err := channel.Shutdown(ctx)
+	shutdownError := &ShutdownError{}
+	assert.True(t, errors.As(err, &shutdownError))
+	assert.True(t, shutdownError.Completed)
+	assert.Equal(t, 0, shutdownError.Discarded)
+	assert.Equal(t, int64(5), consumed.Load())
+}
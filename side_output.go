@@ -0,0 +1,25 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoSideOutput 信道上没有注册这个名字的旁路输出，或者旁路输出的消息类型和这条消息的类型不一致
+var ErrNoSideOutput = errors.New("message channel: no such side output")
+
+type sideOutputsContextKey struct{}
+
+// EmitTo 在消费函数中把一条消息发送到名字为name的旁路输出，比如把迟到的、格式不对的或者需要特殊处理的消息分流出去，不影响这条消息本身的处理决定
+// ctx需要是ContextConsumerFunc或者DecisionConsumerFunc收到的ctx，旁路输出通过WithSideOutput注册，没有注册时返回ErrNoSideOutput
+// 旁路输出的缓存满了时会阻塞消费函数，信道被Abort之后返回context.Canceled
+// 因为ctx中存放的旁路输出是带着消息类型的，所以这里是一个函数而不是方法
+func EmitTo[Message any](ctx context.Context, name string, message Message) error {
+	outputs, _ := ctx.Value(sideOutputsContextKey{}).(map[string]Sender[Message])
+	output, ok := outputs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoSideOutput, name)
+	}
+	return output.Send(ctx, message)
+}
@@ -0,0 +1,42 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEmitTo(t *testing.T) {
+	late := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(8))
+	invalid := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(8))
+	processed := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithSideOutput("late", late).WithSideOutput("invalid", invalid).WithDecisionConsumerFunc(func(ctx context.Context, index int, message int) Decision {
+		switch {
+		case message < 0:
+			assert.Nil(t, EmitTo(ctx, "invalid", message))
+			return DeadLetter{}
+		case message > 100:
+			assert.Nil(t, EmitTo(ctx, "late", message))
+		default:
+			assert.ErrorIs(t, EmitTo(ctx, "unknown", message), ErrNoSideOutput)
+
+			// 消息类型和旁路输出的不一致
+			assert.ErrorIs(t, EmitTo(ctx, "late", "late"), ErrNoSideOutput)
+		}
+		processed = append(processed, message)
+		return Ack{}
+	}))
+	for _, message := range []int{1, -1, 101, 2, 102} {
+		assert.Nil(t, channel.Send(context.Background(), message))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{1, 101, 2, 102}, processed)
+
+	go late.SenderWaitAndClose()
+	assert.Equal(t, []int{101, 102}, drain[int](late))
+	go invalid.SenderWaitAndClose()
+	assert.Equal(t, []int{-1}, drain[int](invalid))
+
+	// 不是在消费函数中调用
+	assert.ErrorIs(t, EmitTo(context.Background(), "late", 1), ErrNoSideOutput)
+}
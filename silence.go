@@ -0,0 +1,41 @@
+package message_channel
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSilenceTimeout 超过SilenceTimeout都没有新的消息放入信道
+var ErrSilenceTimeout = errors.New("message channel: no message within silence timeout")
+
+// 检查有没有超过timeout都没有新的消息，一段沉默只报告一次，之后有新的消息了再重新开始计时，信道结束之后退出
+func (x *Channel[Message]) watchSilence(timeout time.Duration, onSilence func()) {
+	silent := false
+	for {
+		elapsed := x.clock.Since(time.Unix(0, x.stats.lastSendUnixNano.Load()))
+		switch {
+		case elapsed < timeout:
+			silent = false
+		case !silent:
+			silent = true
+			if onSilence != nil {
+				onSilence()
+			}
+			x.publishError(ErrorEvent{Kind: ErrorEventSilence, Err: fmt.Errorf("%w: silent for %s", ErrSilenceTimeout, elapsed)})
+		}
+
+		// 还没有沉默时等到这段时间到期，已经报告过了时每隔timeout看一下有没有新的消息
+		wait := timeout
+		if !silent {
+			wait = timeout - elapsed
+		}
+		timer := x.clock.NewTimer(wait)
+		select {
+		case <-x.done:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
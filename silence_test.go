@@ -0,0 +1,42 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_SilenceTimeout(t *testing.T) {
+	errors := NewChannel[ErrorEvent](NewChannelOptions[ErrorEvent]().WithPullMode().WithChannelBuffSize(10))
+	silences := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithErrorOutput(errors).WithChannelConsumerFunc(func(index int, message int) {
+	}).WithSilenceTimeout(time.Millisecond*30, func() {
+		silences.Add(1)
+	}))
+
+	// 一直有消息的时候不算沉默
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, int64(0), silences.Load())
+
+	// 沉默之后只报告一次，有新的消息之后重新计时
+	event, err := errors.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, ErrorEventSilence, event.Kind)
+	assert.ErrorIs(t, event.Err, ErrSilenceTimeout)
+	time.Sleep(time.Millisecond * 70)
+	assert.Equal(t, int64(1), silences.Load())
+
+	assert.Nil(t, channel.Send(context.Background(), 5))
+	assert.Eventually(t, func() bool {
+		return silences.Load() == 2
+	}, time.Second, time.Millisecond*5)
+
+	channel.SenderWaitAndClose()
+	go errors.SenderWaitAndClose()
+	drain[ErrorEvent](errors)
+}
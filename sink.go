@@ -0,0 +1,27 @@
+package message_channel
+
+// Sink 信道处理完的消息的附加输出，比如“顺便写一份到文件里”，配置到信道上之后每一条被消费函数成功处理的消息都会写入Sink
+// 信道处理完所有的消息关闭时会关闭Sink，Sink需要在Close中把缓冲着的数据都写出去，只会被处理消息的协程调用，开启了多个协程时需要是并发安全的
+// 拉模式和事务模式下没有消费函数，不会写入Sink
+type Sink[Message any] interface {
+	Write(message Message) error
+	Close() error
+}
+
+// 把处理完的消息写入所有的Sink，写入失败时通过ErrorListener报告，不影响消息的处理
+func (x *Channel[Message]) writeSinks(message Message) {
+	for _, sink := range x.options.Sinks {
+		if err := sink.Write(message); err != nil {
+			x.reportError(ErrorOpSink, err)
+		}
+	}
+}
+
+// 信道结束时关闭所有的Sink
+func (x *Channel[Message]) closeSinks() {
+	for _, sink := range x.options.Sinks {
+		if err := sink.Close(); err != nil {
+			x.reportError(ErrorOpSink, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package message_channel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sync"
+)
+
+// RowMapper 把一条消息映射为CSV中的一行，每个元素是一列，顺序需要和表头一致
+type RowMapper[Message any] func(message Message) []string
+
+// CSVSink 把消息按照RowMapper映射为CSV的行追加写入文件的Sink，适合把信道的输出直接导入表格或者BI工具，可以被并发使用
+// 包含逗号、引号、换行的字段会按照RFC 4180加上引号，文件是新创建的时候先写入表头，轮转出来的每个文件都有表头
+type CSVSink[Message any] struct {
+	lock   *sync.Mutex
+	file   *bufferedFile
+	mapper RowMapper[Message]
+
+	// 先把一行编码到row中，再作为一条完整的记录写入文件
+	row    *bytes.Buffer
+	writer *csv.Writer
+}
+
+var _ Sink[any] = (*CSVSink[any])(nil)
+
+// NewCSVSink 创建一个写入path的CSVSink，文件已经存在时追加在后面并且不再写表头，header为nil时不写表头，options为nil时使用默认的选项
+func NewCSVSink[Message any](path string, header []string, mapper RowMapper[Message], options *FileSinkOptions) (*CSVSink[Message], error) {
+	x := &CSVSink[Message]{
+		lock:   &sync.Mutex{},
+		mapper: mapper,
+		row:    &bytes.Buffer{},
+	}
+	x.writer = csv.NewWriter(x.row)
+	var encodedHeader []byte
+	if header != nil {
+		encoded, err := x.encode(header)
+		if err != nil {
+			return nil, err
+		}
+		encodedHeader = append([]byte(nil), encoded...)
+	}
+	file, err := openBufferedFile(path, options, encodedHeader)
+	if err != nil {
+		return nil, err
+	}
+	x.file = file
+	return x, nil
+}
+
+// Write 写入一条消息
+func (x *CSVSink[Message]) Write(message Message) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	data, err := x.encode(x.mapper(message))
+	if err != nil {
+		return err
+	}
+	return x.file.writeRecord(data)
+}
+
+// 把一行编码为CSV，返回的字节在下一次编码之前有效，调用方需要持有锁或者还没有并发访问
+func (x *CSVSink[Message]) encode(row []string) ([]byte, error) {
+	x.row.Reset()
+	if err := x.writer.Write(row); err != nil {
+		return nil, err
+	}
+	x.writer.Flush()
+	if err := x.writer.Error(); err != nil {
+		return nil, err
+	}
+	return x.row.Bytes(), nil
+}
+
+// Flush 把写缓冲中的数据立即写到文件中
+func (x *CSVSink[Message]) Flush() error {
+	return x.file.Flush()
+}
+
+// Close 把写缓冲中的数据写出去之后关闭文件，配置到信道上时信道关闭时会自动调用
+func (x *CSVSink[Message]) Close() error {
+	return x.file.Close()
+}
@@ -0,0 +1,277 @@
+package message_channel
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrSinkClosed 往已经关闭的Sink中写入
+var ErrSinkClosed = errors.New("message channel: sink closed")
+
+const (
+
+	// DefaultFileSinkBufferSize 没有配置BufferSize时文件Sink的写缓冲的大小
+	DefaultFileSinkBufferSize = 64 * 1024
+
+	// DefaultFileSinkFlushInterval 没有配置FlushInterval时文件Sink定期把写缓冲刷到文件中的间隔
+	DefaultFileSinkFlushInterval = time.Second
+)
+
+// FileSinkOptions 写文件的Sink共用的选项
+type FileSinkOptions struct {
+
+	// 写缓冲的大小，为0时使用DefaultFileSinkBufferSize
+	BufferSize int
+
+	// 定期把写缓冲刷到文件中的间隔，为0时使用DefaultFileSinkFlushInterval，小于0时只在写缓冲满了和关闭时才刷
+	FlushInterval time.Duration
+
+	// 文件轮转的选项，为nil时一直写同一个文件
+	Rotation *RotationOptions
+}
+
+// RotationOptions 文件轮转的选项，长时间运行的信道可以一直写下去，不需要再和外部的logrotate配合
+// 需要轮转时当前的文件被重命名为“原文件名.时间戳”，然后重新创建原文件继续写，轮转只发生在两条消息之间，一条消息不会被拆到两个文件中
+// 轮转是在写入消息时判断的，按照时间轮转时一直没有新消息的文件不会被轮转
+type RotationOptions struct {
+
+	// 文件超过这个大小之后轮转，为0时不按照大小轮转
+	MaxSize int64
+
+	// 文件创建之后超过这个时长就轮转，为0时不按照时间轮转
+	MaxAge time.Duration
+
+	// 是否用gzip压缩轮转出来的文件，压缩之后的文件名再加上.gz，压缩是在写入的协程中同步进行的
+	Compress bool
+}
+
+// 轮转出来的文件名中的时间戳的格式，精确到纳秒，这样连续的轮转也不会重名
+const rotatedFileTimeFormat = "20060102T150405.000000000"
+
+// bufferedFile 文件Sink的底层，带写缓冲的追加写入一个文件，在后台定期刷新，关闭时把缓冲中的数据都写出去，配置了轮转时按照大小和时间轮转
+type bufferedFile struct {
+	lock    *sync.Mutex
+	path    string
+	options *FileSinkOptions
+	file    *os.File
+	writer  *bufio.Writer
+	closed  bool
+
+	// 新创建文件时先写入的表头，为nil时不写
+	header []byte
+
+	// 当前文件的大小和创建的时间，用来判断是否需要轮转
+	size     int64
+	openedAt time.Time
+
+	// 关闭时停止后台的定期刷新
+	stop chan struct{}
+	done chan struct{}
+
+	// 后台定期刷新失败时记下来，下一次写入或者关闭时返回
+	err error
+}
+
+// 打开一个文件，文件已经存在时追加在后面，header不为nil时新创建的文件会先写入header（比如CSV的表头）
+func openBufferedFile(path string, options *FileSinkOptions, header []byte) (*bufferedFile, error) {
+	if options == nil {
+		options = &FileSinkOptions{}
+	}
+	size := options.BufferSize
+	if size <= 0 {
+		size = DefaultFileSinkBufferSize
+	}
+	x := &bufferedFile{
+		lock:    &sync.Mutex{},
+		path:    path,
+		options: options,
+		writer:  bufio.NewWriterSize(nil, size),
+		header:  header,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := x.open(); err != nil {
+		return nil, err
+	}
+
+	interval := options.FlushInterval
+	if interval == 0 {
+		interval = DefaultFileSinkFlushInterval
+	}
+	if interval > 0 {
+		spawnRole(goroutineRoleSinkFlush, func() {
+			x.flushPeriodically(interval)
+		})
+	} else {
+		close(x.done)
+	}
+	return x, nil
+}
+
+// 打开path并把写缓冲对接到它上面，新创建的文件先写入表头，调用方需要持有锁或者还没有并发访问
+func (x *bufferedFile) open() error {
+	file, err := os.OpenFile(x.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	x.file = file
+	x.writer.Reset(file)
+	x.size = info.Size()
+	x.openedAt = info.ModTime()
+	if x.size == 0 {
+		x.openedAt = time.Now()
+		if x.header != nil {
+			n, _ := x.writer.Write(x.header)
+			x.size += int64(n)
+		}
+	}
+	return nil
+}
+
+// 写入一条完整的记录，需要轮转时先轮转，这样一条记录不会被拆到两个文件中
+func (x *bufferedFile) writeRecord(p []byte) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return ErrSinkClosed
+	}
+	if err := x.takeErr(); err != nil {
+		return err
+	}
+	if x.rotationDue(int64(len(p))) {
+		if err := x.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := x.writer.Write(p)
+	x.size += int64(n)
+	return err
+}
+
+// 写入p之前判断是否需要轮转，空文件或者只有表头的文件不轮转，调用方需要持有锁
+func (x *bufferedFile) rotationDue(n int64) bool {
+	rotation := x.options.Rotation
+	if rotation == nil || x.size <= int64(len(x.header)) {
+		return false
+	}
+	if rotation.MaxSize > 0 && x.size+n > rotation.MaxSize {
+		return true
+	}
+	return rotation.MaxAge > 0 && time.Since(x.openedAt) >= rotation.MaxAge
+}
+
+// 把当前的文件重命名为带时间戳的文件，需要时压缩，然后重新创建原文件，调用方需要持有锁
+func (x *bufferedFile) rotate() error {
+	if err := x.writer.Flush(); err != nil {
+		return err
+	}
+	if err := x.file.Close(); err != nil {
+		return err
+	}
+	rotated := x.path + "." + time.Now().Format(rotatedFileTimeFormat)
+	if err := os.Rename(x.path, rotated); err != nil {
+		return err
+	}
+	if err := x.open(); err != nil {
+		return err
+	}
+	if x.options.Rotation.Compress {
+		return compressFile(rotated)
+	}
+	return nil
+}
+
+// 用gzip把文件压缩为path.gz，压缩成功之后删除原文件
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Flush 把写缓冲中的数据写到文件中
+func (x *bufferedFile) Flush() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return ErrSinkClosed
+	}
+	return x.writer.Flush()
+}
+
+// Close 停止定期刷新，把写缓冲中的数据写出去之后关闭文件，重复关闭时什么都不做
+func (x *bufferedFile) Close() error {
+	x.lock.Lock()
+	if x.closed {
+		x.lock.Unlock()
+		return nil
+	}
+	x.closed = true
+	close(x.stop)
+	x.lock.Unlock()
+	<-x.done
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	err := x.takeErr()
+	if flushErr := x.writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := x.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// 取出后台刷新时记下的错误，调用方需要持有锁
+func (x *bufferedFile) takeErr() error {
+	err := x.err
+	x.err = nil
+	return err
+}
+
+func (x *bufferedFile) flushPeriodically(interval time.Duration) {
+	defer close(x.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.stop:
+			return
+		case <-ticker.C:
+		}
+		x.lock.Lock()
+		if err := x.writer.Flush(); err != nil && x.err == nil {
+			x.err = err
+		}
+		x.lock.Unlock()
+	}
+}
@@ -0,0 +1,39 @@
+package message_channel
+
+import "encoding/json"
+
+// JSONLSink 把消息编码为JSON之后每条一行追加写入文件的Sink，写入先进入写缓冲，定期以及关闭时刷到文件中，可以被并发使用
+// 通过FileSinkOptions.Rotation可以按照大小和时间轮转文件
+type JSONLSink[Message any] struct {
+	file *bufferedFile
+}
+
+var _ Sink[any] = (*JSONLSink[any])(nil)
+
+// NewJSONLSink 创建一个写入path的JSONLSink，文件已经存在时追加在后面，options为nil时使用默认的选项
+func NewJSONLSink[Message any](path string, options *FileSinkOptions) (*JSONLSink[Message], error) {
+	file, err := openBufferedFile(path, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink[Message]{file: file}, nil
+}
+
+// Write 写入一条消息
+func (x *JSONLSink[Message]) Write(message Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return x.file.writeRecord(append(data, '\n'))
+}
+
+// Flush 把写缓冲中的数据立即写到文件中
+func (x *JSONLSink[Message]) Flush() error {
+	return x.file.Flush()
+}
+
+// Close 把写缓冲中的数据写出去之后关闭文件，配置到信道上时信道关闭时会自动调用
+func (x *JSONLSink[Message]) Close() error {
+	return x.file.Close()
+}
@@ -0,0 +1,271 @@
+package message_channel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+
+	// DefaultObjectSinkMaxSize 没有配置MaxSize时一个对象最多攒多少字节就上传
+	DefaultObjectSinkMaxSize = 8 * 1024 * 1024
+
+	// DefaultObjectSinkMaxRetries 没有配置MaxRetries时上传失败之后最多重试的次数
+	DefaultObjectSinkMaxRetries = 3
+
+	// DefaultObjectSinkRetryBackoff 没有配置RetryBackoff时第一次重试之前等待的时长，之后每次翻倍
+	DefaultObjectSinkRetryBackoff = 100 * time.Millisecond
+)
+
+// ObjectUploader 把一个对象上传到对象存储，key是对象的名字，可以用S3、GCS、OSS之类的SDK实现，也可以使用HTTPObjectUploader
+type ObjectUploader interface {
+	Upload(ctx context.Context, key string, data []byte) error
+}
+
+// ObjectKeyFunc 生成对象的名字，at是这个对象中第一条消息写入的时间，sequence是这个Sink上传的第几个对象，从1开始
+type ObjectKeyFunc func(at time.Time, sequence uint64) string
+
+// ObjectSinkOptions 对象存储Sink的选项
+type ObjectSinkOptions struct {
+
+	// 攒够这么多字节就上传一个对象，为0时使用DefaultObjectSinkMaxSize
+	MaxSize int
+
+	// 对象中第一条消息写入之后超过这个时长即使没有攒够也会上传，为0时只按照大小上传
+	MaxWait time.Duration
+
+	// 生成对象的名字，为nil时使用“时间戳-序号.jsonl”
+	Key ObjectKeyFunc
+
+	// 上传失败之后最多重试的次数，为0时使用DefaultObjectSinkMaxRetries，小于0时不重试
+	MaxRetries int
+
+	// 第一次重试之前等待的时长，之后每次翻倍，为0时使用DefaultObjectSinkRetryBackoff
+	RetryBackoff time.Duration
+
+	// 一个对象上传成功之后的回调，可以为nil
+	OnUploaded func(key string, size int)
+}
+
+// ObjectSink 把消息按照Codec编码之后每条一行攒成对象，按照大小或者时间上传到对象存储，失败时按照指数退避重试，可以被并发使用
+// 配合S3兼容的存储就能把一个信道变成一个轻量的数据湖写入器，上传是在写入的协程中同步进行的，MaxWait到期时在后台上传
+// 重试次数用完的对象会被丢弃，Write或者Close返回上传失败的错误，配置到信道上时错误会通过ErrorListener报告
+type ObjectSink[Message any] struct {
+	uploader ObjectUploader
+	codec    Codec[Message]
+	options  ObjectSinkOptions
+
+	lock     *sync.Mutex
+	buffer   *bytes.Buffer
+	firstAt  time.Time
+	sequence uint64
+	closed   bool
+
+	// 后台按照MaxWait上传失败时记下来，下一次写入或者关闭时返回
+	err error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ Sink[any] = (*ObjectSink[any])(nil)
+
+// NewObjectSink 创建一个上传到uploader的ObjectSink，options为nil时使用默认的选项
+func NewObjectSink[Message any](uploader ObjectUploader, codec Codec[Message], options *ObjectSinkOptions) *ObjectSink[Message] {
+	x := &ObjectSink[Message]{
+		uploader: uploader,
+		codec:    codec,
+		lock:     &sync.Mutex{},
+		buffer:   &bytes.Buffer{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if options != nil {
+		x.options = *options
+	}
+	if x.options.MaxSize <= 0 {
+		x.options.MaxSize = DefaultObjectSinkMaxSize
+	}
+	if x.options.MaxRetries == 0 {
+		x.options.MaxRetries = DefaultObjectSinkMaxRetries
+	}
+	if x.options.RetryBackoff <= 0 {
+		x.options.RetryBackoff = DefaultObjectSinkRetryBackoff
+	}
+	if x.options.Key == nil {
+		x.options.Key = func(at time.Time, sequence uint64) string {
+			return fmt.Sprintf("%s-%06d.jsonl", at.UTC().Format("20060102T150405.000000000"), sequence)
+		}
+	}
+	if x.options.MaxWait > 0 {
+		spawnRole(goroutineRoleSinkUpload, x.uploadPeriodically)
+	} else {
+		close(x.done)
+	}
+	return x
+}
+
+// Write 写入一条消息，攒够MaxSize时上传
+func (x *ObjectSink[Message]) Write(message Message) error {
+	data, err := x.codec.Encode(message)
+	if err != nil {
+		return err
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return ErrSinkClosed
+	}
+	if err := x.takeErr(); err != nil {
+		return err
+	}
+	if x.buffer.Len() == 0 {
+		x.firstAt = time.Now()
+	}
+	x.buffer.Write(data)
+	x.buffer.WriteByte('\n')
+	if x.buffer.Len() >= x.options.MaxSize {
+		return x.upload()
+	}
+	return nil
+}
+
+// Flush 立即上传攒着的消息
+func (x *ObjectSink[Message]) Flush() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return ErrSinkClosed
+	}
+	return x.upload()
+}
+
+// Close 停止后台上传，上传完攒着的消息之后关闭，重复关闭时什么都不做
+func (x *ObjectSink[Message]) Close() error {
+	x.lock.Lock()
+	if x.closed {
+		x.lock.Unlock()
+		return nil
+	}
+	x.closed = true
+	close(x.stop)
+	x.lock.Unlock()
+	<-x.done
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	err := x.takeErr()
+	if uploadErr := x.upload(); err == nil {
+		err = uploadErr
+	}
+	return err
+}
+
+// 上传攒着的消息，失败时按照指数退避重试，不管最终是否成功都会清空，调用方需要持有锁
+func (x *ObjectSink[Message]) upload() error {
+	if x.buffer.Len() == 0 {
+		return nil
+	}
+	x.sequence++
+	key := x.options.Key(x.firstAt, x.sequence)
+	data := append([]byte(nil), x.buffer.Bytes()...)
+	x.buffer.Reset()
+
+	backoff := x.options.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = x.uploader.Upload(context.Background(), key, data); err == nil {
+			if x.options.OnUploaded != nil {
+				x.options.OnUploaded(key, len(data))
+			}
+			return nil
+		}
+		if attempt >= x.options.MaxRetries {
+			return fmt.Errorf("message channel: upload %s: %w", key, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// 取出后台上传时记下的错误，调用方需要持有锁
+func (x *ObjectSink[Message]) takeErr() error {
+	err := x.err
+	x.err = nil
+	return err
+}
+
+func (x *ObjectSink[Message]) uploadPeriodically() {
+	defer close(x.done)
+	interval := x.options.MaxWait / 4
+	if interval <= 0 {
+		interval = x.options.MaxWait
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.stop:
+			return
+		case <-ticker.C:
+		}
+		x.lock.Lock()
+		if x.buffer.Len() > 0 && time.Since(x.firstAt) >= x.options.MaxWait {
+			if err := x.upload(); err != nil && x.err == nil {
+				x.err = err
+			}
+		}
+		x.lock.Unlock()
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// HTTPObjectUploader 用HTTP PUT上传对象的ObjectUploader，适用于S3兼容存储的路径风格地址（比如MinIO）或者预签名的地址
+// 对象上传到 BaseURL/key，需要鉴权时在Sign中给请求签名（比如AWS Signature V4）或者加上鉴权头
+type HTTPObjectUploader struct {
+
+	// 桶的地址，比如 http://localhost:9000/bucket
+	BaseURL string
+
+	// 发送请求的客户端，为nil时使用http.DefaultClient
+	Client *http.Client
+
+	// 发送之前修改请求，可以为nil，返回错误时放弃这次上传
+	Sign func(request *http.Request, data []byte) error
+}
+
+var _ ObjectUploader = (*HTTPObjectUploader)(nil)
+
+func (x *HTTPObjectUploader) Upload(ctx context.Context, key string, data []byte) error {
+	url := strings.TrimSuffix(x.BaseURL, "/") + "/" + strings.TrimPrefix(key, "/")
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.ContentLength = int64(len(data))
+	request.Header.Set("Content-Type", "application/octet-stream")
+	if x.Sign != nil {
+		if err := x.Sign(request, data); err != nil {
+			return err
+		}
+	}
+	client := x.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("message channel: upload %s: unexpected status %s", key, response.Status)
+	}
+	return nil
+}
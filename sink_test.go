@@ -0,0 +1,280 @@
+package message_channel
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type event struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestChannel_JSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewJSONLSink[event](path, nil)
+	assert.Nil(t, err)
+
+	// 只有被成功处理的消息才会写入，信道关闭时自动关闭Sink
+	channel := NewChannel[event](NewChannelOptions[event]().WithDecisionConsumerFunc(func(ctx context.Context, index int, message event) Decision {
+		if message.ID == 2 {
+			return DeadLetter{}
+		}
+		return Ack{}
+	}).WithSink(sink))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), event{ID: i, Name: "e"}))
+	}
+	channel.SenderWaitAndClose()
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"id\":1,\"name\":\"e\"}\n{\"id\":3,\"name\":\"e\"}\n", string(data))
+	assert.Equal(t, ErrSinkClosed, sink.Write(event{ID: 4}))
+	assert.Nil(t, sink.Close())
+
+	// 再次打开时追加在后面
+	sink, err = NewJSONLSink[event](path, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Write(event{ID: 5}))
+	assert.Nil(t, sink.Close())
+	data, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"id\":1,\"name\":\"e\"}\n{\"id\":3,\"name\":\"e\"}\n{\"id\":5,\"name\":\"\"}\n", string(data))
+}
+
+func TestChannel_SinkConsumerError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewJSONLSink[event](path, nil)
+	assert.Nil(t, err)
+
+	// 消费函数返回了错误的消息既不写入Sink也不转发给父信道
+	forwarded := make([]int, 0)
+	parent := NewChannel[event](NewChannelOptions[event]().WithChannelConsumerFunc(func(index int, message event) {
+		forwarded = append(forwarded, message.ID)
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[event]().WithContextConsumerFunc(func(ctx context.Context, index int, message event) error {
+		if message.ID == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	}).WithSink(sink))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, child.Send(context.Background(), event{ID: i, Name: "e"}))
+	}
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"id\":1,\"name\":\"e\"}\n{\"id\":3,\"name\":\"e\"}\n", string(data))
+	assert.Equal(t, []int{1, 3}, forwarded)
+	assert.Equal(t, uint64(1), child.Stats().DroppedByReason[DropReasonConsumerError])
+}
+
+func TestJSONLSink_PeriodicFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewJSONLSink[int](path, &FileSinkOptions{FlushInterval: time.Millisecond * 10})
+	assert.Nil(t, err)
+	defer sink.Close()
+	assert.Nil(t, sink.Write(1))
+
+	// 不需要等到写缓冲满了或者关闭
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(path)
+		return string(data) == "1\n"
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestChannel_CSVSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+	mapper := func(message event) []string {
+		return []string{strconv.Itoa(message.ID), message.Name}
+	}
+	sink, err := NewCSVSink[event](path, []string{"id", "name"}, mapper, nil)
+	assert.Nil(t, err)
+	channel := NewChannel[event](NewChannelOptions[event]().WithChannelConsumerFunc(func(index int, message event) {
+	}).WithSink(sink))
+	assert.Nil(t, channel.Send(context.Background(), event{ID: 1, Name: "plain"}))
+	assert.Nil(t, channel.Send(context.Background(), event{ID: 2, Name: "with, comma and \"quote\""}))
+	channel.SenderWaitAndClose()
+
+	// 追加写入已经存在的文件时不会再写表头
+	sink, err = NewCSVSink[event](path, []string{"id", "name"}, mapper, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Write(event{ID: 3, Name: "multi\nline"}))
+	assert.Nil(t, sink.Close())
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "name"},
+		{"1", "plain"},
+		{"2", "with, comma and \"quote\""},
+		{"3", "multi\nline"},
+	}, rows)
+}
+
+func TestFileSink_RotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.csv")
+	sink, err := NewCSVSink[int](path, []string{"n"}, func(message int) []string {
+		return []string{strconv.Itoa(message)}
+	}, &FileSinkOptions{Rotation: &RotationOptions{MaxSize: 10}})
+	assert.Nil(t, err)
+
+	// 表头2个字节，每行2个字节，每个文件最多写4行
+	for i := 0; i < 9; i++ {
+		assert.Nil(t, sink.Write(i))
+	}
+	assert.Nil(t, sink.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.Nil(t, err)
+	sort.Strings(rotated)
+	assert.Len(t, rotated, 2)
+	contents := make([]string, 0)
+	for _, file := range append(rotated, path) {
+		data, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		contents = append(contents, string(data))
+	}
+	assert.Equal(t, []string{"n\n0\n1\n2\n3\n", "n\n4\n5\n6\n7\n", "n\n8\n"}, contents)
+}
+
+func TestFileSink_RotateByAgeCompressed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := NewJSONLSink[int](path, &FileSinkOptions{Rotation: &RotationOptions{MaxAge: time.Millisecond * 20, Compress: true}})
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Write(1))
+	time.Sleep(time.Millisecond * 30)
+	assert.Nil(t, sink.Write(2))
+	assert.Nil(t, sink.Close())
+
+	rotated, err := filepath.Glob(path + ".*.gz")
+	assert.Nil(t, err)
+	assert.Len(t, rotated, 1)
+	file, err := os.Open(rotated[0])
+	assert.Nil(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n", string(data))
+
+	data, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "2\n", string(data))
+}
+
+type memoryUploader struct {
+	lock    *sync.Mutex
+	objects map[string]string
+	fails   int
+}
+
+func (x *memoryUploader) Upload(ctx context.Context, key string, data []byte) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.fails > 0 {
+		x.fails--
+		return errors.New("unavailable")
+	}
+	x.objects[key] = string(data)
+	return nil
+}
+
+func (x *memoryUploader) snapshot() map[string]string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	objects := make(map[string]string, len(x.objects))
+	for key, data := range x.objects {
+		objects[key] = data
+	}
+	return objects
+}
+
+func TestChannel_ObjectSink(t *testing.T) {
+	uploader := &memoryUploader{lock: &sync.Mutex{}, objects: map[string]string{}, fails: 2}
+	sink := NewObjectSink[int](uploader, JSONCodec[int](), &ObjectSinkOptions{
+		MaxSize:      4,
+		RetryBackoff: time.Millisecond,
+		Key: func(at time.Time, sequence uint64) string {
+			return "events/" + strconv.FormatUint(sequence, 10)
+		},
+	})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+	}).WithSink(sink))
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	// 每个对象攒够4个字节就上传，失败的上传重试之后成功，关闭时上传剩下的
+	assert.Equal(t, map[string]string{
+		"events/1": "1\n2\n",
+		"events/2": "3\n4\n",
+		"events/3": "5\n",
+	}, uploader.snapshot())
+}
+
+func TestObjectSink_MaxWaitAndRetriesExhausted(t *testing.T) {
+	uploader := &memoryUploader{lock: &sync.Mutex{}, objects: map[string]string{}}
+	sink := NewObjectSink[int](uploader, JSONCodec[int](), &ObjectSinkOptions{MaxWait: time.Millisecond * 20, RetryBackoff: time.Millisecond})
+	assert.Nil(t, sink.Write(1))
+	assert.Eventually(t, func() bool {
+		return len(uploader.snapshot()) == 1
+	}, time.Second, time.Millisecond*5)
+
+	uploader.lock.Lock()
+	uploader.fails = 100
+	uploader.lock.Unlock()
+	assert.Nil(t, sink.Write(2))
+
+	// 重试次数用完之后关闭时返回上传失败的错误
+	assert.NotNil(t, sink.Close())
+	assert.Equal(t, ErrSinkClosed, sink.Write(3))
+}
+
+func TestHTTPObjectUploader(t *testing.T) {
+	lock := &sync.Mutex{}
+	received := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "token" {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+		data, _ := io.ReadAll(request.Body)
+		lock.Lock()
+		received[request.Method+" "+request.URL.Path] = string(data)
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	uploader := &HTTPObjectUploader{BaseURL: server.URL + "/bucket/"}
+	assert.NotNil(t, uploader.Upload(context.Background(), "a.jsonl", []byte("1\n")))
+	uploader.Sign = func(request *http.Request, data []byte) error {
+		request.Header.Set("Authorization", "token")
+		return nil
+	}
+	assert.Nil(t, uploader.Upload(context.Background(), "a.jsonl", []byte("1\n")))
+	assert.Equal(t, map[string]string{"PUT /bucket/a.jsonl": "1\n"}, received)
+}
@@ -0,0 +1,138 @@
+package message_channel
+
+import (
+	"context"
+	"fmt"
+)
+
+// State 信道的生命周期状态
+type State int32
+
+const (
+
+	// StateCreated 刚创建，处理消息的协程还没有启动
+	StateCreated State = iota
+
+	// StateRunning 正常运行中
+	StateRunning
+
+	// StatePaused 被暂停了，消息仍然可以发送进来，但是不会被消费，直到调用Resume
+	StatePaused
+
+	// StateDraining 发送方已经开始关闭信道，正在等待剩余的消息处理完
+	StateDraining
+
+	// StateClosed 已经关闭并且处理完了
+	StateClosed
+)
+
+var stateNames = map[State]string{
+	StateCreated:  "created",
+	StateRunning:  "running",
+	StatePaused:   "paused",
+	StateDraining: "draining",
+	StateClosed:   "closed",
+}
+
+func (x State) String() string {
+	if name, ok := stateNames[x]; ok {
+		return name
+	}
+	return fmt.Sprintf("state(%d)", int32(x))
+}
+
+// StateListener 信道的状态发生变化时的回调
+// 回调是在持有状态锁的时候同步执行的，这样回调的顺序和状态迁移的顺序一致，所以在回调中不能再调用Pause、Resume之类改变状态的方法
+type StateListener func(old, new State)
+
+// 每个状态可以迁移到的状态
+var stateTransitions = map[State][]State{
+	StateCreated:  {StateRunning},
+	StateRunning:  {StatePaused, StateDraining},
+	StatePaused:   {StateRunning, StateDraining},
+	StateDraining: {StateClosed},
+}
+
+// State 获取信道当前的状态
+func (x *Channel[Message]) State() State {
+	return State(x.state.Load())
+}
+
+// IsClosed 信道是否已经关闭并且处理完了，关闭之后的信道不能再使用了
+func (x *Channel[Message]) IsClosed() bool {
+	return x.State() == StateClosed
+}
+
+// Pause 暂停消费消息，正在被处理的消息会继续处理完，之后的消息留在缓存中，只有运行中的信道才能被暂停
+// 暂停期间发送方仍然可以继续发送，缓存满了之后按照OverflowPolicy处理，关闭信道时会自动恢复消费
+func (x *Channel[Message]) Pause() error {
+	x.stateLock.Lock()
+	defer x.stateLock.Unlock()
+
+	if err := x.setState(StatePaused); err != nil {
+		return err
+	}
+	x.resumed = make(chan struct{})
+	return nil
+}
+
+// Resume 恢复被暂停的信道
+func (x *Channel[Message]) Resume() error {
+	x.stateLock.Lock()
+	defer x.stateLock.Unlock()
+
+	if err := x.setState(StateRunning); err != nil {
+		return err
+	}
+
+	// 暂停期间没有消费消息不算卡住，恢复时重新开始计时
+	x.stats.lastConsumeUnixNano.Store(x.clock.Now().UnixNano())
+	close(x.resumed)
+	return nil
+}
+
+// 开始关闭信道，所有的关闭方式都要经过这里，重复调用时什么也不做
+func (x *Channel[Message]) beginDraining() {
+	x.stateLock.Lock()
+	defer x.stateLock.Unlock()
+
+	old := x.State()
+	if old == StateDraining || old == StateClosed {
+		return
+	}
+	_ = x.setState(StateDraining)
+
+	// 暂停中的信道要恢复消费，否则剩余的消息永远处理不完
+	if old == StatePaused {
+		close(x.resumed)
+	}
+}
+
+// 迁移到新的状态，调用方需要持有stateLock
+func (x *Channel[Message]) setState(to State) error {
+	from := x.State()
+	for _, allowed := range stateTransitions[from] {
+		if allowed == to {
+			x.state.Store(int32(to))
+			if x.options.StateListener != nil {
+				x.options.StateListener(from, to)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidStateTransition, from, to)
+}
+
+// 等待信道没有被暂停
+func (x *Channel[Message]) waitResumed(ctx context.Context) error {
+	x.stateLock.Lock()
+	resumed := x.resumed
+	x.stateLock.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
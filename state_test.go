@@ -0,0 +1,82 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_PauseResume(t *testing.T) {
+	consumed := &atomic.Int64{}
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		consumed.Add(1)
+	}))
+	assert.Equal(t, StateRunning, channel.State())
+	assert.ErrorIs(t, channel.Resume(), ErrInvalidStateTransition)
+
+	assert.Nil(t, channel.Pause())
+	assert.Equal(t, StatePaused, channel.State())
+	assert.ErrorIs(t, channel.Pause(), ErrInvalidStateTransition)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, int64(0), consumed.Load())
+	assert.Equal(t, 3, channel.Stats().Depth)
+	assert.Equal(t, HealthStatusPaused, channel.Health(context.Background()).Self.Status)
+
+	assert.Nil(t, channel.Resume())
+	assert.Eventually(t, func() bool {
+		return consumed.Load() == 3
+	}, time.Second, time.Millisecond)
+
+	// 关闭暂停中的信道时会恢复消费
+	assert.Nil(t, channel.Pause())
+	assert.Nil(t, channel.Send(context.Background(), 4))
+	channel.SenderWaitAndClose()
+	assert.Equal(t, int64(4), consumed.Load())
+	assert.True(t, channel.IsClosed())
+	assert.ErrorIs(t, channel.Send(context.Background(), 5), ErrChannelClosed)
+	assert.ErrorIs(t, channel.Pause(), ErrInvalidStateTransition)
+}
+
+func TestChannel_RejectSendWhileDraining(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithPullMode().WithChannelBuffSize(10).WithRejectSendWhileDraining())
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	go channel.SenderWaitAndClose()
+	assert.Eventually(t, func() bool {
+		return channel.State() == StateDraining
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, channel.Send(context.Background(), 2), ErrChannelDraining)
+
+	message, err := channel.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, message)
+	_, err = channel.Receive(context.Background())
+	assert.ErrorIs(t, err, ErrChannelClosed)
+	assert.Equal(t, StateClosed, channel.State())
+}
+
+func TestChannel_StateListener(t *testing.T) {
+	transitions := make([]string, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithStateListener(func(old, new State) {
+		transitions = append(transitions, old.String()+"->"+new.String())
+	}))
+	assert.Nil(t, channel.Pause())
+	assert.Nil(t, channel.Resume())
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []string{
+		"created->running",
+		"running->paused",
+		"paused->running",
+		"running->draining",
+		"draining->closed",
+	}, transitions)
+}
+
+func TestState_String(t *testing.T) {
+	assert.Equal(t, "draining", StateDraining.String())
+	assert.Equal(t, "state(42)", State(42).String())
+}
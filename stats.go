@@ -0,0 +1,383 @@
+package message_channel
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// latencyHistogramBuckets 延迟直方图的桶的数量，第i个桶统计的是 [2^(i-1), 2^i) 微秒的延迟，最后一个桶统计所有更大的延迟
+const latencyHistogramBuckets = 32
+
+// latencyHistogram 一个轻量的按照2的幂次分桶的延迟直方图，所有操作都是原子的，用来估算延迟的分位数
+type latencyHistogram struct {
+	buckets [latencyHistogramBuckets]atomic.Uint64
+	count   atomic.Uint64
+	max     atomic.Int64
+}
+
+// 记录一次延迟
+func (x *latencyHistogram) observe(d time.Duration) {
+	micros := uint64(d / time.Microsecond)
+	index := 0
+	for micros > 0 && index < latencyHistogramBuckets-1 {
+		micros >>= 1
+		index++
+	}
+	x.buckets[index].Add(1)
+	x.count.Add(1)
+	for {
+		old := x.max.Load()
+		if int64(d) <= old || x.max.CompareAndSwap(old, int64(d)) {
+			break
+		}
+	}
+}
+
+// 估算分位数，返回分位数所在的桶的上界，还没有记录过延迟时返回0
+func (x *latencyHistogram) quantile(q float64) time.Duration {
+	total := x.count.Load()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(total))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for index := 0; index < latencyHistogramBuckets; index++ {
+		seen += x.buckets[index].Load()
+		if seen >= rank {
+			upper := time.Duration(uint64(1)<<uint(index)) * time.Microsecond
+			if max := time.Duration(x.max.Load()); max < upper {
+				return max
+			}
+			return upper
+		}
+	}
+	return time.Duration(x.max.Load())
+}
+
+// channelStats 信道的各项统计计数
+type channelStats struct {
+
+	// 信道创建的时间
+	createdAt time.Time
+
+	// 成功放入信道的消息的数量，包括子信道转发过来的
+	sent atomic.Uint64
+
+	// 已经被消费的消息的数量
+	consumed atomic.Uint64
+
+	// 被丢弃的消息的数量
+	dropped atomic.Uint64
+
+	// 已经从缓存中取出来还没有处理完的消息的数量
+	inFlight atomic.Int64
+
+	// 信道启动的还在运行的协程的数量
+	goroutines atomic.Int64
+
+	// 按照丢弃原因分别统计的被丢弃的消息的数量
+	drops *dropCounters
+
+	// 消费函数崩溃或者返回错误的次数
+	consumerErrors atomic.Uint64
+
+	// 消费函数处理一条消息的耗时超过期限的次数
+	slowConsumes atomic.Uint64
+
+	// 消费函数处理一条消息超时被打断的次数
+	consumeTimeouts atomic.Uint64
+
+	// 消费函数要求重试的次数
+	retries atomic.Uint64
+
+	// 累计停留的时长超过延迟预算的消息的数量
+	overBudget atomic.Uint64
+
+	// 消费函数判定为死信的消息的数量
+	deadLettered atomic.Uint64
+
+	// 开启工作窃取时从兄弟信道的缓存中拿过来处理的消息的数量
+	stolen atomic.Uint64
+
+	// 最近一次消费消息的时间，还没有消费过时是信道的创建时间
+	lastConsumeUnixNano atomic.Int64
+
+	// 配置了SilenceTimeout时最近一次有消息放入的时间，还没有放入过时是信道的创建时间
+	lastSendUnixNano atomic.Int64
+
+	// 消费函数处理每条消息的耗时
+	latency *latencyHistogram
+
+	// 开启了QueueLatency时每条消息在缓存中等待的时长
+	queueLatency *latencyHistogram
+
+	// 最近一分钟每秒被消费的消息的数量
+	throughput *throughputCounter
+
+	// 消费耗时以及消息到达速率的指数加权移动平均
+	latencyEWMA *latencyEWMA
+	arrivalRate *rateEWMA
+}
+
+func newChannelStats(clock Clock) *channelStats {
+	x := &channelStats{
+		createdAt:    clock.Now(),
+		latency:      &latencyHistogram{},
+		queueLatency: &latencyHistogram{},
+		throughput:   &throughputCounter{},
+		latencyEWMA:  &latencyEWMA{},
+		arrivalRate:  newRateEWMA(),
+		drops:        newDropCounters(),
+	}
+	x.lastConsumeUnixNano.Store(x.createdAt.UnixNano())
+	x.lastSendUnixNano.Store(x.createdAt.UnixNano())
+	return x
+}
+
+// ChannelStats 信道的统计信息快照
+type ChannelStats struct {
+
+	// 信道的ID
+	ID uint64 `json:"id"`
+
+	// 信道的名字
+	Name string `json:"name"`
+
+	// 信道的标签
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// 成功放入信道的消息的数量，包括子信道转发过来的
+	Sent uint64 `json:"sent"`
+
+	// 已经被消费的消息的数量
+	Consumed uint64 `json:"consumed"`
+
+	// 被丢弃的消息的数量
+	Dropped uint64 `json:"dropped"`
+
+	// 按照丢弃原因分别统计的被丢弃的消息的数量
+	DroppedByReason map[DropReason]uint64 `json:"dropped_by_reason,omitempty"`
+
+	// 消费函数崩溃或者返回错误的次数
+	ConsumerErrors uint64 `json:"consumer_errors"`
+
+	// 消费函数处理一条消息的耗时超过ConsumeDeadline的次数
+	SlowConsumes uint64 `json:"slow_consumes"`
+
+	// 消费函数处理一条消息超过ConsumeTimeout被打断的次数
+	ConsumeTimeouts uint64 `json:"consume_timeouts"`
+
+	// 消费函数要求重试的次数
+	Retries uint64 `json:"retries"`
+
+	// 累计停留的时长超过延迟预算的消息的数量，包括按照LatencyBudgetDrop丢弃的
+	OverLatencyBudget uint64 `json:"over_latency_budget"`
+
+	// 消费函数判定为死信的消息的数量，包括重试次数用完的消息
+	DeadLettered uint64 `json:"dead_lettered"`
+
+	// 开启工作窃取时从兄弟信道的缓存中拿过来处理的消息的数量，这些消息算在兄弟信道的Sent和当前信道的Consumed中
+	Stolen uint64 `json:"stolen"`
+
+	// 缓存中当前还没有被消费的消息的数量
+	Depth int `json:"depth"`
+
+	// 缓存的容量
+	Capacity int `json:"capacity"`
+
+	// 已经从缓存中取出来还没有处理完的消息的数量
+	InFlight int `json:"in_flight"`
+
+	// 消费函数处理一条消息的耗时的分位数，是按照2的幂次分桶估算出来的上界
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP90 time.Duration `json:"latency_p90"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+
+	// 消费函数处理一条消息的最大耗时
+	LatencyMax time.Duration `json:"latency_max"`
+
+	// 开启了QueueLatency时消息在缓存中等待的时长的分位数和最大值，没有开启时都是0
+	QueueLatencyP50 time.Duration `json:"queue_latency_p50"`
+	QueueLatencyP90 time.Duration `json:"queue_latency_p90"`
+	QueueLatencyP99 time.Duration `json:"queue_latency_p99"`
+	QueueLatencyMax time.Duration `json:"queue_latency_max"`
+
+	// 缓存中最早的一条消息已经等了多久，没有记录放入时间或者缓存为空时是0
+	OldestMessageAge time.Duration `json:"oldest_message_age"`
+
+	// 最近1秒、10秒、1分钟平均每秒被消费的消息的数量，只统计已经过去了的完整的秒，是一个估算值，可以直接用于自动伸缩或者监控面板
+	Throughput1s  float64 `json:"throughput_1s"`
+	Throughput10s float64 `json:"throughput_10s"`
+	Throughput1m  float64 `json:"throughput_1m"`
+
+	// 消费函数处理一条消息的耗时的指数加权移动平均，最新的一次耗时占10%的权重，还没有消费过消息时是0
+	LatencyEWMA time.Duration `json:"latency_ewma"`
+
+	// 消息到达速率的指数加权移动平均，单位是每秒的消息数量，时间常数是10秒，没有消息到达时会随着时间衰减
+	// 和LatencyEWMA一样维护的开销很小，适合作为自动伸缩或者按负载分发的输入
+	ArrivalRateEWMA float64 `json:"arrival_rate_ewma"`
+
+	// 信道创建以来的时长
+	Uptime time.Duration `json:"uptime"`
+}
+
+// Stats 获取当前信道自己的统计信息，统计都是用原子计数维护的，可以随时调用
+func (x *Channel[Message]) Stats() ChannelStats {
+	now := x.clock.Now()
+	return ChannelStats{
+		ID:                x.ID,
+		Name:              x.options.Name,
+		Tags:              x.Tags(),
+		Sent:              x.stats.sent.Load(),
+		Consumed:          x.stats.consumed.Load(),
+		Dropped:           x.stats.dropped.Load(),
+		DroppedByReason:   x.stats.drops.snapshot(),
+		ConsumerErrors:    x.stats.consumerErrors.Load(),
+		SlowConsumes:      x.stats.slowConsumes.Load(),
+		ConsumeTimeouts:   x.stats.consumeTimeouts.Load(),
+		Retries:           x.stats.retries.Load(),
+		OverLatencyBudget: x.stats.overBudget.Load(),
+		DeadLettered:      x.stats.deadLettered.Load(),
+		Stolen:            x.stats.stolen.Load(),
+		Depth:             x.buffer.len(),
+		Capacity:          x.buffer.cap(),
+		InFlight:          int(x.stats.inFlight.Load()),
+		LatencyP50:        x.stats.latency.quantile(0.5),
+		LatencyP90:        x.stats.latency.quantile(0.9),
+		LatencyP99:        x.stats.latency.quantile(0.99),
+		LatencyMax:        time.Duration(x.stats.latency.max.Load()),
+		QueueLatencyP50:   x.stats.queueLatency.quantile(0.5),
+		QueueLatencyP90:   x.stats.queueLatency.quantile(0.9),
+		QueueLatencyP99:   x.stats.queueLatency.quantile(0.99),
+		QueueLatencyMax:   time.Duration(x.stats.queueLatency.max.Load()),
+		OldestMessageAge:  x.OldestMessageAge(),
+		Throughput1s:      x.stats.throughput.rate(now, x.stats.createdAt, 1),
+		Throughput10s:     x.stats.throughput.rate(now, x.stats.createdAt, 10),
+		Throughput1m:      x.stats.throughput.rate(now, x.stats.createdAt, 60),
+		LatencyEWMA:       x.stats.latencyEWMA.value(),
+		ArrivalRateEWMA:   x.stats.arrivalRate.value(now),
+		Uptime:            now.Sub(x.stats.createdAt),
+	}
+}
+
+// SubtreeStats 一个信道以及它所有子孙信道的统计信息
+type SubtreeStats struct {
+
+	// 信道自己的统计信息
+	Self ChannelStats `json:"self"`
+
+	// 信道以及所有子孙信道汇总的统计信息，延迟分位数、LatencyEWMA和OldestMessageAge取的是子树中最大的值
+	Total ChannelStats `json:"total"`
+
+	// 子信道的统计信息，包括分发子信道，按照ID排序
+	Children []SubtreeStats `json:"children,omitempty"`
+}
+
+// SubtreeStats 汇总当前信道以及所有子孙信道的统计信息，遍历每一层子信道时都会持有这一层子信道map的锁，
+// 这样一次遍历得到的是一个一致的子树，适合为每条流水线导出一个汇总的指标
+func (x *Channel[Message]) SubtreeStats(ctx context.Context) (SubtreeStats, error) {
+
+	subtree := SubtreeStats{
+		Self: x.Stats(),
+	}
+	subtree.Total = subtree.Self
+
+	err := x.childrenChannelMap.Run(ctx, func(ctx context.Context, m map[uint64]*Channel[Message]) error {
+		children := make([]*Channel[Message], 0, len(m))
+		for _, child := range m {
+			children = append(children, child)
+		}
+		children = append(children, x.DistributionChildren()...)
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].ID < children[j].ID
+		})
+		for _, child := range children {
+			childStats, err := child.SubtreeStats(ctx)
+			if err != nil {
+				return err
+			}
+			subtree.Children = append(subtree.Children, childStats)
+			subtree.Total.add(childStats.Total)
+		}
+		return nil
+	})
+	return subtree, err
+}
+
+// 把另一个信道的统计累加进来
+func (x *ChannelStats) add(other ChannelStats) {
+	x.Sent += other.Sent
+	x.Consumed += other.Consumed
+	x.Dropped += other.Dropped
+	if len(other.DroppedByReason) > 0 {
+		// 不能直接修改other的map，Self和Total一开始共用的是同一个map
+		merged := make(map[DropReason]uint64, len(x.DroppedByReason)+len(other.DroppedByReason))
+		for reason, count := range x.DroppedByReason {
+			merged[reason] += count
+		}
+		for reason, count := range other.DroppedByReason {
+			merged[reason] += count
+		}
+		x.DroppedByReason = merged
+	}
+	x.ConsumerErrors += other.ConsumerErrors
+	x.SlowConsumes += other.SlowConsumes
+	x.ConsumeTimeouts += other.ConsumeTimeouts
+	x.Throughput1s += other.Throughput1s
+	x.Throughput10s += other.Throughput10s
+	x.Throughput1m += other.Throughput1m
+	x.ArrivalRateEWMA += other.ArrivalRateEWMA
+	x.Retries += other.Retries
+	x.OverLatencyBudget += other.OverLatencyBudget
+	x.DeadLettered += other.DeadLettered
+	x.Stolen += other.Stolen
+	x.Depth += other.Depth
+	x.Capacity += other.Capacity
+	x.InFlight += other.InFlight
+	if other.LatencyP50 > x.LatencyP50 {
+		x.LatencyP50 = other.LatencyP50
+	}
+	if other.LatencyP90 > x.LatencyP90 {
+		x.LatencyP90 = other.LatencyP90
+	}
+	if other.LatencyP99 > x.LatencyP99 {
+		x.LatencyP99 = other.LatencyP99
+	}
+	if other.LatencyMax > x.LatencyMax {
+		x.LatencyMax = other.LatencyMax
+	}
+	if other.LatencyEWMA > x.LatencyEWMA {
+		x.LatencyEWMA = other.LatencyEWMA
+	}
+	if other.QueueLatencyP50 > x.QueueLatencyP50 {
+		x.QueueLatencyP50 = other.QueueLatencyP50
+	}
+	if other.QueueLatencyP90 > x.QueueLatencyP90 {
+		x.QueueLatencyP90 = other.QueueLatencyP90
+	}
+	if other.QueueLatencyP99 > x.QueueLatencyP99 {
+		x.QueueLatencyP99 = other.QueueLatencyP99
+	}
+	if other.QueueLatencyMax > x.QueueLatencyMax {
+		x.QueueLatencyMax = other.QueueLatencyMax
+	}
+	if other.OldestMessageAge > x.OldestMessageAge {
+		x.OldestMessageAge = other.OldestMessageAge
+	}
+}
+
+// 检查消费函数处理一条消息的耗时是否超过了期限
+func (x *Channel[Message]) checkSlowConsume(message Message, elapsed time.Duration) {
+	if deadline := x.tuned().ConsumeDeadline; deadline <= 0 || elapsed <= deadline {
+		return
+	}
+	x.stats.slowConsumes.Add(1)
+	if x.options.SlowConsumeListener != nil {
+		x.options.SlowConsumeListener(message, elapsed)
+	}
+}
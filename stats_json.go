@@ -0,0 +1,37 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// StatsJSONSchemaVersion StatsJSON导出的格式的版本，之后只会增加字段，改名或者删除字段这样不兼容的修改会增加版本号
+const StatsJSONSchemaVersion = 1
+
+// StatsDocument StatsJSON导出的文档
+type StatsDocument struct {
+
+	// 格式的版本，见StatsJSONSchemaVersion
+	SchemaVersion int `json:"schema_version"`
+
+	// 统计的时间
+	Time time.Time `json:"time"`
+
+	// 当前信道以及所有子孙信道的统计信息，所有的时长都是纳秒数
+	Stats SubtreeStats `json:"stats"`
+}
+
+// StatsJSON 把当前信道以及所有子孙信道的统计信息序列化为JSON，数据和SubtreeStats一样，格式是稳定的，
+// 这样外部的采集程序不需要链接指标相关的包也可以抓取流水线的统计，ctx结束时返回ctx的错误
+func (x *Channel[Message]) StatsJSON(ctx context.Context) ([]byte, error) {
+	stats, err := x.SubtreeStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(StatsDocument{
+		SchemaVersion: StatsJSONSchemaVersion,
+		Time:          x.clock.Now(),
+		Stats:         stats,
+	})
+}
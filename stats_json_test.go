@@ -0,0 +1,34 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_StatsJSON(t *testing.T) {
+	root := NewChannel[int](NewChannelOptions[int]().WithName("root").WithChannelBuffSize(10))
+	child := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child").WithChannelBuffSize(10))
+	assert.Nil(t, root.Send(context.Background(), 1))
+
+	data, err := root.StatsJSON(context.Background())
+	assert.Nil(t, err)
+	document := &StatsDocument{}
+	assert.Nil(t, json.Unmarshal(data, document))
+	assert.Equal(t, StatsJSONSchemaVersion, document.SchemaVersion)
+	assert.Equal(t, "root", document.Stats.Self.Name)
+	assert.Equal(t, uint64(1), document.Stats.Self.Sent)
+	if assert.Len(t, document.Stats.Children, 1) {
+		assert.Equal(t, "child", document.Stats.Children[0].Self.Name)
+	}
+
+	// 字段名是稳定的格式的一部分
+	raw := map[string]any{}
+	assert.Nil(t, json.Unmarshal(data, &raw))
+	stats := raw["stats"].(map[string]any)
+	assert.Equal(t, float64(1), stats["total"].(map[string]any)["sent"])
+
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+}
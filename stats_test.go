@@ -0,0 +1,109 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_Stats(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		time.Sleep(time.Millisecond * time.Duration(message))
+	}))
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	stats := channel.Stats()
+	assert.Equal(t, uint64(5), stats.Sent)
+	assert.Equal(t, uint64(5), stats.Consumed)
+	assert.Equal(t, 0, stats.Depth)
+	assert.Equal(t, 10, stats.Capacity)
+	assert.GreaterOrEqual(t, stats.LatencyMax, time.Millisecond*5)
+	assert.LessOrEqual(t, stats.LatencyP50, stats.LatencyP99)
+	assert.GreaterOrEqual(t, stats.LatencyP50, time.Millisecond)
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	histogram := &latencyHistogram{}
+	assert.Equal(t, time.Duration(0), histogram.quantile(0.5))
+	for i := 0; i < 99; i++ {
+		histogram.observe(time.Microsecond * 3)
+	}
+	histogram.observe(time.Second)
+	assert.Equal(t, time.Microsecond*4, histogram.quantile(0.5))
+	assert.Equal(t, time.Second, histogram.quantile(1))
+}
+
+func TestChannel_SubtreeStats(t *testing.T) {
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10))
+	child := root.MakeChildChannel()
+	grandchild := child.MakeChildChannel()
+	assert.Nil(t, grandchild.Send(context.Background(), 1))
+	assert.Nil(t, child.Send(context.Background(), 2))
+
+	subtree, err := root.SubtreeStats(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(subtree.Children))
+	assert.Equal(t, 1, len(subtree.Children[0].Children))
+	assert.Equal(t, 30, subtree.Total.Capacity)
+
+	grandchild.SenderWaitAndClose()
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+
+	// 孙子信道的消息经过子信道转发到根信道，每一层都算一次发送
+	subtree, err = root.SubtreeStats(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), subtree.Self.Sent)
+	assert.Equal(t, uint64(2), subtree.Self.Consumed)
+}
+
+func TestChannel_DepthHistory(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithPullMode().WithDepthSampler(time.Millisecond*5, 3))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	time.Sleep(time.Millisecond * 50)
+
+	history := channel.DepthHistory()
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, 1, history[2].Depth)
+	assert.True(t, history[0].Time.Before(history[2].Time))
+
+	go channel.SenderWaitAndClose()
+	_, err := Reduce[int, int](context.Background(), channel, 0, func(acc int, message int) int { return acc })
+	assert.Nil(t, err)
+
+	other := NewChannel[int](NewChannelOptions[int]())
+	assert.Nil(t, other.DepthHistory())
+	other.SenderWaitAndClose()
+}
+
+func TestDepthSampler_Ring(t *testing.T) {
+	sampler := &depthSampler{lock: &sync.Mutex{}, samples: make([]DepthSample, 2)}
+	for i := 1; i <= 3; i++ {
+		sampler.record(DepthSample{Depth: i})
+	}
+	assert.Equal(t, []DepthSample{{Depth: 2}, {Depth: 3}}, sampler.history())
+}
+
+func TestChannel_ConsumeDeadline(t *testing.T) {
+	slow := make([]int, 0)
+	options := NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 2 {
+			time.Sleep(time.Millisecond * 20)
+		}
+	}).WithConsumeDeadline(time.Millisecond*10, func(message int, elapsed time.Duration) {
+		slow = append(slow, message)
+		assert.GreaterOrEqual(t, elapsed, time.Millisecond*10)
+	})
+	channel := NewChannel[int](options)
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+	assert.Equal(t, []int{2}, slow)
+	assert.Equal(t, uint64(1), channel.Stats().SlowConsumes)
+}
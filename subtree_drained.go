@@ -0,0 +1,48 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// 轮询等待信道达到某个状态时检查的间隔，比如等待整个子树排空或者工作协程的数量调整完
+const statePollInterval = 10 * time.Millisecond
+
+// WaitForSubtreeDrained 阻塞直到当前信道以及所有的子孙信道的缓存中都没有消息、也没有正在处理的消息，或者ctx结束，不会关闭任何信道
+// 任何一方都可以调用，不只是发送方，适合在流水线的两个阶段之间做屏障：等上一阶段的消息都处理完了再开始下一阶段
+// 返回之后新发送的消息不在等待的范围内，ctx结束时返回ctx的错误
+func (x *Channel[Message]) WaitForSubtreeDrained(ctx context.Context) error {
+	// 消息从缓存中取出来到开始处理之间有一个很短的间隙，这时候缓存是空的也还没有计入正在处理的数量，
+	// 所以要连续两次看到整个子树都空了并且期间没有消息被放入或者处理完才算是排空了
+	var last subtreeCounts
+	drained := false
+	for {
+		counts, ok := x.subtreeDrained()
+		if ok && drained && counts == last {
+			return nil
+		}
+		last, drained = counts, ok
+		if !sleepContext(ctx, x.clock, statePollInterval) {
+			return ctx.Err()
+		}
+	}
+}
+
+// subtreeCounts 整个子树累计放入和处理完的消息的数量
+type subtreeCounts struct {
+	sent, settled uint64
+}
+
+// 整个子树中是否都没有消息了，同时返回子树的累计计数
+func (x *Channel[Message]) subtreeDrained() (subtreeCounts, bool) {
+	var counts subtreeCounts
+	drained := true
+	x.eachInSubtree(func(channel *Channel[Message]) {
+		if channel.buffer.len() > 0 || channel.stats.inFlight.Load() > 0 {
+			drained = false
+		}
+		counts.sent += channel.stats.sent.Load()
+		counts.settled += channel.stats.consumed.Load() + channel.stats.dropped.Load()
+	})
+	return counts, drained
+}
@@ -0,0 +1,41 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_WaitForSubtreeDrained(t *testing.T) {
+	consumed := &atomic.Int64{}
+	release := make(chan struct{})
+	parent := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		if message < 0 {
+			<-release
+		}
+		time.Sleep(time.Millisecond)
+		consumed.Add(1)
+	}))
+	child := parent.MakeChildChannelWithOptions(NewChannelOptions[int]().WithChannelBuffSize(10))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, child.Send(context.Background(), i))
+		assert.Nil(t, parent.Send(context.Background(), i))
+	}
+
+	// 子信道转发上来的消息也要等父信道处理完
+	assert.Nil(t, parent.WaitForSubtreeDrained(context.Background()))
+	assert.Equal(t, int64(10), consumed.Load())
+	assert.Equal(t, StateRunning, parent.State())
+
+	// 有消息卡住的时候等到ctx到期
+	assert.Nil(t, parent.Send(context.Background(), -1))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, parent.WaitForSubtreeDrained(ctx), context.DeadlineExceeded)
+	close(release)
+
+	child.SenderWaitAndClose()
+	parent.SenderWaitAndClose()
+}
@@ -0,0 +1,113 @@
+package message_channel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FatalError 消费函数遇到无法继续处理的错误时可以通过Fatal主动让处理消息的协程退出，由监督者决定是否重启
+type FatalError struct {
+	Err error
+}
+
+func (x *FatalError) Error() string {
+	return fmt.Sprintf("message channel: consumer fatal: %v", x.Err)
+}
+
+func (x *FatalError) Unwrap() error {
+	return x.Err
+}
+
+// Fatal 在消费函数中调用，让当前处理消息的协程崩溃退出，配置了监督者时会按照策略重启
+func Fatal(err error) {
+	panic(&FatalError{Err: err})
+}
+
+// RestartListener 处理消息的协程被重启时的回调
+// restarts: 在当前统计周期内已经重启的次数
+// reason: 协程崩溃的原因，panic的值不是error时会被包装为error
+type RestartListener func(restarts int, reason error)
+
+// SupervisorOptions 监督处理消息的协程的策略，类似于Erlang的监督树
+// 消费函数panic或者调用了Fatal时，正在处理的那条消息会被丢弃，然后在退避一段时间之后重新启动处理消息的协程
+type SupervisorOptions struct {
+
+	// 一个统计周期内最多重启多少次，超过之后不再重启，继续panic
+	MaxRestarts int
+
+	// 统计重启次数的周期，为0时表示整个信道的生命周期
+	Period time.Duration
+
+	// 第一次重启之前退避的时长，之后每次重启都会翻倍
+	Backoff time.Duration
+
+	// 退避时长的上限，为0时表示不限制
+	MaxBackoff time.Duration
+
+	// 每次重启时的回调
+	RestartListener RestartListener
+}
+
+// supervisor 根据监督策略判断崩溃的协程是否可以重启
+type supervisor struct {
+	lock    *sync.Mutex
+	options *SupervisorOptions
+	clock   Clock
+
+	// 当前统计周期内的重启次数以及周期的开始时间
+	restarts    int
+	periodStart time.Time
+}
+
+func newSupervisor(options *SupervisorOptions, clock Clock) *supervisor {
+	return &supervisor{
+		lock:        &sync.Mutex{},
+		options:     options,
+		clock:       clock,
+		periodStart: clock.Now(),
+	}
+}
+
+// 判断是否允许重启，允许的话会先退避一段时间再返回
+func (x *supervisor) allowRestart(reason any) bool {
+
+	x.lock.Lock()
+	if x.options.Period > 0 && x.clock.Since(x.periodStart) > x.options.Period {
+		x.restarts = 0
+		x.periodStart = x.clock.Now()
+	}
+	if x.restarts >= x.options.MaxRestarts {
+		x.lock.Unlock()
+		return false
+	}
+	x.restarts++
+	restarts := x.restarts
+	x.lock.Unlock()
+
+	// 指数退避
+	backoff := x.options.Backoff
+	for i := 1; i < restarts && backoff > 0; i++ {
+		backoff *= 2
+		if x.options.MaxBackoff > 0 && backoff >= x.options.MaxBackoff {
+			backoff = x.options.MaxBackoff
+			break
+		}
+	}
+	if backoff > 0 {
+		x.clock.Sleep(backoff)
+	}
+
+	if x.options.RestartListener != nil {
+		x.options.RestartListener(restarts, panicReasonToError(reason))
+	}
+	return true
+}
+
+// 把panic的值转为error
+func panicReasonToError(reason any) error {
+	if err, ok := reason.(error); ok {
+		return err
+	}
+	return fmt.Errorf("message channel: consumer panic: %v", reason)
+}
@@ -0,0 +1,46 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	consumed := make([]int, 0)
+	reasons := make([]error, 0)
+	options := NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		switch message {
+		case 2:
+			panic("boom")
+		case 4:
+			Fatal(errors.New("fatal"))
+		}
+		consumed = append(consumed, message)
+	}).WithSupervisor(&SupervisorOptions{
+		MaxRestarts: 3,
+		Backoff:     time.Millisecond,
+		RestartListener: func(restarts int, reason error) {
+			reasons = append(reasons, reason)
+		},
+	})
+	channel := NewChannel[int](options)
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	// 崩溃时正在处理的消息被丢弃，其它的消息继续被处理
+	assert.Equal(t, []int{1, 3, 5}, consumed)
+	assert.Equal(t, 2, len(reasons))
+	fatalError := &FatalError{}
+	assert.True(t, errors.As(reasons[1], &fatalError))
+}
+
+func TestSupervisor_MaxRestarts(t *testing.T) {
+	s := newSupervisor(&SupervisorOptions{MaxRestarts: 1}, SystemClock())
+	assert.True(t, s.allowRestart("first"))
+	assert.False(t, s.allowRestart("second"))
+}
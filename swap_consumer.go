@@ -0,0 +1,53 @@
+package message_channel
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// consumerFuncs 信道当前使用的一组消费函数，SwapConsumer的时候整体替换，同时只会有一个不为nil
+type consumerFuncs[Message any] struct {
+	channel  ChannelConsumerFunc[Message]
+	context  ContextConsumerFunc[Message]
+	decision DecisionConsumerFunc[Message]
+	async    AsyncConsumerFunc[Message]
+
+	// 正在用这一组消费函数处理的消息的数量
+	active atomic.Int64
+}
+
+// 是否没有配置消费函数
+func (x *consumerFuncs[Message]) empty() bool {
+	return x.channel == nil && x.context == nil && x.decision == nil && x.async == nil
+}
+
+// 拿到当前的消费函数并且计入正在处理的数量，拿到之后被替换了的话重新拿，这样SwapConsumer等待的时候不会漏掉刚开始处理的消息
+func (x *Channel[Message]) acquireConsumer() *consumerFuncs[Message] {
+	for {
+		consumer := x.consumer.Load()
+		consumer.active.Add(1)
+		if x.consumer.Load() == consumer {
+			return consumer
+		}
+		consumer.active.Add(-1)
+	}
+}
+
+// SwapConsumer 替换信道的消费函数，之后开始处理的消息都交给新的消费函数，缓存中的消息不会受影响，适合插件重新加载或者根据开关切换处理逻辑
+// 替换是立即生效的，然后等待正在被旧的消费函数处理的消息都处理完才返回，这样返回之后旧的消费函数就不会再被调用了，ctx结束时返回ctx的错误，这时候替换已经生效了
+// 等待重试的消息重试时使用的是新的消费函数；consumer为nil时之后的消息不再被消费，子信道会直接转发给父信道
+// 分发模式和拉模式的信道没有消费函数，替换之后也不会被调用；信道已经关闭时返回ErrChannelClosed
+// 不能在消费函数中替换自己所在的信道的消费函数，否则会一直等待自己处理完
+func (x *Channel[Message]) SwapConsumer(ctx context.Context, consumer DecisionConsumerFunc[Message]) error {
+	if x.IsClosed() {
+		return ErrChannelClosed
+	}
+	old := x.consumer.Swap(&consumerFuncs[Message]{decision: consumer})
+	for old.active.Load() > 0 {
+		if !sleepContext(ctx, x.clock, time.Millisecond) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannel_SwapConsumer(t *testing.T) {
+	lock := &sync.Mutex{}
+	handled := make(map[string][]int)
+	record := func(version string, message int) {
+		lock.Lock()
+		defer lock.Unlock()
+		handled[version] = append(handled[version], message)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		if message == 1 {
+			close(started)
+			<-release
+		}
+		record("old", message)
+	}))
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	<-started
+
+	// 旧的消费函数还在处理消息，ctx到期时替换已经生效了
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := channel.SwapConsumer(ctx, func(ctx context.Context, index int, message int) Decision {
+		record("new", message)
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, map[string][]int{"old": {1}, "new": {2, 3}}, handled)
+	assert.ErrorIs(t, channel.SwapConsumer(context.Background(), nil), ErrChannelClosed)
+}
+
+func TestChannel_SwapConsumerWaitsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelConsumerFunc(func(index int, message int) {
+		<-release
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Eventually(t, func() bool {
+		return channel.Stats().InFlight == 1
+	}, time.Second, time.Millisecond)
+
+	swapped := make(chan error, 1)
+	go func() {
+		swapped <- channel.SwapConsumer(context.Background(), nil)
+	}()
+	select {
+	case <-swapped:
+		t.Fatal("swap returned while the old consumer was still running")
+	case <-time.After(time.Millisecond * 20):
+	}
+	close(release)
+	assert.Nil(t, <-swapped)
+	channel.SenderWaitAndClose()
+}
@@ -0,0 +1,16 @@
+package message_channel
+
+// 同步模式下直接在发送方的协程中处理一条消息，多个发送方同时发送时排队处理，消费函数的panic会直接抛给发送方
+func (x *Channel[Message]) consumeInline(envelope envelope[Message]) {
+	x.synchronous.Lock()
+	defer x.synchronous.Unlock()
+	x.dequeued(envelope)
+
+	if !x.admit(&envelope) {
+		return
+	}
+	x.stats.inFlight.Add(1)
+	defer x.stats.inFlight.Add(-1)
+	x.consume(x.markConsumed(envelope), envelope, nil)
+	x.markProcessed(envelope.offset)
+}
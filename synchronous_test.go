@@ -0,0 +1,42 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannel_SynchronousMode(t *testing.T) {
+	received := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithSynchronousMode().WithChannelConsumerFunc(func(index int, message int) {
+		received = append(received, message)
+	}))
+
+	// Send返回的时候消息已经处理完了，不需要等待
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Equal(t, []int{1}, received)
+	assert.Nil(t, channel.SendUrgent(context.Background(), 2))
+	assert.Equal(t, []int{1, 2}, received)
+	assert.Equal(t, 0, channel.Workers())
+
+	channel.SenderWaitAndClose()
+	assert.True(t, channel.IsClosed())
+	assert.ErrorIs(t, channel.Send(context.Background(), 3), ErrChannelClosed)
+	stats := channel.Stats()
+	assert.Equal(t, uint64(2), stats.Sent)
+	assert.Equal(t, uint64(2), stats.Consumed)
+}
+
+func TestChannel_SynchronousModePanic(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithSynchronousMode().WithChannelConsumerFunc(func(index int, message int) {
+		panic("boom")
+	}))
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = channel.Send(context.Background(), 1)
+	})
+
+	// 崩溃之后信道仍然可以继续使用和关闭
+	assert.Equal(t, 0, channel.Stats().InFlight)
+	channel.SenderWaitAndClose()
+	assert.True(t, channel.IsClosed())
+}
@@ -0,0 +1,107 @@
+package message_channel
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Tags 获取信道的标签，返回的是一份拷贝，没有标签时返回nil
+func (x *Channel[Message]) Tags() map[string]string {
+	return copyTags(x.options.Tags)
+}
+
+// Tag 获取信道的一个标签
+func (x *Channel[Message]) Tag(key string) (string, bool) {
+	value, ok := x.options.Tags[key]
+	return value, ok
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
+
+// 子信道继承父信道的标签，子信道自己设置的同名标签优先
+func mergeTags(parent, child map[string]string) map[string]string {
+	if len(parent) == 0 {
+		return copyTags(child)
+	}
+	merged := copyTags(parent)
+	for key, value := range child {
+		merged[key] = value
+	}
+	return merged
+}
+
+// 把标签格式化为 {k1=v1,k2=v2} 的形式，按照key排序，没有标签时返回空字符串
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// ChannelPredicate 用来筛选信道的函数
+type ChannelPredicate[Message any] func(channel *Channel[Message]) bool
+
+// MatchTags 生成一个按照标签筛选信道的函数，信道需要包含selector中所有的标签并且值相等
+func MatchTags[Message any](selector map[string]string) ChannelPredicate[Message] {
+	return func(channel *Channel[Message]) bool {
+		for key, value := range selector {
+			if actual, ok := channel.options.Tags[key]; !ok || actual != value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FindChildren 在所有的子孙信道中找出满足条件的信道，不包括当前信道自己，父信道排在子信道的前面，同一层按照ID排序
+// 比如找出所有 tenant=acme 的子信道之后把它们都暂停掉：channel.FindChildren(ctx, MatchTags[Message](map[string]string{"tenant": "acme"}))
+func (x *Channel[Message]) FindChildren(ctx context.Context, predicate ChannelPredicate[Message]) ([]*Channel[Message], error) {
+	found := make([]*Channel[Message], 0)
+	return found, x.findChildren(ctx, predicate, &found)
+}
+
+// FindChildrenByTags 在所有的子孙信道中找出包含给定的所有标签的信道
+func (x *Channel[Message]) FindChildrenByTags(ctx context.Context, selector map[string]string) ([]*Channel[Message], error) {
+	return x.FindChildren(ctx, MatchTags[Message](selector))
+}
+
+func (x *Channel[Message]) findChildren(ctx context.Context, predicate ChannelPredicate[Message], found *[]*Channel[Message]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	children, err := x.childrenChannelMap.ChildrenSlice(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].ID < children[j].ID
+	})
+	for _, child := range children {
+		if predicate(child) {
+			*found = append(*found, child)
+		}
+		if err := child.findChildren(ctx, predicate, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
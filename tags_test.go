@@ -0,0 +1,66 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestChannel_Tags(t *testing.T) {
+	tags := map[string]string{"tenant": "acme", "stage": "ingest"}
+	root := NewChannel[int](NewChannelOptions[int]().WithName("root").WithTags(tags))
+	tags["tenant"] = "changed"
+	assert.Equal(t, map[string]string{"tenant": "acme", "stage": "ingest"}, root.Tags())
+
+	child := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("child").WithTag("stage", "parse"))
+	value, ok := child.Tag("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", value)
+	assert.Equal(t, map[string]string{"tenant": "acme", "stage": "parse"}, child.Stats().Tags)
+	assert.Equal(t, "acme", root.Health(context.Background()).Children[0].Tags["tenant"])
+
+	topology := root.TopologyAscii()
+	assert.True(t, strings.Contains(topology, "{stage=ingest,tenant=acme}"))
+	assert.True(t, strings.Contains(topology, "{stage=parse,tenant=acme}"))
+
+	child.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+}
+
+func TestChannel_FindChildren(t *testing.T) {
+	root := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10))
+	acme := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("acme").WithTag("tenant", "acme"))
+	other := root.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("other").WithTag("tenant", "other"))
+	acmeWorker := acme.MakeChildChannelWithOptions(NewChannelOptions[int]().WithName("acme-worker"))
+
+	found, err := root.FindChildrenByTags(context.Background(), map[string]string{"tenant": "acme"})
+	assert.Nil(t, err)
+	assert.Equal(t, []*Channel[int]{acme, acmeWorker}, found)
+
+	// 把acme的子信道都暂停掉
+	for _, channel := range found {
+		assert.Nil(t, channel.Pause())
+	}
+	paused, err := root.FindChildren(context.Background(), func(channel *Channel[int]) bool {
+		return channel.State() == StatePaused
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(paused))
+
+	all, err := root.FindChildren(context.Background(), func(channel *Channel[int]) bool {
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []*Channel[int]{acme, acmeWorker, other}, all)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	_, err = root.FindChildren(ctx, MatchTags[int](nil))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	acmeWorker.SenderWaitAndClose()
+	acme.SenderWaitAndClose()
+	other.SenderWaitAndClose()
+	root.SenderWaitAndClose()
+}
@@ -0,0 +1,50 @@
+package message_channel
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// throughputBuckets 吞吐量统计保留最近多少秒，最长的窗口是一分钟
+const throughputBuckets = 60
+
+// throughputCounter 按秒分桶的滑动窗口计数，所有操作都是原子的，用来估算最近一段时间每秒被消费的消息的数量
+// 桶是循环使用的，切换到新的一秒时清零，和清零同时发生的计数可能会丢失，所以得到的是一个估算值
+type throughputCounter struct {
+	buckets [throughputBuckets]throughputBucket
+}
+
+// throughputBucket 一秒内的计数，second是这个桶当前对应的是哪一秒
+type throughputBucket struct {
+	second atomic.Int64
+	count  atomic.Uint64
+}
+
+// 记录一条消息
+func (x *throughputCounter) observe(now time.Time) {
+	second := now.Unix()
+	bucket := &x.buckets[second%throughputBuckets]
+	if old := bucket.second.Load(); old != second && bucket.second.CompareAndSwap(old, second) {
+		bucket.count.Store(0)
+	}
+	bucket.count.Add(1)
+}
+
+// 最近window秒每秒的平均数量，只统计已经过去了的完整的秒，信道创建的时间比window短时按照实际存在的秒数平均
+func (x *throughputCounter) rate(now, createdAt time.Time, window int64) float64 {
+	second := now.Unix()
+	if elapsed := second - createdAt.Unix(); elapsed < window {
+		window = elapsed
+	}
+	if window <= 0 {
+		return 0
+	}
+	var total uint64
+	for s := second - window; s < second; s++ {
+		bucket := &x.buckets[s%throughputBuckets]
+		if bucket.second.Load() == s {
+			total += bucket.count.Load()
+		}
+	}
+	return float64(total) / float64(window)
+}
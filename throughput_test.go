@@ -0,0 +1,57 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestThroughputCounter(t *testing.T) {
+	start := time.Unix(1000, 0)
+	counter := &throughputCounter{}
+
+	// 前10秒每秒6条，第10秒每秒60条
+	for second := 0; second < 10; second++ {
+		for i := 0; i < 6; i++ {
+			counter.observe(start.Add(time.Duration(second) * time.Second))
+		}
+	}
+	for i := 0; i < 60; i++ {
+		counter.observe(start.Add(10 * time.Second))
+	}
+
+	// 当前这一秒还没有过完，不统计
+	now := start.Add(10*time.Second + 500*time.Millisecond)
+	assert.Equal(t, float64(6), counter.rate(now, start, 1))
+	assert.Equal(t, float64(6), counter.rate(now, start, 10))
+	assert.Equal(t, float64(6), counter.rate(now, start, 60))
+
+	now = start.Add(11 * time.Second)
+	assert.Equal(t, float64(60), counter.rate(now, start, 1))
+	assert.Equal(t, float64(6*9+60)/10, counter.rate(now, start, 10))
+	assert.Equal(t, float64(6*10+60)/11, counter.rate(now, start, 60))
+
+	// 很久之后旧的桶不再被统计，桶被复用时重新计数
+	now = start.Add(2 * time.Minute)
+	assert.Equal(t, float64(0), counter.rate(now, start, 60))
+	counter.observe(now)
+	assert.Equal(t, float64(1), counter.rate(now.Add(time.Second), start, 1))
+}
+
+func TestChannel_Throughput(t *testing.T) {
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+	}))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	// 等到下一秒开始之后，消费过消息的那一秒才是完整的
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second + time.Millisecond*50).Sub(now))
+	stats := channel.Stats()
+	seconds := float64(time.Now().Unix() - channel.stats.createdAt.Unix())
+	assert.InDelta(t, float64(5), stats.Throughput1m*seconds, 0.001)
+	assert.Equal(t, stats.Throughput1m, stats.Throughput10s)
+}
@@ -0,0 +1,359 @@
+package message_channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// TopologyConfig 用配置描述的一棵信道树，可以从JSON或者YAML中加载，这样调整拓扑时不需要重新编译
+type TopologyConfig struct {
+
+	// 信道的名字，构建出来的信道可以通过名字找到
+	Name string `json:"name" yaml:"name"`
+
+	// 信道的标签，子信道会继承父信道的标签
+	Tags map[string]string `json:"tags" yaml:"tags"`
+
+	// 信道的缓存大小，子信道没有配置时和父信道保持一致
+	BuffSize uint64 `json:"buff_size" yaml:"buff_size"`
+
+	// 是否工作在拉模式下，只能配置在根信道上，子信道总是推模式的
+	PullMode bool `json:"pull_mode" yaml:"pull_mode"`
+
+	// 消费函数的名字，对应TopologyBuilder中注册的消费函数，子信道的消费函数处理完之后消息仍然会转发给父信道
+	Consumer string `json:"consumer" yaml:"consumer"`
+
+	// 消息被消费之前要依次经过的处理阶段，只能配置在根信道上
+	Stages []*TopologyStageConfig `json:"stages" yaml:"stages"`
+
+	// 缓存满的时候Send的处理策略，可选值为 block、drop_newest、drop_oldest，为空时是block
+	OverflowPolicy string `json:"overflow_policy" yaml:"overflow_policy"`
+
+	// 消费函数返回Retry时最多重试的次数，配置了处理阶段时不能配置
+	MaxRetries int `json:"max_retries" yaml:"max_retries"`
+
+	// 消费函数处理一条消息的超时时间，比如 5s，配置了处理阶段时不能配置
+	ConsumeTimeout string `json:"consume_timeout" yaml:"consume_timeout"`
+
+	// 消费函数超时之后怎么处理这条消息，可选值为 retry、dead_letter、skip，为空时是retry
+	ConsumeTimeoutPolicy string `json:"consume_timeout_policy" yaml:"consume_timeout_policy"`
+
+	// 关闭信道时最多等待的时长，比如 30s，为空时一直等待
+	CloseTimeout string `json:"close_timeout" yaml:"close_timeout"`
+
+	// 子信道
+	Children []*TopologyConfig `json:"children" yaml:"children"`
+}
+
+// TopologyStageConfig 一个处理阶段的配置，对应Pipeline中的一个阶段
+type TopologyStageConfig struct {
+
+	// 阶段的类型，可选值为 filter、map、batch、rate_limit
+	Type string `json:"type" yaml:"type"`
+
+	// filter和map阶段使用的函数的名字，对应TopologyBuilder中注册的函数
+	Func string `json:"func" yaml:"func"`
+
+	// batch阶段每批的消息条数
+	Size int `json:"size" yaml:"size"`
+
+	// batch阶段每批最多等待的时长，比如 100ms
+	MaxWait string `json:"max_wait" yaml:"max_wait"`
+
+	// rate_limit阶段每个周期最多放行的消息条数
+	Limit int `json:"limit" yaml:"limit"`
+
+	// rate_limit阶段的周期，比如 1s
+	Per string `json:"per" yaml:"per"`
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// Topology 根据配置构建出来的信道树
+type Topology[Message any] struct {
+
+	// 根信道，配置了处理阶段时根信道就是流水线的入口
+	Root *Channel[Message]
+
+	// 按照名字索引所有的信道，没有名字的信道不会出现在这里
+	Channels map[string]*Channel[Message]
+
+	// 根信道配置了处理阶段时对应的流水线
+	pipeline *Pipeline[Message]
+}
+
+// Channel 根据名字获取信道
+func (x *Topology[Message]) Channel(name string) *Channel[Message] {
+	return x.Channels[name]
+}
+
+// SenderWaitAndClose 关闭根信道并等待整棵树处理完，子信道需要由它们的发送方先关闭
+func (x *Topology[Message]) SenderWaitAndClose() {
+	x.Root.SenderWaitAndClose()
+	if x.pipeline != nil {
+		x.pipeline.Wait(context.Background())
+	}
+}
+
+// ------------------------------------------------ ---------------------------------------------------------------------
+
+// TopologyBuilder 根据配置构建信道树，配置中引用的函数需要先按照名字注册到构建器上
+type TopologyBuilder[Message any] struct {
+	consumers map[string]ChannelConsumerFunc[Message]
+	filters   map[string]PredicateFunc[Message]
+	mappers   map[string]func(message Message) Message
+}
+
+// NewTopologyBuilder 创建一个拓扑构建器
+func NewTopologyBuilder[Message any]() *TopologyBuilder[Message] {
+	return &TopologyBuilder[Message]{
+		consumers: make(map[string]ChannelConsumerFunc[Message]),
+		filters:   make(map[string]PredicateFunc[Message]),
+		mappers:   make(map[string]func(message Message) Message),
+	}
+}
+
+// RegisterConsumer 注册一个消费函数，配置中通过consumer引用
+func (x *TopologyBuilder[Message]) RegisterConsumer(name string, consumer ChannelConsumerFunc[Message]) *TopologyBuilder[Message] {
+	x.consumers[name] = consumer
+	return x
+}
+
+// RegisterFilter 注册一个过滤函数，配置中通过filter阶段的func引用
+func (x *TopologyBuilder[Message]) RegisterFilter(name string, filter PredicateFunc[Message]) *TopologyBuilder[Message] {
+	x.filters[name] = filter
+	return x
+}
+
+// RegisterMap 注册一个转换函数，配置中通过map阶段的func引用
+func (x *TopologyBuilder[Message]) RegisterMap(name string, mapper func(message Message) Message) *TopologyBuilder[Message] {
+	x.mappers[name] = mapper
+	return x
+}
+
+// BuildJSON 从JSON格式的配置构建信道树
+func (x *TopologyBuilder[Message]) BuildJSON(data []byte) (*Topology[Message], error) {
+	config := &TopologyConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return x.Build(config)
+}
+
+// BuildYAML 从YAML格式的配置构建信道树
+func (x *TopologyBuilder[Message]) BuildYAML(data []byte) (*Topology[Message], error) {
+	config := &TopologyConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return x.Build(config)
+}
+
+// Build 根据配置构建信道树，配置中引用了没有注册的函数或者有重名的信道时返回错误，此时不会创建任何信道
+func (x *TopologyBuilder[Message]) Build(config *TopologyConfig) (*Topology[Message], error) {
+
+	// 先把配置整体检查一遍，避免构建到一半失败时留下一些没人关闭的信道
+	if err := x.check(config, make(map[string]struct{}), true); err != nil {
+		return nil, err
+	}
+
+	topology := &Topology[Message]{
+		Channels: make(map[string]*Channel[Message]),
+	}
+
+	options := x.options(config, config.BuffSize)
+	if len(config.Stages) != 0 {
+		topology.pipeline = x.buildPipeline(config, options)
+		topology.Root = topology.pipeline.source
+	} else {
+		if config.PullMode {
+			options.WithPullMode()
+		}
+		topology.Root = NewChannel[Message](options)
+	}
+
+	x.register(topology, topology.Root, config)
+	return topology, nil
+}
+
+// 根信道配置了处理阶段时，构建一条以消费函数为终点的流水线
+func (x *TopologyBuilder[Message]) buildPipeline(config *TopologyConfig, sourceOptions *ChannelOptions[Message]) *Pipeline[Message] {
+	pipeline := newPipeline[Message](config.BuffSize, sourceOptions)
+	for _, stage := range config.Stages {
+		switch stage.Type {
+		case "filter":
+			pipeline.Filter(x.filters[stage.Func])
+		case "map":
+			pipeline.Map(x.mappers[stage.Func])
+		case "batch":
+			maxWait, _ := parseTopologyDuration(stage.MaxWait)
+			pipeline.Batch(stage.Size, maxWait)
+		case "rate_limit":
+			per, _ := parseTopologyDuration(stage.Per)
+			pipeline.RateLimit(context.Background(), stage.Limit, per)
+		}
+	}
+
+	consumer := x.consumers[config.Consumer]
+	index := 0
+	pipeline.To(func(messages []Message) {
+		for _, message := range messages {
+			index++
+			if consumer != nil {
+				consumer(index, message)
+			}
+		}
+	})
+	return pipeline
+}
+
+// 递归的创建子信道并按照名字索引
+func (x *TopologyBuilder[Message]) register(topology *Topology[Message], channel *Channel[Message], config *TopologyConfig) {
+	if config.Name != "" {
+		topology.Channels[config.Name] = channel
+	}
+	for _, childConfig := range config.Children {
+		buffSize := childConfig.BuffSize
+		if buffSize == 0 {
+			buffSize = channel.options.ChannelBuffSize
+		}
+		child := channel.MakeChildChannelWithOptions(x.options(childConfig, buffSize))
+		x.register(topology, child, childConfig)
+	}
+}
+
+// 根据信道的配置生成创建信道的选项，配置了处理阶段时消费函数由流水线的终点调用，不放在选项里
+func (x *TopologyBuilder[Message]) options(config *TopologyConfig, buffSize uint64) *ChannelOptions[Message] {
+	options := NewChannelOptions[Message]().WithName(config.Name).WithTags(config.Tags).WithChannelBuffSize(buffSize)
+	if config.Consumer != "" && len(config.Stages) == 0 {
+		options.WithChannelConsumerFunc(x.consumers[config.Consumer])
+	}
+	overflowPolicy, _ := parseTopologyOverflowPolicy(config.OverflowPolicy)
+	options.WithOverflowPolicy(overflowPolicy)
+	if config.MaxRetries > 0 {
+		options.WithMaxRetries(config.MaxRetries)
+	}
+	if config.ConsumeTimeout != "" {
+		consumeTimeout, _ := parseTopologyDuration(config.ConsumeTimeout)
+		policy, _ := parseTopologyConsumeTimeoutPolicy(config.ConsumeTimeoutPolicy)
+		options.WithConsumeTimeout(consumeTimeout, policy)
+	}
+	if config.CloseTimeout != "" {
+		closeTimeout, _ := parseTopologyDuration(config.CloseTimeout)
+		options.WithCloseTimeout(closeTimeout)
+	}
+	return options
+}
+
+// 检查配置是否合法，root表示是不是根信道
+func (x *TopologyBuilder[Message]) check(config *TopologyConfig, names map[string]struct{}, root bool) error {
+
+	if config.Name != "" {
+		if _, exists := names[config.Name]; exists {
+			return fmt.Errorf("message channel: topology: duplicate channel name %q", config.Name)
+		}
+		names[config.Name] = struct{}{}
+	}
+
+	if config.Consumer != "" {
+		if _, exists := x.consumers[config.Consumer]; !exists {
+			return fmt.Errorf("message channel: topology: channel %q: consumer %q not registered", config.Name, config.Consumer)
+		}
+	}
+
+	if !root && config.PullMode {
+		return fmt.Errorf("message channel: topology: channel %q: pull_mode is only supported on the root channel", config.Name)
+	}
+	if !root && len(config.Stages) != 0 {
+		return fmt.Errorf("message channel: topology: channel %q: stages are only supported on the root channel", config.Name)
+	}
+	if len(config.Stages) != 0 && (config.MaxRetries != 0 || config.ConsumeTimeout != "") {
+		return fmt.Errorf("message channel: topology: channel %q: max_retries and consume_timeout are not supported together with stages", config.Name)
+	}
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("message channel: topology: channel %q: max_retries must not be negative", config.Name)
+	}
+	if _, err := parseTopologyOverflowPolicy(config.OverflowPolicy); err != nil {
+		return fmt.Errorf("message channel: topology: channel %q: %w", config.Name, err)
+	}
+	if _, err := parseTopologyDuration(config.ConsumeTimeout); err != nil {
+		return fmt.Errorf("message channel: topology: channel %q: consume_timeout: %w", config.Name, err)
+	}
+	if _, err := parseTopologyConsumeTimeoutPolicy(config.ConsumeTimeoutPolicy); err != nil {
+		return fmt.Errorf("message channel: topology: channel %q: %w", config.Name, err)
+	}
+	if _, err := parseTopologyDuration(config.CloseTimeout); err != nil {
+		return fmt.Errorf("message channel: topology: channel %q: close_timeout: %w", config.Name, err)
+	}
+
+	for _, stage := range config.Stages {
+		switch stage.Type {
+		case "filter":
+			if _, exists := x.filters[stage.Func]; !exists {
+				return fmt.Errorf("message channel: topology: channel %q: filter %q not registered", config.Name, stage.Func)
+			}
+		case "map":
+			if _, exists := x.mappers[stage.Func]; !exists {
+				return fmt.Errorf("message channel: topology: channel %q: map %q not registered", config.Name, stage.Func)
+			}
+		case "batch":
+			if _, err := parseTopologyDuration(stage.MaxWait); err != nil {
+				return fmt.Errorf("message channel: topology: channel %q: batch max_wait: %w", config.Name, err)
+			}
+		case "rate_limit":
+			if _, err := parseTopologyDuration(stage.Per); err != nil {
+				return fmt.Errorf("message channel: topology: channel %q: rate_limit per: %w", config.Name, err)
+			}
+		default:
+			return fmt.Errorf("message channel: topology: channel %q: unknown stage type %q", config.Name, stage.Type)
+		}
+	}
+
+	for _, childConfig := range config.Children {
+		if err := x.check(childConfig, names, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 配置中的时长使用go的时长格式，比如 100ms、1s，为空时表示0
+func parseTopologyDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// 配置中的溢出策略
+func parseTopologyOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "block":
+		return OverflowBlock, nil
+	case "drop_newest":
+		return OverflowDropNewest, nil
+	case "drop_oldest":
+		return OverflowDropOldest, nil
+	default:
+		return OverflowBlock, fmt.Errorf("unknown overflow_policy %q", s)
+	}
+}
+
+// 配置中的消费超时策略
+func parseTopologyConsumeTimeoutPolicy(s string) (ConsumeTimeoutPolicy, error) {
+	switch s {
+	case "", "retry":
+		return ConsumeTimeoutRetry, nil
+	case "dead_letter":
+		return ConsumeTimeoutDeadLetter, nil
+	case "skip":
+		return ConsumeTimeoutSkip, nil
+	default:
+		return ConsumeTimeoutRetry, fmt.Errorf("unknown consume_timeout_policy %q", s)
+	}
+}
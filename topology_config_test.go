@@ -0,0 +1,132 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTopologyBuilder_BuildYAML(t *testing.T) {
+	lock := &sync.Mutex{}
+	consumed := make([]string, 0)
+	builder := NewTopologyBuilder[string]().
+		RegisterConsumer("collect", func(index int, message string) {
+			lock.Lock()
+			defer lock.Unlock()
+			consumed = append(consumed, message)
+		}).
+		RegisterFilter("not-empty", func(message string) bool {
+			return message != ""
+		}).
+		RegisterMap("upper", strings.ToUpper)
+
+	topology, err := builder.BuildYAML([]byte(`
+name: root
+buff_size: 10
+consumer: collect
+stages:
+  - type: filter
+    func: not-empty
+  - type: map
+    func: upper
+children:
+  - name: component-a
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(topology.Channels))
+
+	child := topology.Channel("component-a")
+	assert.Nil(t, child.Send(context.Background(), "a"))
+	assert.Nil(t, child.Send(context.Background(), ""))
+	child.SenderWaitAndClose()
+	assert.Nil(t, topology.Root.Send(context.Background(), "b"))
+	topology.SenderWaitAndClose()
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"A", "B"}, consumed)
+}
+
+func TestTopologyBuilder_BuildJSON_Invalid(t *testing.T) {
+	builder := NewTopologyBuilder[string]()
+	_, err := builder.BuildJSON([]byte(`{"name": "root", "consumer": "missing"}`))
+	assert.NotNil(t, err)
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "children": [{"name": "root"}]}`))
+	assert.NotNil(t, err)
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "stages": [{"type": "unknown"}]}`))
+	assert.NotNil(t, err)
+}
+
+func TestTopologyBuilder_BuildPolicies(t *testing.T) {
+	lock := &sync.Mutex{}
+	consumed := make(map[string][]string)
+	collect := func(name string) ChannelConsumerFunc[string] {
+		return func(index int, message string) {
+			lock.Lock()
+			defer lock.Unlock()
+			consumed[name] = append(consumed[name], message)
+		}
+	}
+	builder := NewTopologyBuilder[string]().
+		RegisterConsumer("root", collect("root")).
+		RegisterConsumer("child", collect("child"))
+
+	topology, err := builder.BuildJSON([]byte(`{
+  "name": "root",
+  "tags": {"team": "infra"},
+  "buff_size": 10,
+  "consumer": "root",
+  "overflow_policy": "drop_newest",
+  "max_retries": 3,
+  "consume_timeout": "1s",
+  "consume_timeout_policy": "skip",
+  "close_timeout": "5s",
+  "children": [{"name": "component-a", "buff_size": 4, "consumer": "child", "overflow_policy": "drop_oldest"}]
+}`))
+	assert.Nil(t, err)
+
+	// 名字、标签和策略在创建信道的时候就已经生效了
+	root := topology.Root
+	assert.Equal(t, "root", root.options.Name)
+	assert.Equal(t, map[string]string{"team": "infra"}, root.Tags())
+	assert.Equal(t, OverflowDropNewest, root.options.OverflowPolicy)
+	assert.Equal(t, 3, root.options.MaxRetries)
+	assert.Equal(t, time.Second, root.options.ConsumeTimeout)
+	assert.Equal(t, ConsumeTimeoutSkip, root.options.ConsumeTimeoutPolicy)
+	assert.Equal(t, time.Second*5, root.options.CloseTimeout)
+
+	// 子信道的缓存大小和消费函数也会生效，消费完之后仍然转发给父信道
+	child := topology.Channel("component-a")
+	assert.Equal(t, uint64(4), child.options.ChannelBuffSize)
+	assert.Equal(t, OverflowDropOldest, child.options.OverflowPolicy)
+	assert.Equal(t, map[string]string{"team": "infra"}, child.Tags())
+	assert.Nil(t, child.Send(context.Background(), "a"))
+	child.SenderWaitAndClose()
+	topology.SenderWaitAndClose()
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"a"}, consumed["child"])
+	assert.Equal(t, []string{"a"}, consumed["root"])
+}
+
+func TestTopologyBuilder_BuildJSON_RootOnly(t *testing.T) {
+	builder := NewTopologyBuilder[string]().RegisterFilter("all", func(message string) bool {
+		return true
+	})
+	_, err := builder.BuildJSON([]byte(`{"name": "root", "children": [{"name": "child", "pull_mode": true}]}`))
+	assert.ErrorContains(t, err, "pull_mode")
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "children": [{"name": "child", "stages": [{"type": "filter", "func": "all"}]}]}`))
+	assert.ErrorContains(t, err, "stages")
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "max_retries": 1, "stages": [{"type": "filter", "func": "all"}]}`))
+	assert.ErrorContains(t, err, "max_retries")
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "overflow_policy": "unknown"}`))
+	assert.ErrorContains(t, err, "overflow_policy")
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "consume_timeout": "soon"}`))
+	assert.ErrorContains(t, err, "consume_timeout")
+	_, err = builder.BuildJSON([]byte(`{"name": "root", "consume_timeout_policy": "unknown"}`))
+	assert.ErrorContains(t, err, "consume_timeout_policy")
+}
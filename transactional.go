@@ -0,0 +1,185 @@
+package message_channel
+
+import (
+	"context"
+	"time"
+)
+
+// TransactionalSink 支持事务的输出，信道按批驱动它：Begin开始一个事务，Add逐条写入这一批消息，Commit提交，任何一步失败都会Rollback
+// 一批消息要么全部提交成功，要么全部回滚之后整批重试，配合支持事务的下游（比如数据库）就可以实现效果上的恰好一次
+// 所有方法都只会被处理消息的协程调用，不需要是并发安全的，ctx在信道被Abort或者强制关闭时取消
+type TransactionalSink[Message any] interface {
+	Begin(ctx context.Context) error
+	Add(ctx context.Context, message Message) error
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// TransactionalSinkOptions 事务模式的选项，开启之后信道不再调用消费函数，而是把消息按批交给Sink
+type TransactionalSinkOptions[Message any] struct {
+
+	// 支持事务的输出
+	Sink TransactionalSink[Message]
+
+	// 每一批最多多少条消息，小于1时按照1处理
+	BatchSize int
+
+	// 一批中第一条消息等待超过MaxWait之后即使没有凑够BatchSize也会提交，为0时一直等到凑够或者信道关闭
+	MaxWait time.Duration
+
+	// 事务失败之后重试整批之前等待的时长，重试次数由MaxRetries控制，重试次数用完之后这一批消息都按照死信处理
+	RetryBackoff time.Duration
+
+	// 一批消息提交成功之后的回调
+	OnCommit func(batch []Message)
+}
+
+// 事务模式下处理消息的循环，缓存关闭、协程被替换或者被缩容时提交完手上的一批之后返回
+// batch: 正在攒的一批消息，由调用方持有，这样提交的过程中崩溃了调用方也能知道丢了哪些消息
+func (x *Channel[Message]) workTransactional(generation uint64, retire context.Context, batch *[]envelope[Message]) {
+	options := x.options.TransactionalSinkOptions
+	size := options.BatchSize
+	if size < 1 {
+		size = 1
+	}
+
+	var deadline time.Time
+	for {
+		if x.waitResumed(retire) != nil {
+			x.commitBatch(batch)
+			return
+		}
+
+		// 已经有攒着的消息的时候，最多只等到这一批的截止时间
+		ctx, cancelFunc := retire, context.CancelFunc(func() {})
+		if len(*batch) > 0 && options.MaxWait > 0 {
+			ctx, cancelFunc = withClockDeadline(retire, x.clock, deadline)
+		}
+		envelope, ok, err := x.takeNext(ctx)
+		cancelFunc()
+		if err != nil {
+			x.commitBatch(batch)
+			if retire.Err() != nil {
+				return
+			}
+			continue
+		}
+		if !ok {
+			x.commitBatch(batch)
+			return
+		}
+		if !x.admit(&envelope) {
+			continue
+		}
+
+		if len(*batch) == 0 {
+			deadline = x.clock.Now().Add(options.MaxWait)
+		}
+		*batch = append(*batch, envelope)
+		if len(*batch) >= size {
+			x.commitBatch(batch)
+		}
+
+		if x.workerGeneration.Load() != generation || retire.Err() != nil {
+			x.commitBatch(batch)
+			return
+		}
+	}
+}
+
+// 用一个事务提交一批消息，失败时回滚之后整批重试
+func (x *Channel[Message]) commitBatch(batch *[]envelope[Message]) {
+	envelopes := *batch
+	if len(envelopes) == 0 {
+		return
+	}
+	indexes := make([]int, len(envelopes))
+	for i, envelope := range envelopes {
+		indexes[i] = x.markConsumed(envelope)
+	}
+
+	// 不用defer，这样提交的过程中崩溃时batch还保留着，处理消息的协程可以把它们作为丢弃的消息报告出去
+	x.stats.inFlight.Add(int64(len(envelopes)))
+	done := func() {
+		x.stats.inFlight.Add(-int64(len(envelopes)))
+		*batch = nil
+	}
+
+	options := x.options.TransactionalSinkOptions
+	maxRetries := x.tuned().MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		start := x.clock.Now()
+		err := x.runTransaction(options.Sink, envelopes)
+		elapsed := x.clock.Since(start)
+		x.stats.latency.observe(elapsed)
+		x.stats.latencyEWMA.observe(elapsed)
+		if err == nil {
+			break
+		}
+		x.consumerFailed(err, nil)
+		x.reportError(ErrorOpTransaction, err)
+
+		if maxRetries > 0 && attempt >= maxRetries {
+			for _, envelope := range envelopes {
+				x.deadLetter(envelope.message)
+				x.checkpointEnd(envelope.offset)
+			}
+			done()
+			return
+		}
+		x.stats.retries.Add(1)
+		if !x.waitRetry(options.RetryBackoff) {
+			for _, envelope := range envelopes {
+				x.drop(DropReasonAborted, envelope.message)
+				x.checkpointEnd(envelope.offset)
+			}
+			done()
+			return
+		}
+	}
+
+	if options.OnCommit != nil {
+		messages := make([]Message, len(envelopes))
+		for i, envelope := range envelopes {
+			messages[i] = envelope.message
+		}
+		options.OnCommit(messages)
+	}
+	// 副作用已经提交了，这一批的偏移量跟着提交
+	var offset uint64
+	for _, envelope := range envelopes {
+		x.markProcessed(envelope.offset)
+		if envelope.offset > offset {
+			offset = envelope.offset
+		}
+	}
+	if err := x.Commit(offset); err != nil {
+		x.reportError(ErrorOpStore, err)
+	}
+	for i, envelope := range envelopes {
+		x.forwardToParent(indexes[i], envelope)
+	}
+	done()
+}
+
+// 执行一次事务，任何一步失败都会回滚，返回导致失败的错误
+func (x *Channel[Message]) runTransaction(sink TransactionalSink[Message], envelopes []envelope[Message]) error {
+	ctx := x.consumeCtx
+	if err := sink.Begin(ctx); err != nil {
+		return err
+	}
+	for _, envelope := range envelopes {
+		if err := sink.Add(ctx, envelope.message); err != nil {
+			_ = sink.Rollback(ctx)
+			return err
+		}
+	}
+	if err := sink.Commit(ctx); err != nil {
+		_ = sink.Rollback(ctx)
+		return err
+	}
+	return nil
+}
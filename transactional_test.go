@@ -0,0 +1,100 @@
+package message_channel
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// memorySink 把提交的消息记录在内存中的事务性输出，failCommits大于0时前几次提交会失败
+type memorySink struct {
+	pending     []int
+	committed   [][]int
+	rollbacks   int
+	failCommits int
+}
+
+func (x *memorySink) Begin(ctx context.Context) error {
+	x.pending = nil
+	return nil
+}
+
+func (x *memorySink) Add(ctx context.Context, message int) error {
+	x.pending = append(x.pending, message)
+	return nil
+}
+
+func (x *memorySink) Commit(ctx context.Context) error {
+	if x.failCommits > 0 {
+		x.failCommits--
+		return errors.New("commit failed")
+	}
+	x.committed = append(x.committed, x.pending)
+	return nil
+}
+
+func (x *memorySink) Rollback(ctx context.Context) error {
+	x.rollbacks++
+	x.pending = nil
+	return nil
+}
+
+func TestChannel_TransactionalSink(t *testing.T) {
+	sink := &memorySink{failCommits: 1}
+	errs := make([]string, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithChannelBuffSize(10).WithTransactionalSink(sink, 3, 0).WithErrorListener(func(op string, err error) {
+		errs = append(errs, op)
+	}))
+	for i := 1; i <= 7; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+	channel.SenderWaitAndClose()
+
+	// 第一批提交失败回滚之后整批重试，关闭时剩余的消息作为最后一批提交
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, sink.committed)
+	assert.Equal(t, 1, sink.rollbacks)
+	assert.Equal(t, []string{ErrorOpTransaction}, errs)
+	stats := channel.Stats()
+	assert.Equal(t, uint64(7), stats.Consumed)
+	assert.Equal(t, uint64(1), stats.Retries)
+	assert.Equal(t, 0, stats.InFlight)
+}
+
+func TestChannel_TransactionalSinkMaxWait(t *testing.T) {
+	sink := &memorySink{}
+	committed := make(chan []int, 1)
+	options := NewChannelOptions[int]().WithTransactionalSink(sink, 100, 10*time.Millisecond)
+	options.TransactionalSinkOptions.OnCommit = func(batch []int) {
+		committed <- batch
+	}
+	channel := NewChannel[int](options)
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+
+	// 没有凑够一批，等待超时之后也会提交
+	select {
+	case batch := <-committed:
+		assert.Equal(t, []int{1, 2}, batch)
+	case <-time.After(time.Second):
+		t.Fatal("batch not committed after max wait")
+	}
+	channel.SenderWaitAndClose()
+}
+
+func TestChannel_TransactionalSinkRetriesExhausted(t *testing.T) {
+	sink := &memorySink{failCommits: 100}
+	dropped := make([]int, 0)
+	channel := NewChannel[int](NewChannelOptions[int]().WithTransactionalSink(sink, 2, 0).WithMaxRetries(1).WithOnDropped(func(reason DropReason, message int) {
+		assert.Equal(t, DropReasonDeadLetter, reason)
+		dropped = append(dropped, message)
+	}))
+	assert.Nil(t, channel.Send(context.Background(), 1))
+	assert.Nil(t, channel.Send(context.Background(), 2))
+	channel.SenderWaitAndClose()
+
+	assert.Equal(t, []int{1, 2}, dropped)
+	assert.Equal(t, 2, sink.rollbacks)
+	assert.Equal(t, uint64(2), channel.Stats().DeadLettered)
+}
@@ -0,0 +1,65 @@
+package message_channel
+
+import "time"
+
+// StallListener 看门狗发现信道卡住时的回调
+// pending: 缓存中还没有被消费的消息的数量
+// stalledFor: 已经多久没有消息被消费了
+type StallListener func(pending int, stalledFor time.Duration)
+
+// WatchdogOptions 看门狗的选项，看门狗会在后台检查信道中有消息但是很久都没有被消费的情况，一般是消费函数卡住了
+type WatchdogOptions struct {
+
+	// 超过这个时长都没有消息被消费时认为卡住了
+	Period time.Duration
+
+	// 检查的间隔，为0时使用Period的一半
+	CheckInterval time.Duration
+
+	// 发现卡住时的回调，同一次卡住只会回调一次，恢复消费之后再次卡住时会再次回调
+	StallListener StallListener
+
+	// 发现卡住时是否重新启动一个处理消息的协程，卡住的协程没办法被强制结束，它处理完手上的消息之后会自己退出
+	// 注意在旧的协程退出之前会有两个协程同时在处理消息
+	RestartWorker bool
+}
+
+// 看门狗的协程，信道关闭之后退出
+func (x *Channel[Message]) watch(options *WatchdogOptions) {
+
+	interval := options.CheckInterval
+	if interval <= 0 {
+		interval = options.Period / 2
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := x.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 已经为哪一次消费之后的卡住报过警了，避免同一次卡住重复报警
+	var alertedAt int64
+	for {
+		select {
+		case <-x.done:
+			return
+		case <-ticker.C():
+		}
+
+		pending := x.buffer.len()
+		lastConsume := x.stats.lastConsumeUnixNano.Load()
+		stalledFor := x.clock.Since(time.Unix(0, lastConsume))
+		if pending == 0 || x.State() == StatePaused || stalledFor < options.Period || alertedAt == lastConsume {
+			continue
+		}
+		alertedAt = lastConsume
+
+		if options.StallListener != nil {
+			options.StallListener(pending, stalledFor)
+		}
+		if options.RestartWorker && !x.options.PullMode {
+			x.workerGeneration.Add(1)
+			x.startWorker()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package message_channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_Watchdog(t *testing.T) {
+	release := make(chan struct{})
+	consumed := &atomic.Int64{}
+	stalls := &atomic.Int64{}
+	options := NewChannelOptions[int]().WithChannelBuffSize(10).WithChannelConsumerFunc(func(index int, message int) {
+		// 第一条消息会一直卡住，直到测试放行
+		if message == 1 {
+			<-release
+		}
+		consumed.Add(1)
+	}).WithWatchdog(&WatchdogOptions{
+		Period:        time.Millisecond * 20,
+		CheckInterval: time.Millisecond * 5,
+		StallListener: func(pending int, stalledFor time.Duration) {
+			stalls.Add(1)
+		},
+		RestartWorker: true,
+	})
+	channel := NewChannel[int](options)
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, channel.Send(context.Background(), i))
+	}
+
+	// 新启动的协程把后面的消息处理掉了
+	assert.Eventually(t, func() bool {
+		return consumed.Load() == 2
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, int64(1), stalls.Load())
+
+	close(release)
+	channel.SenderWaitAndClose()
+	assert.Equal(t, int64(3), consumed.Load())
+}